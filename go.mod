@@ -3,9 +3,12 @@ module github.com/qiushi1511/usd-buy-rate-monitor
 go 1.25.3
 
 require (
-	github.com/guptarohit/asciigraph v0.7.3 // indirect
+	github.com/guptarohit/asciigraph v0.7.3
+	github.com/mattn/go-sqlite3 v1.14.32
+)
+
+require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
-	github.com/mattn/go-sqlite3 v1.14.32 // indirect
 	github.com/spf13/cobra v1.10.1 // indirect
 	github.com/spf13/pflag v1.0.9 // indirect
 )
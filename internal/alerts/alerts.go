@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
@@ -23,6 +24,7 @@ const (
 	AlertTypeChangeDecrease AlertType = "change_decrease"
 	AlertTypeUnusual        AlertType = "unusual_pattern"
 	AlertTypeTargetReached  AlertType = "target_reached" // Target rate for exchange achieved
+	AlertTypeContract       AlertType = "contract_violation" // Upstream response broke the expected contract
 )
 
 // Alert represents an alert condition
@@ -44,12 +46,13 @@ type Config struct {
 	PatternStdDevs     float64 // Number of std deviations for pattern alerts
 	CooldownMinutes    int     // Minutes to wait before repeating same alert
 	TargetRate         float64 // Target rate to achieve for optimal exchange (alerts when reached)
+	Currency           string  // Currency to watch (default: USD)
 }
 
 // Manager handles alert checking and notifications
 type Manager struct {
 	config       *Config
-	repo         *storage.Repository
+	repo         storage.RateReader
 	logger       *slog.Logger
 	lastAlerts   map[AlertType]time.Time // Track last alert time per type
 	lastRate     float64
@@ -57,7 +60,11 @@ type Manager struct {
 }
 
 // NewManager creates a new alert manager
-func NewManager(config *Config, repo *storage.Repository, logger *slog.Logger) *Manager {
+func NewManager(config *Config, repo storage.RateReader, logger *slog.Logger) *Manager {
+	if config.Currency == "" {
+		config.Currency = "USD"
+	}
+
 	return &Manager{
 		config:     config,
 		repo:       repo,
@@ -72,7 +79,7 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 
 	// Check threshold alerts
 	if m.config.HighThreshold > 0 && rate > m.config.HighThreshold {
-		if m.shouldAlert(AlertTypeThresholdHigh) {
+		if m.shouldAlert(AlertTypeThresholdHigh, timestamp) {
 			alerts = append(alerts, Alert{
 				Type:      AlertTypeThresholdHigh,
 				Message:   fmt.Sprintf("Rate exceeded high threshold: %.4f > %.4f CNY", rate, m.config.HighThreshold),
@@ -80,12 +87,12 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 				Threshold: m.config.HighThreshold,
 				Timestamp: timestamp,
 			})
-			m.markAlerted(AlertTypeThresholdHigh)
+			m.markAlerted(AlertTypeThresholdHigh, timestamp)
 		}
 	}
 
 	if m.config.LowThreshold > 0 && rate < m.config.LowThreshold {
-		if m.shouldAlert(AlertTypeThresholdLow) {
+		if m.shouldAlert(AlertTypeThresholdLow, timestamp) {
 			alerts = append(alerts, Alert{
 				Type:      AlertTypeThresholdLow,
 				Message:   fmt.Sprintf("Rate dropped below low threshold: %.4f < %.4f CNY", rate, m.config.LowThreshold),
@@ -93,7 +100,7 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 				Threshold: m.config.LowThreshold,
 				Timestamp: timestamp,
 			})
-			m.markAlerted(AlertTypeThresholdLow)
+			m.markAlerted(AlertTypeThresholdLow, timestamp)
 		}
 	}
 
@@ -113,7 +120,7 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 				direction = "decreased"
 			}
 
-			if m.shouldAlert(alertType) {
+			if m.shouldAlert(alertType, timestamp) {
 				timeDiff := timestamp.Sub(m.lastRateTime)
 				alerts = append(alerts, Alert{
 					Type:      alertType,
@@ -122,7 +129,7 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 					Change:    changePercent,
 					Timestamp: timestamp,
 				})
-				m.markAlerted(alertType)
+				m.markAlerted(alertType, timestamp)
 			}
 		}
 	}
@@ -137,7 +144,7 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 
 	// Check target rate alert (optimal exchange rate achieved)
 	if m.config.TargetRate > 0 && rate >= m.config.TargetRate {
-		if m.shouldAlert(AlertTypeTargetReached) {
+		if m.shouldAlert(AlertTypeTargetReached, timestamp) {
 			alerts = append(alerts, Alert{
 				Type:      AlertTypeTargetReached,
 				Message:   fmt.Sprintf("Target rate achieved: %.4f >= %.4f CNY (Good time to exchange!)", rate, m.config.TargetRate),
@@ -145,7 +152,7 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 				Threshold: m.config.TargetRate,
 				Timestamp: timestamp,
 			})
-			m.markAlerted(AlertTypeTargetReached)
+			m.markAlerted(AlertTypeTargetReached, timestamp)
 		}
 	}
 
@@ -156,15 +163,30 @@ func (m *Manager) Check(ctx context.Context, rate float64, timestamp time.Time)
 	return alerts
 }
 
+// CheckContract returns an alert for a response or quote that broke the
+// response contract, or nil while contract alerts are cooling down
+func (m *Manager) CheckContract(source string, violation error, timestamp time.Time) *Alert {
+	if !m.shouldAlert(AlertTypeContract, timestamp) {
+		return nil
+	}
+
+	m.markAlerted(AlertTypeContract, timestamp)
+	return &Alert{
+		Type:      AlertTypeContract,
+		Message:   fmt.Sprintf("Upstream %s response failed validation, quotes rejected: %v", source, violation),
+		Timestamp: timestamp,
+	}
+}
+
 // checkPatternDeviation checks if current rate is unusual compared to historical patterns
 func (m *Manager) checkPatternDeviation(ctx context.Context, rate float64, timestamp time.Time) *Alert {
-	if !m.shouldAlert(AlertTypeUnusual) {
+	if !m.shouldAlert(AlertTypeUnusual, timestamp) {
 		return nil
 	}
 
-	// Get hourly pattern for this hour
-	hour := timestamp.Hour()
-	patterns, err := m.repo.GetHourlyPatterns(ctx, 30) // Last 30 days
+	// Get hourly pattern for this hour; patterns group hours in market time
+	hour := timestamp.In(market.Zone).Hour()
+	patterns, err := m.repo.GetHourlyPatterns(ctx, m.config.Currency, 30) // Last 30 days
 	if err != nil {
 		m.logger.Warn("failed to get hourly patterns for alert", "error", err)
 		return nil
@@ -185,15 +207,16 @@ func (m *Manager) checkPatternDeviation(ctx context.Context, rate float64, times
 
 	// Calculate standard deviation (approximate from range)
 	// For normal distribution, range ≈ 6 * stddev
-	rng := hourPattern.MaxRate - hourPattern.MinRate
+	rng := (hourPattern.MaxRate - hourPattern.MinRate).Float64()
 	stdDev := rng / 6.0
+	avgRate := hourPattern.AvgRate.Float64()
 
 	if stdDev == 0 {
 		return nil // No variation
 	}
 
 	// Check how many standard deviations away from average
-	deviation := (rate - hourPattern.AvgRate) / stdDev
+	deviation := (rate - avgRate) / stdDev
 	absDeviation := deviation
 	if absDeviation < 0 {
 		absDeviation = -absDeviation
@@ -205,12 +228,12 @@ func (m *Manager) checkPatternDeviation(ctx context.Context, rate float64, times
 			direction = "lower"
 		}
 
-		m.markAlerted(AlertTypeUnusual)
+		m.markAlerted(AlertTypeUnusual, timestamp)
 		return &Alert{
 			Type:      AlertTypeUnusual,
-			Message:   fmt.Sprintf("Unusual rate at %02d:00: %.4f CNY is %.1f std devs %s than usual (avg: %.4f)", hour, rate, absDeviation, direction, hourPattern.AvgRate),
+			Message:   fmt.Sprintf("Unusual rate at %02d:00: %.4f CNY is %.1f std devs %s than usual (avg: %.4f)", hour, rate, absDeviation, direction, avgRate),
 			Rate:      rate,
-			Threshold: hourPattern.AvgRate,
+			Threshold: avgRate,
 			Timestamp: timestamp,
 		}
 	}
@@ -219,7 +242,9 @@ func (m *Manager) checkPatternDeviation(ctx context.Context, rate float64, times
 }
 
 // shouldAlert checks if we should send an alert based on cooldown
-func (m *Manager) shouldAlert(alertType AlertType) bool {
+// Cooldowns are measured between rate timestamps so replayed data alerts
+// the same way it would have live
+func (m *Manager) shouldAlert(alertType AlertType, timestamp time.Time) bool {
 	if m.config.CooldownMinutes <= 0 {
 		return true
 	}
@@ -230,12 +255,12 @@ func (m *Manager) shouldAlert(alertType AlertType) bool {
 	}
 
 	cooldown := time.Duration(m.config.CooldownMinutes) * time.Minute
-	return time.Since(lastAlert) >= cooldown
+	return timestamp.Sub(lastAlert) >= cooldown
 }
 
-// markAlerted records that an alert was sent
-func (m *Manager) markAlerted(alertType AlertType) {
-	m.lastAlerts[alertType] = time.Now()
+// markAlerted records that an alert was sent for a rate at timestamp
+func (m *Manager) markAlerted(alertType AlertType, timestamp time.Time) {
+	m.lastAlerts[alertType] = timestamp
 }
 
 // Notifier handles alert notifications
@@ -376,6 +401,13 @@ func (n *WeChatNotifier) formatChineseMessage(alert Alert) string {
 			"🕐 触发时间：%s",
 			alert.Rate, alert.Threshold, timeStr)
 
+	case AlertTypeContract:
+		message = fmt.Sprintf("【接口告警】汇率接口数据校验失败\n"+
+			"⚠️ %s\n"+
+			"🔧 接口格式可能已变更，相关报价未入库\n"+
+			"🕐 触发时间：%s",
+			alert.Message, timeStr)
+
 	default:
 		message = fmt.Sprintf("【汇率提醒】\n"+
 			"💱 当前汇率：%.4f CNY\n"+
@@ -0,0 +1,58 @@
+package alerts
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+func TestCheckPatternDeviationUsesMarketHour(t *testing.T) {
+	ctx := context.Background()
+	store := storage.NewMemoryStore()
+
+	// The last 12 days: around 7.08 at 10:00 market time, 7.20 at 02:00
+	// (10:00 in Asia/Shanghai is 02:00 UTC)
+	today := time.Now().In(market.Zone)
+	for day := 1; day <= 12; day++ {
+		d := today.AddDate(0, 0, -day)
+		for minute := 0; minute < 60; minute += 20 {
+			for hour, rate := range map[int]string{10: "7.07", 2: "7.20"} {
+				if hour == 10 && minute == 20 {
+					rate = "7.09"
+				}
+				at := time.Date(d.Year(), d.Month(), d.Day(), hour, minute, 0, 0, market.Zone)
+				err := store.InsertRate(ctx, &storage.ExchangeRate{
+					CurrencyCode: "USD", Source: "cmb", RtcBid: fixed.MustParse(rate),
+					CollectedAt: at, DatePartition: market.Date(at),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	// 10:30 market time, given in UTC as the poller's clock may be
+	at := time.Date(today.Year(), today.Month(), today.Day(), 10, 30, 0, 0, market.Zone).UTC()
+	manager := NewManager(&Config{CheckPatterns: true, PatternStdDevs: 2, Currency: "USD"}, store,
+		slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if alert := manager.checkPatternDeviation(ctx, 7.08, at); alert != nil {
+		t.Errorf("checkPatternDeviation(usual 10:00 rate) = %q, want none", alert.Message)
+	}
+
+	alert := manager.checkPatternDeviation(ctx, 7.20, at)
+	if alert == nil {
+		t.Fatal("checkPatternDeviation(unusual 10:00 rate) = nil, want an alert")
+	}
+	if !strings.Contains(alert.Message, "at 10:00") {
+		t.Errorf("alert message %q, want the market hour 10:00", alert.Message)
+	}
+}
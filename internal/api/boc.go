@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+const defaultBOCURL = "https://www.boc.cn/sourcedb/whpj/"
+
+var (
+	tableRowPattern  = regexp.MustCompile(`(?is)<tr[^>]*>(.*?)</tr>`)
+	tableCellPattern = regexp.MustCompile(`(?is)<td[^>]*>(.*?)</td>`)
+	htmlTagPattern   = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// BOCClient fetches rates from the Bank of China foreign exchange board
+// (外汇牌价), an HTML table of prices per 100 units of foreign currency
+type BOCClient struct {
+	httpClient *http.Client
+	baseURL    string
+	logger     *slog.Logger
+}
+
+// NewBOCClient creates a Bank of China rate source with default configuration
+func NewBOCClient(logger *slog.Logger) *BOCClient {
+	return &BOCClient{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		baseURL:    defaultBOCURL,
+		logger:     logger,
+	}
+}
+
+// Name returns the source name of the BOC client
+func (c *BOCClient) Name() string {
+	return SourceBOC
+}
+
+// FetchRates retrieves the BOC board and extracts every recognized currency
+func (c *BOCClient) FetchRates(ctx context.Context) ([]CurrencyRate, error) {
+	body, err := c.FetchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := c.ParseRates(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debug("BOC request successful", "currencies", len(rates))
+
+	return rates, nil
+}
+
+// FetchRaw retrieves the BOC board page
+func (c *BOCClient) FetchRaw(ctx context.Context) ([]byte, error) {
+	return fetchURL(ctx, c.httpClient, c.baseURL, "text/html", nil)
+}
+
+// ParseRates extracts the quotes of a BOC board page
+func (c *BOCClient) ParseRates(body []byte) ([]CurrencyRate, error) {
+	return parseBOCBoard(string(body))
+}
+
+// parseBOCBoard extracts quotes from the BOC board table, whose rows are:
+// 货币名称, 现汇买入价, 现钞买入价, 现汇卖出价, 现钞卖出价, 中行折算价, 发布日期, 发布时间
+// Older pages publish date and time in a single "2025.11.25 20:11:02" cell
+func parseBOCBoard(page string) ([]CurrencyRate, error) {
+	var rates []CurrencyRate
+	for _, row := range tableRowPattern.FindAllStringSubmatch(page, -1) {
+		var cells []string
+		for _, cell := range tableCellPattern.FindAllStringSubmatch(row[1], -1) {
+			text := html.UnescapeString(htmlTagPattern.ReplaceAllString(cell[1], ""))
+			cells = append(cells, strings.TrimSpace(text))
+		}
+		if len(cells) < 7 {
+			continue
+		}
+
+		code, ok := CurrencyCode(cells[0])
+		if !ok {
+			continue
+		}
+
+		quote, err := parseBOCRow(code, cells)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, quote)
+	}
+
+	if len(rates) == 0 {
+		return nil, errors.New("no known currencies found in BOC board")
+	}
+
+	return rates, nil
+}
+
+// parseBOCRow parses a single BOC board row
+// Some currencies have no cash quotes; those sides are left at 0, and the
+// spot bid stands in for the tracked cash bid when it is missing
+func parseBOCRow(code string, cells []string) (CurrencyRate, error) {
+	quote := CurrencyRate{Currency: code}
+
+	sides := []struct {
+		name  string
+		value string
+		dest  *fixed.Rate
+	}{
+		{"现汇买入价", cells[1], &quote.RthBid},
+		{"现钞买入价", cells[2], &quote.RtcBid},
+		{"现汇卖出价", cells[3], &quote.RthOfr},
+		{"现钞卖出价", cells[4], &quote.RtcOfr},
+		{"中行折算价", cells[5], &quote.RtbBid},
+	}
+	for _, side := range sides {
+		if side.value == "" {
+			continue
+		}
+		val, err := parseRate(side.value, 100)
+		if err != nil {
+			return quote, fmt.Errorf("parsing %s %s: %w", code, side.name, err)
+		}
+		*side.dest = val
+	}
+
+	if quote.RtcBid == 0 {
+		quote.RtcBid = quote.RthBid
+	}
+	if quote.RtcBid == 0 {
+		return quote, fmt.Errorf("parsing %s: no bid quoted", code)
+	}
+
+	stamp := cells[6]
+	if len(cells) > 7 && !strings.Contains(stamp, " ") {
+		stamp += " " + cells[7]
+	}
+	if stamp != "" {
+		quotedAt, err := time.ParseInLocation("2006.01.02 15:04:05", stamp, quoteLocation)
+		if err != nil {
+			return quote, fmt.Errorf("parsing %s quote time: %w", code, err)
+		}
+		quote.QuotedAt = quotedAt
+	}
+
+	return quote, nil
+}
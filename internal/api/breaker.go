@@ -0,0 +1,220 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultFailureThreshold = 3
+	defaultCoolOff          = 5 * time.Minute
+)
+
+// ErrCircuitOpen is returned without contacting the upstream while its
+// circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Requests flow normally
+	BreakerOpen                         // Requests fail fast until the cool-off ends
+	BreakerHalfOpen                     // A single probe is in flight
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerConfig configures a circuit breaker
+type BreakerConfig struct {
+	FailureThreshold int           // Consecutive failed fetches before the circuit opens
+	CoolOff          time.Duration // Time the circuit stays open before a probe is allowed
+}
+
+// DefaultBreakerConfig returns the circuit breaker configuration of new clients
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		FailureThreshold: defaultFailureThreshold,
+		CoolOff:          defaultCoolOff,
+	}
+}
+
+// BreakerHealth is a snapshot of an upstream's health as seen by its breaker
+type BreakerHealth struct {
+	Source              string
+	State               BreakerState
+	ConsecutiveFailures int
+	DownSince           time.Time // First failure of the current outage (zero when healthy)
+	NextProbe           time.Time // When the open circuit lets a probe through (zero unless open)
+	LastError           error
+}
+
+// CircuitOpenError is returned while the circuit is open
+type CircuitOpenError struct {
+	Source    string
+	DownSince time.Time
+	NextProbe time.Time
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s %v: upstream down since %s, next probe at %s",
+		e.Source, ErrCircuitOpen,
+		e.DownSince.Format("2006-01-02 15:04:05"),
+		e.NextProbe.Format("15:04:05"))
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+// CircuitBreaker stops calling a failing upstream: after FailureThreshold
+// consecutive failures it opens and fails fast, then lets one probe through
+// per cool-off period (half-open) until a probe succeeds and closes it again
+type CircuitBreaker struct {
+	mu        sync.Mutex
+	source    string
+	config    BreakerConfig
+	state     BreakerState
+	failures  int
+	downSince time.Time
+	openedAt  time.Time
+	lastErr   error
+	logger    *slog.Logger
+	now       func() time.Time
+}
+
+// NewCircuitBreaker creates a closed circuit breaker for a source
+func NewCircuitBreaker(source string, config BreakerConfig, logger *slog.Logger) *CircuitBreaker {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	return &CircuitBreaker{
+		source: source,
+		config: config,
+		logger: logger,
+		now:    time.Now,
+	}
+}
+
+// Allow reports whether a request may be made, returning a *CircuitOpenError
+// while the circuit is open
+// Once the cool-off has elapsed the circuit turns half-open and the caller
+// becomes the probe; other callers keep failing fast until it reports back
+func (b *CircuitBreaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.nextProbe()) {
+			return b.openError()
+		}
+		b.state = BreakerHalfOpen
+		b.logger.Info("circuit half-open, probing upstream",
+			"source", b.source,
+			"down_since", b.downSince)
+		return nil
+	case BreakerHalfOpen:
+		return b.openError()
+	default:
+		return nil
+	}
+}
+
+// Success records a successful request, closing the circuit
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != BreakerClosed {
+		b.logger.Info("circuit closed, upstream recovered",
+			"source", b.source,
+			"down_since", b.downSince,
+			"downtime", b.now().Sub(b.downSince).Round(time.Second))
+	}
+
+	b.state = BreakerClosed
+	b.failures = 0
+	b.downSince = time.Time{}
+	b.lastErr = nil
+}
+
+// Failure records a failed request, opening the circuit once the threshold
+// is reached or when a half-open probe fails
+func (b *CircuitBreaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.failures == 0 {
+		b.downSince = now
+	}
+	b.failures++
+	b.lastErr = err
+
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.config.FailureThreshold) {
+		b.state = BreakerOpen
+		b.openedAt = now
+		b.logger.Warn("circuit open, upstream down",
+			"source", b.source,
+			"down_since", b.downSince,
+			"failures", b.failures,
+			"next_probe", b.nextProbe(),
+			"error", err)
+	}
+}
+
+// Release ends a probe that was abandoned before it completed (e.g., on
+// shutdown), so the next caller probes instead
+func (b *CircuitBreaker) Release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.state = BreakerOpen
+	}
+}
+
+// Health returns a snapshot of the breaker state
+func (b *CircuitBreaker) Health() BreakerHealth {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	health := BreakerHealth{
+		Source:              b.source,
+		State:               b.state,
+		ConsecutiveFailures: b.failures,
+		DownSince:           b.downSince,
+		LastError:           b.lastErr,
+	}
+	if b.state == BreakerOpen {
+		health.NextProbe = b.nextProbe()
+	}
+	return health
+}
+
+func (b *CircuitBreaker) nextProbe() time.Time {
+	return b.openedAt.Add(b.config.CoolOff)
+}
+
+func (b *CircuitBreaker) openError() error {
+	return &CircuitOpenError{
+		Source:    b.source,
+		DownSince: b.downSince,
+		NextProbe: b.nextProbe(),
+	}
+}
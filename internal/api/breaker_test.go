@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	now := time.Date(2025, 11, 25, 10, 0, 0, 0, time.UTC)
+	b := NewCircuitBreaker(SourceCMB, BreakerConfig{FailureThreshold: 2, CoolOff: 5 * time.Minute}, testLogger())
+	b.now = func() time.Time { return now }
+
+	failure := errors.New("connection refused")
+	b.Failure(failure)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() below threshold error = %v", err)
+	}
+
+	b.Failure(failure)
+	err := b.Allow()
+	var openErr *CircuitOpenError
+	if !errors.As(err, &openErr) || !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Allow() after threshold error = %v, want *CircuitOpenError", err)
+	}
+	if !openErr.DownSince.Equal(now) {
+		t.Errorf("DownSince = %v, want %v", openErr.DownSince, now)
+	}
+
+	// After the cool-off, exactly one probe is let through
+	now = now.Add(5 * time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() after cool-off error = %v", err)
+	}
+	if state := b.Health().State; state != BreakerHalfOpen {
+		t.Errorf("State = %v, want half-open", state)
+	}
+	if err := b.Allow(); err == nil {
+		t.Error("second Allow() during probe should fail fast")
+	}
+
+	// A failed probe re-opens for another cool-off
+	b.Failure(failure)
+	health := b.Health()
+	if health.State != BreakerOpen || !health.NextProbe.Equal(now.Add(5*time.Minute)) {
+		t.Errorf("Health() = %+v, want open until %v", health, now.Add(5*time.Minute))
+	}
+
+	// A successful probe closes the circuit
+	now = now.Add(5 * time.Minute)
+	if err := b.Allow(); err != nil {
+		t.Fatalf("Allow() after second cool-off error = %v", err)
+	}
+	b.Success()
+	health = b.Health()
+	if health.State != BreakerClosed || health.ConsecutiveFailures != 0 || !health.DownSince.IsZero() {
+		t.Errorf("Health() after recovery = %+v, want closed", health)
+	}
+}
+
+func TestClientCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(testLogger(),
+		WithBaseURL(srv.URL),
+		WithRetries(1, time.Millisecond),
+		WithBreaker(BreakerConfig{FailureThreshold: 1, CoolOff: time.Hour}))
+
+	ctx := context.Background()
+	if _, err := c.FetchRates(ctx); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("first FetchRates() error = %v, want upstream error", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2 (one retry)", n)
+	}
+
+	// Open circuit: no requests until the cool-off ends
+	for i := 0; i < 3; i++ {
+		if _, err := c.FetchRates(ctx); !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("FetchRates() error = %v, want ErrCircuitOpen", err)
+		}
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d while open, want 2", n)
+	}
+	if state := c.Health().State; state != BreakerOpen {
+		t.Errorf("Health().State = %v, want open", state)
+	}
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrCassetteExhausted is returned by a cassette player when no recorded
+// exchange is left for a request
+var ErrCassetteExhausted = errors.New("no recorded exchange left in cassette")
+
+// Cassette is a recording of HTTP exchanges, saved as indented JSON
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single recorded request and its response
+type Interaction struct {
+	RecordedAt time.Time        `json:"recorded_at"`
+	Request    CassetteRequest  `json:"request"`
+	Response   CassetteResponse `json:"response"`
+}
+
+// CassetteRequest identifies a recorded request
+// Request headers are not recorded so cassettes can be shared safely
+type CassetteRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// CassetteResponse is a recorded response
+type CassetteResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette: %w", err)
+	}
+
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("decoding cassette %s: %w", path, err)
+	}
+	return &cassette, nil
+}
+
+// Save writes the cassette to a file, creating its directory if needed
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("creating cassette directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("writing cassette: %w", err)
+	}
+	return nil
+}
+
+// CassetteRecorder is an http.RoundTripper that forwards requests and saves
+// every exchange to a cassette file as it happens
+type CassetteRecorder struct {
+	mu       sync.Mutex
+	path     string
+	next     http.RoundTripper
+	cassette Cassette
+}
+
+// NewCassetteRecorder records exchanges made through next (the default
+// transport if nil) to the cassette file at path, replacing its contents
+func NewCassetteRecorder(path string, next http.RoundTripper) *CassetteRecorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &CassetteRecorder{path: path, next: next}
+}
+
+// RoundTrip forwards the request and records the exchange
+// Network errors are passed through and not recorded
+func (r *CassetteRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		RecordedAt: time.Now(),
+		Request:    CassetteRequest{Method: req.Method, URL: req.URL.String()},
+		Response: CassetteResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    resp.Header.Clone(),
+			Body:       string(body),
+		},
+	})
+	if err := r.cassette.Save(r.path); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// CassettePlayer is an http.RoundTripper that serves recorded responses
+// instead of contacting the network
+// Requests are matched on method, path and query (not host, so a cassette
+// recorded against production replays against any base URL), and matching
+// exchanges are served in recorded order, each once
+type CassettePlayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewCassettePlayer creates a player serving the exchanges of a cassette
+func NewCassettePlayer(cassette *Cassette) *CassettePlayer {
+	return &CassettePlayer{
+		interactions: cassette.Interactions,
+		used:         make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// LoadCassettePlayer creates a player serving a cassette file
+func LoadCassettePlayer(path string) (*CassettePlayer, error) {
+	cassette, err := LoadCassette(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewCassettePlayer(cassette), nil
+}
+
+// RoundTrip serves the next unused recorded response matching the request
+func (p *CassettePlayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, interaction := range p.interactions {
+		if p.used[i] || !matchesRequest(interaction.Request, req) {
+			continue
+		}
+		p.used[i] = true
+
+		rec := interaction.Response
+		header := rec.Headers.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", rec.StatusCode, http.StatusText(rec.StatusCode)),
+			StatusCode:    rec.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(bytes.NewReader([]byte(rec.Body))),
+			ContentLength: int64(len(rec.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrCassetteExhausted)
+}
+
+// Remaining returns how many recorded exchanges have not been served yet
+func (p *CassettePlayer) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	n := 0
+	for _, used := range p.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// matchesRequest reports whether a recorded request matches a live one
+func matchesRequest(rec CassetteRequest, req *http.Request) bool {
+	if rec.Method != req.Method {
+		return false
+	}
+
+	recorded, err := req.URL.Parse(rec.URL)
+	if err != nil {
+		return false
+	}
+	return recorded.Path == req.URL.Path && recorded.RawQuery == req.URL.RawQuery
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCassetteRecordAndReplay(t *testing.T) {
+	srv := serve(t, http.StatusOK, string(readFixture(t)))
+	path := filepath.Join(t.TempDir(), "cassettes", "cmb.json")
+
+	recorder := NewCassetteRecorder(path, nil)
+	live := NewClient(testLogger(), WithBaseURL(srv.URL+"/api/rate/fx-rate"), WithTransport(recorder))
+	want, err := live.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("recording FetchRates() error = %v", err)
+	}
+	srv.Close()
+
+	// Replay against the production URL: no network involved
+	player, err := LoadCassettePlayer(path)
+	if err != nil {
+		t.Fatalf("LoadCassettePlayer() error = %v", err)
+	}
+	replayed := NewClient(testLogger(), WithTransport(player), WithRetries(0, 0))
+	got, err := replayed.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("replayed FetchRates() error = %v", err)
+	}
+	if len(got) != len(want) || findRate(t, got, "USD") != findRate(t, want, "USD") {
+		t.Errorf("replayed rates differ from recorded ones")
+	}
+
+	if _, err := replayed.FetchRates(context.Background()); !errors.Is(err, ErrCassetteExhausted) {
+		t.Errorf("FetchRates() past the recording error = %v, want ErrCassetteExhausted", err)
+	}
+}
+
+func TestCassetteRetryAfterOutage(t *testing.T) {
+	player, err := LoadCassettePlayer("../../fixtures/cassettes/cmb-503-then-ok.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(testLogger(), WithTransport(player), WithRetries(1, time.Millisecond))
+	rates, err := c.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v, want the retry to succeed", err)
+	}
+	findRate(t, rates, "USD")
+	if player.Remaining() != 0 {
+		t.Errorf("Remaining() = %d, want every exchange served", player.Remaining())
+	}
+}
+
+// TestCassettes replays every captured cassette through the current client,
+// so a change that breaks parsing of real traffic fails here
+func TestCassettes(t *testing.T) {
+	paths, err := filepath.Glob("../../fixtures/cassettes/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no cassettes found: %v", err)
+	}
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			player, err := LoadCassettePlayer(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			c := NewClient(testLogger(), WithTransport(player), WithRetries(3, time.Millisecond))
+			body, err := c.FetchRaw(context.Background())
+			if err != nil {
+				t.Fatalf("FetchRaw() error = %v", err)
+			}
+			if err := DefaultContract().ValidateCMB(body); err != nil {
+				t.Errorf("ValidateCMB() error = %v", err)
+			}
+			if _, err := c.ParseRates(body); err != nil {
+				t.Errorf("ParseRates() error = %v", err)
+			}
+		})
+	}
+}
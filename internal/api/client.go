@@ -5,10 +5,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,20 +18,74 @@ const (
 	defaultTimeout    = 10 * time.Second
 	defaultMaxRetries = 3
 	defaultRetryDelay = 2 * time.Second
+	maxRetryAfter     = 30 * time.Second // Longest Retry-After honored within a single fetch
 )
 
 // Client is an HTTP client for fetching exchange rates from CMB API
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
+	headers    http.Header // Extra request headers, overriding the defaults
 	maxRetries int
 	retryDelay time.Duration
+	breaker    *CircuitBreaker
 	logger     *slog.Logger
 }
 
-// NewClient creates a new API client with default configuration
-func NewClient(logger *slog.Logger) *Client {
-	return &Client{
+// ClientOption configures the CMB client
+type ClientOption func(*Client)
+
+// WithTransport sends requests through a custom round tripper (e.g., a
+// cassette recorder or player)
+func WithTransport(transport http.RoundTripper) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Transport = transport
+	}
+}
+
+// WithBaseURL points the client at another endpoint (e.g., a test server)
+func WithBaseURL(baseURL string) ClientOption {
+	return func(c *Client) {
+		c.baseURL = baseURL
+	}
+}
+
+// WithTimeout sets the timeout of a single request attempt
+func WithTimeout(timeout time.Duration) ClientOption {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a failed request is retried and the base
+// delay of the exponential backoff between attempts
+func WithRetries(maxRetries int, delay time.Duration) ClientOption {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.retryDelay = delay
+	}
+}
+
+// WithHeaders adds headers to every request, replacing defaults of the same name
+func WithHeaders(headers http.Header) ClientOption {
+	return func(c *Client) {
+		for name, values := range headers {
+			c.headers[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+// WithBreaker configures the client's circuit breaker
+func WithBreaker(config BreakerConfig) ClientOption {
+	return func(c *Client) {
+		c.breaker = NewCircuitBreaker(SourceCMB, config, c.logger)
+	}
+}
+
+// NewClient creates a new API client, with default configuration unless
+// options are given
+func NewClient(logger *slog.Logger, opts ...ClientOption) *Client {
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout: defaultTimeout,
 			Transport: &http.Transport{
@@ -40,20 +95,71 @@ func NewClient(logger *slog.Logger) *Client {
 			},
 		},
 		baseURL:    defaultBaseURL,
+		headers:    make(http.Header),
 		maxRetries: defaultMaxRetries,
 		retryDelay: defaultRetryDelay,
+		breaker:    NewCircuitBreaker(SourceCMB, DefaultBreakerConfig(), logger),
 		logger:     logger,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Health returns the state of the client's circuit breaker
+func (c *Client) Health() BreakerHealth {
+	return c.breaker.Health()
 }
 
 // FetchExchangeRates retrieves current exchange rates with retry logic
 func (c *Client) FetchExchangeRates(ctx context.Context) (*CMBResponse, error) {
+	resp, _, err := c.fetchWithRetry(ctx)
+	return resp, err
+}
+
+// fetchWithRetry retrieves and decodes the current response, retrying failed
+// attempts with backoff, and returns it along with the raw body
+// The circuit breaker gates every call: while it is open no request is made,
+// and a half-open probe is a single attempt without retries
+func (c *Client) fetchWithRetry(ctx context.Context) (*CMBResponse, []byte, error) {
+	if err := c.breaker.Allow(); err != nil {
+		return nil, nil, err
+	}
+
+	resp, body, err := c.fetchAttempts(ctx)
+	if err != nil {
+		if ctx.Err() != nil {
+			c.breaker.Release()
+		} else {
+			c.breaker.Failure(err)
+		}
+		return nil, nil, err
+	}
+
+	c.breaker.Success()
+	return resp, body, nil
+}
+
+// fetchAttempts performs the request, retrying failed attempts with backoff
+func (c *Client) fetchAttempts(ctx context.Context) (*CMBResponse, []byte, error) {
+	maxRetries := c.maxRetries
+	if c.breaker.Health().State == BreakerHalfOpen {
+		maxRetries = 0
+	}
+
 	var lastErr error
+	var retryAfter time.Duration
 
-	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+	for attempt := 0; attempt <= maxRetries; attempt++ {
 		if attempt > 0 {
-			// Exponential backoff with jitter
+			// Exponential backoff with jitter, or longer if the server asked for it
 			backoff := c.calculateBackoff(attempt)
+			if retryAfter > backoff {
+				backoff = retryAfter
+			}
 			c.logger.Info("retrying API request",
 				"attempt", attempt,
 				"backoff", backoff)
@@ -61,22 +167,25 @@ func (c *Client) FetchExchangeRates(ctx context.Context) (*CMBResponse, error) {
 			select {
 			case <-time.After(backoff):
 			case <-ctx.Done():
-				return nil, ctx.Err()
+				return nil, nil, ctx.Err()
 			}
 		}
 
-		resp, err := c.fetchOnce(ctx)
+		resp, body, err := c.fetchOnce(ctx)
 		if err == nil {
-			return resp, nil
+			return resp, body, nil
 		}
 
 		lastErr = err
 
-		// Don't retry on certain errors
-		var httpErr *HTTPError
-		if errors.As(err, &httpErr) && httpErr.StatusCode < 500 {
-			// Don't retry 4xx client errors
-			return nil, fmt.Errorf("non-retryable error: %w", err)
+		if !IsRetryable(err) {
+			return nil, nil, fmt.Errorf("non-retryable error: %w", err)
+		}
+
+		// Longer waits are left to the poller rather than holding up this poll
+		retryAfter = RetryAfter(err)
+		if retryAfter > maxRetryAfter {
+			return nil, nil, fmt.Errorf("server asked to retry after %v: %w", retryAfter, err)
 		}
 
 		c.logger.Warn("API request failed",
@@ -84,47 +193,27 @@ func (c *Client) FetchExchangeRates(ctx context.Context) (*CMBResponse, error) {
 			"error", err)
 	}
 
-	return nil, fmt.Errorf("max retries exceeded: %w", lastErr)
+	return nil, nil, fmt.Errorf("max retries exceeded: %w", lastErr)
 }
 
 // fetchOnce performs a single API request
-func (c *Client) fetchOnce(ctx context.Context) (*CMBResponse, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("User-Agent", "USD-Buy-Rate-Monitor/1.0")
-	req.Header.Set("Accept", "application/json")
-
+func (c *Client) fetchOnce(ctx context.Context) (*CMBResponse, []byte, error) {
 	startTime := time.Now()
-	resp, err := c.httpClient.Do(req)
-	elapsed := time.Since(startTime)
-
+	body, err := fetchURL(ctx, c.httpClient, c.baseURL, "application/json", c.headers)
 	if err != nil {
-		return nil, &NetworkError{Err: err, Duration: elapsed}
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, &HTTPError{
-			StatusCode: resp.StatusCode,
-			Body:       string(body),
-		}
+		return nil, nil, err
 	}
 
 	var cmbResp CMBResponse
-	if err := json.NewDecoder(resp.Body).Decode(&cmbResp); err != nil {
-		return nil, fmt.Errorf("decoding response: %w", err)
+	if err := json.Unmarshal(body, &cmbResp); err != nil {
+		return nil, nil, fmt.Errorf("decoding response: %w", err)
 	}
 
 	c.logger.Debug("API request successful",
-		"response_time_ms", elapsed.Milliseconds(),
+		"response_time_ms", time.Since(startTime).Milliseconds(),
 		"return_code", cmbResp.ReturnCode)
 
-	return &cmbResp, nil
+	return &cmbResp, body, nil
 }
 
 // calculateBackoff calculates exponential backoff with jitter
@@ -161,8 +250,58 @@ func (e *NetworkError) Unwrap() error {
 type HTTPError struct {
 	StatusCode int
 	Body       string
+	RetryAfter time.Duration // Wait requested by the server's Retry-After header (0 if none)
 }
 
 func (e *HTTPError) Error() string {
 	return fmt.Sprintf("HTTP %d: %s", e.StatusCode, e.Body)
 }
+
+// IsRetryable reports whether a failed request may succeed if repeated:
+// network errors, server errors and 408/425/429 responses
+func IsRetryable(err error) bool {
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		var netErr *NetworkError
+		return errors.As(err, &netErr)
+	}
+
+	switch httpErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+		return true
+	default:
+		return httpErr.StatusCode >= 500
+	}
+}
+
+// RetryAfter returns how long the server asked to wait before the next
+// request, or 0 if the error carries no Retry-After
+func RetryAfter(err error) time.Duration {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After header value, either delay seconds
+// or an HTTP date, into a wait from now (0 if absent, invalid or past)
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil || !at.After(now) {
+		return 0
+	}
+	return at.Sub(now)
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 11, 25, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-5", 0},
+		{"Tue, 25 Nov 2025 12:01:30 GMT", 90 * time.Second},
+		{"Tue, 25 Nov 2025 11:59:00 GMT", 0}, // Already past
+		{"soon", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&NetworkError{Err: errors.New("connection reset")}, true},
+		{&HTTPError{StatusCode: http.StatusRequestTimeout}, true},
+		{&HTTPError{StatusCode: http.StatusTooEarly}, true},
+		{fmt.Errorf("fetching: %w", &HTTPError{StatusCode: http.StatusTooManyRequests}), true},
+		{&HTTPError{StatusCode: http.StatusBadGateway}, true},
+		{&HTTPError{StatusCode: http.StatusNotFound}, false},
+		{&HTTPError{StatusCode: http.StatusForbidden}, false},
+		{errors.New("decoding response: unexpected EOF"), false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestClientRetriesRateLimit(t *testing.T) {
+	fixture := readFixture(t)
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write(fixture)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(testLogger(), WithBaseURL(srv.URL), WithRetries(2, time.Millisecond))
+	if _, err := c.FetchRates(context.Background()); err != nil {
+		t.Fatalf("FetchRates() error = %v, want retry after 429 to succeed", err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("requests = %d, want 2", n)
+	}
+}
+
+func TestClientLongRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(testLogger(), WithBaseURL(srv.URL), WithRetries(3, time.Millisecond))
+	_, err := c.FetchRates(context.Background())
+	if got := RetryAfter(err); got != 10*time.Minute {
+		t.Errorf("RetryAfter(%v) = %v, want 10m", err, got)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1 (no retries within the requested wait)", n)
+	}
+}
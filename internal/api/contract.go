@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+var (
+	ratePattern    = regexp.MustCompile(`^\d+(\.\d+)?$`)
+	ratDatPattern  = regexp.MustCompile(`^\d{4}年\d{1,2}月\d{1,2}日$`)
+	ratTimPattern  = regexp.MustCompile(`^\d{2}:\d{2}:\d{2}$`)
+	cmbPriceFields = []string{"rtbBid", "rthOfr", "rtcOfr", "rthBid", "rtcBid"}
+)
+
+// Contract describes what the monitor relies on in a rate payload
+// Fields and currencies not listed here may change freely
+type Contract struct {
+	RequiredCurrencies []string // ISO codes that must be quoted (e.g., "USD")
+	AllowedCcyExc      []string // Exchange units the rate normalization was validated against
+	MaxChange          float64  // Largest plausible change of a rate between two stored quotes (fraction, e.g. 0.05)
+}
+
+// DefaultContract returns the contract of the CMB payloads seen so far
+func DefaultContract() *Contract {
+	return &Contract{
+		RequiredCurrencies: []string{DefaultCurrency},
+		AllowedCcyExc:      []string{"10"},
+		MaxChange:          0.05,
+	}
+}
+
+// ContractViolation is a single way a payload breaks the contract
+type ContractViolation struct {
+	Path    string // Location in the payload (e.g., "body.data[3].rtcBid")
+	Problem string
+}
+
+// ContractError is returned when a payload violates the response contract,
+// a sign that the upstream format changed
+type ContractError struct {
+	Source     string
+	Violations []ContractViolation
+}
+
+func (e *ContractError) Error() string {
+	problems := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		problems[i] = v.Path + ": " + v.Problem
+	}
+	return fmt.Sprintf("%s response contract violated: %s", e.Source, strings.Join(problems, "; "))
+}
+
+// PayloadValidator is implemented by raw sources that can check a payload
+// against the response contract before it is parsed
+type PayloadValidator interface {
+	ValidatePayload(contract *Contract, body []byte) error
+}
+
+// ValidatePayload checks a raw CMB response body against the contract
+func (c *Client) ValidatePayload(contract *Contract, body []byte) error {
+	return contract.ValidateCMB(body)
+}
+
+// ValidateCMB checks a raw CMB response body: required fields are present
+// and are strings, prices are plain decimals, dates and times have the
+// expected format, ccyExc has an allowed value and the required currencies
+// are quoted. It returns a *ContractError listing every violation
+// Non-success return codes are API errors, not violations, and pass
+func (c *Contract) ValidateCMB(body []byte) error {
+	cerr := &ContractError{Source: SourceCMB}
+	violate := func(path, format string, args ...any) {
+		cerr.Violations = append(cerr.Violations, ContractViolation{Path: path, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(body, &top); err != nil {
+		violate("$", "not a JSON object: %v", err)
+		return cerr
+	}
+
+	var returnCode string
+	if !decodeString(top, "returnCode", &returnCode, "", violate) {
+		return cerr
+	}
+	if returnCode != "SUC0000" {
+		return nil
+	}
+
+	var respBody map[string]json.RawMessage
+	if raw, ok := top["body"]; !ok || json.Unmarshal(raw, &respBody) != nil || respBody == nil {
+		violate("body", "missing or not an object")
+		return cerr
+	}
+
+	var rows []map[string]json.RawMessage
+	if raw, ok := respBody["data"]; !ok || json.Unmarshal(raw, &rows) != nil {
+		violate("body.data", "missing or not an array of objects")
+		return cerr
+	}
+	if len(rows) == 0 {
+		violate("body.data", "empty")
+		return cerr
+	}
+
+	quoted := make(map[string]bool)
+	for i, row := range rows {
+		prefix := fmt.Sprintf("body.data[%d]", i)
+
+		var name string
+		if decodeString(row, "ccyNbr", &name, prefix, violate) {
+			if code, ok := CurrencyCode(name); ok {
+				quoted[code] = true
+				prefix = fmt.Sprintf("%s(%s)", prefix, code)
+			}
+		}
+
+		for _, field := range cmbPriceFields {
+			var value string
+			if !decodeString(row, field, &value, prefix, violate) {
+				continue
+			}
+			if value == "" && field != "rtcBid" {
+				continue
+			}
+			if !ratePattern.MatchString(value) {
+				violate(prefix+"."+field, "%q is not a decimal price", value)
+			}
+		}
+
+		var ratDat, ratTim, ccyExc string
+		if decodeString(row, "ratDat", &ratDat, prefix, violate) && !ratDatPattern.MatchString(ratDat) {
+			violate(prefix+".ratDat", "%q is not a date like 2025年11月25日", ratDat)
+		}
+		if decodeString(row, "ratTim", &ratTim, prefix, violate) && !ratTimPattern.MatchString(ratTim) {
+			violate(prefix+".ratTim", "%q is not a time like 20:11:02", ratTim)
+		}
+		if decodeString(row, "ccyExc", &ccyExc, prefix, violate) && !contains(c.AllowedCcyExc, ccyExc) {
+			violate(prefix+".ccyExc", "unexpected exchange unit %q (expected one of %v)", ccyExc, c.AllowedCcyExc)
+		}
+	}
+
+	for _, code := range c.RequiredCurrencies {
+		if !quoted[code] {
+			violate("body.data", "required currency %s not quoted", code)
+		}
+	}
+
+	if len(cerr.Violations) > 0 {
+		return cerr
+	}
+	return nil
+}
+
+// CheckQuote checks that a quote is internally consistent: no offer below
+// its bid
+func (c *Contract) CheckQuote(source string, quote CurrencyRate) error {
+	var violations []ContractViolation
+	if quote.RthOfr > 0 && quote.RthBid > quote.RthOfr {
+		violations = append(violations, ContractViolation{
+			Path:    quote.Currency + ".rthOfr",
+			Problem: fmt.Sprintf("spot offer %s below bid %s", quote.RthOfr, quote.RthBid),
+		})
+	}
+	if quote.RtcOfr > 0 && quote.RtcBid > quote.RtcOfr {
+		violations = append(violations, ContractViolation{
+			Path:    quote.Currency + ".rtcOfr",
+			Problem: fmt.Sprintf("cash offer %s below bid %s", quote.RtcOfr, quote.RtcBid),
+		})
+	}
+
+	if len(violations) > 0 {
+		return &ContractError{Source: source, Violations: violations}
+	}
+	return nil
+}
+
+// CheckBand checks that a quote's tracked rate is within MaxChange of the
+// last stored rate (last <= 0 or MaxChange <= 0 skips the check)
+func (c *Contract) CheckBand(source string, quote CurrencyRate, last fixed.Rate) error {
+	if last <= 0 || c.MaxChange <= 0 {
+		return nil
+	}
+
+	change := float64(quote.RtcBid-last) / float64(last)
+	if change <= c.MaxChange && change >= -c.MaxChange {
+		return nil
+	}
+
+	return &ContractError{Source: source, Violations: []ContractViolation{{
+		Path: quote.Currency + ".rtcBid",
+		Problem: fmt.Sprintf("%s is %+.2f%% from last stored %s (max ±%.2f%%)",
+			quote.RtcBid, change*100, last, c.MaxChange*100),
+	}}}
+}
+
+// decodeString reads a required string field of a JSON object, recording a
+// violation if it is missing or has another type
+func decodeString(obj map[string]json.RawMessage, field string, dest *string, prefix string, violate func(string, string, ...any)) bool {
+	path := field
+	if prefix != "" {
+		path = prefix + "." + field
+	}
+
+	raw, ok := obj[field]
+	if !ok {
+		violate(path, "missing")
+		return false
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		violate(path, "expected a string, got %s", string(raw))
+		return false
+	}
+	return true
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
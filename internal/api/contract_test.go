@@ -0,0 +1,118 @@
+package api
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+func TestValidateCMBFixture(t *testing.T) {
+	if err := DefaultContract().ValidateCMB(readFixture(t)); err != nil {
+		t.Errorf("ValidateCMB() on recorded payload error = %v", err)
+	}
+}
+
+func TestValidateCMBDrift(t *testing.T) {
+	fixture := string(readFixture(t))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantPath string
+	}{
+		{
+			name:     "renamed field",
+			body:     strings.Replace(fixture, `"rtcBid"`, `"rtcBidPrice"`, 1),
+			wantPath: "body.data[0](HKD).rtcBid",
+		},
+		{
+			name:     "number instead of string",
+			body:     strings.Replace(fixture, `"rthOfr": "91.32"`, `"rthOfr": 91.32`, 1),
+			wantPath: "body.data[0](HKD).rthOfr",
+		},
+		{
+			name:     "price format",
+			body:     strings.Replace(fixture, `"rtbBid": "91.14"`, `"rtbBid": "91,14"`, 1),
+			wantPath: "body.data[0](HKD).rtbBid",
+		},
+		{
+			name:     "exchange unit",
+			body:     strings.Replace(fixture, `"ccyExc": "10"`, `"ccyExc": "100"`, 1),
+			wantPath: "body.data[0](HKD).ccyExc",
+		},
+		{
+			name:     "date format",
+			body:     strings.Replace(fixture, `"ratDat": "2025年11月25日"`, `"ratDat": "2025-11-25"`, 1),
+			wantPath: "body.data[0](HKD).ratDat",
+		},
+		{
+			name:     "required currency missing",
+			body:     strings.Replace(fixture, `"美元"`, `"美金"`, 1),
+			wantPath: "body.data",
+		},
+		{
+			name:     "data moved",
+			body:     strings.Replace(fixture, `"data"`, `"rates"`, 1),
+			wantPath: "body.data",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := DefaultContract().ValidateCMB([]byte(tt.body))
+
+			var cerr *ContractError
+			if !errors.As(err, &cerr) {
+				t.Fatalf("ValidateCMB() error = %v, want *ContractError", err)
+			}
+			for _, v := range cerr.Violations {
+				if v.Path == tt.wantPath {
+					return
+				}
+			}
+			t.Errorf("violations = %+v, want one at %s", cerr.Violations, tt.wantPath)
+		})
+	}
+}
+
+func TestValidateCMBAPIError(t *testing.T) {
+	body := []byte(`{"returnCode":"ERR0001","errorMsg":"busy","body":null}`)
+	if err := DefaultContract().ValidateCMB(body); err != nil {
+		t.Errorf("ValidateCMB() on API error = %v, want nil", err)
+	}
+}
+
+func TestCheckQuote(t *testing.T) {
+	c := DefaultContract()
+
+	if err := c.CheckQuote(SourceCMB, CurrencyRate{Currency: "USD", RtcBid: fixed.MustParse("7.05"), RtcOfr: fixed.MustParse("7.10")}); err != nil {
+		t.Errorf("CheckQuote() consistent quote error = %v", err)
+	}
+	if err := c.CheckQuote(SourceCMB, CurrencyRate{Currency: "USD", RtcBid: fixed.MustParse("7.10"), RtcOfr: fixed.MustParse("7.05")}); err == nil {
+		t.Error("CheckQuote() should reject an offer below the bid")
+	}
+}
+
+func TestCheckBand(t *testing.T) {
+	c := DefaultContract()
+	quote := CurrencyRate{Currency: "USD", RtcBid: fixed.MustParse("7.10")}
+
+	tests := []struct {
+		last    fixed.Rate
+		wantErr bool
+	}{
+		{0, false},                       // Nothing stored yet
+		{fixed.MustParse("7.05"), false}, // +0.7%
+		{fixed.MustParse("0.71"), true},  // Unit change: 10x
+		{fixed.MustParse("7.60"), true},  // -6.6%
+	}
+
+	for _, tt := range tests {
+		err := c.CheckBand(SourceCMB, quote, tt.last)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("CheckBand(last=%s) error = %v, wantErr %v", tt.last, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+const defaultICBCURL = "https://papi.icbc.com.cn/exchanges/ns/getLatest"
+
+// ICBCResponse represents the response of the ICBC latest-rates endpoint
+type ICBCResponse struct {
+	Code    int            `json:"code"`
+	Message string         `json:"message"`
+	Data    []ICBCCurrency `json:"data"`
+}
+
+// ICBCCurrency represents a single currency on the ICBC board
+// Prices are CNY per 100 units of foreign currency
+type ICBCCurrency struct {
+	CurrencyENName string `json:"currencyENName"` // ISO 4217 code (e.g., "USD")
+	CurrencyCHName string `json:"currencyCHName"` // Currency name in Chinese
+	Reference      string `json:"reference"`      // Reference (middle) rate
+	ForeignBuy     string `json:"foreignBuy"`     // Spot (现汇) bid
+	ForeignSell    string `json:"foreignSell"`    // Spot (现汇) offer
+	CashBuy        string `json:"cashBuy"`        // Cash (现钞) bid
+	CashSell       string `json:"cashSell"`       // Cash (现钞) offer
+	PublishDate    string `json:"publishDate"`    // e.g., "2025-11-25"
+	PublishTime    string `json:"publishTime"`    // e.g., "20:11:02"
+}
+
+// ICBCClient fetches rates from the ICBC foreign exchange board
+type ICBCClient struct {
+	httpClient *http.Client
+	baseURL    string
+	logger     *slog.Logger
+}
+
+// NewICBCClient creates an ICBC rate source with default configuration
+func NewICBCClient(logger *slog.Logger) *ICBCClient {
+	return &ICBCClient{
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		baseURL:    defaultICBCURL,
+		logger:     logger,
+	}
+}
+
+// Name returns the source name of the ICBC client
+func (c *ICBCClient) Name() string {
+	return SourceICBC
+}
+
+// FetchRates retrieves the ICBC board and extracts every recognized currency
+func (c *ICBCClient) FetchRates(ctx context.Context) ([]CurrencyRate, error) {
+	body, err := c.FetchRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := c.ParseRates(body)
+	if err != nil {
+		return nil, err
+	}
+
+	c.logger.Debug("ICBC request successful", "currencies", len(rates))
+
+	return rates, nil
+}
+
+// FetchRaw retrieves the ICBC latest-rates response body
+func (c *ICBCClient) FetchRaw(ctx context.Context) ([]byte, error) {
+	return fetchURL(ctx, c.httpClient, c.baseURL, "application/json", nil)
+}
+
+// ParseRates extracts the quotes of an ICBC response body
+func (c *ICBCClient) ParseRates(body []byte) ([]CurrencyRate, error) {
+	return parseICBCPayload(body)
+}
+
+// parseICBCPayload decodes an ICBC response body and extracts its quotes
+func parseICBCPayload(body []byte) ([]CurrencyRate, error) {
+	var resp ICBCResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return extractICBCRates(&resp)
+}
+
+// extractICBCRates extracts every supported currency from an ICBC response
+func extractICBCRates(resp *ICBCResponse) ([]CurrencyRate, error) {
+	if resp.Code != 0 {
+		return nil, fmt.Errorf("API error: %d %s", resp.Code, resp.Message)
+	}
+
+	var rates []CurrencyRate
+	for _, row := range resp.Data {
+		code := row.CurrencyENName
+		if !IsSupportedCurrency(code) {
+			if c, ok := CurrencyCode(row.CurrencyCHName); ok {
+				code = c
+			} else {
+				continue
+			}
+		}
+
+		quote := CurrencyRate{Currency: code}
+		sides := []struct {
+			name  string
+			value string
+			dest  *fixed.Rate
+		}{
+			{"foreignBuy", row.ForeignBuy, &quote.RthBid},
+			{"cashBuy", row.CashBuy, &quote.RtcBid},
+			{"foreignSell", row.ForeignSell, &quote.RthOfr},
+			{"cashSell", row.CashSell, &quote.RtcOfr},
+			{"reference", row.Reference, &quote.RtbBid},
+		}
+		for _, side := range sides {
+			if side.value == "" || side.value == "--" {
+				continue
+			}
+			val, err := parseRate(side.value, 100)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s %s: %w", code, side.name, err)
+			}
+			*side.dest = val
+		}
+
+		if quote.RtcBid == 0 {
+			quote.RtcBid = quote.RthBid
+		}
+		if quote.RtcBid == 0 {
+			continue
+		}
+
+		if row.PublishDate != "" && row.PublishTime != "" {
+			quotedAt, err := time.ParseInLocation("2006-01-02 15:04:05",
+				row.PublishDate+" "+row.PublishTime, quoteLocation)
+			if err != nil {
+				return nil, fmt.Errorf("parsing %s quote time: %w", code, err)
+			}
+			quote.QuotedAt = quotedAt
+		}
+
+		rates = append(rates, quote)
+	}
+
+	if len(rates) == 0 {
+		return nil, errors.New("no known currencies found in ICBC response")
+	}
+
+	return rates, nil
+}
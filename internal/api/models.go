@@ -3,7 +3,10 @@ package api
 import (
 	"errors"
 	"fmt"
-	"strconv"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 )
 
 // CMBResponse represents the top-level response from CMB API
@@ -22,43 +25,216 @@ type CMBBody struct {
 // CMBCurrencyRate represents a single currency's exchange rate
 type CMBCurrencyRate struct {
 	CcyNbr string `json:"ccyNbr"` // Currency name in Chinese (e.g., "美元")
-	RtbBid string `json:"rtbBid"` // Bank bid
-	RthOfr string `json:"rthOfr"` // Cash offer
-	RtcOfr string `json:"rtcOfr"` // Cash offer
-	RthBid string `json:"rthBid"` // Cash bid
+	RtbBid string `json:"rtbBid"` // Reference (middle) rate
+	RthOfr string `json:"rthOfr"` // Spot (现汇) offer
+	RtcOfr string `json:"rtcOfr"` // Cash (现钞) offer
+	RthBid string `json:"rthBid"` // Spot (现汇) bid
 	RtcBid string `json:"rtcBid"` // Cash bid rate - THIS IS WHAT WE NEED
 	RatTim string `json:"ratTim"` // Rate time
 	RatDat string `json:"ratDat"` // Rate date
-	CcyExc string `json:"ccyExc"` // Exchange unit (e.g., "10")
+	CcyExc string `json:"ccyExc"` // Exchange unit code (e.g., "10"), see quoteUnits
 }
 
-// ExtractUSDRate extracts the USD exchange rate from CMB API response
-// Returns the rate divided by 100 (since ccyExc is "10")
-func ExtractUSDRate(resp *CMBResponse) (float64, error) {
-	if resp.ReturnCode != "SUC0000" {
-		errMsg := "unknown error"
-		if resp.ErrorMsg != nil {
-			errMsg = *resp.ErrorMsg
+// DefaultCurrency is the currency tracked when none is specified
+const DefaultCurrency = "USD"
+
+// quoteLocation is the zone CMB publishes ratDat/ratTim in
+var quoteLocation = market.Zone
+
+// quoteUnits maps CMB's ccyExc codes to the number of foreign currency units
+// a price is quoted for. Every board currency, JPY included, is published
+// per 100 units under code "10"; unknown codes are rejected rather than
+// guessed at
+var quoteUnits = map[string]int64{
+	"10": 100,
+}
+
+// QuoteUnit returns the number of units a CMB price with the given ccyExc
+// code is quoted for
+func QuoteUnit(ccyExc string) (int64, error) {
+	unit, ok := quoteUnits[ccyExc]
+	if !ok {
+		return 0, fmt.Errorf("unknown exchange unit ccyExc=%q", ccyExc)
+	}
+	return unit, nil
+}
+
+// currencyCodes maps CMB's Chinese currency names to ISO 4217 codes
+var currencyCodes = map[string]string{
+	"美元":    "USD",
+	"港币":    "HKD",
+	"欧元":    "EUR",
+	"日元":    "JPY",
+	"英镑":    "GBP",
+	"澳大利亚元": "AUD",
+	"加拿大元":  "CAD",
+	"新西兰元":  "NZD",
+	"新加坡元":  "SGD",
+	"瑞士法郎":  "CHF",
+	"澳门元":   "MOP",
+	"韩元":    "KRW",
+	"韩国元":   "KRW",
+	"泰国铢":   "THB",
+	"丹麦克朗":  "DKK",
+	"瑞典克朗":  "SEK",
+	"挪威克朗":  "NOK",
+	"新台币":   "TWD",
+}
+
+// CurrencyCode returns the ISO 4217 code for a CMB currency name
+func CurrencyCode(ccyNbr string) (string, bool) {
+	code, ok := currencyCodes[ccyNbr]
+	return code, ok
+}
+
+// IsSupportedCurrency reports whether an ISO code can appear in CMB responses
+func IsSupportedCurrency(code string) bool {
+	for _, c := range currencyCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrencyRate holds the extracted quote for a single currency
+// All prices are CNY per unit of foreign currency; 0 means the side was not quoted
+type CurrencyRate struct {
+	Currency string // ISO 4217 code (e.g., "USD")
+	RtcBid   fixed.Rate
+	RtbBid   fixed.Rate
+	RthBid   fixed.Rate
+	RthOfr   fixed.Rate
+	RtcOfr   fixed.Rate
+	QuotedAt time.Time // When CMB published the quote (zero if not provided)
+}
+
+// ExtractRates extracts every recognized currency from CMB API response
+// Currencies without a known ISO code are skipped
+func ExtractRates(resp *CMBResponse) ([]CurrencyRate, error) {
+	if err := checkResponse(resp); err != nil {
+		return nil, err
+	}
+
+	rates := make([]CurrencyRate, 0, len(resp.Body.Data))
+	for _, rate := range resp.Body.Data {
+		code, ok := CurrencyCode(rate.CcyNbr)
+		if !ok {
+			continue
 		}
-		return 0, fmt.Errorf("API error: %s", errMsg)
+
+		quote, err := parseQuote(code, rate)
+		if err != nil {
+			return nil, err
+		}
+
+		rates = append(rates, quote)
 	}
 
-	if resp.Body == nil || len(resp.Body.Data) == 0 {
-		return 0, errors.New("empty response body")
+	if len(rates) == 0 {
+		return nil, errors.New("no known currencies found in response")
+	}
+
+	return rates, nil
+}
+
+// ExtractUSDRate extracts the USD cash bid from CMB API response, converted
+// to CNY per dollar according to the row's ccyExc
+func ExtractUSDRate(resp *CMBResponse) (fixed.Rate, error) {
+	if err := checkResponse(resp); err != nil {
+		return 0, err
 	}
 
 	for _, rate := range resp.Body.Data {
 		if rate.CcyNbr == "美元" {
-			// Parse string to float
-			val, err := strconv.ParseFloat(rate.RtcBid, 64)
+			unit, err := QuoteUnit(rate.CcyExc)
+			if err != nil {
+				return 0, err
+			}
+			val, err := parseRate(rate.RtcBid, unit)
 			if err != nil {
 				return 0, fmt.Errorf("parsing rate: %w", err)
 			}
-
-			// Divide by 100 (since ccyExc is "10", rates are per 10 units)
-			return val / 100, nil
+			return val, nil
 		}
 	}
 
 	return 0, errors.New("USD (美元) not found in response")
 }
+
+// checkResponse verifies the return code and that the body carries data
+func checkResponse(resp *CMBResponse) error {
+	if resp.ReturnCode != "SUC0000" {
+		errMsg := "unknown error"
+		if resp.ErrorMsg != nil {
+			errMsg = *resp.ErrorMsg
+		}
+		return fmt.Errorf("API error: %s", errMsg)
+	}
+
+	if resp.Body == nil || len(resp.Body.Data) == 0 {
+		return errors.New("empty response body")
+	}
+
+	return nil
+}
+
+// parseQuote parses every price side of a currency row, normalized to CNY
+// per unit using the row's ccyExc
+// RtcBid is required; the other sides may be blank
+func parseQuote(code string, rate CMBCurrencyRate) (CurrencyRate, error) {
+	quote := CurrencyRate{Currency: code}
+
+	unit, err := QuoteUnit(rate.CcyExc)
+	if err != nil {
+		return quote, fmt.Errorf("parsing %s rate: %w", code, err)
+	}
+
+	val, err := parseRate(rate.RtcBid, unit)
+	if err != nil {
+		return quote, fmt.Errorf("parsing %s rate: %w", code, err)
+	}
+	quote.RtcBid = val
+
+	sides := []struct {
+		name  string
+		value string
+		dest  *fixed.Rate
+	}{
+		{"rtbBid", rate.RtbBid, &quote.RtbBid},
+		{"rthBid", rate.RthBid, &quote.RthBid},
+		{"rthOfr", rate.RthOfr, &quote.RthOfr},
+		{"rtcOfr", rate.RtcOfr, &quote.RtcOfr},
+	}
+	for _, side := range sides {
+		if side.value == "" {
+			continue
+		}
+		val, err := parseRate(side.value, unit)
+		if err != nil {
+			return quote, fmt.Errorf("parsing %s %s: %w", code, side.name, err)
+		}
+		*side.dest = val
+	}
+
+	if rate.RatDat != "" && rate.RatTim != "" {
+		quotedAt, err := ParseQuoteTime(rate.RatDat, rate.RatTim)
+		if err != nil {
+			return quote, fmt.Errorf("parsing %s quote time: %w", code, err)
+		}
+		quote.QuotedAt = quotedAt
+	}
+
+	return quote, nil
+}
+
+// ParseQuoteTime parses CMB's ratDat ("2025年11月25日") and ratTim ("20:11:02")
+func ParseQuoteTime(ratDat, ratTim string) (time.Time, error) {
+	return time.ParseInLocation("2006年1月2日 15:04:05", ratDat+" "+ratTim, quoteLocation)
+}
+
+// parseRate converts a price quoted per unit units of foreign currency to
+// CNY per unit
+func parseRate(s string, unit int64) (fixed.Rate, error) {
+	return fixed.ParseQuote(s, unit)
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrReplayDone is returned once a replay source has no records left
+var ErrReplayDone = fmt.Errorf("replay finished: %w", io.EOF)
+
+// maxReplayLine bounds a single JSONL record (a full CMB payload is ~3 KB)
+const maxReplayLine = 1 << 20
+
+// fileStampPattern finds a timestamp such as 20251125T201102 or
+// 2025-11-25T20-11-02 in a recorded payload's file name
+var fileStampPattern = regexp.MustCompile(`(\d{4})-?(\d{2})-?(\d{2})[T_ -]?(\d{2})[-:]?(\d{2})[-:]?(\d{2})`)
+
+// ReplayRecord is a recorded CMB payload and when it was collected
+type ReplayRecord struct {
+	CollectedAt time.Time // Zero if the recording carries no timestamp
+	Response    *CMBResponse
+}
+
+// replayLine is the JSONL shape written by recorders that keep the poll time;
+// lines holding a bare CMBResponse are accepted as well
+type replayLine struct {
+	CollectedAt time.Time    `json:"collected_at"`
+	Response    *CMBResponse `json:"response"`
+}
+
+// ReplaySource reads recorded CMB payloads from a JSON file, a directory of
+// timestamped JSON files or a JSONL stream, in order
+// Records are read lazily so large archives are not held in memory
+type ReplaySource struct {
+	files   []string       // Remaining files to read, sorted by name
+	scanner *bufio.Scanner // Open JSONL stream, if any
+	stream  io.Closer
+	line    int
+	current string // File or stream being read, for error messages
+	logger  *slog.Logger
+}
+
+// NewReplaySource creates a replay source from a file, directory or "-" (JSONL on stdin)
+func NewReplaySource(path string, logger *slog.Logger) (*ReplaySource, error) {
+	s := &ReplaySource{logger: logger}
+
+	if path == "-" {
+		s.openStream("stdin", io.NopCloser(os.Stdin))
+		return s, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening replay source: %w", err)
+	}
+
+	if !info.IsDir() {
+		s.files = []string{path}
+		return s, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading replay directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !(strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".jsonl")) {
+			continue
+		}
+		s.files = append(s.files, filepath.Join(path, name))
+	}
+	sort.Strings(s.files)
+
+	if len(s.files) == 0 {
+		return nil, fmt.Errorf("no .json or .jsonl files in %s", path)
+	}
+
+	return s, nil
+}
+
+// Name returns the source name of replayed data; recordings are CMB payloads
+func (s *ReplaySource) Name() string {
+	return SourceCMB
+}
+
+// FetchRates extracts the quotes of the next recorded payload
+// Returns ErrReplayDone once every record has been read
+func (s *ReplaySource) FetchRates(ctx context.Context) ([]CurrencyRate, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	record, err := s.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return ExtractRates(record.Response)
+}
+
+// Next returns the next recorded payload, or ErrReplayDone at the end
+func (s *ReplaySource) Next() (*ReplayRecord, error) {
+	for {
+		if s.scanner != nil {
+			record, err := s.nextLine()
+			if !errors.Is(err, io.EOF) {
+				return record, err
+			}
+			s.closeStream()
+			continue
+		}
+
+		if len(s.files) == 0 {
+			return nil, ErrReplayDone
+		}
+
+		path := s.files[0]
+		s.files = s.files[1:]
+		s.logger.Debug("replaying file", "path", path)
+
+		if strings.HasSuffix(path, ".jsonl") {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, fmt.Errorf("opening %s: %w", path, err)
+			}
+			s.openStream(path, f)
+			continue
+		}
+
+		return readReplayFile(path)
+	}
+}
+
+// Close releases any open stream
+func (s *ReplaySource) Close() error {
+	s.closeStream()
+	return nil
+}
+
+func (s *ReplaySource) openStream(name string, r io.ReadCloser) {
+	s.scanner = bufio.NewScanner(r)
+	s.scanner.Buffer(make([]byte, 64*1024), maxReplayLine)
+	s.stream = r
+	s.current = name
+	s.line = 0
+}
+
+func (s *ReplaySource) closeStream() {
+	if s.stream != nil {
+		s.stream.Close()
+	}
+	s.scanner = nil
+	s.stream = nil
+}
+
+// nextLine decodes the next non-blank JSONL line, returning io.EOF at the end
+func (s *ReplaySource) nextLine() (*ReplayRecord, error) {
+	for s.scanner.Scan() {
+		s.line++
+		data := strings.TrimSpace(s.scanner.Text())
+		if data == "" {
+			continue
+		}
+
+		var line replayLine
+		if err := json.Unmarshal([]byte(data), &line); err != nil {
+			return nil, fmt.Errorf("%s line %d: decoding record: %w", s.current, s.line, err)
+		}
+
+		if line.Response == nil {
+			var resp CMBResponse
+			if err := json.Unmarshal([]byte(data), &resp); err != nil {
+				return nil, fmt.Errorf("%s line %d: decoding response: %w", s.current, s.line, err)
+			}
+			line.Response = &resp
+		}
+
+		record := &ReplayRecord{CollectedAt: line.CollectedAt, Response: line.Response}
+		if record.CollectedAt.IsZero() {
+			record.CollectedAt = bodyTime(record.Response)
+		}
+		return record, nil
+	}
+
+	if err := s.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", s.current, err)
+	}
+	return nil, io.EOF
+}
+
+// readReplayFile reads a single recorded payload; its collection time comes
+// from a timestamp in the file name, the payload's body time or the file's
+// modification time, in that order
+func readReplayFile(path string) (*ReplayRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var resp CMBResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+
+	record := &ReplayRecord{Response: &resp}
+	if t, ok := fileTimestamp(filepath.Base(path)); ok {
+		record.CollectedAt = t
+	} else if t := bodyTime(&resp); !t.IsZero() {
+		record.CollectedAt = t
+	} else if info, err := os.Stat(path); err == nil {
+		record.CollectedAt = info.ModTime()
+	}
+
+	return record, nil
+}
+
+// fileTimestamp parses a timestamp embedded in a file name (local time)
+func fileTimestamp(name string) (time.Time, bool) {
+	m := fileStampPattern.FindStringSubmatch(name)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	t, err := time.ParseInLocation("20060102150405", strings.Join(m[1:], ""), time.Local)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// bodyTime parses the response's body time ("2025-11-25 20:11"), zero if absent
+func bodyTime(resp *CMBResponse) time.Time {
+	if resp.Body == nil || resp.Body.Time == "" {
+		return time.Time{}
+	}
+
+	t, err := time.ParseInLocation("2006-01-02 15:04", resp.Body.Time, quoteLocation)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
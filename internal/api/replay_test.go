@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+func readFixture(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../fixtures/sample-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReplaySourceFile(t *testing.T) {
+	s, err := NewReplaySource("../../fixtures/sample-data.json", testLogger())
+	if err != nil {
+		t.Fatalf("NewReplaySource() error = %v", err)
+	}
+
+	record, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+
+	// No timestamp in the file name: falls back to the body time
+	want := time.Date(2025, 11, 25, 20, 11, 0, 0, quoteLocation)
+	if !record.CollectedAt.Equal(want) {
+		t.Errorf("CollectedAt = %v, want %v", record.CollectedAt, want)
+	}
+
+	if _, err := s.Next(); !errors.Is(err, ErrReplayDone) {
+		t.Errorf("Next() after last record error = %v, want ErrReplayDone", err)
+	}
+}
+
+func TestReplaySourceDirectory(t *testing.T) {
+	dir := t.TempDir()
+	fixture := readFixture(t)
+	for _, name := range []string{"cmb-20251125T201200.json", "cmb-20251125T201100.json", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), fixture, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := NewReplaySource(dir, testLogger())
+	if err != nil {
+		t.Fatalf("NewReplaySource() error = %v", err)
+	}
+
+	var times []time.Time
+	for {
+		record, err := s.Next()
+		if errors.Is(err, ErrReplayDone) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		times = append(times, record.CollectedAt)
+	}
+
+	if len(times) != 2 {
+		t.Fatalf("replayed %d files, want 2", len(times))
+	}
+	first := time.Date(2025, 11, 25, 20, 11, 0, 0, time.Local)
+	if !times[0].Equal(first) || !times[1].Equal(first.Add(time.Minute)) {
+		t.Errorf("times = %v, want files in timestamp order", times)
+	}
+}
+
+func TestReplaySourceJSONL(t *testing.T) {
+	var resp CMBResponse
+	if err := json.Unmarshal(readFixture(t), &resp); err != nil {
+		t.Fatal(err)
+	}
+	bare, _ := json.Marshal(resp)
+	collectedAt := time.Date(2025, 11, 26, 9, 0, 0, 0, time.UTC)
+	wrapped, _ := json.Marshal(replayLine{CollectedAt: collectedAt, Response: &resp})
+
+	path := filepath.Join(t.TempDir(), "archive.jsonl")
+	content := strings.Join([]string{string(wrapped), "", string(bare)}, "\n")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewReplaySource(path, testLogger())
+	if err != nil {
+		t.Fatalf("NewReplaySource() error = %v", err)
+	}
+	defer s.Close()
+
+	rates, err := s.FetchRates(t.Context())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if usd := findRate(t, rates, "USD"); usd.RtcBid != fixed.MustParse("7.0749") {
+		t.Errorf("USD RtcBid = %v, want 7.0749", usd.RtcBid)
+	}
+
+	record, err := s.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if record.CollectedAt.IsZero() {
+		t.Error("bare response should fall back to its body time")
+	}
+
+	if _, err := s.FetchRates(t.Context()); !errors.Is(err, ErrReplayDone) {
+		t.Errorf("FetchRates() at end error = %v, want ErrReplayDone", err)
+	}
+}
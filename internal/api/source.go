@@ -0,0 +1,145 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Source names used to tag stored rates
+const (
+	SourceCMB  = "cmb"
+	SourceBOC  = "boc"
+	SourceICBC = "icbc"
+)
+
+// RateSource is a provider of current bank exchange rates
+type RateSource interface {
+	// Name identifies the source (e.g., "cmb") and is stored with each rate
+	Name() string
+	// FetchRates retrieves the latest quote for every currency the source publishes
+	FetchRates(ctx context.Context) ([]CurrencyRate, error)
+}
+
+// RawSource is implemented by sources whose raw payloads can be archived
+// and parsed again later
+type RawSource interface {
+	RateSource
+	// FetchRaw retrieves the latest payload without extracting rates
+	FetchRaw(ctx context.Context) ([]byte, error)
+	// ParseRates extracts every recognized currency from a raw payload
+	ParseRates(body []byte) ([]CurrencyRate, error)
+}
+
+// HealthReporter is implemented by sources guarded by a circuit breaker
+type HealthReporter interface {
+	Health() BreakerHealth
+}
+
+// ParseRaw extracts rates from a raw payload recorded from the named source
+func ParseRaw(source string, body []byte) ([]CurrencyRate, error) {
+	switch source {
+	case SourceCMB:
+		return parseCMBPayload(body)
+	case SourceBOC:
+		return parseBOCBoard(string(body))
+	case SourceICBC:
+		return parseICBCPayload(body)
+	default:
+		return nil, fmt.Errorf("unknown rate source %q", source)
+	}
+}
+
+// NewSource creates the rate source registered under a name
+func NewSource(name string, logger *slog.Logger) (RateSource, error) {
+	switch name {
+	case SourceCMB:
+		return NewClient(logger), nil
+	case SourceBOC:
+		return NewBOCClient(logger), nil
+	case SourceICBC:
+		return NewICBCClient(logger), nil
+	default:
+		return nil, fmt.Errorf("unknown rate source %q (supported: %s, %s, %s)", name, SourceCMB, SourceBOC, SourceICBC)
+	}
+}
+
+// Name returns the source name of the CMB client
+func (c *Client) Name() string {
+	return SourceCMB
+}
+
+// FetchRates retrieves and extracts the latest CMB quotes
+func (c *Client) FetchRates(ctx context.Context) ([]CurrencyRate, error) {
+	resp, err := c.FetchExchangeRates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExtractRates(resp)
+}
+
+// FetchRaw retrieves the latest CMB response body with retry logic
+func (c *Client) FetchRaw(ctx context.Context) ([]byte, error) {
+	_, body, err := c.fetchWithRetry(ctx)
+	return body, err
+}
+
+// ParseRates extracts the quotes of a raw CMB response body
+func (c *Client) ParseRates(body []byte) ([]CurrencyRate, error) {
+	return parseCMBPayload(body)
+}
+
+// parseCMBPayload decodes a CMB response body and extracts its quotes
+func parseCMBPayload(body []byte) ([]CurrencyRate, error) {
+	var resp CMBResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("decoding response: %w", err)
+	}
+
+	return ExtractRates(&resp)
+}
+
+// fetchURL performs a single GET request and returns the response body,
+// reporting failures as NetworkError or HTTPError like the CMB client
+// headers are set after the defaults and may override them
+func fetchURL(ctx context.Context, httpClient *http.Client, url, accept string, headers http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", "USD-Buy-Rate-Monitor/1.0")
+	req.Header.Set("Accept", accept)
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+
+	startTime := time.Now()
+	resp, err := httpClient.Do(req)
+	elapsed := time.Since(startTime)
+
+	if err != nil {
+		return nil, &NetworkError{Err: err, Duration: elapsed}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &NetworkError{Err: err, Duration: time.Since(startTime)}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HTTPError{
+			StatusCode: resp.StatusCode,
+			Body:       string(body),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return body, nil
+}
@@ -0,0 +1,232 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func serve(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func findRate(t *testing.T, rates []CurrencyRate, currency string) CurrencyRate {
+	t.Helper()
+	for _, r := range rates {
+		if r.Currency == currency {
+			return r
+		}
+	}
+	t.Fatalf("%s not found in %d rates", currency, len(rates))
+	return CurrencyRate{}
+}
+
+func TestCMBClientFetchRates(t *testing.T) {
+	fixture, err := os.ReadFile("../../fixtures/sample-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := serve(t, http.StatusOK, string(fixture))
+
+	c := NewClient(testLogger(), WithBaseURL(srv.URL))
+
+	rates, err := c.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+
+	usd := findRate(t, rates, "USD")
+	if usd.RtcBid != fixed.MustParse("7.0749") {
+		t.Errorf("USD RtcBid = %v, want 7.0749", usd.RtcBid)
+	}
+	// JPY is quoted per 100 yen like every other currency: 4.5124 -> 0.045124
+	if jpy := findRate(t, rates, "JPY"); jpy.RtcBid != fixed.MustParse("0.045124") {
+		t.Errorf("JPY RtcBid = %v, want 0.045124", jpy.RtcBid)
+	}
+	if c.Name() != SourceCMB {
+		t.Errorf("Name() = %q, want %q", c.Name(), SourceCMB)
+	}
+}
+
+func TestExtractUSDRateUnknownUnit(t *testing.T) {
+	resp := &CMBResponse{ReturnCode: "SUC0000", Body: &CMBBody{Data: []CMBCurrencyRate{
+		{CcyNbr: "美元", RtcBid: "7.0749", CcyExc: "1"},
+	}}}
+	if _, err := ExtractUSDRate(resp); err == nil {
+		t.Error("ExtractUSDRate() should reject an unknown ccyExc instead of guessing the unit")
+	}
+}
+
+const bocBoard = `<html><body><table>
+<tr>
+	<th>货币名称</th><th>现汇买入价</th><th>现钞买入价</th><th>现汇卖出价</th>
+	<th>现钞卖出价</th><th>中行折算价</th><th>发布日期</th><th>发布时间</th>
+</tr>
+<tr>
+	<td>美元</td>
+	<td>709.86</td>
+	<td>704.10</td>
+	<td>712.84</td>
+	<td>712.84</td>
+	<td>708.83</td>
+	<td class="pjrq">2025.11.25</td>
+	<td class="pjrq">20:11:02</td>
+</tr>
+<tr>
+	<td>巴西里亚尔</td>
+	<td></td><td>125.61</td><td></td><td>150.07</td><td>132.14</td>
+	<td class="pjrq">2025.11.25</td><td class="pjrq">20:11:02</td>
+</tr>
+<tr>
+	<td>韩国元</td>
+	<td>0.4826</td><td>0.4656</td><td>0.4866</td><td>0.5042</td><td>0.4840</td>
+	<td class="pjrq">2025.11.25 20:11:02</td>
+</tr>
+</table></body></html>`
+
+func TestBOCClientFetchRates(t *testing.T) {
+	srv := serve(t, http.StatusOK, bocBoard)
+
+	c := NewBOCClient(testLogger())
+	c.baseURL = srv.URL
+
+	rates, err := c.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if len(rates) != 2 {
+		t.Fatalf("got %d rates, want 2 (unknown currencies skipped)", len(rates))
+	}
+
+	usd := findRate(t, rates, "USD")
+	want := CurrencyRate{
+		Currency: "USD",
+		RthBid:   fixed.MustParse("7.0986"),
+		RtcBid:   fixed.MustParse("7.041"),
+		RthOfr:   fixed.MustParse("7.1284"),
+		RtcOfr:   fixed.MustParse("7.1284"),
+		RtbBid:   fixed.MustParse("7.0883"),
+		QuotedAt: time.Date(2025, 11, 25, 20, 11, 2, 0, quoteLocation),
+	}
+	if !usd.QuotedAt.Equal(want.QuotedAt) {
+		t.Errorf("QuotedAt = %v, want %v", usd.QuotedAt, want.QuotedAt)
+	}
+	usd.QuotedAt = want.QuotedAt
+	if usd != want {
+		t.Errorf("USD = %+v, want %+v", usd, want)
+	}
+
+	krw := findRate(t, rates, "KRW")
+	if krw.QuotedAt.IsZero() {
+		t.Error("combined date and time cell should be parsed")
+	}
+}
+
+const icbcLatest = `{
+	"code": 0,
+	"message": "success",
+	"data": [
+		{
+			"currencyENName": "USD",
+			"currencyCHName": "美元",
+			"reference": "708.83",
+			"foreignBuy": "709.51",
+			"foreignSell": "712.49",
+			"cashBuy": "709.51",
+			"cashSell": "712.49",
+			"publishDate": "2025-11-25",
+			"publishTime": "20:11:02"
+		},
+		{
+			"currencyENName": "XAU",
+			"currencyCHName": "黄金",
+			"reference": "--",
+			"foreignBuy": "--",
+			"foreignSell": "--",
+			"cashBuy": "--",
+			"cashSell": "--",
+			"publishDate": "2025-11-25",
+			"publishTime": "20:11:02"
+		}
+	]
+}`
+
+func TestICBCClientFetchRates(t *testing.T) {
+	srv := serve(t, http.StatusOK, icbcLatest)
+
+	c := NewICBCClient(testLogger())
+	c.baseURL = srv.URL
+
+	rates, err := c.FetchRates(context.Background())
+	if err != nil {
+		t.Fatalf("FetchRates() error = %v", err)
+	}
+	if len(rates) != 1 {
+		t.Fatalf("got %d rates, want 1", len(rates))
+	}
+
+	usd := findRate(t, rates, "USD")
+	if usd.RtcBid != fixed.MustParse("7.0951") || usd.RthOfr != fixed.MustParse("7.1249") || usd.RtbBid != fixed.MustParse("7.0883") {
+		t.Errorf("USD = %+v", usd)
+	}
+	if !usd.QuotedAt.Equal(time.Date(2025, 11, 25, 20, 11, 2, 0, quoteLocation)) {
+		t.Errorf("QuotedAt = %v", usd.QuotedAt)
+	}
+}
+
+func TestSourceHTTPError(t *testing.T) {
+	srv := serve(t, http.StatusServiceUnavailable, "maintenance")
+
+	sources := []struct {
+		name   string
+		source RateSource
+	}{
+		{SourceBOC, &BOCClient{httpClient: srv.Client(), baseURL: srv.URL, logger: testLogger()}},
+		{SourceICBC, &ICBCClient{httpClient: srv.Client(), baseURL: srv.URL, logger: testLogger()}},
+	}
+
+	for _, tt := range sources {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.source.FetchRates(context.Background())
+
+			var httpErr *HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("FetchRates() error = %v, want HTTP 503", err)
+			}
+		})
+	}
+}
+
+func TestNewSource(t *testing.T) {
+	for _, name := range []string{SourceCMB, SourceBOC, SourceICBC} {
+		source, err := NewSource(name, testLogger())
+		if err != nil {
+			t.Fatalf("NewSource(%q) error = %v", name, err)
+		}
+		if source.Name() != name {
+			t.Errorf("NewSource(%q).Name() = %q", name, source.Name())
+		}
+	}
+
+	if _, err := NewSource("abc", testLogger()); err == nil {
+		t.Error("unknown source should fail")
+	}
+}
@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+	"github.com/qiushi1511/usd-buy-rate-monitor/pkg/chart"
+)
+
+// annotationLabels label annotations in reports and under charts, in order
+const annotationLabels = "123456789abcdefghijklmnopqrstuvwxyz"
+
+// currencyCode matches the three-letter codes currencies are stored under
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// AnnotateCommand adds, lists and removes annotations
+type AnnotateCommand struct {
+	repo   storage.Annotator
+	logger *slog.Logger
+}
+
+// NewAnnotateCommand creates a new annotate command handler
+func NewAnnotateCommand(repo storage.Annotator, logger *slog.Logger) *AnnotateCommand {
+	return &AnnotateCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Add pins a note to a time, or to a range when until is set, read in the
+// display zone. An empty currency pins it to every currency
+func (c *AnnotateCommand) Add(ctx context.Context, currency, at, until, note string) error {
+	now := time.Now().In(displayZone)
+	a := &storage.Annotation{CurrencyCode: strings.ToUpper(currency), Note: strings.TrimSpace(note)}
+	if a.CurrencyCode != "" && !currencyCode.MatchString(a.CurrencyCode) {
+		return fmt.Errorf("invalid currency %q", currency)
+	}
+
+	var err error
+	if a.StartsAt, err = parseDisplayTime(at, now); err != nil {
+		return err
+	}
+	if until != "" {
+		if a.EndsAt, err = parseDisplayTime(until, now); err != nil {
+			return err
+		}
+	}
+
+	if err := c.repo.AddAnnotation(ctx, a); err != nil {
+		return fmt.Errorf("adding annotation: %w", err)
+	}
+
+	c.logger.Info("added annotation", "id", a.ID, "currency", a.CurrencyCode, "starts_at", a.StartsAt)
+	fmt.Printf("✅ Added annotation %d: %s  %s\n", a.ID, annotationSpan(*a), a.Note)
+	return nil
+}
+
+// List shows the annotations of a currency (empty: every currency) that
+// overlap start to end
+func (c *AnnotateCommand) List(ctx context.Context, currency string, start, end time.Time) error {
+	notes, err := c.repo.GetAnnotations(ctx, strings.ToUpper(currency), start, end)
+	if err != nil {
+		return fmt.Errorf("querying annotations: %w", err)
+	}
+
+	if len(notes) == 0 {
+		fmt.Printf("No annotations from %s to %s\n",
+			displayTime(start).Format("2006-01-02 15:04"),
+			displayTime(end).Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Annotations\n")
+	fmt.Printf("═══════════\n")
+	fmt.Printf("Period: %s to %s (%s)\n",
+		displayTime(start).Format("2006-01-02 15:04"),
+		displayTime(end).Format("2006-01-02 15:04"),
+		displayZone)
+	fmt.Printf("\n")
+
+	fmt.Printf("%5s  %-8s  %-35s  %s\n", "ID", "Currency", "Time", "Note")
+	fmt.Printf("%s\n", strings.Repeat("─", 78))
+	for _, a := range notes {
+		currency := a.CurrencyCode
+		if currency == "" {
+			currency = "all"
+		}
+		fmt.Printf("%5d  %-8s  %-35s  %s\n", a.ID, currency, annotationSpan(a), a.Note)
+	}
+	fmt.Printf("\n")
+
+	return nil
+}
+
+// Remove deletes an annotation by ID
+func (c *AnnotateCommand) Remove(ctx context.Context, id int64) error {
+	if err := c.repo.DeleteAnnotation(ctx, id); err != nil {
+		return err
+	}
+
+	c.logger.Info("removed annotation", "id", id)
+	fmt.Printf("✅ Removed annotation %d\n", id)
+	return nil
+}
+
+// annotationLabel returns the label of the i-th annotation of a report
+func annotationLabel(i int) string {
+	if i < len(annotationLabels) {
+		return annotationLabels[i : i+1]
+	}
+	return "*"
+}
+
+// annotationSpan formats when an annotation applies, in the display zone
+func annotationSpan(a storage.Annotation) string {
+	start := displayTime(a.StartsAt)
+	if a.EndsAt.IsZero() {
+		return start.Format("2006-01-02 15:04")
+	}
+	end := displayTime(a.EndsAt)
+	if end.Format("2006-01-02") == start.Format("2006-01-02") {
+		return start.Format("2006-01-02 15:04") + "–" + end.Format("15:04")
+	}
+	return start.Format("2006-01-02 15:04") + " – " + end.Format("2006-01-02 15:04")
+}
+
+// formatAnnotation formats the i-th annotation of a report for a line of
+// its own
+func formatAnnotation(i int, a storage.Annotation) string {
+	return fmt.Sprintf("📌 [%s] %s  %s", annotationLabel(i), annotationSpan(a), a.Note)
+}
+
+// printAnnotations lists a report's annotations with their labels
+func printAnnotations(notes []storage.Annotation) {
+	if len(notes) == 0 {
+		return
+	}
+	fmt.Printf("Annotations (%s):\n", displayZone)
+	for i, a := range notes {
+		fmt.Printf("  %s\n", formatAnnotation(i, a))
+	}
+	fmt.Printf("\n")
+}
+
+// printDateAnnotations lists the annotations of a date, by index, under it
+func printDateAnnotations(notes []storage.Annotation, indexes []int) {
+	for _, i := range indexes {
+		fmt.Printf("  %s\n", formatAnnotation(i, notes[i]))
+	}
+}
+
+// annotationMarkers marks each annotation under the last chart point at or
+// before its start (the first point when it starts earlier)
+func annotationMarkers(notes []storage.Annotation, times []time.Time) []chart.Marker {
+	var markers []chart.Marker
+	for i, a := range notes {
+		index := 0
+		for j, t := range times {
+			if t.After(a.StartsAt) {
+				break
+			}
+			index = j
+		}
+		markers = append(markers, chart.Marker{Index: index, Label: annotationLabel(i)})
+	}
+	return markers
+}
+
+// dateAnnotations reads the annotations of a currency overlapping a set of
+// market dates in one query. It returns them in time order with, per date,
+// the indexes of those overlapping it
+func dateAnnotations(ctx context.Context, repo storage.RateReader, currency string, dates []string) ([]storage.Annotation, map[string][]int, error) {
+	if len(dates) == 0 {
+		return nil, nil, nil
+	}
+
+	type span struct{ start, end time.Time }
+	days := make(map[string]span, len(dates))
+	first, last := time.Time{}, time.Time{}
+	for _, date := range dates {
+		start, err := time.ParseInLocation("2006-01-02", date, market.Zone)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing date: %w", err)
+		}
+		end := start.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		days[date] = span{start, end}
+		if first.IsZero() || start.Before(first) {
+			first = start
+		}
+		if end.After(last) {
+			last = end
+		}
+	}
+
+	notes, err := repo.GetAnnotations(ctx, currency, first, last)
+	if err != nil {
+		return nil, nil, fmt.Errorf("querying annotations: %w", err)
+	}
+
+	byDate := make(map[string][]int)
+	for date, day := range days {
+		for i, a := range notes {
+			if a.Overlaps(day.start, day.end) {
+				byDate[date] = append(byDate[date], i)
+			}
+		}
+	}
+	return notes, byDate, nil
+}
+
+// noteLabels joins the labels of annotations by index, e.g. "1,3"
+func noteLabels(indexes []int) string {
+	labels := make([]string, len(indexes))
+	for i, index := range indexes {
+		labels[i] = annotationLabel(index)
+	}
+	return strings.Join(labels, ",")
+}
@@ -5,58 +5,70 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 	"github.com/qiushi1511/usd-buy-rate-monitor/pkg/chart"
 )
 
 // AverageCommand handles the average command functionality
 type AverageCommand struct {
-	repo   *storage.Repository
+	repo   storage.RateReader
 	logger *slog.Logger
 }
 
 // NewAverageCommand creates a new average command handler
-func NewAverageCommand(repo *storage.Repository, logger *slog.Logger) *AverageCommand {
+// A store that sees every source is scoped to the default one
+func NewAverageCommand(repo storage.RateReader, logger *slog.Logger) *AverageCommand {
 	return &AverageCommand{
-		repo:   repo,
+		repo:   singleSource(repo),
 		logger: logger,
 	}
 }
 
-// DisplayAverage shows the daily average exchange rate
-func (a *AverageCommand) DisplayAverage(ctx context.Context, dates []string, compare bool, showChart bool) error {
+// DisplayAverage shows the daily average exchange rate of a currency
+func (a *AverageCommand) DisplayAverage(ctx context.Context, currency string, dates []string, compare bool, showChart bool) error {
 	if len(dates) == 0 {
 		return fmt.Errorf("no dates specified")
 	}
 
+	notes, byDate, err := dateAnnotations(ctx, a.repo, currency, dates)
+	if err != nil {
+		return err
+	}
+
 	var allStats []*storage.DailyStats
 
 	fmt.Printf("\n")
-	fmt.Printf("Daily Average Exchange Rates\n")
-	fmt.Printf("════════════════════════════\n")
+	fmt.Printf("Daily Average %s/CNY Exchange Rates\n", currency)
+	fmt.Printf("════════════════════════════════════\n")
 	fmt.Printf("\n")
 
 	for _, date := range dates {
-		stats, err := a.repo.GetDailyStats(ctx, date)
+		stats, err := a.repo.GetDailyStats(ctx, currency, date)
 		if err != nil {
 			return fmt.Errorf("getting stats for %s: %w", date, err)
 		}
 
 		if stats.SampleCount == 0 {
-			fmt.Printf("%-12s  No data available\n\n", date)
+			fmt.Printf("%-12s  No data available\n", date)
+			printDateAnnotations(notes, byDate[date])
+			fmt.Printf("\n")
 			continue
 		}
 
 		allStats = append(allStats, stats)
 
 		fmt.Printf("%-12s\n", date)
-		fmt.Printf("  Average:     %.4f CNY\n", stats.AvgRate)
-		fmt.Printf("  Min:         %.4f CNY\n", stats.MinRate)
-		fmt.Printf("  Max:         %.4f CNY\n", stats.MaxRate)
-		fmt.Printf("  Peak Time:   %s\n", stats.PeakTime.Format("15:04:05"))
+		fmt.Printf("  Average:     %s CNY\n", stats.AvgRate)
+		fmt.Printf("  Min:         %s CNY\n", stats.MinRate)
+		fmt.Printf("  Max:         %s CNY\n", stats.MaxRate)
+		fmt.Printf("  Peak Time:   %s\n", displayTime(stats.PeakTime).Format("15:04:05"))
 		fmt.Printf("  Samples:     %d\n", stats.SampleCount)
-		fmt.Printf("  Volatility:  %.4f CNY\n", stats.MaxRate-stats.MinRate)
+		fmt.Printf("  Volatility:  %s CNY\n", stats.MaxRate-stats.MinRate)
+		printDateAnnotations(notes, byDate[date])
 		fmt.Printf("\n")
 	}
 
@@ -67,68 +79,90 @@ func (a *AverageCommand) DisplayAverage(ctx context.Context, dates []string, com
 
 	// Display charts if requested and we have data
 	if showChart && len(allStats) > 0 {
-		a.displayCharts(allStats)
+		a.displayCharts(allStats, notes, byDate)
 	}
 
 	return nil
 }
 
-func (a *AverageCommand) displayCharts(stats []*storage.DailyStats) {
+// displayCharts charts daily averages and volatility, marking each day's
+// annotations under it
+func (a *AverageCommand) displayCharts(stats []*storage.DailyStats, notes []storage.Annotation, byDate map[string][]int) {
 	width, height := chart.GetTerminalDimensions()
 
+	var markers []chart.Marker
+	for i, s := range stats {
+		for _, note := range byDate[s.Date] {
+			markers = append(markers, chart.Marker{Index: i, Label: annotationLabel(note)})
+		}
+	}
+
 	// Average rate trend chart
 	fmt.Printf("\n")
-	fmt.Println(chart.RenderDailyAverageChart(stats, width, height))
+	fmt.Println(chart.AddMarkers(chart.RenderDailyAverageChart(stats, width, height), len(stats), width, markers))
 	fmt.Printf("\n")
 
 	// Volatility chart
 	if len(stats) > 1 {
-		fmt.Println(chart.RenderVolatilityChart(stats, width, height))
+		fmt.Println(chart.AddMarkers(chart.RenderVolatilityChart(stats, width, height), len(stats), width, markers))
 		fmt.Printf("\n")
 	}
+	printAnnotations(notes)
 }
 
-// DisplayAverageRange shows average rates for a range of recent days
-func (a *AverageCommand) DisplayAverageRange(ctx context.Context, days int, compare bool, showChart bool) error {
+// DisplayAverageRange shows average rates of a currency for a range of recent days
+func (a *AverageCommand) DisplayAverageRange(ctx context.Context, currency string, days int, compare bool, showChart bool) error {
+	if days <= 0 {
+		return fmt.Errorf("days must be positive")
+	}
 	dates := getRecentDates(days)
 
+	byDate, err := a.dailyStats(ctx, currency, dates[len(dates)-1])
+	if err != nil {
+		return err
+	}
+	notes, notesByDate, err := dateAnnotations(ctx, a.repo, currency, dates)
+	if err != nil {
+		return err
+	}
+
 	var allStats []*storage.DailyStats
 
 	fmt.Printf("\n")
-	fmt.Printf("Daily Average Exchange Rates (Last %d Days)\n", days)
-	fmt.Printf("═══════════════════════════════════════════\n")
+	fmt.Printf("Daily Average %s/CNY Exchange Rates (Last %d Days)\n", currency, days)
+	fmt.Printf("═══════════════════════════════════════════════════\n")
 	fmt.Printf("\n")
 
 	// Display table header
-	fmt.Printf("%-12s  %-10s  %-10s  %-10s  %-10s  %-8s\n",
-		"Date", "Average", "Min", "Max", "Volatility", "Samples")
-	fmt.Printf("%s\n", strings.Repeat("─", 75))
+	fmt.Printf("%-12s  %-10s  %-10s  %-10s  %-10s  %-8s  %s\n",
+		"Date", "Average", "Min", "Max", "Volatility", "Samples", "Notes")
+	fmt.Printf("%s\n", strings.Repeat("─", 82))
 
 	for _, date := range dates {
-		stats, err := a.repo.GetDailyStats(ctx, date)
-		if err != nil {
-			a.logger.Warn("failed to get stats", "date", date, "error", err)
-			continue
-		}
-
-		if stats.SampleCount == 0 {
-			fmt.Printf("%-12s  %-10s  %-10s  %-10s  %-10s  %-8s\n",
-				date, "No data", "-", "-", "-", "0")
+		stats, ok := byDate[date]
+		if !ok {
+			fmt.Printf("%-12s  %-10s  %-10s  %-10s  %-10s  %-8s  %s\n",
+				date, "No data", "-", "-", "-", "0", noteLabels(notesByDate[date]))
 			continue
 		}
 
 		allStats = append(allStats, stats)
 
-		fmt.Printf("%-12s  %10.4f  %10.4f  %10.4f  %10.4f  %8d\n",
+		fmt.Printf("%-12s  %10s  %10s  %10s  %10s  %8d  %s\n",
 			date,
 			stats.AvgRate,
 			stats.MinRate,
 			stats.MaxRate,
 			stats.MaxRate-stats.MinRate,
-			stats.SampleCount)
+			stats.SampleCount,
+			noteLabels(notesByDate[date]))
 	}
 
 	fmt.Printf("\n")
+	// Charts list the annotations under them
+	if !showChart || len(byDate) == 0 {
+		printAnnotations(notes)
+	}
 
 	// Display comparison if requested and we have data
 	if compare && len(allStats) > 0 {
@@ -137,12 +171,39 @@ func (a *AverageCommand) DisplayAverageRange(ctx context.Context, days int, comp
 
 	// Display charts if requested and we have data
 	if showChart && len(allStats) > 0 {
-		a.displayCharts(allStats)
+		a.displayCharts(allStats, notes, notesByDate)
 	}
 
 	return nil
 }
 
+// dailyStats reads a currency's daily statistics from a market date to
+// today in one query, keyed by date. Daily rollups are the tier retention
+// keeps longest, so long ranges keep their oldest days
+func (a *AverageCommand) dailyStats(ctx context.Context, currency, since string) (map[string]*storage.DailyStats, error) {
+	start, err := time.ParseInLocation("2006-01-02", since, market.Zone)
+	if err != nil {
+		return nil, fmt.Errorf("parsing date: %w", err)
+	}
+
+	points, err := a.repo.GetSeries(ctx, currency, start, time.Now(), storage.TierDaily)
+	if err != nil {
+		return nil, fmt.Errorf("querying daily rates: %w", err)
+	}
+
+	byDate := make(map[string]*storage.DailyStats)
+	for _, p := range points {
+		byDate[p.DatePartition] = &storage.DailyStats{
+			Date:        p.DatePartition,
+			MinRate:     p.MinRate,
+			MaxRate:     p.MaxRate,
+			AvgRate:     p.AvgRate,
+			SampleCount: p.SampleCount,
+		}
+	}
+	return byDate, nil
+}
+
 func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 	if len(allStats) == 0 {
 		return
@@ -153,13 +214,12 @@ func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 	fmt.Printf("\n")
 
 	// Calculate overall statistics
-	var totalAvg, totalMin, totalMax float64
-	totalMin = allStats[0].MinRate
-	totalMax = allStats[0].MaxRate
+	totalMin := allStats[0].MinRate
+	totalMax := allStats[0].MaxRate
 
-	var avgSum float64
+	avgs := make([]fixed.Rate, 0, len(allStats))
 	for _, stats := range allStats {
-		avgSum += stats.AvgRate
+		avgs = append(avgs, stats.AvgRate)
 		if stats.MinRate < totalMin {
 			totalMin = stats.MinRate
 		}
@@ -167,12 +227,12 @@ func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 			totalMax = stats.MaxRate
 		}
 	}
-	totalAvg = avgSum / float64(len(allStats))
+	totalAvg := fixed.Mean(avgs)
 
-	fmt.Printf("  Overall Average:    %.4f CNY\n", totalAvg)
-	fmt.Printf("  Absolute Minimum:   %.4f CNY\n", totalMin)
-	fmt.Printf("  Absolute Maximum:   %.4f CNY\n", totalMax)
-	fmt.Printf("  Total Range:        %.4f CNY\n", totalMax-totalMin)
+	fmt.Printf("  Overall Average:    %s CNY\n", totalAvg)
+	fmt.Printf("  Absolute Minimum:   %s CNY\n", totalMin)
+	fmt.Printf("  Absolute Maximum:   %s CNY\n", totalMax)
+	fmt.Printf("  Total Range:        %s CNY\n", totalMax-totalMin)
 	fmt.Printf("\n")
 
 	// Day-to-day changes
@@ -183,7 +243,7 @@ func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 			curr := allStats[i-1]
 
 			delta := curr.AvgRate - prev.AvgRate
-			deltaPercent := (delta / prev.AvgRate) * 100
+			deltaPercent := delta.Float64() / prev.AvgRate.Float64() * 100
 
 			symbol := "→"
 			trend := "stable"
@@ -195,7 +255,7 @@ func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 				trend = "down"
 			}
 
-			fmt.Printf("  %s → %s:  %s %.4f (%.2f%%) [%s]\n",
+			fmt.Printf("  %s → %s:  %s %s (%.2f%%) [%s]\n",
 				prev.Date,
 				curr.Date,
 				symbol,
@@ -207,14 +267,14 @@ func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 	}
 
 	// Volatility analysis
-	var totalVolatility float64
+	ranges := make([]fixed.Rate, 0, len(allStats))
 	for _, stats := range allStats {
-		totalVolatility += (stats.MaxRate - stats.MinRate)
+		ranges = append(ranges, stats.MaxRate-stats.MinRate)
 	}
-	avgVolatility := totalVolatility / float64(len(allStats))
+	avgVolatility := fixed.Mean(ranges)
 
 	fmt.Printf("Volatility Analysis:\n")
-	fmt.Printf("  Average Daily Range:  %.4f CNY\n", avgVolatility)
+	fmt.Printf("  Average Daily Range:  %s CNY\n", avgVolatility)
 
 	// Find most and least volatile days
 	var mostVolatile, leastVolatile *storage.DailyStats
@@ -229,12 +289,12 @@ func (a *AverageCommand) displayComparison(allStats []*storage.DailyStats) {
 	}
 
 	if mostVolatile != nil {
-		fmt.Printf("  Most Volatile Day:    %s (%.4f CNY range)\n",
+		fmt.Printf("  Most Volatile Day:    %s (%s CNY range)\n",
 			mostVolatile.Date,
 			mostVolatile.MaxRate-mostVolatile.MinRate)
 	}
 	if leastVolatile != nil {
-		fmt.Printf("  Least Volatile Day:   %s (%.4f CNY range)\n",
+		fmt.Printf("  Least Volatile Day:   %s (%s CNY range)\n",
 			leastVolatile.Date,
 			leastVolatile.MaxRate-leastVolatile.MinRate)
 	}
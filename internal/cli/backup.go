@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// BackupCommand takes, lists and verifies database snapshots
+type BackupCommand struct {
+	db     *storage.DB
+	logger *slog.Logger
+}
+
+// NewBackupCommand creates a new backup command handler
+func NewBackupCommand(db *storage.DB, logger *slog.Logger) *BackupCommand {
+	return &BackupCommand{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Run backs the database up to dest, or to a timestamped snapshot in dir
+// (keeping the newest keep snapshots there) when dest is empty
+func (c *BackupCommand) Run(ctx context.Context, dest, dir string, keep int) error {
+	var info *storage.BackupInfo
+	var err error
+	if dest != "" {
+		info, err = c.db.Backup(ctx, dest)
+	} else {
+		info, err = c.db.Snapshot(ctx, dir, keep)
+	}
+	if err != nil {
+		return fmt.Errorf("backing up: %w", err)
+	}
+
+	fmt.Printf("✅ Backed up to %s\n", info.Path)
+	printBackupInfo(info)
+	return nil
+}
+
+// Verify checks a snapshot's integrity
+func (c *BackupCommand) Verify(ctx context.Context, path string) error {
+	info, err := storage.VerifyBackup(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ %s passed the integrity check\n", path)
+	printBackupInfo(info)
+	return nil
+}
+
+// List shows the snapshots in dir, newest first
+func (c *BackupCommand) List(dir string) error {
+	snapshots, err := storage.ListBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(snapshots) == 0 {
+		fmt.Printf("No snapshots in %s\n", dir)
+		return nil
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Database Snapshots\n")
+	fmt.Printf("══════════════════\n")
+	fmt.Printf("Directory: %s\n", dir)
+	fmt.Printf("\n")
+
+	fmt.Printf("%-32s  %10s  %-20s\n", "File", "Size", "Written")
+	fmt.Printf("%s\n", strings.Repeat("─", 66))
+	for _, path := range snapshots {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("reading snapshot: %w", err)
+		}
+		fmt.Printf("%-32s  %10s  %-20s\n",
+			filepath.Base(path),
+			formatBytes(stat.Size()),
+			stat.ModTime().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("\n")
+
+	return nil
+}
+
+// printBackupInfo prints a snapshot summary
+func printBackupInfo(info *storage.BackupInfo) {
+	fmt.Printf("  Size:            %s\n", formatBytes(info.Size))
+	fmt.Printf("  Rates:           %d\n", info.Rates)
+	fmt.Printf("  Schema Version:  %d\n", info.SchemaVersion)
+}
+
+// formatBytes formats a file size in B, KB, MB or GB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMG"[exp])
+}
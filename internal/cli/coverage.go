@@ -0,0 +1,117 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/coverage"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// lowCoverage is the completeness under which a day is flagged: statistics
+// over it describe only part of the day
+const lowCoverage = 0.9
+
+// CoverageCommand compares logged polls against the polling schedule
+type CoverageCommand struct {
+	repo   storage.RateReader
+	logger *slog.Logger
+}
+
+// NewCoverageCommand creates a new coverage command handler
+func NewCoverageCommand(repo storage.RateReader, logger *slog.Logger) *CoverageCommand {
+	return &CoverageCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// analyzeCoverage measures how well logged polls cover a schedule over a period
+func analyzeCoverage(ctx context.Context, repo storage.RateReader, start, end time.Time, schedule coverage.Schedule, minGap time.Duration) (coverage.Report, error) {
+	polls, err := repo.GetPollTimes(ctx, start, end)
+	if err != nil {
+		return coverage.Report{}, fmt.Errorf("getting poll times: %w", err)
+	}
+	return coverage.Analyze(polls, start, end, schedule, minGap), nil
+}
+
+// Run reports per-day completeness and every gap longer than minGap between
+// start and end
+func (c *CoverageCommand) Run(ctx context.Context, start, end time.Time, schedule coverage.Schedule, minGap time.Duration) error {
+	report, err := analyzeCoverage(ctx, c.repo, start, end, schedule, minGap)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Days) == 0 {
+		fmt.Println("No polling window falls in this period.")
+		return nil
+	}
+
+	zone := schedule.Location.String()
+	fmt.Printf("\n")
+	fmt.Printf("Data Coverage\n")
+	fmt.Printf("═════════════\n")
+	fmt.Printf("Period:   %s to %s (%s)\n", report.Days[0].Date, report.Days[len(report.Days)-1].Date, zone)
+	fmt.Printf("Schedule: every %s, %s-%s\n", schedule.Interval, clock(schedule.Open), clock(schedule.Close))
+	fmt.Printf("Gaps:     over %s without a poll\n", minGap)
+	fmt.Printf("\n")
+
+	fmt.Printf("%-12s  %-11s  %7s  %8s  %5s  %9s\n", "Date", "Window", "Polls", "Missing", "Gaps", "Coverage")
+	fmt.Printf("%s\n", strings.Repeat("─", 62))
+
+	low := 0
+	for _, day := range report.Days {
+		flag := ""
+		if day.Completeness() < lowCoverage {
+			flag = "  ⚠️"
+			low++
+		}
+		midnight, _ := time.ParseInLocation("2006-01-02", day.Date, schedule.Location)
+		fmt.Printf("%-12s  %s-%s  %7d  %8s  %5d  %8.1f%%%s\n",
+			day.Date,
+			clock(day.Open.Sub(midnight)),
+			clock(day.Close.Sub(midnight)),
+			day.Polls,
+			formatDuration(day.Missing),
+			len(day.Gaps),
+			day.Completeness()*100,
+			flag)
+	}
+	fmt.Printf("%s\n", strings.Repeat("─", 62))
+	fmt.Printf("%-12s  %-11s  %7s  %8s  %5d  %8.1f%%\n", "Total", "", "", "", len(report.Gaps()), report.Completeness()*100)
+	fmt.Printf("\n")
+
+	if gaps := report.Gaps(); len(gaps) > 0 {
+		fmt.Printf("Gaps (%s):\n", displayZone)
+		printGaps(gaps)
+		fmt.Printf("\n")
+	}
+
+	if low > 0 {
+		fmt.Printf("⚠️  %d of %d days are under %.0f%% covered; daily stats and patterns over them\n", low, len(report.Days), lowCoverage*100)
+		fmt.Printf("   describe only part of the day\n")
+	} else {
+		fmt.Printf("✅ Every day is at least %.0f%% covered\n", lowCoverage*100)
+	}
+
+	return nil
+}
+
+// printGaps lists gaps with their start, end and duration in the display zone
+func printGaps(gaps []coverage.Gap) {
+	for _, gap := range gaps {
+		fmt.Printf("  %s → %s  %s\n",
+			displayTime(gap.Start).Format("2006-01-02 15:04"),
+			displayTime(gap.End).Format("2006-01-02 15:04"),
+			formatDuration(gap.Duration()))
+	}
+}
+
+// clock formats an offset from midnight as HH:MM
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// DedupeCommand removes repeated quotes stored before inserts were idempotent
+type DedupeCommand struct {
+	repo   *storage.Repository
+	logger *slog.Logger
+}
+
+// NewDedupeCommand creates a new dedupe command handler
+func NewDedupeCommand(repo *storage.Repository, logger *slog.Logger) *DedupeCommand {
+	return &DedupeCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Run finds rows sharing a natural key (currency, source and observation
+// time) and, unless dryRun is set, collapses each group into its first row
+// with the prices of its last, reporting the groups by date
+func (c *DedupeCommand) Run(ctx context.Context, dryRun bool) error {
+	var groups []storage.DuplicateGroup
+	var deleted int64
+	var err error
+	if dryRun {
+		groups, err = c.repo.FindDuplicates(ctx)
+	} else {
+		groups, deleted, err = c.repo.RemoveDuplicates(ctx)
+	}
+	if err != nil {
+		return fmt.Errorf("deduplicating rates: %w", err)
+	}
+
+	if len(groups) == 0 {
+		fmt.Println("No duplicate quotes found.")
+		return nil
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Duplicate Quotes\n")
+	fmt.Printf("════════════════\n")
+	if dryRun {
+		fmt.Printf("DRY RUN MODE - No actual changes will be made\n")
+	}
+	fmt.Printf("\n")
+
+	fmt.Printf("%-12s  %-8s  %-8s  %8s  %10s  %11s\n", "Date", "Source", "Currency", "Quotes", "Extra Rows", "Conflicting")
+	fmt.Printf("%s\n", strings.Repeat("─", 66))
+
+	// Groups come oldest first; summarize them per date, source and currency
+	type row struct {
+		date, source, currency     string
+		quotes, extra, conflicting int64
+	}
+	var rows []*row
+	index := make(map[string]*row)
+	var total row
+	for _, g := range groups {
+		key := g.DatePartition + "/" + g.Source + "/" + g.CurrencyCode
+		r := index[key]
+		if r == nil {
+			r = &row{date: g.DatePartition, source: g.Source, currency: g.CurrencyCode}
+			index[key] = r
+			rows = append(rows, r)
+		}
+
+		for _, sum := range []*row{r, &total} {
+			sum.quotes++
+			sum.extra += g.Extra()
+			if g.Conflicting {
+				sum.conflicting++
+			}
+		}
+	}
+
+	for _, r := range rows {
+		fmt.Printf("%-12s  %-8s  %-8s  %8d  %10d  %11d\n", r.date, r.source, r.currency, r.quotes, r.extra, r.conflicting)
+	}
+	fmt.Printf("%s\n", strings.Repeat("─", 66))
+	fmt.Printf("%-12s  %-8s  %-8s  %8d  %10d  %11d\n", "Total", "", "", total.quotes, total.extra, total.conflicting)
+	fmt.Printf("\n")
+
+	if total.conflicting > 0 {
+		fmt.Printf("⚠️  %d quotes were stored with differing prices; the last stored prices are kept\n\n", total.conflicting)
+	}
+
+	if dryRun {
+		fmt.Println("Run without --dry-run to remove the extra rows.")
+	} else {
+		fmt.Printf("✅ Removed %d duplicate rows\n", deleted)
+	}
+
+	return nil
+}
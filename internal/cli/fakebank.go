@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fakebank"
+)
+
+// FakeBankCommand serves a simulated CMB rate API for local testing
+type FakeBankCommand struct {
+	logger *slog.Logger
+}
+
+// NewFakeBankCommand creates a new fake-bank command handler
+func NewFakeBankCommand(logger *slog.Logger) *FakeBankCommand {
+	return &FakeBankCommand{
+		logger: logger,
+	}
+}
+
+// Run serves the fake bank on addr (e.g., "127.0.0.1:8089") until ctx is cancelled
+func (c *FakeBankCommand) Run(ctx context.Context, addr string, config fakebank.Config) error {
+	bank := fakebank.NewServer(config, c.logger)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           bank,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	scenario := "random walk"
+	if config.Scenario != nil {
+		scenario = fmt.Sprintf("%s (%d steps)", config.Scenario.Name, len(config.Scenario.Steps))
+		if config.Scenario.Loop {
+			scenario += ", looping"
+		}
+	}
+
+	url := "http://" + addr + fakebank.Path
+	fmt.Printf("\n")
+	fmt.Printf("Fake CMB Server\n")
+	fmt.Printf("═══════════════\n")
+	fmt.Printf("  Endpoint:    %s\n", url)
+	fmt.Printf("  Scenario:    %s\n", scenario)
+	fmt.Printf("  Start Rate:  %s CNY\n", bank.Rate())
+	fmt.Printf("\n")
+	fmt.Printf("Point the daemon at it with:\n")
+	fmt.Printf("  ./ratemon daemon --api-url %s --no-business-hours -d /tmp/fake.db\n", url)
+	fmt.Printf("\n")
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serving fake bank: %w", err)
+	}
+
+	fmt.Printf("Served %d requests, final rate %s CNY\n", bank.Requests(), bank.Rate())
+	return nil
+}
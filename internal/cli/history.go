@@ -4,110 +4,204 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/coverage"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 	"github.com/qiushi1511/usd-buy-rate-monitor/pkg/chart"
 )
 
 // HistoryCommand handles the history command functionality
 type HistoryCommand struct {
-	repo   *storage.Repository
-	logger *slog.Logger
+	repo     storage.RateReader
+	logger   *slog.Logger
+	schedule *coverage.Schedule // Annotate charts with gaps in this schedule (nil: don't)
+	minGap   time.Duration
 }
 
 // NewHistoryCommand creates a new history command handler
-func NewHistoryCommand(repo *storage.Repository, logger *slog.Logger) *HistoryCommand {
+func NewHistoryCommand(repo storage.RateReader, logger *slog.Logger) *HistoryCommand {
 	return &HistoryCommand{
 		repo:   repo,
 		logger: logger,
 	}
 }
 
-// DisplayHistory shows exchange rates for a specific time range
-func (h *HistoryCommand) DisplayHistory(ctx context.Context, start, end time.Time, format string, showChart bool) error {
-	rates, err := h.repo.GetRatesByTimeRange(ctx, start, end)
+// WithGaps makes charts list the gaps in polling over the charted period
+func (h *HistoryCommand) WithGaps(schedule coverage.Schedule, minGap time.Duration) *HistoryCommand {
+	h.schedule = &schedule
+	h.minGap = minGap
+	return h
+}
+
+// DisplayHistory shows exchange rates of a currency for a specific time range
+// at a resolution; dates retention has thinned out are shown at the finest
+// tier left, and every point says which tier it came from
+func (h *HistoryCommand) DisplayHistory(ctx context.Context, currency string, start, end time.Time, resolution storage.Tier, format string, showChart bool) error {
+	rates, err := h.repo.GetSeries(ctx, currency, start, end, resolution)
 	if err != nil {
 		return fmt.Errorf("querying rates: %w", err)
 	}
 
 	if len(rates) == 0 {
 		fmt.Printf("No data found for the time range %s to %s\n",
-			start.Format("2006-01-02 15:04:05"),
-			end.Format("2006-01-02 15:04:05"))
+			displayTime(start).Format("2006-01-02 15:04:05"),
+			displayTime(end).Format("2006-01-02 15:04:05"))
 		return nil
 	}
 
+	// Exports stay importable; tables and charts show annotations
+	notes, err := h.repo.GetAnnotations(ctx, currency, start, end)
+	if err != nil {
+		return fmt.Errorf("querying annotations: %w", err)
+	}
+
 	switch format {
 	case "table":
-		h.displayTable(rates, start, end)
+		h.displayTable(rates, notes, start, end)
 	case "csv":
 		h.displayCSV(rates)
 	case "json":
 		h.displayJSON(rates)
 	case "chart":
-		h.displayChart(rates)
+		h.displayChart(ctx, rates, notes, start, end)
 		return nil
 	default:
-		h.displayTable(rates, start, end)
+		h.displayTable(rates, notes, start, end)
 	}
 
 	// Show chart after table if requested
 	if showChart && format != "chart" {
-		h.displayChart(rates)
+		h.displayChart(ctx, rates, notes, start, end)
 	}
 
 	return nil
 }
 
-func (h *HistoryCommand) displayChart(rates []storage.ExchangeRate) {
+func (h *HistoryCommand) displayChart(ctx context.Context, rates []storage.SeriesPoint, notes []storage.Annotation, start, end time.Time) {
+	// Each source gets a chart of its own, so the spread between banks
+	// doesn't plot as movement
+	sources, bySource := splitSources(rates)
 	width, height := chart.GetTerminalDimensions()
-	chart.PrintChartWithStats(rates, width, height)
+	for _, source := range sources {
+		points := bySource[source]
+
+		// The chart plots quotes, labelling its axis with their collection times
+		local := make([]storage.ExchangeRate, len(points))
+		times := make([]time.Time, len(points))
+		for i, rate := range points {
+			times[i] = rate.Time
+			local[i] = storage.ExchangeRate{
+				CurrencyCode: rate.CurrencyCode,
+				Source:       rate.Source,
+				RtcBid:       rate.AvgRate,
+				CollectedAt:  displayTime(rate.Time),
+			}
+		}
+
+		if len(sources) > 1 {
+			fmt.Printf("\n%s:\n", source)
+		}
+		chart.PrintChartWithStats(local, width, height, annotationMarkers(notes, times)...)
+	}
+	printAnnotations(notes)
+
+	if h.schedule == nil {
+		return
+	}
+
+	// The chart plots samples side by side, so stretches without polls
+	// don't show; list them instead
+	report, err := analyzeCoverage(ctx, h.repo, start, end, *h.schedule, h.minGap)
+	if err != nil {
+		h.logger.Warn("failed to find gaps", "error", err)
+		return
+	}
+	if gaps := report.Gaps(); len(gaps) > 0 {
+		fmt.Printf("⚠️  Gaps without polls (%.1f%% coverage, %s):\n", report.Completeness()*100, displayZone)
+		printGaps(gaps)
+		fmt.Println()
+	}
 }
 
-func (h *HistoryCommand) displayTable(rates []storage.ExchangeRate, start, end time.Time) {
+func (h *HistoryCommand) displayTable(rates []storage.SeriesPoint, notes []storage.Annotation, start, end time.Time) {
 	fmt.Printf("\n")
-	fmt.Printf("Exchange Rate History\n")
-	fmt.Printf("═════════════════════\n")
-	fmt.Printf("Period: %s to %s\n",
-		start.Format("2006-01-02 15:04:05"),
-		end.Format("2006-01-02 15:04:05"))
+	fmt.Printf("%s/CNY Exchange Rate History\n", rates[0].CurrencyCode)
+	fmt.Printf("═════════════════════════════\n")
+	fmt.Printf("Period: %s to %s (%s)\n",
+		displayTime(start).Format("2006-01-02 15:04:05"),
+		displayTime(end).Format("2006-01-02 15:04:05"),
+		displayZone)
 	fmt.Printf("Records: %d\n", len(rates))
+	if tiers := tierCounts(rates); tiers != "" {
+		fmt.Printf("Tiers:   %s\n", tiers)
+	}
 	fmt.Printf("\n")
 
-	// Calculate statistics
-	var minRate, maxRate, sumRate float64
-	minRate = rates[0].RtcBid
-	maxRate = rates[0].RtcBid
+	// Statistics and changes only compare points of the same source, so the
+	// spread between banks doesn't read as movement
+	sources, bySource := splitSources(rates)
+	multi := len(sources) > 1
+	for _, source := range sources {
+		points := bySource[source]
 
-	for _, rate := range rates {
-		if rate.RtcBid < minRate {
-			minRate = rate.RtcBid
+		// Calculate statistics, weighting rollups by the samples they summarize
+		minRate := points[0].MinRate
+		maxRate := points[0].MaxRate
+		var sum fixed.Rate
+		var samples int
+
+		for _, rate := range points {
+			if rate.MinRate < minRate {
+				minRate = rate.MinRate
+			}
+			if rate.MaxRate > maxRate {
+				maxRate = rate.MaxRate
+			}
+			sum += rate.SumRate
+			samples += rate.SampleCount
 		}
-		if rate.RtcBid > maxRate {
-			maxRate = rate.RtcBid
+		avgRate := fixed.MeanOf(sum, samples)
+
+		if multi {
+			fmt.Printf("Summary Statistics (%s):\n", source)
+		} else {
+			fmt.Printf("Summary Statistics:\n")
 		}
-		sumRate += rate.RtcBid
+		fmt.Printf("  Min:     %s CNY\n", minRate)
+		fmt.Printf("  Max:     %s CNY\n", maxRate)
+		fmt.Printf("  Average: %s CNY\n", avgRate)
+		fmt.Printf("  Range:   %s CNY\n", maxRate-minRate)
+		fmt.Printf("\n")
 	}
-	avgRate := sumRate / float64(len(rates))
-
-	fmt.Printf("Summary Statistics:\n")
-	fmt.Printf("  Min:     %.4f CNY\n", minRate)
-	fmt.Printf("  Max:     %.4f CNY\n", maxRate)
-	fmt.Printf("  Average: %.4f CNY\n", avgRate)
-	fmt.Printf("  Range:   %.4f CNY\n", maxRate-minRate)
-	fmt.Printf("\n")
 
 	// Display table header
-	fmt.Printf("%-20s  %-10s  %-8s\n", "Time", "Rate (CNY)", "Change")
-	fmt.Printf("%s\n", strings.Repeat("─", 50))
+	if multi {
+		fmt.Printf("%-20s  %-6s  %-10s  %-8s  %s\n", "Time", "Source", "Rate (CNY)", "Change", "Tier")
+		fmt.Printf("%s\n", strings.Repeat("─", 58))
+	} else {
+		fmt.Printf("%-20s  %-10s  %-8s  %s\n", "Time", "Rate (CNY)", "Change", "Tier")
+		fmt.Printf("%s\n", strings.Repeat("─", 50))
+	}
+
+	// Each annotation follows the last row at or before its start
+	next := 0
+	printNotes := func(before time.Time) {
+		for ; next < len(notes) && (before.IsZero() || notes[next].StartsAt.Before(before)); next++ {
+			fmt.Printf("  %s\n", formatAnnotation(next, notes[next]))
+		}
+	}
 
-	var prevRate *float64
+	prevRates := make(map[string]fixed.Rate)
 	for _, rate := range rates {
+		printNotes(rate.Time)
+
 		changeStr := "   -    "
-		if prevRate != nil {
-			delta := rate.RtcBid - *prevRate
+		if prevRate, ok := prevRates[rate.Source]; ok {
+			delta := rate.AvgRate - prevRate
 			symbol := " "
 			if delta > 0 {
 				symbol = "↑"
@@ -116,31 +210,44 @@ func (h *HistoryCommand) displayTable(rates []storage.ExchangeRate, start, end t
 			} else {
 				symbol = "→"
 			}
-			changeStr = fmt.Sprintf("%s%+.4f", symbol, delta)
+			changeStr = symbol + delta.Signed()
+		}
+
+		tier := string(rate.Tier)
+		if rate.Tier != storage.TierRaw {
+			tier = fmt.Sprintf("%s avg of %d", rate.Tier, rate.SampleCount)
 		}
 
-		fmt.Printf("%-20s  %10.4f  %-8s\n",
-			rate.CollectedAt.Format("2006-01-02 15:04:05"),
-			rate.RtcBid,
-			changeStr)
+		at := displayTime(rate.Time).Format("2006-01-02 15:04:05")
+		if multi {
+			fmt.Printf("%-20s  %-6s  %10s  %-8s  %s\n", at, rate.Source, rate.AvgRate, changeStr, tier)
+		} else {
+			fmt.Printf("%-20s  %10s  %-8s  %s\n", at, rate.AvgRate, changeStr, tier)
+		}
 
-		prevRate = &rate.RtcBid
+		prevRates[rate.Source] = rate.AvgRate
 	}
+	printNotes(time.Time{})
 	fmt.Printf("\n")
 }
 
-func (h *HistoryCommand) displayCSV(rates []storage.ExchangeRate) {
-	fmt.Printf("Timestamp,Rate,Date,Time\n")
+func (h *HistoryCommand) displayCSV(rates []storage.SeriesPoint) {
+	fmt.Printf("Timestamp,Rate,Date,Time,Currency,Source,Tier,Samples\n")
 	for _, rate := range rates {
-		fmt.Printf("%s,%.4f,%s,%s\n",
-			rate.CollectedAt.Format("2006-01-02 15:04:05"),
-			rate.RtcBid,
-			rate.CollectedAt.Format("2006-01-02"),
-			rate.CollectedAt.Format("15:04:05"))
+		at := displayTime(rate.Time)
+		fmt.Printf("%s,%s,%s,%s,%s,%s,%s,%d\n",
+			at.Format("2006-01-02 15:04:05"),
+			rate.AvgRate,
+			at.Format("2006-01-02"),
+			at.Format("15:04:05"),
+			rate.CurrencyCode,
+			rate.Source,
+			rate.Tier,
+			rate.SampleCount)
 	}
 }
 
-func (h *HistoryCommand) displayJSON(rates []storage.ExchangeRate) {
+func (h *HistoryCommand) displayJSON(rates []storage.SeriesPoint) {
 	fmt.Printf("[\n")
 	for i, rate := range rates {
 		comma := ","
@@ -148,17 +255,56 @@ func (h *HistoryCommand) displayJSON(rates []storage.ExchangeRate) {
 			comma = ""
 		}
 		fmt.Printf("  {\n")
-		fmt.Printf("    \"timestamp\": \"%s\",\n", rate.CollectedAt.Format(time.RFC3339))
-		fmt.Printf("    \"rate\": %.4f,\n", rate.RtcBid)
-		fmt.Printf("    \"currency\": \"%s\"\n", rate.CurrencyCode)
+		fmt.Printf("    \"timestamp\": \"%s\",\n", displayTime(rate.Time).Format(time.RFC3339))
+		fmt.Printf("    \"rate\": %s,\n", rate.AvgRate)
+		fmt.Printf("    \"currency\": \"%s\",\n", rate.CurrencyCode)
+		fmt.Printf("    \"source\": \"%s\",\n", rate.Source)
+		fmt.Printf("    \"tier\": \"%s\",\n", rate.Tier)
+		fmt.Printf("    \"samples\": %d\n", rate.SampleCount)
 		fmt.Printf("  }%s\n", comma)
 	}
 	fmt.Printf("]\n")
 }
 
-// ParseTimeRange parses start and end times from various formats
+// splitSources splits a series into one per source, in source order
+func splitSources(points []storage.SeriesPoint) ([]string, map[string][]storage.SeriesPoint) {
+	bySource := make(map[string][]storage.SeriesPoint)
+	for _, p := range points {
+		bySource[p.Source] = append(bySource[p.Source], p)
+	}
+
+	sources := make([]string, 0, len(bySource))
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources, bySource
+}
+
+// tierCounts describes how many points of a series each tier served, e.g.
+// "120 raw, 24 hourly"; empty when every point is raw
+func tierCounts(points []storage.SeriesPoint) string {
+	counts := make(map[storage.Tier]int)
+	for _, p := range points {
+		counts[p.Tier]++
+	}
+	if counts[storage.TierRaw] == len(points) {
+		return ""
+	}
+
+	var parts []string
+	for _, tier := range []storage.Tier{storage.TierRaw, storage.TierHourly, storage.TierDaily} {
+		if counts[tier] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[tier], tier))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// ParseTimeRange parses start and end times from various formats, read in
+// the display zone
 func ParseTimeRange(startStr, endStr, lastDuration string) (time.Time, time.Time, error) {
-	now := time.Now()
+	now := time.Now().In(displayZone)
 
 	// If "last" duration is specified (e.g., "2h", "30m")
 	if lastDuration != "" {
@@ -169,54 +315,19 @@ func ParseTimeRange(startStr, endStr, lastDuration string) (time.Time, time.Time
 		return now.Add(-duration), now, nil
 	}
 
-	// Parse start and end times
-	var start, end time.Time
-	var err error
-
 	if startStr == "" {
 		return time.Time{}, time.Time{}, fmt.Errorf("start time is required (or use --last)")
 	}
 
-	// Try different time formats
-	formats := []string{
-		"2006-01-02 15:04:05",
-		"2006-01-02 15:04",
-		"2006-01-02",
-		"15:04:05",
-		"15:04",
-	}
-
-	for _, format := range formats {
-		start, err = time.ParseInLocation(format, startStr, time.Local)
-		if err == nil {
-			// If only time is provided (no date), use today's date
-			if format == "15:04:05" || format == "15:04" {
-				start = time.Date(now.Year(), now.Month(), now.Day(),
-					start.Hour(), start.Minute(), start.Second(), 0, time.Local)
-			}
-			break
-		}
-	}
+	start, err := parseDisplayTime(startStr, now)
 	if err != nil {
 		return time.Time{}, time.Time{}, fmt.Errorf("invalid start time format: %s", startStr)
 	}
 
 	// If end time is not specified, use current time
-	if endStr == "" {
-		end = now
-	} else {
-		for _, format := range formats {
-			end, err = time.ParseInLocation(format, endStr, time.Local)
-			if err == nil {
-				// If only time is provided (no date), use today's date
-				if format == "15:04:05" || format == "15:04" {
-					end = time.Date(now.Year(), now.Month(), now.Day(),
-						end.Hour(), end.Minute(), end.Second(), 0, time.Local)
-				}
-				break
-			}
-		}
-		if err != nil {
+	end := now
+	if endStr != "" {
+		if end, err = parseDisplayTime(endStr, now); err != nil {
 			return time.Time{}, time.Time{}, fmt.Errorf("invalid end time format: %s", endStr)
 		}
 	}
@@ -228,3 +339,30 @@ func ParseTimeRange(startStr, endStr, lastDuration string) (time.Time, time.Time
 
 	return start, end, nil
 }
+
+// displayTimeFormats are the layouts parseDisplayTime accepts
+var displayTimeFormats = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+// parseDisplayTime parses a time in the display zone; a time of day without
+// a date is on now's date
+func parseDisplayTime(s string, now time.Time) (time.Time, error) {
+	for _, format := range displayTimeFormats {
+		t, err := time.ParseInLocation(format, s, displayZone)
+		if err != nil {
+			continue
+		}
+		// If only time is provided (no date), use today's date
+		if format == "15:04:05" || format == "15:04" {
+			t = time.Date(now.Year(), now.Month(), now.Day(),
+				t.Hour(), t.Minute(), t.Second(), 0, displayZone)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
@@ -0,0 +1,156 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/importer"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// maxInvalidShown caps the invalid rows listed before summarizing the rest
+const maxInvalidShown = 10
+
+// ImportCommand loads historical rates from files
+type ImportCommand struct {
+	repo   storage.Importer
+	logger *slog.Logger
+}
+
+// NewImportCommand creates a new import command handler
+func NewImportCommand(repo storage.Importer, logger *slog.Logger) *ImportCommand {
+	return &ImportCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Run imports the rates of a file ("-" for standard input) in transactions
+// of batchSize records. Rows that fail validation are listed and skipped,
+// and rows already stored are counted as duplicates and left as they are
+// Times without an offset are read in the display zone, so a history
+// export imports with the --tz it was written with. A dry run imports the
+// whole file in one transaction and rolls it back
+func (c *ImportCommand) Run(ctx context.Context, path string, opts importer.Options, batchSize int, dryRun bool) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive")
+	}
+
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", path, err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	if opts.Zone == nil {
+		opts.Zone = displayZone
+	}
+	records, invalid, err := importer.Read(in, opts)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Import Historical Rates\n")
+	fmt.Printf("═══════════════════════\n")
+	fmt.Printf("File: %s\n", path)
+	if dryRun {
+		fmt.Printf("DRY RUN MODE - No actual changes will be made\n")
+	}
+	fmt.Printf("\n")
+
+	if len(invalid) > 0 {
+		fmt.Printf("⚠️  %d rows can't be imported and will be skipped:\n", len(invalid))
+		for i, rowErr := range invalid {
+			if i == maxInvalidShown {
+				fmt.Printf("  ... and %d more\n", len(invalid)-maxInvalidShown)
+				break
+			}
+			fmt.Printf("  %s\n", rowErr)
+		}
+		fmt.Printf("\n")
+	}
+
+	if len(records) == 0 {
+		fmt.Println("No rows to import.")
+		return nil
+	}
+
+	if dryRun {
+		batchSize = len(records)
+	}
+
+	var stats storage.ImportStats
+	read := make(map[storage.Tier]int)
+	batches := 0
+	for start := 0; start < len(records); start += batchSize {
+		batch := records[start:min(start+batchSize, len(records))]
+
+		var rates []*storage.ExchangeRate
+		var rollups []storage.SeriesPoint
+		for _, rec := range batch {
+			if rec.Rate != nil {
+				rates = append(rates, rec.Rate)
+				read[storage.TierRaw]++
+			} else {
+				rollups = append(rollups, *rec.Rollup)
+				read[rec.Rollup.Tier]++
+			}
+		}
+
+		batchStats, err := c.repo.ImportBatch(ctx, rates, rollups, dryRun)
+		if err != nil {
+			return fmt.Errorf("importing lines %d-%d (%d batches committed before): %w",
+				batch[0].Line, batch[len(batch)-1].Line, batches, err)
+		}
+		stats.Add(batchStats)
+		batches++
+
+		c.logger.Debug("imported batch", "batch", batches, "records", len(batch), "dry_run", dryRun)
+	}
+
+	fmt.Printf("%-8s  %8s  %8s  %10s  %8s\n", "Tier", "Read", "New", "Duplicate", "Covered")
+	fmt.Printf("%s\n", strings.Repeat("─", 50))
+	var total storage.ImportCounts
+	for _, tier := range []storage.Tier{storage.TierRaw, storage.TierHourly, storage.TierDaily} {
+		counts := stats.Tier(tier)
+		if read[tier] == 0 {
+			continue
+		}
+		fmt.Printf("%-8s  %8d  %8d  %10d  %8d\n", tier, read[tier], counts.Inserted, counts.Duplicates, counts.Covered)
+		total.Inserted += counts.Inserted
+		total.Duplicates += counts.Duplicates
+		total.Covered += counts.Covered
+	}
+	fmt.Printf("%s\n", strings.Repeat("─", 50))
+	fmt.Printf("%-8s  %8d  %8d  %10d  %8d\n", "Total", len(records), total.Inserted, total.Duplicates, total.Covered)
+	fmt.Printf("\n")
+
+	rawCovered := stats.Tier(storage.TierRaw).Covered
+	if rawCovered > 0 {
+		fmt.Printf("ℹ️  Covered quotes were skipped: retention left only rollups of their dates, which they would replace\n")
+	}
+	if total.Covered > rawCovered {
+		fmt.Printf("ℹ️  Covered rollups were skipped: their dates have raw quotes, which rollups are computed from\n")
+	}
+	if total.Covered > 0 {
+		fmt.Printf("\n")
+	}
+
+	if dryRun {
+		fmt.Println("Run without --dry-run to import.")
+		return nil
+	}
+
+	c.logger.Info("imported rates", "file", path, "inserted", total.Inserted, "duplicates", total.Duplicates, "batches", batches)
+	fmt.Printf("✅ Imported %d rows in %d batches\n", total.Inserted, batches)
+	return nil
+}
@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/recommender"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// recommendationWindow is how soon after a conversion it must be recorded
+// for the current recommendation to count as what recommend said at the time
+const recommendationWindow = time.Hour
+
+// LedgerCommand records conversions actually made and scores the rates they
+// achieved
+type LedgerCommand struct {
+	repo        storage.RateReader
+	ledger      storage.Ledger
+	recommender *recommender.Recommender
+	logger      *slog.Logger
+}
+
+// NewLedgerCommand creates a new ledger command handler scoring the
+// conversions of a ledger against the rates of repo
+// A store that sees every source is scoped to the default one, so days are
+// scored against the bank recommendations are given for
+func NewLedgerCommand(repo storage.RateReader, ledger storage.Ledger, logger *slog.Logger) *LedgerCommand {
+	repo = singleSource(repo)
+	return &LedgerCommand{
+		repo:        repo,
+		ledger:      ledger,
+		recommender: recommender.NewRecommender(repo, logger),
+		logger:      logger,
+	}
+}
+
+// Add records a conversion of rmb RMB into foreign units of a currency, less
+// fees RMB, made at a time read in the display zone (empty: now). A
+// conversion recorded within recommendationWindow of being made keeps the
+// recommendation recommend gives now, for the report to score
+func (c *LedgerCommand) Add(ctx context.Context, currency, at string, rmb, foreign, fees float64, note string) error {
+	now := time.Now().In(displayZone)
+	conv := &storage.Conversion{
+		CurrencyCode:  strings.ToUpper(currency),
+		ConvertedAt:   now,
+		RMBAmount:     rmb,
+		ForeignAmount: foreign,
+		Fees:          fees,
+		Note:          strings.TrimSpace(note),
+	}
+	if !currencyCode.MatchString(conv.CurrencyCode) {
+		return fmt.Errorf("invalid currency %q", currency)
+	}
+	if at != "" {
+		var err error
+		if conv.ConvertedAt, err = parseDisplayTime(at, now); err != nil {
+			return err
+		}
+	}
+
+	recent := now.Sub(conv.ConvertedAt).Abs() <= recommendationWindow
+	if recent {
+		rec, err := c.recommender.GetRecommendation(ctx, conv.CurrencyCode, rmb)
+		if err != nil {
+			c.logger.Warn("no recommendation to record", "currency", conv.CurrencyCode, "error", err)
+		} else {
+			conv.RecommendedAction = string(rec.Action)
+			conv.RecommendedConfidence = rec.ConfidenceScore
+			conv.RecommendedRate = rec.CurrentRate
+		}
+	}
+
+	if err := c.ledger.AddConversion(ctx, conv); err != nil {
+		return fmt.Errorf("adding conversion: %w", err)
+	}
+
+	c.logger.Info("added conversion", "id", conv.ID, "currency", conv.CurrencyCode, "converted_at", conv.ConvertedAt)
+	fmt.Printf("✅ Added conversion %d: %s  %s RMB → %s %s at %s (effective %s)\n",
+		conv.ID,
+		displayTime(conv.ConvertedAt).Format("2006-01-02 15:04"),
+		formatMoney(conv.RMBAmount),
+		formatMoney(conv.ForeignAmount),
+		conv.CurrencyCode,
+		conv.Rate(),
+		conv.EffectiveRate())
+	switch {
+	case conv.RecommendedAction != "":
+		fmt.Printf("📌 Recommendation on record: %s\n", adviceLabel(*conv))
+	case recent:
+		fmt.Printf("⚠️  No recommendation available to record\n")
+	default:
+		fmt.Printf("ℹ️  Recorded more than %.0fh after the conversion; no recommendation on record\n", recommendationWindow.Hours())
+	}
+	return nil
+}
+
+// List shows the conversions of a currency (empty: every currency) made
+// from start to end
+func (c *LedgerCommand) List(ctx context.Context, currency string, start, end time.Time) error {
+	conversions, err := c.ledger.GetConversions(ctx, strings.ToUpper(currency), start, end)
+	if err != nil {
+		return fmt.Errorf("querying conversions: %w", err)
+	}
+
+	if len(conversions) == 0 {
+		fmt.Printf("No conversions from %s to %s\n",
+			displayTime(start).Format("2006-01-02 15:04"),
+			displayTime(end).Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Conversion Ledger\n")
+	fmt.Printf("═════════════════\n")
+	fmt.Printf("Period: %s to %s (%s)\n",
+		displayTime(start).Format("2006-01-02 15:04"),
+		displayTime(end).Format("2006-01-02 15:04"),
+		displayZone)
+	fmt.Printf("\n")
+
+	fmt.Printf("%5s  %-16s  %-8s  %14s  %12s  %8s  %9s  %-20s  %s\n",
+		"ID", "Time", "Currency", "RMB", "Amount", "Fees", "Effective", "Recommendation", "Note")
+	fmt.Printf("%s\n", strings.Repeat("─", 112))
+	for _, conv := range conversions {
+		fmt.Printf("%5d  %-16s  %-8s  %14s  %12s  %8s  %9s  %-20s  %s\n",
+			conv.ID,
+			displayTime(conv.ConvertedAt).Format("2006-01-02 15:04"),
+			conv.CurrencyCode,
+			formatMoney(conv.RMBAmount),
+			formatMoney(conv.ForeignAmount),
+			formatMoney(conv.Fees),
+			conv.EffectiveRate(),
+			adviceLabel(conv),
+			conv.Note)
+	}
+	fmt.Printf("\n")
+
+	return nil
+}
+
+// Remove deletes a conversion from the ledger by ID
+func (c *LedgerCommand) Remove(ctx context.Context, id int64) error {
+	if err := c.ledger.DeleteConversion(ctx, id); err != nil {
+		return err
+	}
+
+	c.logger.Info("removed conversion", "id", id)
+	fmt.Printf("✅ Removed conversion %d\n", id)
+	return nil
+}
+
+// tradeScore is a conversion with the statistics of the market date it was
+// made on
+type tradeScore struct {
+	conv  storage.Conversion
+	rate  fixed.Rate          // Effective rate
+	stats *storage.DailyStats // nil: no rates that day
+}
+
+// left returns the RMB the conversion would have brought at the day's peak
+// on top of what it did
+func (s tradeScore) left() float64 {
+	if s.stats == nil {
+		return 0
+	}
+	return s.conv.LeftOnTable(s.stats.MaxRate)
+}
+
+// ledgerTally sums the conversions of a period or recommendation
+type ledgerTally struct {
+	trades  int
+	foreign float64
+	rmb     float64
+	fees    float64
+
+	// Over conversions made on days with rates
+	scored        int
+	scoredForeign float64
+	scoredNet     float64 // RMB net of fees
+	atPeak        float64 // RMB at the day's peaks
+	atAvg         float64 // RMB at the day's averages
+	left          float64
+}
+
+// add counts a conversion in the tally
+func (t *ledgerTally) add(s tradeScore) {
+	t.trades++
+	t.foreign += s.conv.ForeignAmount
+	t.rmb += s.conv.RMBAmount
+	t.fees += s.conv.Fees
+	if s.stats == nil {
+		return
+	}
+	t.scored++
+	t.scoredForeign += s.conv.ForeignAmount
+	t.scoredNet += s.conv.RMBAmount - s.conv.Fees
+	t.atPeak += s.stats.MaxRate.Float64() * s.conv.ForeignAmount
+	t.atAvg += s.stats.AvgRate.Float64() * s.conv.ForeignAmount
+	t.left += s.left()
+}
+
+// avgRate returns the average effective rate, weighted by amount
+func (t *ledgerTally) avgRate() fixed.Rate {
+	return averageRate(t.rmb-t.fees, t.foreign)
+}
+
+// versus returns how far the scored conversions' average effective rate is
+// from the average peak and average rate of their days, weighted by amount
+func (t *ledgerTally) versus() (peak, avg string) {
+	if t.scored == 0 {
+		return "-", "-"
+	}
+	rate := averageRate(t.scoredNet, t.scoredForeign)
+	return (rate - averageRate(t.atPeak, t.scoredForeign)).Signed(),
+		(rate - averageRate(t.atAvg, t.scoredForeign)).Signed()
+}
+
+// averageRate returns sum / weight as a rate rounded to the places rates are
+// shown with, so weighted averages don't print spurious digits
+func averageRate(sum, weight float64) fixed.Rate {
+	scale := math.Pow10(fixed.DisplayPlaces)
+	return fixed.FromFloat(math.Round(sum/weight*scale) / scale)
+}
+
+// Report scores the conversions of a currency made from start to end against
+// the peak and average rate of their market dates, read from the daily
+// rollups like peak and average, and the recommendation on record. It rolls
+// up the average rate achieved and the RMB left on the table, against each
+// day's peak, per month and quarter
+func (c *LedgerCommand) Report(ctx context.Context, currency string, start, end time.Time) error {
+	currency = strings.ToUpper(currency)
+	conversions, err := c.ledger.GetConversions(ctx, currency, start, end)
+	if err != nil {
+		return fmt.Errorf("querying conversions: %w", err)
+	}
+
+	if len(conversions) == 0 {
+		fmt.Printf("No %s conversions from %s to %s\n", currency,
+			displayTime(start).Format("2006-01-02 15:04"),
+			displayTime(end).Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	days := make(map[string]*storage.DailyStats)
+	scores := make([]tradeScore, len(conversions))
+	for i, conv := range conversions {
+		date := market.Date(conv.ConvertedAt)
+		stats, ok := days[date]
+		if !ok {
+			if stats, err = c.repo.GetDailyStats(ctx, currency, date); err != nil {
+				return fmt.Errorf("getting stats for %s: %w", date, err)
+			}
+			if stats.SampleCount == 0 {
+				stats = nil
+			}
+			days[date] = stats
+		}
+		scores[i] = tradeScore{conv: conv, rate: conv.EffectiveRate(), stats: stats}
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("%s Conversion Scorecard\n", currency)
+	fmt.Printf("════════════════════════\n")
+	fmt.Printf("Period: %s to %s (%s)\n",
+		displayTime(start).Format("2006-01-02 15:04"),
+		displayTime(end).Format("2006-01-02 15:04"),
+		displayZone)
+	fmt.Printf("\n")
+
+	fmt.Printf("%-16s  %12s  %9s  %9s  %9s  %8s  %8s  %10s  %s\n",
+		"Time", "Amount", "Effective", "Day Peak", "Day Avg", "vs Peak", "vs Avg", "Left (CNY)", "Recommendation")
+	fmt.Printf("%s\n", strings.Repeat("─", 112))
+
+	var total ledgerTally
+	months := make(map[string]*ledgerTally)
+	quarters := make(map[string]*ledgerTally)
+	advice := make(map[string]*ledgerTally)
+	for _, s := range scores {
+		peak, avg, vsPeak, vsAvg, left := "No data", "-", "-", "-", "-"
+		if s.stats != nil {
+			peak, avg = s.stats.MaxRate.String(), s.stats.AvgRate.String()
+			vsPeak, vsAvg = (s.rate - s.stats.MaxRate).Signed(), (s.rate - s.stats.AvgRate).Signed()
+			left = formatMoney(s.left())
+		}
+		fmt.Printf("%-16s  %12s  %9s  %9s  %9s  %8s  %8s  %10s  %s\n",
+			displayTime(s.conv.ConvertedAt).Format("2006-01-02 15:04"),
+			formatMoney(s.conv.ForeignAmount),
+			s.rate, peak, avg, vsPeak, vsAvg, left,
+			adviceLabel(s.conv))
+
+		day := s.conv.ConvertedAt.In(market.Zone)
+		for _, group := range []struct {
+			tallies map[string]*ledgerTally
+			key     string
+		}{
+			{months, day.Format("2006-01")},
+			{quarters, fmt.Sprintf("%d-Q%d", day.Year(), (int(day.Month())+2)/3)},
+			{advice, s.conv.RecommendedAction},
+		} {
+			if group.tallies[group.key] == nil {
+				group.tallies[group.key] = &ledgerTally{}
+			}
+			group.tallies[group.key].add(s)
+		}
+		total.add(s)
+	}
+	fmt.Printf("\n")
+
+	printLedgerTallies("By Month", months)
+	printLedgerTallies("By Quarter", quarters)
+
+	fmt.Printf("By Recommendation:\n")
+	fmt.Printf("  %-14s  %6s  %12s  %8s  %8s  %10s\n", "Recommended", "Trades", "Amount", "vs Peak", "vs Avg", "Left (CNY)")
+	for _, action := range []recommender.Action{
+		recommender.ActionExchangeNow, recommender.ActionWait, recommender.ActionNeutral, "",
+	} {
+		t := advice[string(action)]
+		if t == nil {
+			continue
+		}
+		label := strings.ToLower(strings.ReplaceAll(string(action), "_", " "))
+		if action == "" {
+			label = "none on record"
+		}
+		vsPeak, vsAvg := t.versus()
+		fmt.Printf("  %-14s  %6d  %12s  %8s  %8s  %10s\n", label, t.trades, formatMoney(t.foreign), vsPeak, vsAvg, formatMoney(t.left))
+	}
+	fmt.Printf("\n")
+
+	vsPeak, vsAvg := total.versus()
+	fmt.Printf("Summary:\n")
+	fmt.Printf("  Conversions:       %d\n", total.trades)
+	fmt.Printf("  Converted:         %s RMB → %s %s\n", formatMoney(total.rmb), formatMoney(total.foreign), currency)
+	fmt.Printf("  Fees:              %s RMB\n", formatMoney(total.fees))
+	fmt.Printf("  Average Rate:      %s CNY (net of fees)\n", total.avgRate())
+	fmt.Printf("  vs Day Peaks:      %s CNY\n", vsPeak)
+	fmt.Printf("  vs Day Averages:   %s CNY\n", vsAvg)
+	fmt.Printf("  Left on Table:     %s RMB\n", formatMoney(total.left))
+	fmt.Printf("\n")
+
+	if unscored := total.trades - total.scored; unscored > 0 {
+		fmt.Printf("ℹ️  %d conversions were made on days without rates and aren't compared\n\n", unscored)
+	}
+
+	return nil
+}
+
+// printLedgerTallies prints a table of tallies by period, in period order
+func printLedgerTallies(title string, tallies map[string]*ledgerTally) {
+	periods := make([]string, 0, len(tallies))
+	for period := range tallies {
+		periods = append(periods, period)
+	}
+	sort.Strings(periods)
+
+	fmt.Printf("%s:\n", title)
+	fmt.Printf("  %-8s  %6s  %12s  %14s  %10s  %9s  %8s  %8s  %10s\n",
+		"Period", "Trades", "Amount", "RMB", "Fees", "Avg Rate", "vs Peak", "vs Avg", "Left (CNY)")
+	for _, period := range periods {
+		t := tallies[period]
+		vsPeak, vsAvg := t.versus()
+		fmt.Printf("  %-8s  %6d  %12s  %14s  %10s  %9s  %8s  %8s  %10s\n",
+			period, t.trades, formatMoney(t.foreign), formatMoney(t.rmb), formatMoney(t.fees),
+			t.avgRate(), vsPeak, vsAvg, formatMoney(t.left))
+	}
+	fmt.Printf("\n")
+}
+
+// adviceLabel describes the recommendation on record for a conversion, such
+// as "EXCHANGE_NOW 72% @ 7.0751"
+func adviceLabel(conv storage.Conversion) string {
+	if conv.RecommendedAction == "" {
+		return "-"
+	}
+	return fmt.Sprintf("%s %.0f%% @ %s", conv.RecommendedAction, conv.RecommendedConfidence, conv.RecommendedRate)
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// MigrateCommand inspects and applies the database's schema migrations
+type MigrateCommand struct {
+	db     *storage.DB
+	logger *slog.Logger
+}
+
+// NewMigrateCommand creates a new migrate command handler; db should be
+// opened with storage.OpenDB so that nothing is applied before the command runs
+func NewMigrateCommand(db *storage.DB, logger *slog.Logger) *MigrateCommand {
+	return &MigrateCommand{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Status lists every migration with its state in the database
+func (c *MigrateCommand) Status(ctx context.Context) error {
+	statuses, err := c.db.MigrationStatus(ctx)
+	if err != nil {
+		return fmt.Errorf("reading migration status: %w", err)
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Schema Migrations\n")
+	fmt.Printf("═════════════════\n")
+	fmt.Printf("\n")
+
+	fmt.Printf("%-32s  %-10s  %-20s\n", "Migration", "Status", "Applied At")
+	fmt.Printf("%s\n", strings.Repeat("─", 66))
+
+	pending := 0
+	for _, s := range statuses {
+		status := "pending"
+		appliedAt := "-"
+		switch {
+		case s.Unknown:
+			status = "unknown"
+		case s.Modified:
+			status = "modified"
+		case s.Applied():
+			status = "applied"
+		default:
+			pending++
+		}
+		if s.Applied() {
+			appliedAt = displayTime(s.AppliedAt).Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-32s  %-10s  %-20s\n", s.Migration, status, appliedAt)
+	}
+	fmt.Printf("\n")
+
+	for _, s := range statuses {
+		switch {
+		case s.Unknown:
+			fmt.Printf("⚠️  %s was applied by a newer ratemon; upgrade before migrating\n", s.Migration)
+		case s.Modified:
+			fmt.Printf("⚠️  %s was changed after it was applied (checksum mismatch)\n", s.Migration)
+		}
+	}
+	if pending > 0 {
+		fmt.Printf("%d pending; run `ratemon migrate up` to apply\n", pending)
+	} else {
+		fmt.Printf("Database is up to date\n")
+	}
+	fmt.Printf("\n")
+
+	return nil
+}
+
+// Up applies every pending migration
+func (c *MigrateCommand) Up(ctx context.Context) error {
+	applied, err := c.db.MigrateUp(ctx)
+	for _, m := range applied {
+		fmt.Printf("✅ Applied %s\n", m)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Printf("Database is up to date\n")
+	}
+	return nil
+}
+
+// Down reverts the most recently applied migration
+func (c *MigrateCommand) Down(ctx context.Context) error {
+	m, err := c.db.MigrateDown(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Reverted %s\n", m)
+	fmt.Printf("Other commands re-apply pending migrations when they open the database;\n")
+	fmt.Printf("switch to the older ratemon before running them\n")
+	return nil
+}
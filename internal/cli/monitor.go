@@ -11,21 +11,21 @@ import (
 
 // MonitorCommand handles the monitor command functionality
 type MonitorCommand struct {
-	repo   *storage.Repository
+	repo   storage.RateReader
 	logger *slog.Logger
 }
 
 // NewMonitorCommand creates a new monitor command handler
-func NewMonitorCommand(repo *storage.Repository, logger *slog.Logger) *MonitorCommand {
+func NewMonitorCommand(repo storage.RateReader, logger *slog.Logger) *MonitorCommand {
 	return &MonitorCommand{
 		repo:   repo,
 		logger: logger,
 	}
 }
 
-// DisplayCurrent shows the current/latest exchange rate
-func (m *MonitorCommand) DisplayCurrent(ctx context.Context) error {
-	rate, err := m.repo.GetLatestRate(ctx)
+// DisplayCurrent shows the current/latest exchange rate of a currency
+func (m *MonitorCommand) DisplayCurrent(ctx context.Context, currency string) error {
+	rate, err := m.repo.GetLatestRate(ctx, currency)
 	if err != nil {
 		return fmt.Errorf("getting latest rate: %w", err)
 	}
@@ -36,19 +36,22 @@ func (m *MonitorCommand) DisplayCurrent(ctx context.Context) error {
 	}
 
 	fmt.Printf("\n")
-	fmt.Printf("USD/CNY Exchange Rate\n")
+	fmt.Printf("%s/CNY Exchange Rate\n", rate.CurrencyCode)
 	fmt.Printf("═════════════════════\n")
 	fmt.Printf("\n")
-	fmt.Printf("  Rate:      %.4f CNY\n", rate.RtcBid)
-	fmt.Printf("  Time:      %s\n", rate.CollectedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("  Rate:      %s CNY\n", rate.RtcBid)
+	fmt.Printf("  Time:      %s\n", displayTime(rate.CollectedAt).Format("2006-01-02 15:04:05"))
 	fmt.Printf("  Age:       %s ago\n", formatDuration(time.Since(rate.CollectedAt)))
+	if !rate.QuotedAt.IsZero() {
+		fmt.Printf("  Quoted:    %s (bank time)\n", displayTime(rate.QuotedAt).Format("2006-01-02 15:04:05"))
+	}
 	fmt.Printf("\n")
 
 	// Get previous rate for comparison
-	prevRate, err := m.getPreviousRate(ctx, rate.CollectedAt)
+	prevRate, err := m.getPreviousRate(ctx, currency, rate.CollectedAt)
 	if err == nil && prevRate != nil {
 		delta := rate.RtcBid - prevRate.RtcBid
-		deltaPercent := (delta / prevRate.RtcBid) * 100
+		deltaPercent := delta.Float64() / prevRate.RtcBid.Float64() * 100
 
 		symbol := "→"
 		if delta > 0 {
@@ -57,10 +60,10 @@ func (m *MonitorCommand) DisplayCurrent(ctx context.Context) error {
 			symbol = "↓"
 		}
 
-		fmt.Printf("  Change:    %s %.4f (%.2f%%)\n", symbol, delta, deltaPercent)
-		fmt.Printf("  Previous:  %.4f CNY at %s\n",
+		fmt.Printf("  Change:    %s %s (%.2f%%)\n", symbol, delta, deltaPercent)
+		fmt.Printf("  Previous:  %s CNY at %s\n",
 			prevRate.RtcBid,
-			prevRate.CollectedAt.Format("15:04:05"))
+			displayTime(prevRate.CollectedAt).Format("15:04:05"))
 		fmt.Printf("\n")
 	}
 
@@ -68,13 +71,13 @@ func (m *MonitorCommand) DisplayCurrent(ctx context.Context) error {
 }
 
 // DisplayRealtime shows real-time updates with the specified refresh interval
-func (m *MonitorCommand) DisplayRealtime(ctx context.Context, refresh time.Duration) error {
+func (m *MonitorCommand) DisplayRealtime(ctx context.Context, currency string, refresh time.Duration) error {
 	ticker := time.NewTicker(refresh)
 	defer ticker.Stop()
 
 	// Clear screen and show initial data
 	clearScreen()
-	if err := m.displayRealtimeOnce(ctx); err != nil {
+	if err := m.displayRealtimeOnce(ctx, currency); err != nil {
 		return err
 	}
 
@@ -84,39 +87,39 @@ func (m *MonitorCommand) DisplayRealtime(ctx context.Context, refresh time.Durat
 			return ctx.Err()
 		case <-ticker.C:
 			clearScreen()
-			if err := m.displayRealtimeOnce(ctx); err != nil {
+			if err := m.displayRealtimeOnce(ctx, currency); err != nil {
 				m.logger.Warn("failed to refresh display", "error", err)
 			}
 		}
 	}
 }
 
-func (m *MonitorCommand) displayRealtimeOnce(ctx context.Context) error {
-	rate, err := m.repo.GetLatestRate(ctx)
+func (m *MonitorCommand) displayRealtimeOnce(ctx context.Context, currency string) error {
+	rate, err := m.repo.GetLatestRate(ctx, currency)
 	if err != nil {
 		return fmt.Errorf("getting latest rate: %w", err)
 	}
 
 	if rate == nil {
-		fmt.Println("\nNo data available yet. Make sure the daemon is running.\n")
+		fmt.Print("\nNo data available yet. Make sure the daemon is running.\n\n")
 		return nil
 	}
 
 	now := time.Now()
-	fmt.Printf("USD/CNY Exchange Rate Monitor\n")
+	fmt.Printf("%s/CNY Exchange Rate Monitor\n", rate.CurrencyCode)
 	fmt.Printf("═════════════════════════════\n")
 	fmt.Printf("\n")
-	fmt.Printf("  Current Rate:    %.4f CNY\n", rate.RtcBid)
+	fmt.Printf("  Current Rate:    %s CNY\n", rate.RtcBid)
 	fmt.Printf("  Last Updated:    %s (%s ago)\n",
-		rate.CollectedAt.Format("2006-01-02 15:04:05"),
+		displayTime(rate.CollectedAt).Format("2006-01-02 15:04:05"),
 		formatDuration(now.Sub(rate.CollectedAt)))
 	fmt.Printf("\n")
 
 	// Get previous rate for comparison
-	prevRate, err := m.getPreviousRate(ctx, rate.CollectedAt)
+	prevRate, err := m.getPreviousRate(ctx, currency, rate.CollectedAt)
 	if err == nil && prevRate != nil {
 		delta := rate.RtcBid - prevRate.RtcBid
-		deltaPercent := (delta / prevRate.RtcBid) * 100
+		deltaPercent := delta.Float64() / prevRate.RtcBid.Float64() * 100
 
 		symbol := "→"
 		color := ""
@@ -128,8 +131,8 @@ func (m *MonitorCommand) displayRealtimeOnce(ctx context.Context) error {
 			color = " (decreasing)"
 		}
 
-		fmt.Printf("  Change:          %s %.4f (%.2f%%)%s\n", symbol, delta, deltaPercent, color)
-		fmt.Printf("  Previous Rate:   %.4f CNY\n", prevRate.RtcBid)
+		fmt.Printf("  Change:          %s %s (%.2f%%)%s\n", symbol, delta, deltaPercent, color)
+		fmt.Printf("  Previous Rate:   %s CNY\n", prevRate.RtcBid)
 		fmt.Printf("\n")
 	}
 
@@ -141,17 +144,17 @@ func (m *MonitorCommand) displayRealtimeOnce(ctx context.Context) error {
 
 	fmt.Printf("\n")
 	fmt.Printf("  Press Ctrl+C to exit\n")
-	fmt.Printf("  Refreshed at: %s\n", now.Format("15:04:05"))
+	fmt.Printf("  Refreshed at: %s\n", displayTime(now).Format("15:04:05"))
 
 	return nil
 }
 
-func (m *MonitorCommand) getPreviousRate(ctx context.Context, currentTime time.Time) (*storage.ExchangeRate, error) {
+func (m *MonitorCommand) getPreviousRate(ctx context.Context, currency string, currentTime time.Time) (*storage.ExchangeRate, error) {
 	// Get rates from the last 2 hours to find the previous reading
 	start := currentTime.Add(-2 * time.Hour)
 	end := currentTime.Add(-1 * time.Second) // Exclude current reading
 
-	rates, err := m.repo.GetRatesByTimeRange(ctx, start, end)
+	rates, err := m.repo.GetRatesByTimeRange(ctx, currency, start, end)
 	if err != nil {
 		return nil, err
 	}
@@ -5,52 +5,66 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
 // PatternsCommand handles the patterns command functionality
 type PatternsCommand struct {
-	repo   *storage.Repository
+	repo   storage.RateReader
 	logger *slog.Logger
 }
 
 // NewPatternsCommand creates a new patterns command handler
-func NewPatternsCommand(repo *storage.Repository, logger *slog.Logger) *PatternsCommand {
+// A store that sees every source is scoped to the default one
+func NewPatternsCommand(repo storage.RateReader, logger *slog.Logger) *PatternsCommand {
 	return &PatternsCommand{
-		repo:   repo,
+		repo:   singleSource(repo),
 		logger: logger,
 	}
 }
 
-// DisplayPatterns shows historical patterns in exchange rates
-func (p *PatternsCommand) DisplayPatterns(ctx context.Context, days, weeks int) error {
+// DisplayPatterns shows historical patterns in a currency's exchange rates
+func (p *PatternsCommand) DisplayPatterns(ctx context.Context, currency string, days, weeks int) error {
 	fmt.Printf("\n")
-	fmt.Printf("Exchange Rate Patterns Analysis\n")
-	fmt.Printf("═══════════════════════════════\n")
+	fmt.Printf("%s/CNY Exchange Rate Patterns Analysis\n", currency)
+	fmt.Printf("═══════════════════════════════════════\n")
 	fmt.Printf("Analyzing last %d days of data\n", days)
 	fmt.Printf("\n")
 
-	// Get hourly patterns
-	hourlyPatterns, err := p.repo.GetHourlyPatterns(ctx, days)
+	// Get hourly patterns; days whose hourly rollups retention has deleted
+	// come back at the daily tier, without hours
+	hourly, err := p.series(ctx, currency, days, storage.TierHourly)
 	if err != nil {
 		return fmt.Errorf("getting hourly patterns: %w", err)
 	}
 
-	if len(hourlyPatterns) == 0 {
+	if len(hourly) == 0 {
 		fmt.Println("No data available for pattern analysis")
 		return nil
 	}
 
+	hourlyPatterns := storage.HourlyPatternsOf(hourly)
+	hourDays, dailyOnly := countDays(hourly)
+	if dailyOnly > 0 {
+		fmt.Printf("ℹ️  %d of these days only have daily rollups left; hourly patterns cover the other %d\n\n",
+			dailyOnly, hourDays)
+	}
+
 	// Display hourly patterns
-	p.displayHourlyPatterns(hourlyPatterns, days)
+	if len(hourlyPatterns) > 0 {
+		p.displayHourlyPatterns(hourlyPatterns, hourDays)
+	}
 
 	// Get day of week patterns if we have enough data
 	if weeks > 0 {
-		dowPatterns, err := p.repo.GetDayOfWeekPatterns(ctx, weeks)
+		daily, err := p.series(ctx, currency, weeks*7, storage.TierDaily)
 		if err != nil {
 			p.logger.Warn("failed to get day of week patterns", "error", err)
-		} else if len(dowPatterns) > 0 {
+		} else if dowPatterns := storage.DayOfWeekPatternsOf(daily); len(dowPatterns) > 0 {
 			p.displayDayOfWeekPatterns(dowPatterns, weeks)
 		}
 	}
@@ -58,6 +72,35 @@ func (p *PatternsCommand) DisplayPatterns(ctx context.Context, days, weeks int)
 	return nil
 }
 
+// series reads a currency's rates over the last N market days at a
+// resolution
+func (p *PatternsCommand) series(ctx context.Context, currency string, days int, resolution storage.Tier) ([]storage.SeriesPoint, error) {
+	start, err := time.ParseInLocation("2006-01-02", market.DaysAgo(days), market.Zone)
+	if err != nil {
+		return nil, fmt.Errorf("parsing date: %w", err)
+	}
+
+	return p.repo.GetSeries(ctx, currency, start, time.Now(), resolution)
+}
+
+// countDays returns how many market dates of a series have hours, and how
+// many only a daily rollup
+func countDays(points []storage.SeriesPoint) (hourDays, dailyOnly int) {
+	seen := make(map[string]bool)
+	for _, p := range points {
+		if seen[p.DatePartition] {
+			continue
+		}
+		seen[p.DatePartition] = true
+		if p.Tier == storage.TierDaily {
+			dailyOnly++
+		} else {
+			hourDays++
+		}
+	}
+	return hourDays, dailyOnly
+}
+
 func (p *PatternsCommand) displayHourlyPatterns(patterns []storage.HourlyPattern, days int) {
 	fmt.Printf("Hourly Patterns (Business Hours 08:00-22:00 CST)\n")
 	fmt.Printf("─────────────────────────────────────────────────\n")
@@ -93,7 +136,7 @@ func (p *PatternsCommand) displayHourlyPatterns(patterns []storage.HourlyPattern
 			peakFreqPct = (float64(pattern.PeakFreq) / float64(days)) * 100
 		}
 
-		fmt.Printf("%02d:00    %10.4f  %10.4f  %10.4f  %8d  %3d (%4.1f%%)%s\n",
+		fmt.Printf("%02d:00    %10s  %10s  %10s  %8d  %3d (%4.1f%%)%s\n",
 			pattern.Hour,
 			pattern.AvgRate,
 			pattern.MinRate,
@@ -109,7 +152,7 @@ func (p *PatternsCommand) displayHourlyPatterns(patterns []storage.HourlyPattern
 	// Key insights
 	fmt.Printf("Key Insights:\n")
 	if highestAvgHour != nil {
-		fmt.Printf("  • Highest average rate: %02d:00 (%.4f CNY)\n",
+		fmt.Printf("  • Highest average rate: %02d:00 (%s CNY)\n",
 			highestAvgHour.Hour, highestAvgHour.AvgRate)
 	}
 	if mostPeaksHour != nil && mostPeaksHour.PeakFreq > 0 {
@@ -119,7 +162,7 @@ func (p *PatternsCommand) displayHourlyPatterns(patterns []storage.HourlyPattern
 	}
 
 	// Find volatility window (highest range)
-	var maxRange float64
+	var maxRange fixed.Rate
 	var maxRangeHour int
 	for _, p := range patterns {
 		rng := p.MaxRate - p.MinRate
@@ -129,7 +172,7 @@ func (p *PatternsCommand) displayHourlyPatterns(patterns []storage.HourlyPattern
 		}
 	}
 	if maxRange > 0 {
-		fmt.Printf("  • Most volatile hour: %02d:00 (range: %.4f CNY)\n",
+		fmt.Printf("  • Most volatile hour: %02d:00 (range: %s CNY)\n",
 			maxRangeHour, maxRange)
 	}
 	fmt.Printf("\n")
@@ -165,7 +208,7 @@ func (p *PatternsCommand) displayDayOfWeekPatterns(patterns []storage.DayOfWeekP
 			indicator = " ↓ Lowest"
 		}
 
-		fmt.Printf("%-10s  %10.4f  %10.4f  %10.4f  %10.4f    %8d%s\n",
+		fmt.Printf("%-10s  %10s  %10s  %10s  %10s    %8d%s\n",
 			pattern.DayName,
 			pattern.AvgRate,
 			pattern.MinRate,
@@ -181,11 +224,11 @@ func (p *PatternsCommand) displayDayOfWeekPatterns(patterns []storage.DayOfWeekP
 	fmt.Printf("Weekly Insights:\n")
 	if bestDay != nil && worstDay != nil {
 		diff := bestDay.AvgRate - worstDay.AvgRate
-		fmt.Printf("  • Best day: %s (avg %.4f CNY)\n",
+		fmt.Printf("  • Best day: %s (avg %s CNY)\n",
 			bestDay.DayName, bestDay.AvgRate)
-		fmt.Printf("  • Lowest day: %s (avg %.4f CNY)\n",
+		fmt.Printf("  • Lowest day: %s (avg %s CNY)\n",
 			worstDay.DayName, worstDay.AvgRate)
-		fmt.Printf("  • Weekly variance: %.4f CNY\n", diff)
+		fmt.Printf("  • Weekly variance: %s CNY\n", diff)
 	}
 
 	// Find most volatile day
@@ -196,7 +239,7 @@ func (p *PatternsCommand) displayDayOfWeekPatterns(patterns []storage.DayOfWeekP
 		}
 	}
 	if mostVolatile != nil {
-		fmt.Printf("  • Most volatile day: %s (avg range: %.4f CNY)\n",
+		fmt.Printf("  • Most volatile day: %s (avg range: %s CNY)\n",
 			mostVolatile.DayName, mostVolatile.AvgRange)
 	}
 	fmt.Printf("\n")
@@ -5,110 +5,124 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
-	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
 // PeakCommand handles the peak command functionality
 type PeakCommand struct {
-	repo   *storage.Repository
+	repo   storage.RateReader
 	logger *slog.Logger
 }
 
 // NewPeakCommand creates a new peak command handler
-func NewPeakCommand(repo *storage.Repository, logger *slog.Logger) *PeakCommand {
+// A store that sees every source is scoped to the default one
+func NewPeakCommand(repo storage.RateReader, logger *slog.Logger) *PeakCommand {
 	return &PeakCommand{
-		repo:   repo,
+		repo:   singleSource(repo),
 		logger: logger,
 	}
 }
 
-// DisplayPeak shows the daily peak exchange rate
-func (p *PeakCommand) DisplayPeak(ctx context.Context, dates []string) error {
+// DisplayPeak shows the daily peak exchange rate of a currency
+func (p *PeakCommand) DisplayPeak(ctx context.Context, currency string, dates []string) error {
 	if len(dates) == 0 {
 		return fmt.Errorf("no dates specified")
 	}
 
+	notes, byDate, err := dateAnnotations(ctx, p.repo, currency, dates)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("\n")
-	fmt.Printf("Daily Peak Exchange Rates\n")
-	fmt.Printf("═════════════════════════\n")
+	fmt.Printf("Daily Peak %s/CNY Exchange Rates\n", currency)
+	fmt.Printf("═════════════════════════════════\n")
 	fmt.Printf("\n")
 
 	for _, date := range dates {
-		peak, err := p.repo.GetDailyPeak(ctx, date)
+		peak, err := p.dailyPeak(ctx, currency, date)
 		if err != nil {
 			return fmt.Errorf("getting peak for %s: %w", date, err)
 		}
 
 		if peak == nil {
 			fmt.Printf("%-12s  No data available\n", date)
+			printDateAnnotations(notes, byDate[date])
 			continue
 		}
 
 		fmt.Printf("%-12s\n", date)
-		fmt.Printf("  Peak Rate:  %.4f CNY\n", peak.RtcBid)
-		fmt.Printf("  Time:       %s\n", peak.CollectedAt.Format("15:04:05"))
+		fmt.Printf("  Peak Rate:  %s CNY\n", peak.MaxRate)
+		fmt.Printf("  Time:       %s\n", displayTime(peak.PeakTime).Format("15:04:05"))
+		printDateAnnotations(notes, byDate[date])
 		fmt.Printf("\n")
 	}
 
 	return nil
 }
 
-// DisplayPeakRange shows peak rates for a range of recent days
-func (p *PeakCommand) DisplayPeakRange(ctx context.Context, days int) error {
+// DisplayPeakRange shows peak rates of a currency for a range of recent days
+func (p *PeakCommand) DisplayPeakRange(ctx context.Context, currency string, days int) error {
 	dates := getRecentDates(days)
 
+	notes, byDate, err := dateAnnotations(ctx, p.repo, currency, dates)
+	if err != nil {
+		return err
+	}
+
 	fmt.Printf("\n")
-	fmt.Printf("Daily Peak Exchange Rates (Last %d Days)\n", days)
-	fmt.Printf("═════════════════════════════════════════\n")
+	fmt.Printf("Daily Peak %s/CNY Exchange Rates (Last %d Days)\n", currency, days)
+	fmt.Printf("═════════════════════════════════════════════════\n")
 	fmt.Printf("\n")
 
 	// Display table header
-	fmt.Printf("%-12s  %-10s  %-10s\n", "Date", "Peak (CNY)", "Time")
-	fmt.Printf("%s\n", strings.Repeat("─", 40))
+	fmt.Printf("%-12s  %-10s  %-10s  %s\n", "Date", "Peak (CNY)", "Time", "Notes")
+	fmt.Printf("%s\n", strings.Repeat("─", 48))
 
 	var peaks []struct {
-		date string
-		rate *storage.ExchangeRate
+		date  string
+		stats *storage.DailyStats
 	}
 
 	for _, date := range dates {
-		peak, err := p.repo.GetDailyPeak(ctx, date)
+		peak, err := p.dailyPeak(ctx, currency, date)
 		if err != nil {
 			p.logger.Warn("failed to get peak", "date", date, "error", err)
 			continue
 		}
 
 		peaks = append(peaks, struct {
-			date string
-			rate *storage.ExchangeRate
-		}{date: date, rate: peak})
+			date  string
+			stats *storage.DailyStats
+		}{date: date, stats: peak})
 
 		if peak == nil {
-			fmt.Printf("%-12s  %-10s  %-10s\n", date, "No data", "-")
+			fmt.Printf("%-12s  %-10s  %-10s  %s\n", date, "No data", "-", noteLabels(byDate[date]))
 		} else {
-			fmt.Printf("%-12s  %10.4f  %-10s\n",
+			fmt.Printf("%-12s  %10s  %-10s  %s\n",
 				date,
-				peak.RtcBid,
-				peak.CollectedAt.Format("15:04:05"))
+				peak.MaxRate,
+				displayTime(peak.PeakTime).Format("15:04:05"),
+				noteLabels(byDate[date]))
 		}
 	}
 
 	fmt.Printf("\n")
 
 	// Display summary statistics if we have data
-	var validPeaks []float64
+	var validPeaks []fixed.Rate
 	for _, p := range peaks {
-		if p.rate != nil {
-			validPeaks = append(validPeaks, p.rate.RtcBid)
+		if p.stats != nil {
+			validPeaks = append(validPeaks, p.stats.MaxRate)
 		}
 	}
 
 	if len(validPeaks) > 0 {
-		var maxPeak, minPeak, sumPeak float64
-		maxPeak = validPeaks[0]
-		minPeak = validPeaks[0]
+		maxPeak := validPeaks[0]
+		minPeak := validPeaks[0]
 
 		for _, rate := range validPeaks {
 			if rate > maxPeak {
@@ -117,28 +131,36 @@ func (p *PeakCommand) DisplayPeakRange(ctx context.Context, days int) error {
 			if rate < minPeak {
 				minPeak = rate
 			}
-			sumPeak += rate
 		}
-		avgPeak := sumPeak / float64(len(validPeaks))
+		avgPeak := fixed.Mean(validPeaks)
 
 		fmt.Printf("Summary:\n")
-		fmt.Printf("  Highest Peak:   %.4f CNY\n", maxPeak)
-		fmt.Printf("  Lowest Peak:    %.4f CNY\n", minPeak)
-		fmt.Printf("  Average Peak:   %.4f CNY\n", avgPeak)
-		fmt.Printf("  Peak Range:     %.4f CNY\n", maxPeak-minPeak)
+		fmt.Printf("  Highest Peak:   %s CNY\n", maxPeak)
+		fmt.Printf("  Lowest Peak:    %s CNY\n", minPeak)
+		fmt.Printf("  Average Peak:   %s CNY\n", avgPeak)
+		fmt.Printf("  Peak Range:     %s CNY\n", maxPeak-minPeak)
 		fmt.Printf("\n")
 	}
 
+	printAnnotations(notes)
 	return nil
 }
 
+// dailyPeak returns the statistics of a date, whose peak is its maximum and
+// peak time, read from the daily rollups (nil without data)
+func (p *PeakCommand) dailyPeak(ctx context.Context, currency, date string) (*storage.DailyStats, error) {
+	stats, err := p.repo.GetDailyStats(ctx, currency, date)
+	if err != nil || stats.SampleCount == 0 {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// getRecentDates returns the last days market dates, today first
 func getRecentDates(days int) []string {
 	dates := make([]string, days)
-	now := time.Now()
-
 	for i := 0; i < days; i++ {
-		date := now.AddDate(0, 0, -i)
-		dates[i] = date.Format("2006-01-02")
+		dates[i] = market.DaysAgo(i)
 	}
 
 	return dates
@@ -7,19 +7,22 @@ import (
 	"strings"
 	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/recommender"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
 // RecommendCommand handles the recommend command functionality
 type RecommendCommand struct {
-	repo       *storage.Repository
+	repo       storage.RateReader
 	recommender *recommender.Recommender
 	logger     *slog.Logger
 }
 
 // NewRecommendCommand creates a new recommend command handler
-func NewRecommendCommand(repo *storage.Repository, logger *slog.Logger) *RecommendCommand {
+// A store that sees every source is scoped to the default one
+func NewRecommendCommand(repo storage.RateReader, logger *slog.Logger) *RecommendCommand {
+	repo = singleSource(repo)
 	return &RecommendCommand{
 		repo:       repo,
 		recommender: recommender.NewRecommender(repo, logger),
@@ -27,9 +30,20 @@ func NewRecommendCommand(repo *storage.Repository, logger *slog.Logger) *Recomme
 	}
 }
 
-// DisplayRecommendation shows the exchange recommendation
-func (c *RecommendCommand) DisplayRecommendation(ctx context.Context, amount float64, showDetails bool) error {
-	rec, err := c.recommender.GetRecommendation(ctx, amount)
+// singleSource scopes a store that sees every source to the default source
+// Commands that compare quotes with each other or summarize a day (peaks,
+// averages, patterns, recommendations) would otherwise mix banks, reading the
+// spread between them as movement; other readers are kept as is
+func singleSource(repo storage.RateReader) storage.RateReader {
+	if store, ok := repo.(storage.Store); ok && store.Source() == "" {
+		return store.WithSource(storage.DefaultSource)
+	}
+	return repo
+}
+
+// DisplayRecommendation shows the exchange recommendation for buying a currency
+func (c *RecommendCommand) DisplayRecommendation(ctx context.Context, currency string, amount float64, showDetails bool) error {
+	rec, err := c.recommender.GetRecommendation(ctx, currency, amount)
 	if err != nil {
 		return fmt.Errorf("getting recommendation: %w", err)
 	}
@@ -41,11 +55,12 @@ func (c *RecommendCommand) DisplayRecommendation(ctx context.Context, amount flo
 	fmt.Printf("\n")
 
 	// Current rate and conversion
-	fmt.Printf("Current Rate:    %.4f CNY per USD\n", rec.CurrentRate)
+	fmt.Printf("Current Rate:    %s CNY per %s\n", rec.CurrentRate, rec.Currency)
 	if amount > 0 {
-		fmt.Printf("Amount:          %s RMB → %s USD\n",
+		fmt.Printf("Amount:          %s RMB → %s %s\n",
 			formatMoney(rec.Amount),
-			formatMoney(rec.USDAmount))
+			formatMoney(rec.USDAmount),
+			rec.Currency)
 	}
 	fmt.Printf("\n")
 
@@ -64,22 +79,22 @@ func (c *RecommendCommand) DisplayRecommendation(ctx context.Context, amount flo
 	fmt.Printf("\n")
 
 	// Show percentile context
-	ranking, _ := c.recommender.GetHistoricalRanking(ctx, rec.CurrentRate, 30)
+	ranking, _ := c.recommender.GetHistoricalRanking(ctx, currency, rec.CurrentRate, 30)
 	fmt.Printf("Historical Context (Last 30 Days):\n")
 	fmt.Printf("  Percentile:      %.0fth (out of 100)\n", rec.PercentileRank)
 	fmt.Printf("  Ranking:         %s\n", ranking)
-	fmt.Printf("  30-Day Average:  %.4f CNY\n", rec.HistoricalStats.AvgRate30Days)
-	fmt.Printf("  30-Day Range:    %.4f - %.4f CNY\n", rec.HistoricalStats.MinRate30Days, rec.HistoricalStats.MaxRate30Days)
+	fmt.Printf("  30-Day Average:  %s CNY\n", rec.HistoricalStats.AvgRate30Days)
+	fmt.Printf("  30-Day Range:    %s - %s CNY\n", rec.HistoricalStats.MinRate30Days, rec.HistoricalStats.MaxRate30Days)
 	fmt.Printf("\n")
 
 	// Potential gain/loss
 	if amount > 0 && (rec.PotentialGain > 0 || rec.PotentialLoss > 0) {
 		fmt.Printf("Risk/Reward Assessment:\n")
 		if rec.PotentialGain > 0 {
-			fmt.Printf("  Potential Gain:  +%s USD (if optimal timing)\n", formatMoney(rec.PotentialGain))
+			fmt.Printf("  Potential Gain:  +%s %s (if optimal timing)\n", formatMoney(rec.PotentialGain), rec.Currency)
 		}
 		if rec.PotentialLoss > 0 {
-			fmt.Printf("  Downside Risk:   -%s USD (worst case scenario)\n", formatMoney(rec.PotentialLoss))
+			fmt.Printf("  Downside Risk:   -%s %s (worst case scenario)\n", formatMoney(rec.PotentialLoss), rec.Currency)
 		}
 		fmt.Printf("  Risk Level:      %s\n", rec.RiskLevel)
 		fmt.Printf("\n")
@@ -96,7 +111,7 @@ func (c *RecommendCommand) DisplayRecommendation(ctx context.Context, amount flo
 
 	// Show detailed predictions if requested
 	if showDetails && len(rec.PredictedNextHours) > 0 {
-		c.displayPredictions(rec.PredictedNextHours, rec.CurrentRate)
+		c.displayPredictions(rec.PredictedNextHours, rec.CurrentRate.Float64())
 	}
 
 	return nil
@@ -179,8 +194,8 @@ func (c *RecommendCommand) displayPredictions(predictions []recommender.HourPred
 }
 
 // DisplayQuickCheck shows a simplified one-line recommendation
-func (c *RecommendCommand) DisplayQuickCheck(ctx context.Context) error {
-	rec, err := c.recommender.GetRecommendation(ctx, 0)
+func (c *RecommendCommand) DisplayQuickCheck(ctx context.Context, currency string) error {
+	rec, err := c.recommender.GetRecommendation(ctx, currency, 0)
 	if err != nil {
 		return fmt.Errorf("getting recommendation: %w", err)
 	}
@@ -196,7 +211,8 @@ func (c *RecommendCommand) DisplayQuickCheck(ctx context.Context) error {
 	}
 
 	fmt.Printf("\n")
-	fmt.Printf("Rate: %.4f CNY  |  %s  |  Confidence: %.0f%%  |  Percentile: %.0fth\n",
+	fmt.Printf("%s: %s CNY  |  %s  |  Confidence: %.0f%%  |  Percentile: %.0fth\n",
+		rec.Currency,
 		rec.CurrentRate,
 		actionText,
 		rec.ConfidenceScore,
@@ -215,13 +231,13 @@ func (c *RecommendCommand) DisplayQuickCheck(ctx context.Context) error {
 }
 
 // DisplayHistoricalRanking shows where a rate ranks historically
-func (c *RecommendCommand) DisplayHistoricalRanking(ctx context.Context, rate float64, days int) error {
-	percentile, err := c.recommender.GetPercentileRank(ctx, rate, days)
+func (c *RecommendCommand) DisplayHistoricalRanking(ctx context.Context, currency string, rate fixed.Rate, days int) error {
+	percentile, err := c.recommender.GetPercentileRank(ctx, currency, rate, days)
 	if err != nil {
 		return fmt.Errorf("calculating percentile: %w", err)
 	}
 
-	ranking, err := c.recommender.GetHistoricalRanking(ctx, rate, days)
+	ranking, err := c.recommender.GetHistoricalRanking(ctx, currency, rate, days)
 	if err != nil {
 		return fmt.Errorf("getting ranking: %w", err)
 	}
@@ -230,7 +246,7 @@ func (c *RecommendCommand) DisplayHistoricalRanking(ctx context.Context, rate fl
 	fmt.Printf("Historical Ranking\n")
 	fmt.Printf("══════════════════\n")
 	fmt.Printf("\n")
-	fmt.Printf("Rate:        %.4f CNY\n", rate)
+	fmt.Printf("Rate:        %s CNY per %s\n", rate, currency)
 	fmt.Printf("Period:      Last %d days\n", days)
 	fmt.Printf("Percentile:  %.0fth\n", percentile)
 	fmt.Printf("Ranking:     %s\n", ranking)
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/api"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// ReparseCommand rebuilds exchange rates from archived raw responses
+type ReparseCommand struct {
+	repo   storage.Archive
+	logger *slog.Logger
+}
+
+// NewReparseCommand creates a new reparse command handler
+func NewReparseCommand(repo storage.Archive, logger *slog.Logger) *ReparseCommand {
+	return &ReparseCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Run re-extracts every archived response between startDate and endDate
+// (YYYY-MM-DD, inclusive; empty for no bound) with the current parsers and
+// replaces the rates linked to those responses, one date per transaction
+// Rates stored before archival was enabled are left untouched
+func (c *ReparseCommand) Run(ctx context.Context, startDate, endDate string, dryRun bool) error {
+	dates, err := c.repo.GetArchiveDates(ctx, startDate, endDate)
+	if err != nil {
+		return fmt.Errorf("getting archive dates: %w", err)
+	}
+
+	if len(dates) == 0 {
+		fmt.Println("No archived responses found. Run the daemon with --archive-raw to collect them.")
+		return nil
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Reparse Archived Responses\n")
+	fmt.Printf("══════════════════════════\n")
+	fmt.Printf("Date range: %s to %s\n", dates[0], dates[len(dates)-1])
+	if dryRun {
+		fmt.Printf("DRY RUN MODE - No actual changes will be made\n")
+	}
+	fmt.Printf("\n")
+
+	fmt.Printf("%-12s  %-10s  %-8s  %-10s  %-10s\n", "Date", "Responses", "Failed", "Old Rows", "New Rows")
+	fmt.Printf("%s\n", strings.Repeat("─", 58))
+
+	// Last rebuilt quote per source and currency, for de-duplication across dates
+	last := make(map[string]*storage.ExchangeRate)
+	var totalResponses, totalFailed, totalNew int
+	var totalOld int64
+
+	for _, date := range dates {
+		responses, err := c.repo.GetRawResponsesForDate(ctx, date)
+		if err != nil {
+			return fmt.Errorf("loading responses for %s: %w", date, err)
+		}
+
+		ids := make([]int64, 0, len(responses))
+		var rates []*storage.ExchangeRate
+		failed := 0
+
+		for _, resp := range responses {
+			ids = append(ids, resp.ID)
+
+			quotes, err := api.ParseRaw(resp.Source, resp.Body)
+			if err != nil {
+				failed++
+				c.logger.Warn("failed to parse archived response",
+					"id", resp.ID,
+					"source", resp.Source,
+					"collected_at", resp.CollectedAt,
+					"error", err)
+				continue
+			}
+
+			for _, q := range quotes {
+				rate := &storage.ExchangeRate{
+					CurrencyCode:  q.Currency,
+					Source:        resp.Source,
+					RtcBid:        q.RtcBid,
+					RtbBid:        q.RtbBid,
+					RthBid:        q.RthBid,
+					RthOfr:        q.RthOfr,
+					RtcOfr:        q.RtcOfr,
+					QuotedAt:      q.QuotedAt,
+					CollectedAt:   resp.CollectedAt,
+					ResponseID:    resp.ID,
+					DatePartition: resp.DatePartition,
+				}
+
+				key := resp.Source + "/" + q.Currency
+				if prev := last[key]; prev != nil && prev.SameQuote(rate) {
+					continue
+				}
+				last[key] = rate
+				rates = append(rates, rate)
+			}
+		}
+
+		var old int64
+		if dryRun {
+			old, err = c.repo.CountArchivedRates(ctx, date)
+		} else {
+			old, err = c.repo.ReplaceArchivedRates(ctx, ids, rates)
+		}
+		if err != nil {
+			return fmt.Errorf("rebuilding %s: %w", date, err)
+		}
+
+		fmt.Printf("%-12s  %10d  %8d  %10d  %10d\n", date, len(responses), failed, old, len(rates))
+
+		totalResponses += len(responses)
+		totalFailed += failed
+		totalOld += old
+		totalNew += len(rates)
+	}
+
+	fmt.Printf("%s\n", strings.Repeat("─", 58))
+	fmt.Printf("%-12s  %10d  %8d  %10d  %10d\n", "Total", totalResponses, totalFailed, totalOld, totalNew)
+	fmt.Printf("\n")
+
+	if dryRun {
+		fmt.Println("Run without --dry-run to replace the old rows.")
+	} else {
+		fmt.Println("Reparse complete. Rollups of the rebuilt dates were refreshed.")
+	}
+
+	return nil
+}
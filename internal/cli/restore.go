@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// RestoreCommand replaces the database with a snapshot
+type RestoreCommand struct {
+	db     *storage.DB
+	logger *slog.Logger
+}
+
+// NewRestoreCommand creates a new restore command handler
+func NewRestoreCommand(db *storage.DB, logger *slog.Logger) *RestoreCommand {
+	return &RestoreCommand{
+		db:     db,
+		logger: logger,
+	}
+}
+
+// Run verifies the snapshot and restores it over the database, first saving
+// the current contents as a snapshot in backupDir (skipped when empty)
+func (c *RestoreCommand) Run(ctx context.Context, snapshot, backupDir string) error {
+	if _, err := storage.VerifyBackup(ctx, snapshot); err != nil {
+		return err
+	}
+
+	if backupDir != "" {
+		current, err := c.db.Snapshot(ctx, backupDir, 0)
+		if err != nil {
+			return fmt.Errorf("saving the current database: %w", err)
+		}
+		fmt.Printf("Saved the current database to %s\n", current.Path)
+	}
+
+	info, err := c.db.Restore(ctx, snapshot)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("✅ Restored %s\n", snapshot)
+	printBackupInfo(info)
+	return nil
+}
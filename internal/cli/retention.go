@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
@@ -46,48 +47,35 @@ func (r *RetentionCommand) Run(ctx context.Context, rawRetentionDays, hourlyRete
 	fmt.Printf("Date range: %s to %s\n\n", oldDates[0], oldDates[len(oldDates)-1])
 
 	if dryRun {
-		fmt.Println("DRY RUN MODE - No actual changes will be made\n")
-	}
-
-	// Process each date
-	totalHourlyCreated := 0
-	totalDailyCreated := 0
-
-	for _, date := range oldDates {
-		r.logger.Debug("processing date", "date", date)
-
-		if !dryRun {
-			// Create hourly aggregates
-			hourlyCount, err := r.repo.AggregateToHourly(ctx, date)
-			if err != nil {
-				r.logger.Error("failed to aggregate hourly", "date", date, "error", err)
-				continue
-			}
-			totalHourlyCreated += hourlyCount
-
-			// Create daily aggregates
-			err = r.repo.AggregateToDaily(ctx, date)
-			if err != nil {
-				r.logger.Error("failed to aggregate daily", "date", date, "error", err)
-				continue
-			}
-			totalDailyCreated++
-		}
+		fmt.Print("DRY RUN MODE - No actual changes will be made\n\n")
 	}
 
 	if !dryRun {
-		fmt.Printf("✅ Created %d hourly aggregates and %d daily aggregates\n\n", totalHourlyCreated, totalDailyCreated)
+		// Rollups are maintained as rates are stored; bring those of the
+		// dates about to lose their raw data up to date first
+		hourly, daily, err := r.repo.RebuildRollups(ctx, oldDates[0], oldDates[len(oldDates)-1])
+		if err != nil {
+			return fmt.Errorf("refreshing rollups: %w", err)
+		}
+		fmt.Printf("✅ Refreshed %d hourly and %d daily rollups\n\n", hourly, daily)
 
 		// Delete old raw data
-		cutoffDate := time.Now().AddDate(0, 0, -rawRetentionDays).Format("2006-01-02")
+		cutoffDate := market.DaysAgo(rawRetentionDays)
 		deletedRaw, err := r.repo.DeleteRawDataBefore(ctx, cutoffDate)
 		if err != nil {
 			return fmt.Errorf("deleting old raw data: %w", err)
 		}
 		fmt.Printf("🗑️  Deleted %d raw records older than %s\n", deletedRaw, cutoffDate)
 
+		// The poll log only serves coverage of raw data
+		deletedPolls, err := r.repo.DeletePollsBefore(ctx, cutoffDate)
+		if err != nil {
+			return fmt.Errorf("deleting old polls: %w", err)
+		}
+		fmt.Printf("🗑️  Deleted %d logged polls older than %s\n", deletedPolls, cutoffDate)
+
 		// Delete old hourly data
-		hourlyCutoffDate := time.Now().AddDate(0, 0, -hourlyRetentionDays).Format("2006-01-02")
+		hourlyCutoffDate := market.DaysAgo(hourlyRetentionDays)
 		deletedHourly, err := r.repo.DeleteHourlyDataBefore(ctx, hourlyCutoffDate)
 		if err != nil {
 			return fmt.Errorf("deleting old hourly data: %w", err)
@@ -95,7 +83,7 @@ func (r *RetentionCommand) Run(ctx context.Context, rawRetentionDays, hourlyRete
 		fmt.Printf("🗑️  Deleted %d hourly records older than %s\n\n", deletedHourly, hourlyCutoffDate)
 	} else {
 		fmt.Printf("Would create ~%d hourly aggregates and %d daily aggregates\n", len(oldDates)*14, len(oldDates))
-		fmt.Printf("Would delete raw data older than %d days\n", rawRetentionDays)
+		fmt.Printf("Would delete raw data and logged polls older than %d days\n", rawRetentionDays)
 		fmt.Printf("Would delete hourly data older than %d days\n\n", hourlyRetentionDays)
 	}
 
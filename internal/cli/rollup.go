@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// RollupCommand rebuilds the hourly and daily rollups from raw data
+type RollupCommand struct {
+	repo   *storage.Repository
+	logger *slog.Logger
+}
+
+// NewRollupCommand creates a new rollup command handler
+func NewRollupCommand(repo *storage.Repository, logger *slog.Logger) *RollupCommand {
+	return &RollupCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Run rebuilds the rollups of every date from start to end (YYYY-MM-DD,
+// inclusive) that still has raw data. Rollups are kept up to date as rates
+// are stored, so this only repairs rows changed outside ratemon; running it
+// again changes nothing
+func (c *RollupCommand) Run(ctx context.Context, start, end string) error {
+	hourly, daily, err := c.repo.RebuildRollups(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("rebuilding rollups: %w", err)
+	}
+
+	if daily == 0 {
+		fmt.Printf("No raw data between %s and %s; rollups left as they are.\n", start, end)
+		return nil
+	}
+
+	c.logger.Info("rebuilt rollups", "start", start, "end", end, "hourly", hourly, "daily", daily)
+	fmt.Printf("✅ Rebuilt %d hourly and %d daily rollups from %s to %s\n", hourly, daily, start, end)
+	return nil
+}
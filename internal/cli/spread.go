@@ -0,0 +1,135 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// SpreadCommand handles the spread command functionality
+type SpreadCommand struct {
+	repo   storage.RateReader
+	logger *slog.Logger
+}
+
+// NewSpreadCommand creates a new spread command handler
+func NewSpreadCommand(repo storage.RateReader, logger *slog.Logger) *SpreadCommand {
+	return &SpreadCommand{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// DisplaySpreads shows the bank's bid/offer and cash-versus-spot spreads
+// over time, by hour of day and by day of week
+func (s *SpreadCommand) DisplaySpreads(ctx context.Context, currency string, days, weeks int) error {
+	fmt.Printf("\n")
+	fmt.Printf("%s/CNY Spread Analysis\n", currency)
+	fmt.Printf("═══════════════════════\n")
+	fmt.Printf("Analyzing last %d days of data\n", days)
+	fmt.Printf("\n")
+
+	daily, err := s.repo.GetDailySpreads(ctx, currency, days)
+	if err != nil {
+		return fmt.Errorf("getting daily spreads: %w", err)
+	}
+
+	if len(daily) == 0 {
+		fmt.Println("No quotes with all price sides available for spread analysis")
+		return nil
+	}
+
+	s.displaySpreadTable("Daily Spreads", "Date", daily)
+
+	hourly, err := s.repo.GetHourlySpreads(ctx, currency, days)
+	if err != nil {
+		return fmt.Errorf("getting hourly spreads: %w", err)
+	}
+	s.displaySpreadTable("Spreads by Hour of Day", "Hour", hourly)
+
+	if weeks > 0 {
+		dow, err := s.repo.GetDayOfWeekSpreads(ctx, currency, weeks)
+		if err != nil {
+			s.logger.Warn("failed to get day of week spreads", "error", err)
+		} else if len(dow) > 0 {
+			s.displaySpreadTable(fmt.Sprintf("Spreads by Day of Week (Last %d weeks)", weeks), "Day", dow)
+			s.displayInsights(hourly, dow)
+			return nil
+		}
+	}
+
+	s.displayInsights(hourly, nil)
+	return nil
+}
+
+func (s *SpreadCommand) displaySpreadTable(title, column string, spreads []storage.SpreadStats) {
+	fmt.Printf("%s\n", title)
+	fmt.Printf("%s\n", strings.Repeat("─", len(title)))
+	fmt.Printf("\n")
+
+	fmt.Printf("%-12s  %-10s  %-10s  %-10s  %-10s  %-12s  %-8s\n",
+		column, "Spot Avg", "Spot Min", "Spot Max", "Cash Avg", "Cash Premium", "Samples")
+	fmt.Printf("%s\n", strings.Repeat("─", 85))
+
+	narrowest := narrowestSpread(spreads)
+	for i, spread := range spreads {
+		indicator := ""
+		if i == narrowest {
+			indicator = " ⭐ Narrowest"
+		}
+
+		fmt.Printf("%-12s  %10s  %10s  %10s  %10s  %12s  %8d%s\n",
+			spread.Label,
+			spread.AvgSpotSpread,
+			spread.MinSpotSpread,
+			spread.MaxSpotSpread,
+			spread.AvgCashSpread,
+			spread.AvgCashPremium,
+			spread.SampleCount,
+			indicator,
+		)
+	}
+	fmt.Printf("\n")
+}
+
+func (s *SpreadCommand) displayInsights(hourly, dow []storage.SpreadStats) {
+	fmt.Printf("Key Insights:\n")
+	if i := narrowestSpread(hourly); i >= 0 {
+		fmt.Printf("  • Narrowest spot spread by hour: %s (avg %s CNY)\n",
+			hourly[i].Label, hourly[i].AvgSpotSpread)
+	}
+	if i := widestSpread(hourly); i >= 0 {
+		fmt.Printf("  • Widest spot spread by hour: %s (avg %s CNY)\n",
+			hourly[i].Label, hourly[i].AvgSpotSpread)
+	}
+	if i := narrowestSpread(dow); i >= 0 {
+		fmt.Printf("  • Narrowest spot spread by day: %s (avg %s CNY)\n",
+			dow[i].Label, dow[i].AvgSpotSpread)
+	}
+	fmt.Printf("\n")
+}
+
+// narrowestSpread returns the index of the group with the lowest average spot spread
+func narrowestSpread(spreads []storage.SpreadStats) int {
+	best := -1
+	for i := range spreads {
+		if best < 0 || spreads[i].AvgSpotSpread < spreads[best].AvgSpotSpread {
+			best = i
+		}
+	}
+	return best
+}
+
+// widestSpread returns the index of the group with the highest average spot spread
+func widestSpread(spreads []storage.SpreadStats) int {
+	worst := -1
+	for i := range spreads {
+		if worst < 0 || spreads[i].AvgSpotSpread > spreads[worst].AvgSpotSpread {
+			worst = i
+		}
+	}
+	return worst
+}
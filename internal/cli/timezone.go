@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// displayZone is the zone commands print times in and read times given
+// without an offset in. Storage keeps instants in UTC and analytics follow
+// the market's zone whatever this is set to
+var displayZone = market.Zone
+
+// SetTimezone sets the display zone (--tz): an IANA name such as
+// "Europe/London", "Local" for the host's zone or "UTC". An empty name keeps
+// the market's zone
+func SetTimezone(name string) error {
+	if name == "" {
+		displayZone = market.Zone
+		return nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("loading timezone %q: %w", name, err)
+	}
+	displayZone = loc
+	return nil
+}
+
+// displayTime converts an instant to the display zone
+func displayTime(t time.Time) time.Time {
+	return t.In(displayZone)
+}
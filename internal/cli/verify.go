@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// maxMismatchesShown caps the rollup mismatches listed before summarizing
+// the rest
+const maxMismatchesShown = 20
+
+// VerifyCommand checks the database's integrity and its rollups
+type VerifyCommand struct {
+	db     *storage.DB
+	repo   *storage.Repository
+	logger *slog.Logger
+}
+
+// NewVerifyCommand creates a new verify command handler
+func NewVerifyCommand(db *storage.DB, repo *storage.Repository, logger *slog.Logger) *VerifyCommand {
+	return &VerifyCommand{
+		db:     db,
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+// Run runs SQLite's integrity and foreign key checks, then recomputes the
+// rollups of every date from start to end (YYYY-MM-DD, inclusive) and
+// reports those that differ from what is stored. With repair the mismatched
+// rollups are rebuilt; integrity problems are only reported. Returns an
+// error when problems are left, so scripts can tell
+func (c *VerifyCommand) Run(ctx context.Context, start, end string, repair bool) error {
+	integrity, foreignKeys, err := c.db.CheckIntegrity(ctx)
+	if err != nil {
+		return err
+	}
+	mismatches, err := c.repo.VerifyRollups(ctx, start, end)
+	if err != nil {
+		return fmt.Errorf("verifying rollups: %w", err)
+	}
+
+	fmt.Printf("\n")
+	fmt.Printf("Database Verification\n")
+	fmt.Printf("═════════════════════\n")
+	fmt.Printf("Rollups from %s to %s\n", start, end)
+	fmt.Printf("\n")
+
+	printCheck("Integrity check", integrity)
+	printCheck("Foreign key check", foreignKeys)
+	fmt.Printf("\n")
+
+	if len(mismatches) == 0 {
+		fmt.Printf("✅ Hourly and daily rollups match the rates they summarize\n")
+	} else {
+		fmt.Printf("⚠️  %d rollups differ from the rates they summarize:\n\n", len(mismatches))
+		fmt.Printf("%-6s  %-10s  %4s  %-6s  %-8s  %-6s  %s\n", "Tier", "Date", "Hour", "Source", "Currency", "From", "Problem")
+		fmt.Printf("%s\n", strings.Repeat("─", 78))
+		for i, m := range mismatches {
+			if i == maxMismatchesShown {
+				fmt.Printf("  ... and %d more\n", len(mismatches)-maxMismatchesShown)
+				break
+			}
+			hour := "-"
+			if m.Tier == storage.TierHourly {
+				hour = fmt.Sprintf("%02d", m.Hour)
+			}
+			fmt.Printf("%-6s  %-10s  %4s  %-6s  %-8s  %-6s  %s\n",
+				m.Tier, m.DatePartition, hour, m.Source, m.CurrencyCode, m.Basis, mismatchProblem(m))
+		}
+	}
+	fmt.Printf("\n")
+
+	if len(mismatches) > 0 && repair {
+		hours, days, err := c.repo.RepairRollups(ctx, mismatches)
+		if err != nil {
+			return fmt.Errorf("repairing rollups: %w", err)
+		}
+		c.logger.Info("repaired rollups", "start", start, "end", end, "hourly", hours, "daily", days)
+		fmt.Printf("✅ Rebuilt %d hourly and %d daily rollups\n\n", hours, days)
+		mismatches = nil
+	} else if len(mismatches) > 0 {
+		fmt.Printf("Run with --repair to rebuild them.\n\n")
+	}
+
+	if len(integrity) > 0 || len(foreignKeys) > 0 {
+		fmt.Printf("ℹ️  Integrity problems can't be repaired in place; restore a snapshot with `ratemon restore`\n\n")
+	}
+
+	if problems := len(integrity) + len(foreignKeys) + len(mismatches); problems > 0 {
+		return fmt.Errorf("verification found %d problems", problems)
+	}
+	return nil
+}
+
+// printCheck prints the result of a database check and its problems
+func printCheck(name string, problems []string) {
+	if len(problems) == 0 {
+		fmt.Printf("✅ %s: ok\n", name)
+		return
+	}
+	fmt.Printf("⚠️  %s: %d problems\n", name, len(problems))
+	for i, problem := range problems {
+		if i == maxMismatchesShown {
+			fmt.Printf("  ... and %d more\n", len(problems)-maxMismatchesShown)
+			break
+		}
+		fmt.Printf("  %s\n", problem)
+	}
+}
+
+// mismatchProblem describes how a rollup differs, as stored → expected
+func mismatchProblem(m storage.RollupMismatch) string {
+	switch {
+	case m.Stored == nil:
+		return "missing"
+	case m.Expected == nil:
+		return "no rates left to summarize"
+	}
+
+	var diffs []string
+	for _, field := range m.Fields {
+		var stored, expected string
+		switch field {
+		case "sum":
+			stored, expected = m.Stored.SumRate.String(), m.Expected.SumRate.String()
+		case "avg":
+			stored, expected = m.Stored.AvgRate.String(), m.Expected.AvgRate.String()
+		case "min":
+			stored, expected = m.Stored.MinRate.String(), m.Expected.MinRate.String()
+		case "max":
+			stored, expected = m.Stored.MaxRate.String(), m.Expected.MaxRate.String()
+		case "peak":
+			stored, expected = m.Stored.PeakRate.String(), m.Expected.PeakRate.String()
+			if m.Stored.PeakRate == m.Expected.PeakRate {
+				stored, expected = m.Stored.PeakTime, m.Expected.PeakTime
+			}
+		case "sample_count":
+			stored, expected = strconv.Itoa(m.Stored.SampleCount), strconv.Itoa(m.Expected.SampleCount)
+		}
+		diffs = append(diffs, fmt.Sprintf("%s %s → %s", field, stored, expected))
+	}
+	return strings.Join(diffs, ", ")
+}
@@ -0,0 +1,171 @@
+package coverage
+
+import (
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// Schedule describes when the daemon is expected to poll
+type Schedule struct {
+	Interval time.Duration  // Time between polls
+	Open     time.Duration  // Start of the daily polling window, from midnight
+	Close    time.Duration  // End of the daily polling window, from midnight
+	Location *time.Location // Zone the window and days are defined in
+}
+
+// BusinessHours is the daemon's default schedule: polls every interval from
+// 08:30 to 22:00 market time, every day (see poller.isBusinessHours)
+func BusinessHours(interval time.Duration) Schedule {
+	return Schedule{
+		Interval: interval,
+		Open:     8*time.Hour + 30*time.Minute,
+		Close:    22 * time.Hour,
+		Location: market.Zone,
+	}
+}
+
+// AllDay is the schedule of a daemon run with --no-business-hours
+func AllDay(interval time.Duration) Schedule {
+	return Schedule{
+		Interval: interval,
+		Close:    24 * time.Hour,
+		Location: market.Zone,
+	}
+}
+
+// window returns the polling window of the day containing t
+func (s Schedule) window(t time.Time) (opens, closes time.Time) {
+	t = t.In(s.Location)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.Location)
+	return midnight.Add(s.Open), midnight.Add(s.Close)
+}
+
+// Gap is a stretch of the polling window without a single poll
+type Gap struct {
+	Start time.Time // Last poll before the gap, or the window opening
+	End   time.Time // First poll after the gap, or the window closing
+}
+
+// Duration returns the length of the gap
+func (g Gap) Duration() time.Duration {
+	return g.End.Sub(g.Start)
+}
+
+// Day is the coverage of one day's polling window
+type Day struct {
+	Date    string        // YYYY-MM-DD in the schedule's zone
+	Open    time.Time     // Window start, clipped to the analyzed period
+	Close   time.Time     // Window end, clipped to the analyzed period
+	Polls   int           // Polls within the window
+	Missing time.Duration // Total length of the day's gaps
+	Gaps    []Gap
+}
+
+// Expected returns the length of the day's polling window
+func (d Day) Expected() time.Duration {
+	return d.Close.Sub(d.Open)
+}
+
+// Completeness returns the share of the window outside gaps (0 to 1)
+func (d Day) Completeness() float64 {
+	if d.Expected() <= 0 {
+		return 0
+	}
+	return 1 - float64(d.Missing)/float64(d.Expected())
+}
+
+// Report is the coverage of a period, day by day
+type Report struct {
+	Schedule Schedule
+	MinGap   time.Duration
+	Days     []Day
+}
+
+// Gaps returns every gap of the report in order, joining gaps that run
+// across midnight when the window spans whole days
+func (r Report) Gaps() []Gap {
+	var gaps []Gap
+	for _, day := range r.Days {
+		for _, gap := range day.Gaps {
+			if n := len(gaps); n > 0 && gaps[n-1].End.Equal(gap.Start) {
+				gaps[n-1].End = gap.End
+				continue
+			}
+			gaps = append(gaps, gap)
+		}
+	}
+	return gaps
+}
+
+// Completeness returns the share of every window in the report outside gaps
+func (r Report) Completeness() float64 {
+	var expected, missing time.Duration
+	for _, day := range r.Days {
+		expected += day.Expected()
+		missing += day.Missing
+	}
+	if expected <= 0 {
+		return 0
+	}
+	return 1 - float64(missing)/float64(expected)
+}
+
+// Analyze measures how well polls cover the schedule between start and end
+// polls must be sorted. A gap is any stretch of a polling window longer than
+// minGap without a poll, counting the window's edges as polls; with a minGap
+// under two intervals, ordinary jitter between polls shows up as gaps
+func Analyze(polls []time.Time, start, end time.Time, schedule Schedule, minGap time.Duration) Report {
+	report := Report{Schedule: schedule, MinGap: minGap}
+
+	next := 0
+	for opens, closes := schedule.window(start); opens.Before(end); opens, closes = schedule.window(opens.AddDate(0, 0, 1)) {
+		day := Day{
+			Date:  opens.Format("2006-01-02"),
+			Open:  laterOf(opens, start),
+			Close: earlierOf(closes, end),
+		}
+		if !day.Open.Before(day.Close) {
+			continue
+		}
+
+		// Skip polls before the window, then walk the ones inside it
+		for next < len(polls) && polls[next].Before(day.Open) {
+			next++
+		}
+		last := day.Open
+		for ; next < len(polls) && !polls[next].After(day.Close); next++ {
+			day.Polls++
+			day.addGap(last, polls[next], minGap)
+			last = polls[next]
+		}
+		day.addGap(last, day.Close, minGap)
+
+		report.Days = append(report.Days, day)
+	}
+
+	return report
+}
+
+// addGap records the stretch from a to b as a gap if it exceeds minGap
+func (d *Day) addGap(a, b time.Time, minGap time.Duration) {
+	if b.Sub(a) <= minGap {
+		return
+	}
+	d.Gaps = append(d.Gaps, Gap{Start: a, End: b})
+	d.Missing += b.Sub(a)
+}
+
+func laterOf(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func earlierOf(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
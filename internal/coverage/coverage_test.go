@@ -0,0 +1,81 @@
+package coverage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// everyMinute returns poll times from start to end (exclusive), a minute apart
+// plus a few seconds of request latency
+func everyMinute(start, end time.Time) []time.Time {
+	var polls []time.Time
+	for t := start; t.Before(end); t = t.Add(time.Minute) {
+		polls = append(polls, t.Add(2*time.Second))
+	}
+	return polls
+}
+
+func TestAnalyzeFindsSleep(t *testing.T) {
+	day := time.Date(2025, 11, 24, 0, 0, 0, 0, market.Zone)
+	opens := day.Add(8*time.Hour + 30*time.Minute)
+	closes := day.Add(22 * time.Hour)
+
+	// The Mac slept from 12:00 to 15:00
+	polls := everyMinute(opens, day.Add(12*time.Hour))
+	polls = append(polls, everyMinute(day.Add(15*time.Hour), closes)...)
+
+	report := Analyze(polls, day, day.Add(24*time.Hour), BusinessHours(time.Minute), 3*time.Minute)
+	if len(report.Days) != 1 {
+		t.Fatalf("Days = %+v, want one", report.Days)
+	}
+
+	gaps := report.Gaps()
+	if len(gaps) != 1 {
+		t.Fatalf("Gaps() = %+v, want the sleep only", gaps)
+	}
+	if want := day.Add(11*time.Hour + 59*time.Minute + 2*time.Second); !gaps[0].Start.Equal(want) {
+		t.Errorf("gap starts at %s, want %s", gaps[0].Start, want)
+	}
+	if want := 3*time.Hour + time.Minute; gaps[0].Duration() != want {
+		t.Errorf("gap lasts %s, want %s", gaps[0].Duration(), want)
+	}
+
+	got := report.Days[0].Completeness()
+	want := 1 - float64(3*time.Hour+time.Minute)/float64(13*time.Hour+30*time.Minute)
+	if got != want {
+		t.Errorf("Completeness() = %f, want %f", got, want)
+	}
+}
+
+func TestAnalyzeClipsToPeriod(t *testing.T) {
+	day := time.Date(2025, 11, 24, 0, 0, 0, 0, market.Zone)
+	start := day.Add(10 * time.Hour)
+	end := day.Add(11 * time.Hour)
+
+	report := Analyze(nil, start, end, BusinessHours(time.Minute), 3*time.Minute)
+	if len(report.Days) != 1 || report.Days[0].Expected() != time.Hour {
+		t.Fatalf("Days = %+v, want one hour of window", report.Days)
+	}
+	if c := report.Completeness(); c != 0 {
+		t.Errorf("Completeness() = %f without polls, want 0", c)
+	}
+
+	// Nothing is expected outside business hours
+	night := Analyze(nil, day.Add(22*time.Hour), day.Add(32*time.Hour), BusinessHours(time.Minute), 3*time.Minute)
+	if len(night.Days) != 0 {
+		t.Errorf("Days = %+v overnight, want none", night.Days)
+	}
+}
+
+func TestGapsJoinAcrossMidnight(t *testing.T) {
+	day := time.Date(2025, 11, 24, 0, 0, 0, 0, market.Zone)
+	polls := []time.Time{day.Add(23 * time.Hour), day.Add(25 * time.Hour)}
+
+	report := Analyze(polls, day.Add(23*time.Hour), day.Add(25*time.Hour), AllDay(time.Minute), 3*time.Minute)
+	gaps := report.Gaps()
+	if len(report.Days) != 2 || len(gaps) != 1 || gaps[0].Duration() != 2*time.Hour {
+		t.Errorf("Days = %+v, Gaps() = %+v; want one two-hour gap over two days", report.Days, gaps)
+	}
+}
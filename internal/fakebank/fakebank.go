@@ -0,0 +1,355 @@
+// Package fakebank serves a simulated CMB rate API for local end-to-end
+// testing of the daemon: rates follow a random walk or a scripted scenario,
+// and faults (latency, 5xx bursts, 429s, malformed bodies, missing rows and
+// API errors) can be injected on demand or at random
+package fakebank
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/api"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// Path is the endpoint the server answers on, as on m.cmbchina.com
+const Path = "/api/rate/fx-rate"
+
+// tick is the smallest USD price move CMB publishes (0.01 per 100 dollars)
+const tick fixed.Rate = 100
+
+// quoteLocation is the zone ratDat/ratTim are published in
+var quoteLocation = market.Zone
+
+// Config configures the simulated market and random faults
+type Config struct {
+	StartRate  fixed.Rate // USD cash bid before the first request
+	Volatility fixed.Rate // Random-walk step size per request (0 holds the rate flat)
+	Seed       uint64     // Random seed (0: seeded from the clock)
+	Scenario   *Scenario  // Scripted steps, followed by a random walk unless looping (nil: random walk)
+	Faults     FaultRates // Random faults injected on requests the scenario leaves alone
+}
+
+// FaultRates are per-request probabilities (0-1) of random faults
+type FaultRates struct {
+	Latency     time.Duration // Delay added to every response
+	ServerError float64
+	RateLimit   float64
+	Malformed   float64
+	MissingUSD  float64
+	APIError    float64
+}
+
+// DefaultConfig returns a random walk from a typical USD rate without faults
+func DefaultConfig() Config {
+	return Config{
+		StartRate:  fixed.MustParse("7.0749"),
+		Volatility: fixed.MustParse("0.0005"),
+	}
+}
+
+// boardCurrency is a row of the simulated board, priced off the USD rate
+type boardCurrency struct {
+	name   string  // CMB's Chinese currency name
+	perUSD float64 // Units of the currency per US dollar
+	places int     // Decimals of the per-100 price
+}
+
+// board lists the simulated currencies in CMB's order
+var board = []boardCurrency{
+	{"港币", 7.7780, 2},
+	{"美元", 1, 2},
+	{"欧元", 0.8620, 2},
+	{"日元", 156.79, 4},
+	{"英镑", 0.7590, 2},
+}
+
+// Spreads relative to the cash bid, as on the real board
+const (
+	referenceSpread = 0.00185 // rtbBid
+	offerSpread     = 0.00435 // rthOfr and rtcOfr
+)
+
+// Server is an http.Handler serving the simulated API
+type Server struct {
+	mu       sync.Mutex
+	config   Config
+	logger   *slog.Logger
+	rng      *rand.Rand
+	rate     fixed.Rate // Current USD cash bid
+	quotedAt time.Time  // When the rate last changed (ratDat/ratTim)
+	step     int        // Index of the current scenario step
+	served   int        // Requests served in the current step
+	requests int
+	now      func() time.Time
+}
+
+// NewServer creates a fake bank; zero StartRate uses the default
+func NewServer(config Config, logger *slog.Logger) *Server {
+	if config.StartRate <= 0 {
+		config.StartRate = DefaultConfig().StartRate
+	}
+
+	seed := config.Seed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+
+	s := &Server{
+		config: config,
+		logger: logger,
+		rng:    rand.New(rand.NewPCG(seed, seed)),
+		rate:   roundToTick(config.StartRate),
+		now:    time.Now,
+	}
+	s.quotedAt = s.now()
+	return s
+}
+
+// response is what a request is answered with
+type response struct {
+	rate       fixed.Rate
+	fault      Fault
+	status     int
+	retryAfter int
+	returnCode string
+	latency    time.Duration
+}
+
+// ServeHTTP answers a request with the next simulated board or fault
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Path {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	resp := s.next()
+	quotedAt := s.quotedAt
+	n := s.requests
+	s.mu.Unlock()
+
+	s.logger.Debug("fake bank request",
+		"request", n,
+		"rate", resp.rate,
+		"fault", string(resp.fault),
+		"latency", resp.latency)
+
+	if resp.latency > 0 && !sleep(r.Context(), resp.latency) {
+		return
+	}
+
+	switch resp.fault {
+	case FaultServerError:
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(resp.status)
+		io.WriteString(w, "<html><body>Service Unavailable</body></html>")
+	case FaultRateLimit:
+		if resp.retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(resp.retryAfter))
+		}
+		w.WriteHeader(http.StatusTooManyRequests)
+	case FaultAPIError:
+		msg := "系统繁忙，请稍后再试"
+		writeJSON(w, &api.CMBResponse{ReturnCode: resp.returnCode, ErrorMsg: &msg})
+	case FaultMalformed:
+		body, _ := json.Marshal(boardResponse(resp.rate, quotedAt, true))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body[:len(body)/2])
+	case FaultMissingUSD:
+		writeJSON(w, boardResponse(resp.rate, quotedAt, false))
+	default:
+		writeJSON(w, boardResponse(resp.rate, quotedAt, true))
+	}
+}
+
+// Rate returns the current USD cash bid
+func (s *Server) Rate() fixed.Rate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rate
+}
+
+// Requests returns how many requests have been answered
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// next moves the market one request forward and decides the response
+// Callers must hold s.mu
+func (s *Server) next() response {
+	step, first := s.advanceStep()
+	s.requests++
+
+	volatility := s.config.Volatility
+	if step.Volatility != nil {
+		volatility = *step.Volatility
+	}
+
+	rate := s.rate + step.Drift + s.noise(volatility)
+	if first && step.Rate != nil {
+		rate = *step.Rate
+	}
+	rate = roundToTick(rate)
+	if rate < tick {
+		rate = tick
+	}
+	if rate != s.rate {
+		s.rate = rate
+		s.quotedAt = s.now()
+	}
+
+	resp := response{
+		rate:       s.rate,
+		fault:      step.Fault,
+		status:     step.Status,
+		retryAfter: step.RetryAfter,
+		returnCode: step.ReturnCode,
+		latency:    time.Duration(step.Latency) + s.config.Faults.Latency,
+	}
+	if resp.fault == FaultNone {
+		resp.fault = s.randomFault()
+	}
+	if resp.status == 0 {
+		resp.status = http.StatusServiceUnavailable
+	}
+	if resp.returnCode == "" {
+		resp.returnCode = "FAIL0001"
+	}
+	return resp
+}
+
+// advanceStep returns the scenario step in effect for this request and
+// whether it is the step's first request; past the end of a non-looping
+// scenario it returns an empty step (a plain random walk)
+func (s *Server) advanceStep() (Step, bool) {
+	scenario := s.config.Scenario
+	if scenario == nil || len(scenario.Steps) == 0 {
+		return Step{}, false
+	}
+
+	if s.step >= len(scenario.Steps) {
+		if !scenario.Loop {
+			return Step{}, false
+		}
+		s.step = 0
+	}
+
+	step := scenario.Steps[s.step]
+	first := s.served == 0
+	s.served++
+	if s.served >= step.requests() {
+		s.step++
+		s.served = 0
+	}
+	return step, first
+}
+
+// noise returns a normally distributed move with volatility as its standard
+// deviation
+func (s *Server) noise(volatility fixed.Rate) fixed.Rate {
+	if volatility <= 0 {
+		return 0
+	}
+	return fixed.Rate(math.Round(s.rng.NormFloat64() * float64(volatility)))
+}
+
+// randomFault rolls the configured fault probabilities
+func (s *Server) randomFault() Fault {
+	f := s.config.Faults
+	roll := s.rng.Float64()
+	for _, candidate := range []struct {
+		fault Fault
+		p     float64
+	}{
+		{FaultServerError, f.ServerError},
+		{FaultRateLimit, f.RateLimit},
+		{FaultMalformed, f.Malformed},
+		{FaultMissingUSD, f.MissingUSD},
+		{FaultAPIError, f.APIError},
+	} {
+		if roll < candidate.p {
+			return candidate.fault
+		}
+		roll -= candidate.p
+	}
+	return FaultNone
+}
+
+// boardResponse builds a CMB response for a USD cash bid
+func boardResponse(usd fixed.Rate, quotedAt time.Time, withUSD bool) *api.CMBResponse {
+	cst := quotedAt.In(quoteLocation)
+	ratDat := cst.Format("2006年1月2日")
+	ratTim := cst.Format("15:04:05")
+
+	var rows []api.CMBCurrencyRate
+	for _, ccy := range board {
+		if ccy.name == "美元" && !withUSD {
+			continue
+		}
+
+		// Per-100 prices; the USD cash bid is the simulated rate exactly
+		bid := usd.Float64() * 100 / ccy.perUSD
+		bidText := fmt.Sprintf("%.*f", ccy.places, bid)
+		if ccy.perUSD == 1 {
+			bidText = (usd * 100).Format(ccy.places)
+		}
+		offer := fmt.Sprintf("%.*f", ccy.places, bid*(1+offerSpread))
+
+		rows = append(rows, api.CMBCurrencyRate{
+			CcyNbr: ccy.name,
+			RtbBid: fmt.Sprintf("%.*f", ccy.places, bid*(1+referenceSpread)),
+			RthOfr: offer,
+			RtcOfr: offer,
+			RthBid: bidText,
+			RtcBid: bidText,
+			RatTim: ratTim,
+			RatDat: ratDat,
+			CcyExc: "10",
+		})
+	}
+
+	return &api.CMBResponse{
+		ReturnCode: "SUC0000",
+		Body: &api.CMBBody{
+			Data: rows,
+			Time: cst.Format("2006-01-02 15:04"),
+		},
+	}
+}
+
+// writeJSON writes a 200 JSON response
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// roundToTick rounds a rate to the nearest publishable USD price
+func roundToTick(r fixed.Rate) fixed.Rate {
+	return fixed.Rate(math.Round(float64(r)/float64(tick))) * tick
+}
+
+// sleep waits for d, returning false if ctx ends first
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,228 @@
+package fakebank
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/api"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// start serves a fake bank and returns a CMB client pointed at it
+func start(t *testing.T, config Config) (*Server, *api.Client) {
+	t.Helper()
+	bank := NewServer(config, testLogger())
+	srv := httptest.NewServer(bank)
+	t.Cleanup(srv.Close)
+	return bank, api.NewClient(testLogger(), api.WithBaseURL(srv.URL+Path), api.WithRetries(0, 0))
+}
+
+// fetchUSD fetches the board and returns its USD cash bid
+func fetchUSD(client *api.Client) (fixed.Rate, error) {
+	resp, err := client.FetchExchangeRates(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	return api.ExtractUSDRate(resp)
+}
+
+func rate(s string) *fixed.Rate {
+	r := fixed.MustParse(s)
+	return &r
+}
+
+func TestServerBoard(t *testing.T) {
+	config := DefaultConfig()
+	config.Seed = 1
+	bank, client := start(t, config)
+
+	for i := 0; i < 5; i++ {
+		body, err := client.FetchRaw(context.Background())
+		if err != nil {
+			t.Fatalf("FetchRaw() error = %v", err)
+		}
+		if err := api.DefaultContract().ValidateCMB(body); err != nil {
+			t.Fatalf("fake board breaks the CMB contract: %v", err)
+		}
+
+		rates, err := client.ParseRates(body)
+		if err != nil {
+			t.Fatalf("ParseRates() error = %v", err)
+		}
+		for _, r := range rates {
+			if r.Currency == "USD" && r.RtcBid != bank.Rate() {
+				t.Errorf("USD RtcBid = %s, want the simulated %s", r.RtcBid, bank.Rate())
+			}
+			if r.RtcOfr < r.RtcBid {
+				t.Errorf("%s offer %s below bid %s", r.Currency, r.RtcOfr, r.RtcBid)
+			}
+		}
+	}
+}
+
+func TestScenarioTargetCrossing(t *testing.T) {
+	scenario, err := LoadScenario("../../fixtures/scenarios/target-crossing.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bank, client := start(t, Config{Scenario: scenario, Seed: 1})
+
+	var rates []fixed.Rate
+	for i := 0; i < 65; i++ {
+		usd, err := fetchUSD(client)
+		if err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+		rates = append(rates, usd)
+	}
+
+	// 5 flat at 7.05, 30 up by 0.002, 30 back down
+	want := map[int]string{0: "7.0500", 4: "7.0500", 34: "7.1100", 64: "7.0500"}
+	for i, w := range want {
+		if rates[i] != fixed.MustParse(w) {
+			t.Errorf("request %d rate = %s, want %s", i+1, rates[i], w)
+		}
+	}
+	if bank.Requests() != 65 {
+		t.Errorf("Requests() = %d, want 65", bank.Requests())
+	}
+}
+
+func TestScenarioFlatQuoteTime(t *testing.T) {
+	scenario := &Scenario{Steps: []Step{
+		{Rate: rate("7.0750")},
+		{Requests: 3, Volatility: rate("0")},
+		{Rate: rate("7.0800")},
+	}}
+	bank := NewServer(Config{Scenario: scenario}, testLogger())
+	clock := time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC)
+	bank.now = func() time.Time {
+		clock = clock.Add(time.Minute)
+		return clock
+	}
+
+	var quoted []time.Time
+	for i := 0; i < 5; i++ {
+		rec := httptest.NewRecorder()
+		bank.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+		quotes, err := api.ParseRaw(api.SourceCMB, rec.Body.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		quoted = append(quoted, quotes[0].QuotedAt)
+	}
+
+	// A flat market keeps the bank's quote time, so the poller stores nothing new
+	for i := 1; i < 4; i++ {
+		if !quoted[i].Equal(quoted[0]) {
+			t.Errorf("request %d quoted at %v, want unchanged %v", i+1, quoted[i], quoted[0])
+		}
+	}
+	if !quoted[4].After(quoted[0]) {
+		t.Errorf("quote time did not move with the rate")
+	}
+}
+
+func TestScenarioFaults(t *testing.T) {
+	tests := []struct {
+		step  Step
+		check func(t *testing.T, rates []api.CurrencyRate, err error)
+	}{
+		{
+			step: Step{Fault: FaultServerError, Status: 502},
+			check: func(t *testing.T, _ []api.CurrencyRate, err error) {
+				var httpErr *api.HTTPError
+				if !errors.As(err, &httpErr) || httpErr.StatusCode != 502 {
+					t.Errorf("error = %v, want HTTP 502", err)
+				}
+			},
+		},
+		{
+			step: Step{Fault: FaultRateLimit, RetryAfter: 120},
+			check: func(t *testing.T, _ []api.CurrencyRate, err error) {
+				if api.RetryAfter(err) != 2*time.Minute {
+					t.Errorf("error = %v, want 429 with Retry-After 2m", err)
+				}
+			},
+		},
+		{
+			step: Step{Fault: FaultMalformed},
+			check: func(t *testing.T, _ []api.CurrencyRate, err error) {
+				if err == nil {
+					t.Error("malformed body should fail to parse")
+				}
+			},
+		},
+		{
+			step: Step{Fault: FaultMissingUSD},
+			check: func(t *testing.T, rates []api.CurrencyRate, err error) {
+				if err != nil {
+					t.Fatalf("error = %v, want the other currencies", err)
+				}
+				for _, r := range rates {
+					if r.Currency == "USD" {
+						t.Error("USD row should be missing")
+					}
+				}
+			},
+		},
+		{
+			step: Step{Fault: FaultAPIError, ReturnCode: "ERR0001"},
+			check: func(t *testing.T, _ []api.CurrencyRate, err error) {
+				if err == nil || !strings.Contains(err.Error(), "API error") {
+					t.Errorf("error = %v, want API error", err)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.step.Fault), func(t *testing.T) {
+			_, client := start(t, Config{Seed: 1, Scenario: &Scenario{Steps: []Step{tt.step}}})
+			rates, err := client.FetchRates(context.Background())
+			tt.check(t, rates, err)
+
+			// The scenario is over: back to a healthy board
+			if _, err := fetchUSD(client); err != nil {
+				t.Errorf("request after the fault: %v", err)
+			}
+		})
+	}
+}
+
+func TestRandomFaults(t *testing.T) {
+	_, client := start(t, Config{Seed: 1, Faults: FaultRates{ServerError: 1}})
+	var httpErr *api.HTTPError
+	if _, err := client.FetchRates(context.Background()); !errors.As(err, &httpErr) || httpErr.StatusCode != 503 {
+		t.Errorf("error = %v, want HTTP 503", err)
+	}
+}
+
+func TestLoadScenarios(t *testing.T) {
+	paths, err := filepath.Glob("../../fixtures/scenarios/*.json")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no scenarios found: %v", err)
+	}
+	for _, path := range paths {
+		if _, err := LoadScenario(path); err != nil {
+			t.Errorf("LoadScenario(%s) error = %v", filepath.Base(path), err)
+		}
+	}
+
+	bad := &Scenario{Steps: []Step{{Fault: "timeout"}}}
+	if err := bad.Validate(); err == nil {
+		t.Error("Validate() should reject an unknown fault")
+	}
+}
@@ -0,0 +1,135 @@
+package fakebank
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+// Fault is a failure the server injects instead of (or into) a normal response
+type Fault string
+
+const (
+	FaultNone        Fault = ""
+	FaultServerError Fault = "server-error" // 5xx status (503 unless Step.Status is set)
+	FaultRateLimit   Fault = "rate-limit"   // 429, with Retry-After when Step.RetryAfter is set
+	FaultMalformed   Fault = "malformed"    // 200 with truncated JSON
+	FaultMissingUSD  Fault = "missing-usd"  // 200 with a board lacking the 美元 row
+	FaultAPIError    Fault = "api-error"    // 200 with a returnCode other than SUC0000
+)
+
+// faults lists the faults a scenario may name
+var faults = []Fault{FaultServerError, FaultRateLimit, FaultMalformed, FaultMissingUSD, FaultAPIError}
+
+// Scenario is a scripted sequence of market moves and faults, loaded from JSON
+type Scenario struct {
+	Name  string `json:"name"`
+	Loop  bool   `json:"loop"` // Start over after the last step instead of random walking
+	Steps []Step `json:"steps"`
+}
+
+// Step covers a number of consecutive requests
+// The step's first request jumps the USD cash bid to Rate if set; every other
+// request moves it by Drift plus normal noise of Volatility (the server's
+// default if unset), rounded to CMB's 0.0001 price tick
+// The market moves on requests that get a fault too
+type Step struct {
+	Requests   int         `json:"requests,omitempty"`   // Requests this step lasts (default 1)
+	Rate       *fixed.Rate `json:"rate,omitempty"`       // Rate to jump to on the step's first request
+	Drift      fixed.Rate  `json:"drift,omitempty"`      // Change per request (e.g., "0.0010" or "-0.0010")
+	Volatility *fixed.Rate `json:"volatility,omitempty"` // Random-walk step size ("0" holds the rate flat)
+	Fault      Fault       `json:"fault,omitempty"`
+	Status     int         `json:"status,omitempty"`      // Status code of a server-error fault
+	RetryAfter int         `json:"retry_after,omitempty"` // Retry-After seconds of a rate-limit fault
+	ReturnCode string      `json:"return_code,omitempty"` // returnCode of an api-error fault (default FAIL0001)
+	Latency    Duration    `json:"latency,omitempty"`     // Delay before responding (e.g., "2s")
+}
+
+// Duration is a time.Duration written as a string ("1.5s") in JSON
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"2s\": %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON formats the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadScenario reads and validates a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+
+	var scenario Scenario
+	if err := json.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("decoding scenario %s: %w", path, err)
+	}
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// Validate checks the steps for unknown faults and impossible values
+func (s *Scenario) Validate() error {
+	if len(s.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+
+	for i, step := range s.Steps {
+		if step.Requests < 0 {
+			return fmt.Errorf("step %d: negative request count", i+1)
+		}
+		if step.Rate != nil && *step.Rate <= 0 {
+			return fmt.Errorf("step %d: rate must be positive", i+1)
+		}
+		if step.Volatility != nil && *step.Volatility < 0 {
+			return fmt.Errorf("step %d: volatility must not be negative", i+1)
+		}
+		if step.Fault != FaultNone && !knownFault(step.Fault) {
+			return fmt.Errorf("step %d: unknown fault %q", i+1, step.Fault)
+		}
+		if step.Status != 0 && (step.Status < 500 || step.Status > 599) {
+			return fmt.Errorf("step %d: status %d is not a 5xx code", i+1, step.Status)
+		}
+		if step.Latency < 0 {
+			return fmt.Errorf("step %d: negative latency", i+1)
+		}
+	}
+	return nil
+}
+
+// requests returns how many requests the step lasts
+func (s Step) requests() int {
+	if s.Requests == 0 {
+		return 1
+	}
+	return s.Requests
+}
+
+// knownFault reports whether a scenario may name the fault
+func knownFault(f Fault) bool {
+	for _, known := range faults {
+		if f == known {
+			return true
+		}
+	}
+	return false
+}
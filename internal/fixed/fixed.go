@@ -0,0 +1,185 @@
+// Package fixed provides exact fixed-point exchange rates
+package fixed
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Scale is the number of decimal places a Rate carries
+// Six places hold per-unit prices of currencies quoted per 100 units to four
+// decimals (JPY 4.5124 per 100 = 0.045124) without rounding
+const Scale = 6
+
+// DisplayPlaces is the minimum number of decimals rates are shown with
+const DisplayPlaces = 4
+
+// one is a Rate of 1
+const one = 1_000_000
+
+// Rate is a price in CNY per unit of foreign currency, stored as an integer
+// number of 10^-Scale CNY (micro-yuan) so sums, averages and comparisons
+// are exact
+type Rate int64
+
+// Parse parses a decimal string such as "7.0749" into a Rate
+func Parse(s string) (Rate, error) {
+	return ParseQuote(s, 1)
+}
+
+// MustParse is like Parse but panics on invalid input; for constants and tests
+func MustParse(s string) Rate {
+	r, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// ParseQuote parses a decimal price quoted per the given number of units of
+// foreign currency (e.g., "707.49" per 100) into a per-unit Rate
+// Digits beyond Scale after the division are rounded half away from zero
+func ParseQuote(s string, per int64) (Rate, error) {
+	if per <= 0 {
+		return 0, fmt.Errorf("invalid quote unit %d", per)
+	}
+
+	text := strings.TrimSpace(s)
+	neg := strings.HasPrefix(text, "-")
+	text = strings.TrimPrefix(strings.TrimPrefix(text, "-"), "+")
+
+	intPart, fracPart, _ := strings.Cut(text, ".")
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	for _, c := range intPart + fracPart {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid rate %q", s)
+		}
+	}
+	if len(fracPart) > 12 {
+		return 0, fmt.Errorf("invalid rate %q: too many decimals", s)
+	}
+
+	// mantissa / 10^len(fracPart) / per, in units of 10^-Scale
+	mantissa, err := strconv.ParseInt(intPart+fracPart, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q: %w", s, err)
+	}
+	denom := int64(math.Pow10(len(fracPart))) * per
+	if mantissa > math.MaxInt64/one {
+		return 0, fmt.Errorf("rate %q out of range", s)
+	}
+
+	r := Rate(divRound(mantissa*one, denom))
+	if neg {
+		r = -r
+	}
+	return r, nil
+}
+
+// FromFloat converts a float64 (e.g., a configured threshold) to the
+// nearest Rate
+func FromFloat(f float64) Rate {
+	return Rate(math.Round(f * one))
+}
+
+// Float64 returns the rate as a float64, for statistics and plotting
+func (r Rate) Float64() float64 {
+	return float64(r) / one
+}
+
+// String formats the rate with DisplayPlaces decimals, or more when the
+// extra digits are significant (e.g., 7.0749, 0.045124)
+func (r Rate) String() string {
+	s := r.Format(Scale)
+	dot := strings.IndexByte(s, '.')
+	end := len(s)
+	for end > dot+1+DisplayPlaces && s[end-1] == '0' {
+		end--
+	}
+	return s[:end]
+}
+
+// MarshalText implements encoding.TextMarshaler, so rates appear in JSON and
+// logs as decimal strings (e.g., "7.0749")
+func (r Rate) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (r *Rate) UnmarshalText(text []byte) error {
+	v, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*r = v
+	return nil
+}
+
+// Signed is like String but always carries a sign, for changes (e.g., +0.0012)
+func (r Rate) Signed() string {
+	if r < 0 {
+		return r.String()
+	}
+	return "+" + r.String()
+}
+
+// Format formats the rate with exactly places decimals, rounding half away
+// from zero
+func (r Rate) Format(places int) string {
+	if places < 0 {
+		places = 0
+	}
+	if places > Scale {
+		places = Scale
+	}
+
+	v := int64(r)
+	sign := ""
+	if v < 0 {
+		sign = "-"
+		v = -v
+	}
+
+	step := int64(math.Pow10(Scale - places))
+	v = divRound(v, step)
+	if places == 0 {
+		return sign + strconv.FormatInt(v, 10)
+	}
+
+	pow := int64(math.Pow10(places))
+	return fmt.Sprintf("%s%d.%0*d", sign, v/pow, places, v%pow)
+}
+
+// Mean returns the average of rates rounded to the nearest Rate (0 if empty)
+func Mean(rates []Rate) Rate {
+	if len(rates) == 0 {
+		return 0
+	}
+
+	var sum Rate
+	for _, r := range rates {
+		sum += r
+	}
+	return MeanOf(sum, len(rates))
+}
+
+// MeanOf returns the average of rates summing to sum, rounded like Mean
+// (0 if count is 0)
+func MeanOf(sum Rate, count int) Rate {
+	if count <= 0 {
+		return 0
+	}
+	return Rate(divRound(int64(sum), int64(count)))
+}
+
+// divRound divides rounding half away from zero (d > 0)
+func divRound(n, d int64) int64 {
+	if n < 0 {
+		return -((-n + d/2) / d)
+	}
+	return (n + d/2) / d
+}
@@ -0,0 +1,102 @@
+package fixed
+
+import "testing"
+
+func TestParseQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		per  int64
+		want Rate
+	}{
+		{"707.49", 100, 7074900},
+		{"4.5124", 100, 45124},  // JPY per 100
+		{"4.51245", 100, 45125}, // Rounded half away from zero
+		{"7.0749", 1, 7074900},
+		{"91.32", 10, 9132000},
+		{".5", 1, 500000},
+		{"-0.0001", 1, -100},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseQuote(tt.s, tt.per)
+		if err != nil {
+			t.Errorf("ParseQuote(%q, %d) error = %v", tt.s, tt.per, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseQuote(%q, %d) = %d, want %d", tt.s, tt.per, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"", ".", "7,07", "1e3", "abc"} {
+		if _, err := ParseQuote(bad, 100); err == nil {
+			t.Errorf("ParseQuote(%q) should fail", bad)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		r    Rate
+		want string
+	}{
+		{MustParse("7.0749"), "7.0749"},
+		{MustParse("7.1"), "7.1000"},
+		{MustParse("0.045124"), "0.045124"},
+		{MustParse("7.07493"), "7.07493"},
+		{-MustParse("0.0005"), "-0.0005"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.r.String(); got != tt.want {
+			t.Errorf("String(%d) = %q, want %q", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	r := MustParse("7.074950")
+	if got := r.Format(4); got != "7.0750" {
+		t.Errorf("Format(4) = %q, want 7.0750", got)
+	}
+	if got := r.Format(2); got != "7.07" {
+		t.Errorf("Format(2) = %q, want 7.07", got)
+	}
+}
+
+func TestMean(t *testing.T) {
+	// Summed as float64 these drift in the last place
+	rates := []Rate{MustParse("7.0749"), MustParse("7.0750"), MustParse("7.0752")}
+	if got := Mean(rates); got != MustParse("7.075033") {
+		t.Errorf("Mean() = %v, want 7.075033", got)
+	}
+	if Mean(nil) != 0 {
+		t.Error("Mean(nil) should be 0")
+	}
+	if got := MeanOf(MustParse("21.2251"), 3); got != MustParse("7.075033") {
+		t.Errorf("MeanOf() = %v, want 7.075033", got)
+	}
+	if MeanOf(0, 0) != 0 {
+		t.Error("MeanOf(0, 0) should be 0")
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	if got := FromFloat(7.1); got != MustParse("7.1") {
+		t.Errorf("FromFloat(7.1) = %v, want 7.1000", got)
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	var r Rate
+	if err := r.UnmarshalText([]byte("-0.0015")); err != nil || r != -1500 {
+		t.Fatalf("UnmarshalText() = %v, %v, want -0.0015", r, err)
+	}
+	text, _ := MustParse("0.045124").MarshalText()
+	if string(text) != "0.045124" {
+		t.Errorf("MarshalText() = %q, want 0.045124", text)
+	}
+	if err := r.UnmarshalText([]byte("7,07")); err == nil {
+		t.Error("UnmarshalText() should reject invalid decimals")
+	}
+}
@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// historyColumns maps fields to the headers of history --format csv
+// Exports made before tiers were recorded have no Tier or Samples column
+var historyColumns = map[string]string{
+	"time":     "Timestamp",
+	"rate":     "Rate",
+	"currency": "Currency",
+	"source":   "Source",
+	"tier":     "Tier",
+	"samples":  "Samples",
+}
+
+// fields lists the fields a column mapping may name
+var fields = []string{"time", "rate", "currency", "source", "tier", "samples", "min", "max"}
+
+// ParseColumns parses a column mapping such as "time=Date,rate=Close"
+func ParseColumns(s string) (map[string]string, error) {
+	columns := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		field, header, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || strings.TrimSpace(header) == "" {
+			return nil, fmt.Errorf("invalid column mapping %q (want field=header)", pair)
+		}
+		if !isField(field) {
+			return nil, fmt.Errorf("unknown field %q (want one of %s)", field, strings.Join(fields, ", "))
+		}
+		columns[field] = strings.TrimSpace(header)
+	}
+	return columns, nil
+}
+
+func isField(name string) bool {
+	for _, f := range fields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readCSV reads a CSV file whose first line is its header
+func readCSV(r io.Reader, opts Options) ([]Record, []RowError, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	// Column index of each mapped field; a mapping must name the required
+	// fields, history exports may lack the optional ones
+	columns := opts.Columns
+	if columns == nil {
+		columns = historyColumns
+	}
+	index := make(map[string]int)
+	for field, name := range columns {
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")), name) {
+				index[field] = i
+				break
+			}
+		}
+		if _, ok := index[field]; !ok && (opts.Columns != nil || field == "time" || field == "rate") {
+			return nil, nil, fmt.Errorf("CSV header has no %q column for %s", name, field)
+		}
+	}
+	for _, field := range []string{"time", "rate"} {
+		if _, ok := index[field]; !ok {
+			return nil, nil, fmt.Errorf("no column mapped to %s", field)
+		}
+	}
+
+	var records []Record
+	var invalid []RowError
+	for {
+		values, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				invalid = append(invalid, RowError{Line: parseErr.Line, Err: parseErr.Err})
+				continue
+			}
+			return nil, nil, fmt.Errorf("reading CSV: %w", err)
+		}
+		line, _ := cr.FieldPos(0)
+
+		get := func(field string) string {
+			if i, ok := index[field]; ok && i < len(values) {
+				return values[i]
+			}
+			return ""
+		}
+		rw := row{
+			line:     line,
+			time:     get("time"),
+			rate:     get("rate"),
+			currency: get("currency"),
+			source:   get("source"),
+			tier:     get("tier"),
+			samples:  get("samples"),
+			lo:       get("min"),
+			hi:       get("max"),
+		}
+
+		rec, err := rw.record(opts)
+		if err != nil {
+			invalid = append(invalid, RowError{Line: line, Err: err})
+			continue
+		}
+		records = append(records, rec)
+	}
+
+	return records, invalid, nil
+}
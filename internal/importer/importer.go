@@ -0,0 +1,282 @@
+// Package importer reads historical rates from files: ratemon's own history
+// exports (CSV or JSON), other CSV files with a column mapping, and raw CMB
+// payloads
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// Format is the layout of an import file
+type Format string
+
+const (
+	FormatCSV  Format = "csv"  // history --format csv, or any CSV with Options.Columns
+	FormatJSON Format = "json" // history --format json
+	FormatCMB  Format = "cmb"  // Raw CMB payloads: one, an array of them, or one per line
+)
+
+// ParseFormat parses a format name (csv, json or cmb)
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatCSV, FormatJSON, FormatCMB:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown import format %q (want csv, json or cmb)", s)
+}
+
+// DetectFormat guesses the format of a file from its first bytes: CMB
+// payloads carry a returnCode, history JSON is an array, anything else is
+// read as CSV
+func DetectFormat(head []byte) Format {
+	head = bytes.TrimLeft(bytes.TrimPrefix(head, []byte("\ufeff")), " \t\r\n")
+	switch {
+	case bytes.Contains(head, []byte(`"returnCode"`)):
+		return FormatCMB
+	case bytes.HasPrefix(head, []byte("[")):
+		return FormatJSON
+	}
+	return FormatCSV
+}
+
+// Options controls how rows are read and what they default to
+type Options struct {
+	Format Format
+
+	// Columns maps fields (time, rate, currency, source, tier, samples, min,
+	// max) to CSV headers; nil reads history exports. time and rate are
+	// required, the other fields take the defaults below
+	Columns map[string]string
+
+	TimeLayout string         // Layout of CSV times (empty: RFC 3339 or common date layouts)
+	Zone       *time.Location // Zone of times without an offset (nil: market.Zone)
+	Per        int64          // Foreign currency units CSV prices are quoted for (0: 1)
+
+	Currency string       // Currency of rows that don't name one
+	Source   string       // Source of rows that don't name one
+	Tier     storage.Tier // Tier of rows that don't name one (empty: raw)
+
+	Now time.Time // Rows after this are rejected (zero: time.Now())
+}
+
+// Record is an imported rate: a quote (Rate) or an hourly or daily rollup
+// (Rollup)
+type Record struct {
+	Line   int // CSV line, JSON element or CMB payload the record came from (from 1)
+	Rate   *storage.ExchangeRate
+	Rollup *storage.SeriesPoint
+}
+
+// RowError reports a row that can't be imported
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// maxRate bounds plausible per-unit prices; no currency CMB quotes is worth
+// 100 CNY, so larger prices are almost certainly quoted per 100 units
+var maxRate = fixed.MustParse("100")
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// Read reads every record of a file. Rows that can't be imported are
+// returned as RowErrors; the error is only set when the file itself can't
+// be read
+func Read(r io.Reader, opts Options) ([]Record, []RowError, error) {
+	if opts.Zone == nil {
+		opts.Zone = market.Zone
+	}
+	if opts.Per == 0 {
+		opts.Per = 1
+	}
+	if opts.Source == "" {
+		opts.Source = storage.DefaultSource
+	}
+	if opts.Tier == "" {
+		opts.Tier = storage.TierRaw
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	br := bufio.NewReader(r)
+	if opts.Format == "" {
+		head, err := br.Peek(512)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, nil, fmt.Errorf("reading file: %w", err)
+		}
+		opts.Format = DetectFormat(head)
+	}
+
+	switch opts.Format {
+	case FormatCSV:
+		return readCSV(br, opts)
+	case FormatJSON:
+		return readHistoryJSON(br, opts)
+	case FormatCMB:
+		return readCMB(br, opts)
+	}
+	return nil, nil, fmt.Errorf("unknown import format %q", opts.Format)
+}
+
+// row holds the fields of a CSV row or history JSON element, as text
+type row struct {
+	line                                                int
+	time, rate, currency, source, tier, samples, lo, hi string
+}
+
+// record validates a row and builds its record
+func (rw row) record(opts Options) (Record, error) {
+	rec := Record{Line: rw.line}
+
+	at, err := parseTime(rw.time, opts)
+	if err != nil {
+		return rec, err
+	}
+	if at.After(opts.Now) {
+		return rec, fmt.Errorf("time %s is in the future", rw.time)
+	}
+
+	rate, err := parseRate(rw.rate, opts.Per)
+	if err != nil {
+		return rec, err
+	}
+	lo, hi := rate, rate
+	if rw.lo != "" {
+		if lo, err = parseRate(rw.lo, opts.Per); err != nil {
+			return rec, err
+		}
+	}
+	if rw.hi != "" {
+		if hi, err = parseRate(rw.hi, opts.Per); err != nil {
+			return rec, err
+		}
+	}
+	if lo > rate || rate > hi {
+		return rec, fmt.Errorf("rate %s is outside its range %s-%s", rate, lo, hi)
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(rw.currency))
+	if currency == "" {
+		currency = opts.Currency
+	}
+	if !currencyPattern.MatchString(currency) {
+		return rec, fmt.Errorf("invalid currency %q", currency)
+	}
+
+	source := strings.ToLower(strings.TrimSpace(rw.source))
+	if source == "" {
+		source = opts.Source
+	}
+
+	tier := opts.Tier
+	if t := strings.TrimSpace(rw.tier); t != "" {
+		if tier, err = storage.ParseTier(t); err != nil {
+			return rec, err
+		}
+	}
+
+	samples := 1
+	if s := strings.TrimSpace(rw.samples); s != "" {
+		if samples, err = strconv.Atoi(s); err != nil || samples < 1 {
+			return rec, fmt.Errorf("invalid sample count %q", s)
+		}
+	}
+
+	if tier == storage.TierRaw {
+		rec.Rate = &storage.ExchangeRate{
+			CurrencyCode:  currency,
+			Source:        source,
+			RtcBid:        rate,
+			QuotedAt:      at,
+			CollectedAt:   at,
+			DatePartition: market.Date(at),
+		}
+		return rec, nil
+	}
+
+	// Rollups start at their market hour or day
+	local := at.In(market.Zone)
+	start := time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, market.Zone)
+	if tier == storage.TierDaily {
+		start = start.Add(-time.Duration(local.Hour()) * time.Hour)
+	}
+	rec.Rollup = &storage.SeriesPoint{
+		Time:          start,
+		Tier:          tier,
+		CurrencyCode:  currency,
+		Source:        source,
+		DatePartition: market.Date(start),
+		SumRate:       rate * fixed.Rate(samples),
+		AvgRate:       rate,
+		MinRate:       lo,
+		MaxRate:       hi,
+		SampleCount:   samples,
+	}
+	return rec, nil
+}
+
+// timeLayouts are tried, in order, for times without a TimeLayout
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006/01/02 15:04:05",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	"2006年1月2日",
+}
+
+// parseTime parses a time with an offset (RFC 3339), or else in the zone
+func parseTime(s string, opts Options) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if opts.TimeLayout != "" {
+		t, err := time.ParseInLocation(opts.TimeLayout, s, opts.Zone)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q: %w", s, err)
+		}
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, s, opts.Zone); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", s)
+}
+
+// parseRate parses a positive price quoted per units of foreign currency
+func parseRate(s string, per int64) (fixed.Rate, error) {
+	rate, err := fixed.ParseQuote(s, per)
+	if err != nil {
+		return 0, err
+	}
+	if rate <= 0 {
+		return 0, fmt.Errorf("rate %s is not positive", rate)
+	}
+	if rate > maxRate {
+		return 0, fmt.Errorf("rate %s is implausibly high; are prices quoted per 100 units?", rate)
+	}
+	return rate, nil
+}
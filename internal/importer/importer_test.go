@@ -0,0 +1,154 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+var now = time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+
+func TestReadHistoryCSV(t *testing.T) {
+	// An export written with --tz UTC, before and after tiers were recorded
+	input := `Timestamp,Rate,Date,Time,Currency,Source,Tier,Samples
+2025-11-24 01:00:00,7.0749,2025-11-24,01:00:00,USD,cmb,raw,1
+2025-11-23 16:00:00,7.0802,2025-11-23,16:00:00,USD,boc,daily,288
+2025-11-24 02:00:00,abc,2025-11-24,02:00:00,USD,cmb,raw,1
+2030-01-01 00:00:00,7.0749,2030-01-01,00:00:00,USD,cmb,raw,1
+`
+	records, invalid, err := Read(strings.NewReader(input), Options{Zone: time.UTC, Currency: "USD", Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || len(invalid) != 2 {
+		t.Fatalf("Read() = %d records, %v; want 2 records and 2 invalid rows", len(records), invalid)
+	}
+	if invalid[0].Line != 4 || invalid[1].Line != 5 {
+		t.Errorf("invalid rows on lines %d and %d, want 4 and 5", invalid[0].Line, invalid[1].Line)
+	}
+
+	raw := records[0].Rate
+	if raw == nil || raw.RtcBid != fixed.MustParse("7.0749") || raw.DatePartition != "2025-11-24" ||
+		!raw.QuotedAt.Equal(time.Date(2025, 11, 24, 1, 0, 0, 0, time.UTC)) {
+		t.Errorf("raw record = %+v", raw)
+	}
+
+	// 16:00 UTC is midnight in the market zone
+	daily := records[1].Rollup
+	if daily == nil || daily.Tier != storage.TierDaily || daily.Source != "boc" || daily.DatePartition != "2025-11-24" ||
+		daily.SampleCount != 288 || daily.SumRate != fixed.MustParse("7.0802")*288 {
+		t.Errorf("daily record = %+v", daily)
+	}
+}
+
+func TestReadMappedCSV(t *testing.T) {
+	// A spreadsheet of daily CMB prices per 100 dollars
+	input := "日期,收盘,最低,最高\n2024/03/01,719.50,718.90,720.10\n2024/03/04,720.00,721.00,722.00\n"
+	columns, err := ParseColumns("time=日期, rate=收盘, min=最低, max=最高")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, invalid, err := Read(strings.NewReader(input), Options{
+		Columns:  columns,
+		Per:      100,
+		Currency: "USD",
+		Tier:     storage.TierDaily,
+		Now:      now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || len(invalid) != 1 {
+		t.Fatalf("Read() = %d records, %v; want 1 record and 1 invalid row (rate below its minimum)", len(records), invalid)
+	}
+
+	p := records[0].Rollup
+	want := storage.SeriesPoint{
+		Time:          time.Date(2024, 3, 1, 0, 0, 0, 0, market.Zone),
+		Tier:          storage.TierDaily,
+		CurrencyCode:  "USD",
+		Source:        storage.DefaultSource,
+		DatePartition: "2024-03-01",
+		SumRate:       fixed.MustParse("7.195"),
+		AvgRate:       fixed.MustParse("7.195"),
+		MinRate:       fixed.MustParse("7.189"),
+		MaxRate:       fixed.MustParse("7.201"),
+		SampleCount:   1,
+	}
+	if p == nil || *p != want {
+		t.Errorf("record = %+v, want %+v", p, want)
+	}
+
+	if _, _, err := Read(strings.NewReader(input), Options{Columns: map[string]string{"time": "Date", "rate": "收盘"}}); err == nil {
+		t.Error("Read() with a mapping to a missing column should fail")
+	}
+}
+
+func TestReadHistoryJSON(t *testing.T) {
+	input := `[
+  {"timestamp": "2025-11-24T09:00:00+08:00", "rate": 7.0749, "currency": "USD", "source": "cmb", "tier": "hourly", "samples": 12},
+  {"timestamp": "2025-11-24T09:05:00+08:00", "rate": 7.0750, "currency": "USD", "source": "cmb"}
+]`
+	records, invalid, err := Read(strings.NewReader(input), Options{Now: now})
+	if err != nil || len(invalid) != 0 || len(records) != 2 {
+		t.Fatalf("Read() = %d records, %v, %v; want 2 records", len(records), invalid, err)
+	}
+	if p := records[0].Rollup; p == nil || p.Tier != storage.TierHourly || p.Time.In(market.Zone).Hour() != 9 || p.SampleCount != 12 {
+		t.Errorf("hourly record = %+v", p)
+	}
+	if r := records[1].Rate; r == nil || r.RtcBid != fixed.MustParse("7.075") {
+		t.Errorf("raw record = %+v", r)
+	}
+}
+
+func TestReadCMBPayloads(t *testing.T) {
+	body, err := os.ReadFile("../../fixtures/sample-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DetectFormat(body); got != FormatCMB {
+		t.Fatalf("DetectFormat() = %q, want cmb", got)
+	}
+
+	// The same payload twice, one per line
+	input := strings.Join([]string{compact(t, body), compact(t, body), `{"returnCode":"ERR","errorMsg":"down"}`}, "\n")
+	records, invalid, err := Read(strings.NewReader(input), Options{Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invalid) != 1 || invalid[0].Line != 3 {
+		t.Errorf("invalid = %v, want the failed payload on line 3", invalid)
+	}
+
+	var usd int
+	for _, rec := range records {
+		if rec.Rate.CurrencyCode == "USD" {
+			usd++
+			if rec.Rate.RtcBid != fixed.MustParse("7.0749") || rec.Rate.RtbBid != fixed.MustParse("7.088") ||
+				rec.Rate.DatePartition != "2025-11-25" || rec.Rate.Source != "cmb" {
+				t.Errorf("USD record = %+v", rec.Rate)
+			}
+		}
+	}
+	if usd != 2 {
+		t.Errorf("read %d USD quotes, want 2", usd)
+	}
+}
+
+// compact puts a JSON document on one line
+func compact(t *testing.T, body []byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, body); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
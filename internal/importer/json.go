@@ -0,0 +1,124 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/api"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
+)
+
+// historyPoint is an element of history --format json
+// Rates are kept as written, so they parse exactly
+type historyPoint struct {
+	Timestamp string      `json:"timestamp"`
+	Rate      json.Number `json:"rate"`
+	Currency  string      `json:"currency"`
+	Source    string      `json:"source"`
+	Tier      string      `json:"tier"`
+	Samples   json.Number `json:"samples"`
+}
+
+// readHistoryJSON reads the array history --format json writes
+func readHistoryJSON(r io.Reader, opts Options) ([]Record, []RowError, error) {
+	var points []historyPoint
+	if err := json.NewDecoder(r).Decode(&points); err != nil {
+		return nil, nil, fmt.Errorf("decoding history JSON: %w", err)
+	}
+
+	var records []Record
+	var invalid []RowError
+	for i, p := range points {
+		rw := row{
+			line:     i + 1,
+			time:     p.Timestamp,
+			rate:     p.Rate.String(),
+			currency: p.Currency,
+			source:   p.Source,
+			tier:     p.Tier,
+			samples:  p.Samples.String(),
+		}
+		rec, err := rw.record(opts)
+		if err != nil {
+			invalid = append(invalid, RowError{Line: rw.line, Err: err})
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, invalid, nil
+}
+
+// readCMB reads raw CMB payloads: a single payload, an array of them or one
+// per line. Every currency of a payload is imported, as the daemon stores
+// them, observed at the payload's quote time
+func readCMB(r *bufio.Reader, opts Options) ([]Record, []RowError, error) {
+	var payloads []json.RawMessage
+	dec := json.NewDecoder(r)
+	for {
+		var value json.RawMessage
+		err := dec.Decode(&value)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("decoding CMB payload %d: %w", len(payloads)+1, err)
+		}
+		if len(value) > 0 && value[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(value, &batch); err != nil {
+				return nil, nil, fmt.Errorf("decoding CMB payloads: %w", err)
+			}
+			payloads = append(payloads, batch...)
+			continue
+		}
+		payloads = append(payloads, value)
+	}
+
+	var records []Record
+	var invalid []RowError
+	for i, body := range payloads {
+		line := i + 1
+		quotes, err := api.ParseRaw(api.SourceCMB, body)
+		if err != nil {
+			invalid = append(invalid, RowError{Line: line, Err: err})
+			continue
+		}
+
+		for _, q := range quotes {
+			switch {
+			case q.QuotedAt.IsZero():
+				err = fmt.Errorf("%s quote has no time", q.Currency)
+			case q.QuotedAt.After(opts.Now):
+				err = fmt.Errorf("%s quote time %s is in the future", q.Currency, q.QuotedAt)
+			case q.RtcBid > maxRate:
+				err = fmt.Errorf("%s rate %s is implausibly high", q.Currency, q.RtcBid)
+			}
+			if err != nil {
+				invalid = append(invalid, RowError{Line: line, Err: err})
+				err = nil
+				continue
+			}
+
+			records = append(records, Record{
+				Line: line,
+				Rate: &storage.ExchangeRate{
+					CurrencyCode:  q.Currency,
+					Source:        api.SourceCMB,
+					RtcBid:        q.RtcBid,
+					RtbBid:        q.RtbBid,
+					RthBid:        q.RthBid,
+					RthOfr:        q.RthOfr,
+					RtcOfr:        q.RtcOfr,
+					QuotedAt:      q.QuotedAt,
+					CollectedAt:   q.QuotedAt,
+					DatePartition: market.Date(q.QuotedAt),
+				},
+			})
+		}
+	}
+	return records, invalid, nil
+}
@@ -0,0 +1,36 @@
+package market
+
+import (
+	"time"
+)
+
+// ZoneName is the zone the market keeps time in: CMB quotes, business hours,
+// date partitions and hour-of-day analytics all follow Beijing time
+const ZoneName = "Asia/Shanghai"
+
+// Zone is the market's zone, independent of the host's
+// Asia/Shanghai has kept UTC+8 without daylight saving since 1991, so hosts
+// without a zone database fall back to a fixed UTC+8 zone with the same times
+var Zone = loadZone()
+
+// SQLModifier shifts a UTC time to market time in SQLite date functions,
+// e.g. strftime('%H', collected_at, '+8 hours')
+const SQLModifier = "+8 hours"
+
+func loadZone() *time.Location {
+	if loc, err := time.LoadLocation(ZoneName); err == nil {
+		return loc
+	}
+	return time.FixedZone("CST", 8*60*60)
+}
+
+// Date returns the market date of an instant (YYYY-MM-DD), the date
+// partition rows collected at that instant are stored under
+func Date(t time.Time) string {
+	return t.In(Zone).Format("2006-01-02")
+}
+
+// DaysAgo returns the market date days before today
+func DaysAgo(days int) string {
+	return time.Now().In(Zone).AddDate(0, 0, -days).Format("2006-01-02")
+}
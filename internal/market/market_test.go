@@ -0,0 +1,29 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDateIgnoresHostZone(t *testing.T) {
+	// 17:30 UTC is already the next day in Beijing
+	instant := time.Date(2025, 11, 24, 17, 30, 0, 0, time.UTC)
+
+	for _, zone := range []*time.Location{time.UTC, time.FixedZone("PST", -8*60*60), time.FixedZone("CST", 8*60*60)} {
+		if got := Date(instant.In(zone)); got != "2025-11-25" {
+			t.Errorf("Date(%s) = %s, want 2025-11-25", instant.In(zone), got)
+		}
+	}
+}
+
+func TestZoneMatchesSQLModifier(t *testing.T) {
+	// The fixed SQL offset is only valid while the zone keeps UTC+8
+	for _, instant := range []time.Time{
+		time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC),
+		time.Date(2025, 7, 15, 12, 0, 0, 0, time.UTC),
+	} {
+		if _, offset := instant.In(Zone).Zone(); offset != 8*60*60 {
+			t.Errorf("offset on %s = %ds, want UTC+8 as in %q", instant, offset, SQLModifier)
+		}
+	}
+}
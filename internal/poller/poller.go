@@ -2,27 +2,49 @@ package poller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"time"
 
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/alerts"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/api"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
 // Poller handles periodic polling of exchange rates
 type Poller struct {
-	apiClient           *api.Client
-	repo                *storage.Repository
+	source              api.RateSource   // Primary rate source
+	compareSources      []api.RateSource // Polled alongside the primary for comparison
+	failoverSources     []api.RateSource // Polled in order while the primary is failing
+	failoverAfter       int              // Consecutive primary failures before failing over
+	primaryFailures     int
+	repo                storage.Store
 	logger              *slog.Logger
 	skipOffHours        bool
 	businessHoursStart  int // Hour in CST (0-23)
 	businessHoursEnd    int // Hour in CST (0-23)
-	alertManager        *alerts.Manager
+	alertConfig         *alerts.Config
+	alertManagers       map[string]*alerts.Manager // Per source, each reading that source's history
+	alertCurrency       string
 	notifiers           []alerts.Notifier
+	lastQuotes          map[string]*storage.ExchangeRate // Most recent stored quote per source and currency
+	archiveRaw          bool                             // Archive raw response bodies of sources that support it
+	lastArchived        map[string]*storage.RawResponse  // Most recent archived response per source
+	contract            *api.Contract                    // Response contract to validate against (nil disables validation)
+	contractViolations  int
+	pendingJumps        map[string]fixed.Rate // Out-of-band rate per source and currency awaiting confirmation
+	interval            time.Duration
+	backoffUntil        map[string]time.Time // Per source, no requests before this time (server asked to back off)
+	rateLimited         map[string]int       // Per source, consecutive rate-limited polls without a Retry-After
 }
 
+// errBackingOff is returned for polls skipped because the source asked us to back off
+var errBackingOff = errors.New("backing off at server's request")
+
 // PollerOption configures the poller
 type PollerOption func(*Poller)
 
@@ -43,14 +65,18 @@ func WithoutBusinessHours() PollerOption {
 }
 
 // WithAlerts enables alert checking
+// Quotes are checked against the history and last quote of the source they
+// came from, failover sources included, so the spread between banks doesn't
+// read as movement or an unusual rate
 func WithAlerts(config *alerts.Config, wechatWebhook string) PollerOption {
 	return func(p *Poller) {
-		p.alertManager = alerts.NewManager(config, p.repo, p.logger)
+		p.alertConfig = config
+		p.alertManagers = make(map[string]*alerts.Manager)
+		p.alertsFor(p.source.Name())
+		p.alertCurrency = config.Currency
 
 		// Always add log notifier
-		p.notifiers = []alerts.Notifier{
-			alerts.NewLogNotifier(p.logger),
-		}
+		p.notifiers = append(p.notifiers, alerts.NewLogNotifier(p.logger))
 
 		// Add WeChat notifier if webhook URL is provided
 		if wechatWebhook != "" {
@@ -60,15 +86,67 @@ func WithAlerts(config *alerts.Config, wechatWebhook string) PollerOption {
 	}
 }
 
-// NewPoller creates a new poller instance
-func NewPoller(apiClient *api.Client, repo *storage.Repository, logger *slog.Logger, opts ...PollerOption) *Poller {
+// WithNotifiers adds notifiers that receive every alert
+func WithNotifiers(notifiers ...alerts.Notifier) PollerOption {
+	return func(p *Poller) {
+		p.notifiers = append(p.notifiers, notifiers...)
+	}
+}
+
+// WithArchive archives the raw response body of every poll (for sources
+// implementing api.RawSource) and links stored rates to it
+func WithArchive() PollerOption {
+	return func(p *Poller) {
+		p.archiveRaw = true
+	}
+}
+
+// WithCompareSources also polls these sources on every tick and stores their
+// quotes tagged with the source name, so banks can be compared
+func WithCompareSources(sources ...api.RateSource) PollerOption {
+	return func(p *Poller) {
+		p.compareSources = append(p.compareSources, sources...)
+	}
+}
+
+// WithFailover polls the backup sources in order, in place of the primary,
+// once the primary has failed the given number of consecutive polls
+// The primary is still tried first on every tick so it takes over again
+// as soon as it recovers
+func WithFailover(after int, backups ...api.RateSource) PollerOption {
+	return func(p *Poller) {
+		if after < 1 {
+			after = 1
+		}
+		p.failoverAfter = after
+		p.failoverSources = append(p.failoverSources, backups...)
+	}
+}
+
+// WithContract validates every response against the contract before its
+// quotes are stored: payloads that break it are rejected, and a rate outside
+// the plausible band is only stored once the next poll confirms it
+func WithContract(contract *api.Contract) PollerOption {
+	return func(p *Poller) {
+		p.contract = contract
+	}
+}
+
+// NewPoller creates a new poller instance reading from a primary rate source
+func NewPoller(source api.RateSource, repo storage.Store, logger *slog.Logger, opts ...PollerOption) *Poller {
 	p := &Poller{
-		apiClient:          apiClient,
+		source:             source,
+		failoverAfter:      3,
 		repo:               repo,
 		logger:             logger,
 		skipOffHours:       true,  // Default: enable business hours check
 		businessHoursStart: 8,      // Default: 08:30 CST (minute check in isBusinessHours)
 		businessHoursEnd:   22,     // Default: 22:00 CST
+		lastQuotes:         make(map[string]*storage.ExchangeRate),
+		lastArchived:       make(map[string]*storage.RawResponse),
+		pendingJumps:       make(map[string]fixed.Rate),
+		backoffUntil:       make(map[string]time.Time),
+		rateLimited:        make(map[string]int),
 	}
 
 	for _, opt := range opts {
@@ -83,11 +161,12 @@ func (p *Poller) Start(ctx context.Context, interval time.Duration) error {
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
-	p.logger.Info("poller started", "interval", interval)
+	p.interval = interval
+	p.logger.Info("poller started", "interval", interval, "source", p.source.Name())
 
 	// Perform initial poll immediately
 	if err := p.poll(ctx); err != nil {
-		p.logger.Error("initial poll failed", "error", err)
+		p.logPollError("initial poll failed", err)
 	}
 
 	for {
@@ -97,13 +176,23 @@ func (p *Poller) Start(ctx context.Context, interval time.Duration) error {
 			return ctx.Err()
 		case <-ticker.C:
 			if err := p.poll(ctx); err != nil {
-				p.logger.Error("poll failed", "error", err)
+				p.logPollError("poll failed", err)
 				// Continue polling despite errors
 			}
 		}
 	}
 }
 
+// logPollError logs a failed poll; polls deferred at the server's request
+// are expected and logged at info level
+func (p *Poller) logPollError(msg string, err error) {
+	if errors.Is(err, errBackingOff) {
+		p.logger.Info("poll deferred", "reason", err)
+		return
+	}
+	p.logger.Error(msg, "error", err)
+}
+
 // isBusinessHours checks if the current time is within CMB business hours (CST)
 // CMB forex rates update from 08:30-22:00 Beijing Time
 func (p *Poller) isBusinessHours() bool {
@@ -111,9 +200,8 @@ func (p *Poller) isBusinessHours() bool {
 		return true // Always poll if business hours check is disabled
 	}
 
-	// Get current time in CST (China Standard Time = UTC+8)
-	cstLocation := time.FixedZone("CST", 8*60*60)
-	now := time.Now().In(cstLocation)
+	// Get current time in market time (Beijing)
+	now := time.Now().In(market.Zone)
 	hour := now.Hour()
 	minute := now.Minute()
 
@@ -137,43 +225,472 @@ func (p *Poller) isBusinessHours() bool {
 func (p *Poller) poll(ctx context.Context) error {
 	// Check if we're within business hours
 	if !p.isBusinessHours() {
-		cstLocation := time.FixedZone("CST", 8*60*60)
-		now := time.Now().In(cstLocation)
+		now := time.Now().In(market.Zone)
 		p.logger.Debug("skipping poll outside business hours",
 			"current_time_cst", fmt.Sprintf("%02d:%02d", now.Hour(), now.Minute()),
 			"business_hours", "08:30-22:00")
 		return nil
 	}
 
+	primaryErr := p.pollPrimary(ctx)
+
+	for _, source := range p.compareSources {
+		if err := p.pollSource(ctx, source, false); err != nil {
+			p.logger.Warn("comparison source poll failed", "source", source.Name(), "error", err)
+		}
+	}
+
+	return primaryErr
+}
+
+// pollPrimary polls the primary source, falling back to the failover sources
+// while the primary keeps failing
+func (p *Poller) pollPrimary(ctx context.Context) error {
+	err := p.pollSource(ctx, p.source, true)
+	if err == nil {
+		if p.failingOver() {
+			p.logger.Info("primary source recovered",
+				"source", p.source.Name(),
+				"failed_polls", p.primaryFailures)
+		}
+		p.primaryFailures = 0
+		return nil
+	}
+
+	p.primaryFailures++
+	if reporter, ok := p.source.(api.HealthReporter); ok {
+		if health := reporter.Health(); health.State != api.BreakerClosed {
+			p.logger.Warn("primary source down",
+				"source", health.Source,
+				"circuit", health.State,
+				"down_since", health.DownSince,
+				"next_probe", health.NextProbe)
+		}
+	}
+	if !p.failingOver() {
+		return err
+	}
+
+	p.logger.Warn("primary source failing, trying failover sources",
+		"source", p.source.Name(),
+		"failed_polls", p.primaryFailures,
+		"error", err)
+
+	for _, backup := range p.failoverSources {
+		backupErr := p.pollSource(ctx, backup, true)
+		if backupErr == nil {
+			return nil
+		}
+		p.logger.Warn("failover source poll failed", "source", backup.Name(), "error", backupErr)
+	}
+
+	return fmt.Errorf("primary and failover sources failed: %w", err)
+}
+
+// Health returns the circuit breaker state of every polled source that
+// reports one, primary first
+func (p *Poller) Health() []api.BreakerHealth {
+	var health []api.BreakerHealth
+	sources := append([]api.RateSource{p.source}, p.failoverSources...)
+	for _, source := range append(sources, p.compareSources...) {
+		if reporter, ok := source.(api.HealthReporter); ok {
+			health = append(health, reporter.Health())
+		}
+	}
+	return health
+}
+
+// failingOver reports whether the primary has failed often enough for the
+// failover sources to be used
+func (p *Poller) failingOver() bool {
+	return len(p.failoverSources) > 0 && p.primaryFailures >= p.failoverAfter
+}
+
+// pollSource fetches and stores the new quotes of a single source, checking
+// alerts against them if requested
+func (p *Poller) pollSource(ctx context.Context, source api.RateSource, checkAlerts bool) error {
 	startTime := time.Now()
+	name := source.Name()
 
-	// Fetch data from API
-	resp, err := p.apiClient.FetchExchangeRates(ctx)
-	if err != nil {
-		return fmt.Errorf("fetching rates: %w", err)
+	if until := p.backoffUntil[name]; startTime.Before(until) {
+		return fmt.Errorf("%s %w until %s", name, errBackingOff, until.Format("15:04:05"))
+	}
+
+	// Fetch every currency the source publishes, archiving the raw body first
+	// so it can be re-parsed even if extraction fails or it breaks the contract
+	var extracted []api.CurrencyRate
+	var responseID int64
+	raw, isRaw := source.(api.RawSource)
+	validator, validates := source.(api.PayloadValidator)
+	validates = validates && p.contract != nil
+	if isRaw && (p.archiveRaw || validates) {
+		body, err := raw.FetchRaw(ctx)
+		p.noteBackoff(name, err)
+		if err != nil {
+			return fmt.Errorf("fetching %s rates: %w", name, err)
+		}
+		if p.archiveRaw {
+			responseID = p.archiveResponse(ctx, name, body, startTime)
+		}
+
+		if validates {
+			if err := validator.ValidatePayload(p.contract, body); err != nil {
+				p.contractViolation(name, err, startTime)
+				return fmt.Errorf("validating %s response: %w", name, err)
+			}
+		}
+
+		extracted, err = raw.ParseRates(body)
+		if err != nil {
+			return fmt.Errorf("extracting %s rates: %w", name, err)
+		}
+	} else {
+		var err error
+		extracted, err = source.FetchRates(ctx)
+		p.noteBackoff(name, err)
+		if err != nil {
+			return fmt.Errorf("fetching %s rates: %w", name, err)
+		}
 	}
 
-	// Extract USD rate
-	usdRate, err := api.ExtractUSDRate(resp)
+	stored, err := p.storeQuotes(ctx, name, extracted, startTime, responseID, checkAlerts)
 	if err != nil {
-		return fmt.Errorf("extracting USD rate: %w", err)
+		return err
+	}
+	p.recordPoll(ctx, name, startTime, len(extracted), stored)
+
+	if stored == 0 {
+		p.logger.Debug("no new quotes since last poll",
+			"source", name,
+			"elapsed_ms", time.Since(startTime).Milliseconds())
+		return nil
+	}
+
+	elapsed := time.Since(startTime)
+	p.logger.Info("poll successful",
+		"source", name,
+		"currencies", stored,
+		"unchanged", len(extracted)-stored,
+		"elapsed_ms", elapsed.Milliseconds())
+
+	return nil
+}
+
+// noteBackoff records how long a source asked us to stay away after a
+// failed fetch: its Retry-After, or for a rate limit without one, a number
+// of poll intervals doubling with each consecutive rate-limited poll
+// (capped at an hour). A successful fetch clears the rate-limit streak
+func (p *Poller) noteBackoff(source string, err error) {
+	if err == nil {
+		delete(p.rateLimited, source)
+		return
+	}
+
+	wait := api.RetryAfter(err)
+	var httpErr *api.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusTooManyRequests {
+		p.rateLimited[source]++
+		if wait == 0 {
+			interval := p.interval
+			if interval <= 0 {
+				interval = time.Minute
+			}
+			wait = interval << min(p.rateLimited[source], 6)
+			if wait > time.Hour {
+				wait = time.Hour
+			}
+		}
+	}
+	if wait <= 0 {
+		return
+	}
+
+	until := time.Now().Add(wait)
+	p.backoffUntil[source] = until
+	p.logger.Warn("source asked us to back off",
+		"source", source,
+		"wait", wait.Round(time.Second),
+		"until", until.Format("15:04:05"),
+		"error", err)
+}
+
+// archiveResponse archives a raw response body and returns its ID
+// A body identical to the source's previous one is not stored again; its
+// archive entry is reused. Archival failures are logged and return 0 so
+// polling carries on
+func (p *Poller) archiveResponse(ctx context.Context, source string, body []byte, collectedAt time.Time) int64 {
+	checksum := storage.Checksum(body)
+	if last := p.lastArchived[source]; last != nil && last.Checksum == checksum {
+		return last.ID
+	}
+
+	resp := &storage.RawResponse{
+		Source:        source,
+		CollectedAt:   collectedAt,
+		DatePartition: market.Date(collectedAt),
+		Checksum:      checksum,
+		Body:          body,
+	}
+	if err := p.repo.InsertRawResponse(ctx, resp); err != nil {
+		p.logger.Warn("failed to archive raw response", "source", source, "error", err)
+		return 0
 	}
 
-	// Store rate in database
-	rate := &storage.ExchangeRate{
-		CurrencyCode:  "USD",
-		RtcBid:        usdRate,
-		CollectedAt:   startTime,
-		DatePartition: startTime.Format("2006-01-02"),
+	resp.Body = nil
+	p.lastArchived[source] = resp
+	return resp.ID
+}
+
+// storeQuotes stores the quotes that changed since the last stored
+// observation, stamped with collectedAt and linked to the archived response
+// they came from (0 if none), and returns how many were stored
+func (p *Poller) storeQuotes(ctx context.Context, source string, quotes []api.CurrencyRate, collectedAt time.Time, responseID int64, checkAlerts bool) (int, error) {
+	// Skip quotes the bank hasn't changed since the last stored observation
+	fresh := p.newQuotes(ctx, source, quotes)
+	if p.contract != nil {
+		fresh = p.plausibleQuotes(source, fresh, collectedAt)
+	}
+	if len(fresh) == 0 {
+		return 0, nil
+	}
+
+	// Store all new rates from this poll together
+	rates := make([]*storage.ExchangeRate, len(fresh))
+	for i, r := range fresh {
+		rates[i] = &storage.ExchangeRate{
+			CurrencyCode:  r.Currency,
+			Source:        source,
+			RtcBid:        r.RtcBid,
+			RtbBid:        r.RtbBid,
+			RthBid:        r.RthBid,
+			RthOfr:        r.RthOfr,
+			RtcOfr:        r.RtcOfr,
+			QuotedAt:      r.QuotedAt,
+			CollectedAt:   collectedAt,
+			ResponseID:    responseID,
+			DatePartition: market.Date(collectedAt),
+		}
 	}
 
-	if err := p.repo.InsertRate(ctx, rate); err != nil {
-		return fmt.Errorf("storing rate: %w", err)
+	if err := p.repo.InsertRates(ctx, rates); err != nil {
+		return 0, fmt.Errorf("storing %s rates: %w", source, err)
+	}
+
+	for _, rate := range rates {
+		p.lastQuotes[quoteKey(source, rate.CurrencyCode)] = rate
 	}
 
 	// Check for alerts if alert manager is configured
-	if p.alertManager != nil {
-		alertsTriggered := p.alertManager.Check(ctx, usdRate, startTime)
+	if checkAlerts && p.alertConfig != nil {
+		p.checkAlerts(ctx, source, fresh, collectedAt)
+	}
+
+	return len(rates), nil
+}
+
+// recordPoll logs a successful poll so coverage reports can tell a flat
+// market from missed polls. A failure is logged but doesn't fail the poll
+func (p *Poller) recordPoll(ctx context.Context, source string, polledAt time.Time, quotes, stored int) {
+	poll := &storage.Poll{
+		Source:        source,
+		PolledAt:      polledAt,
+		DatePartition: market.Date(polledAt),
+		Quotes:        quotes,
+		Stored:        stored,
+	}
+	if err := p.repo.RecordPoll(ctx, poll); err != nil {
+		p.logger.Warn("failed to record poll", "source", source, "error", err)
+	}
+}
+
+// Replay feeds recorded payloads through the same storage and alert path as
+// live polling, stamping rows with the time each payload was recorded
+// speed scales the recorded gaps between payloads: 1 replays in real time,
+// 60 replays an hour per minute and 0 replays as fast as possible
+func (p *Poller) Replay(ctx context.Context, source *api.ReplaySource, speed float64) error {
+	p.logger.Info("replay started", "speed", speed)
+
+	var prev time.Time
+	records, stored := 0, 0
+	for {
+		record, err := source.Next()
+		if errors.Is(err, api.ErrReplayDone) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading replay record: %w", err)
+		}
+		records++
+
+		collectedAt := record.CollectedAt
+		if collectedAt.IsZero() {
+			collectedAt = time.Now()
+		}
+
+		if speed > 0 && !prev.IsZero() && collectedAt.After(prev) {
+			wait := time.Duration(float64(collectedAt.Sub(prev)) / speed)
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		prev = collectedAt
+
+		quotes, err := api.ExtractRates(record.Response)
+		if err != nil {
+			p.logger.Warn("skipping unusable replay record",
+				"collected_at", collectedAt,
+				"error", err)
+			continue
+		}
+
+		n, err := p.storeQuotes(ctx, source.Name(), quotes, collectedAt, 0, true)
+		if err != nil {
+			return err
+		}
+		p.recordPoll(ctx, source.Name(), collectedAt, len(quotes), n)
+		stored += n
+	}
+
+	p.logger.Info("replay finished", "records", records, "stored", stored)
+
+	return nil
+}
+
+// quoteKey identifies a currency's quotes from one source in lastQuotes
+func quoteKey(source, currency string) string {
+	return source + "/" + currency
+}
+
+// newQuotes filters out quotes identical to the last stored one per currency
+// from the same source
+// The last stored quote is loaded from the repository the first time a
+// currency is seen, so a restarted daemon does not re-insert the same quote
+func (p *Poller) newQuotes(ctx context.Context, source string, quotes []api.CurrencyRate) []api.CurrencyRate {
+	repo := p.repo.WithSource(source)
+
+	var fresh []api.CurrencyRate
+	for _, q := range quotes {
+		key := quoteKey(source, q.Currency)
+		last, seen := p.lastQuotes[key]
+		if !seen {
+			stored, err := repo.GetLatestRate(ctx, q.Currency)
+			if err != nil {
+				p.logger.Warn("failed to load last quote", "source", source, "currency", q.Currency, "error", err)
+			} else {
+				p.lastQuotes[key] = stored
+			}
+			last = stored
+		}
+
+		if last != nil && sameQuote(last, q) {
+			continue
+		}
+		fresh = append(fresh, q)
+	}
+	return fresh
+}
+
+// sameQuote reports whether a quote repeats a stored observation: same bank
+// quote time (when known) and same prices on every side
+func sameQuote(last *storage.ExchangeRate, q api.CurrencyRate) bool {
+	return last.SameQuote(&storage.ExchangeRate{
+		RtcBid:   q.RtcBid,
+		RtbBid:   q.RtbBid,
+		RthBid:   q.RthBid,
+		RthOfr:   q.RthOfr,
+		RtcOfr:   q.RtcOfr,
+		QuotedAt: q.QuotedAt,
+	})
+}
+
+// plausibleQuotes drops quotes that break the contract: inconsistent quotes
+// always, and quotes whose rate moved outside the plausible band since the
+// last stored one unless the previous poll reported the same rate
+func (p *Poller) plausibleQuotes(source string, quotes []api.CurrencyRate, timestamp time.Time) []api.CurrencyRate {
+	var plausible []api.CurrencyRate
+	for _, q := range quotes {
+		if err := p.contract.CheckQuote(source, q); err != nil {
+			p.contractViolation(source, err, timestamp)
+			continue
+		}
+
+		key := quoteKey(source, q.Currency)
+		var last fixed.Rate
+		if stored := p.lastQuotes[key]; stored != nil {
+			last = stored.RtcBid
+		}
+
+		if err := p.contract.CheckBand(source, q, last); err != nil {
+			if pending, ok := p.pendingJumps[key]; !ok || pending != q.RtcBid {
+				p.pendingJumps[key] = q.RtcBid
+				p.contractViolation(source, err, timestamp)
+				continue
+			}
+			p.logger.Info("out-of-band rate confirmed by consecutive polls",
+				"source", source,
+				"currency", q.Currency,
+				"rate", q.RtcBid,
+				"last", last)
+		}
+
+		delete(p.pendingJumps, key)
+		plausible = append(plausible, q)
+	}
+	return plausible
+}
+
+// contractViolation logs and counts a contract violation and raises a
+// contract alert when alerts are enabled
+func (p *Poller) contractViolation(source string, violation error, timestamp time.Time) {
+	p.contractViolations++
+	p.logger.Error("response contract violated",
+		"error_class", "contract",
+		"source", source,
+		"violations", p.contractViolations,
+		"error", violation)
+
+	if p.alertConfig == nil {
+		return
+	}
+
+	alert := p.alertsFor(source).CheckContract(source, violation, timestamp)
+	if alert == nil {
+		return
+	}
+	for _, notifier := range p.notifiers {
+		if err := notifier.Notify(*alert); err != nil {
+			p.logger.Error("failed to send alert", "error", err)
+		}
+	}
+}
+
+// ContractViolations returns how many contract violations were seen since
+// the poller was created
+func (p *Poller) ContractViolations() int {
+	return p.contractViolations
+}
+
+// alertsFor returns the alert manager of a source, creating it on first use
+func (p *Poller) alertsFor(source string) *alerts.Manager {
+	manager, ok := p.alertManagers[source]
+	if !ok {
+		manager = alerts.NewManager(p.alertConfig, p.repo.WithSource(source), p.logger)
+		p.alertManagers[source] = manager
+	}
+	return manager
+}
+
+// checkAlerts runs the source's alert manager against the watched currency's
+// new quote
+func (p *Poller) checkAlerts(ctx context.Context, source string, rates []api.CurrencyRate, timestamp time.Time) {
+	for _, r := range rates {
+		if r.Currency != p.alertCurrency {
+			continue
+		}
+
+		alertsTriggered := p.alertsFor(source).Check(ctx, r.RtcBid.Float64(), timestamp)
 		for _, alert := range alertsTriggered {
 			for _, notifier := range p.notifiers {
 				if err := notifier.Notify(alert); err != nil {
@@ -181,12 +698,6 @@ func (p *Poller) poll(ctx context.Context) error {
 				}
 			}
 		}
+		return
 	}
-
-	elapsed := time.Since(startTime)
-	p.logger.Info("poll successful",
-		"rate", usdRate,
-		"elapsed_ms", elapsed.Milliseconds())
-
-	return nil
 }
@@ -1,8 +1,22 @@
 package poller
 
 import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/alerts"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/api"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
 func TestIsBusinessHours(t *testing.T) {
@@ -133,3 +147,489 @@ func TestNewPollerDefaults(t *testing.T) {
 		t.Errorf("Default businessHoursEnd = %d, want 22", p.businessHoursEnd)
 	}
 }
+
+func TestSameQuote(t *testing.T) {
+	cst := time.FixedZone("CST", 8*60*60)
+	quotedAt := time.Date(2025, 11, 25, 20, 11, 2, 0, cst)
+
+	last := &storage.ExchangeRate{
+		CurrencyCode: "USD",
+		RtcBid:       fixed.MustParse("7.0749"),
+		RtbBid:       fixed.MustParse("7.088"),
+		RthBid:       fixed.MustParse("7.0749"),
+		RthOfr:       fixed.MustParse("7.1057"),
+		RtcOfr:       fixed.MustParse("7.1057"),
+		QuotedAt:     quotedAt,
+	}
+	quote := api.CurrencyRate{
+		Currency: "USD",
+		RtcBid:   fixed.MustParse("7.0749"),
+		RtbBid:   fixed.MustParse("7.088"),
+		RthBid:   fixed.MustParse("7.0749"),
+		RthOfr:   fixed.MustParse("7.1057"),
+		RtcOfr:   fixed.MustParse("7.1057"),
+		QuotedAt: quotedAt.UTC(),
+	}
+
+	if !sameQuote(last, quote) {
+		t.Error("identical quote should be treated as a repeat")
+	}
+
+	requoted := quote
+	requoted.QuotedAt = quotedAt.Add(time.Minute)
+	if sameQuote(last, requoted) {
+		t.Error("new quote time should be a new observation")
+	}
+
+	repriced := quote
+	repriced.RthOfr = fixed.MustParse("7.1060")
+	if sameQuote(last, repriced) {
+		t.Error("changed offer should be a new observation")
+	}
+
+	legacy := *last
+	legacy.QuotedAt = time.Time{}
+	unknown := quote
+	unknown.QuotedAt = time.Time{}
+	if !sameQuote(&legacy, unknown) {
+		t.Error("unchanged prices without quote times should be a repeat")
+	}
+}
+
+// fakeSource is a RateSource returning a fixed USD quote or an error
+type fakeSource struct {
+	name  string
+	rate  fixed.Rate
+	err   error
+	calls int
+}
+
+func (s *fakeSource) Name() string { return s.name }
+
+func (s *fakeSource) FetchRates(ctx context.Context) ([]api.CurrencyRate, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []api.CurrencyRate{{Currency: "USD", RtcBid: s.rate}}, nil
+}
+
+func newTestRepo(t *testing.T) *storage.Repository {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	db, err := storage.NewDB(filepath.Join(t.TempDir(), "rates.db"), logger)
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return storage.NewRepository(db, logger)
+}
+
+func TestPollFailover(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	primary := &fakeSource{name: api.SourceCMB, err: errors.New("connection refused")}
+	backup := &fakeSource{name: api.SourceBOC, rate: fixed.MustParse("7.05")}
+	p := NewPoller(primary, repo, logger, WithoutBusinessHours(), WithFailover(2, backup))
+
+	// First failure is reported without touching the backup
+	if err := p.poll(ctx); err == nil {
+		t.Fatal("poll() should fail while below the failover threshold")
+	}
+	if backup.calls != 0 {
+		t.Errorf("backup polled %d times before threshold", backup.calls)
+	}
+
+	// Second consecutive failure fails over
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v, want failover to succeed", err)
+	}
+	latest, err := repo.GetLatestRate(ctx, "USD")
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestRate() = %v, %v", latest, err)
+	}
+	if latest.Source != api.SourceBOC || latest.RtcBid != fixed.MustParse("7.05") {
+		t.Errorf("stored %s %s, want boc 7.0500", latest.Source, latest.RtcBid)
+	}
+
+	// Primary recovers and takes over again
+	primary.err = nil
+	primary.rate = fixed.MustParse("7.08")
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if p.primaryFailures != 0 {
+		t.Errorf("primaryFailures = %d after recovery, want 0", p.primaryFailures)
+	}
+	if backup.calls != 1 {
+		t.Errorf("backup polled %d times, want 1", backup.calls)
+	}
+
+	cmb, err := repo.WithSource(api.SourceCMB).GetLatestRate(ctx, "USD")
+	if err != nil || cmb == nil || cmb.RtcBid != fixed.MustParse("7.08") {
+		t.Errorf("cmb latest = %+v, %v, want 7.0800", cmb, err)
+	}
+}
+
+func TestPollCompareSources(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	primary := &fakeSource{name: api.SourceCMB, rate: fixed.MustParse("7.08")}
+	other := &fakeSource{name: api.SourceICBC, rate: fixed.MustParse("7.09")}
+	p := NewPoller(primary, repo, logger, WithoutBusinessHours(), WithCompareSources(other))
+
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	for _, src := range []*fakeSource{primary, other} {
+		rate, err := repo.WithSource(src.name).GetLatestRate(ctx, "USD")
+		if err != nil || rate == nil || rate.RtcBid != src.rate {
+			t.Errorf("%s latest = %+v, %v, want %s", src.name, rate, err, src.rate)
+		}
+	}
+
+	// Unchanged quotes are de-duplicated per source
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if count, _ := repo.Count(ctx); count != 2 {
+		t.Errorf("Count() = %d after repeat poll, want 2", count)
+	}
+
+	// but every poll is logged for coverage
+	polls, err := repo.WithSource(api.SourceCMB).GetPollTimes(ctx, time.Now().Add(-time.Hour), time.Now())
+	if err != nil || len(polls) != 2 {
+		t.Errorf("GetPollTimes() = %v, %v; want both polls", polls, err)
+	}
+}
+
+func TestPollPatternAlertsReadPrimarySource(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Every hour of the last 12 days: CMB around 7.08, BOC well above it
+	now := time.Now()
+	var history []*storage.ExchangeRate
+	for day := 1; day <= 12; day++ {
+		for hour := 0; hour < 24; hour++ {
+			at := now.AddDate(0, 0, -day).Add(time.Duration(hour) * time.Hour)
+			cmb := fixed.MustParse("7.07")
+			if day%2 == 0 {
+				cmb = fixed.MustParse("7.09")
+			}
+			for source, rate := range map[string]fixed.Rate{api.SourceCMB: cmb, api.SourceBOC: fixed.MustParse("7.20")} {
+				history = append(history, &storage.ExchangeRate{
+					CurrencyCode: "USD", Source: source, RtcBid: rate,
+					CollectedAt: at, DatePartition: market.Date(at),
+				})
+			}
+		}
+	}
+	if err := repo.InsertRates(ctx, history); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier := &recordingNotifier{}
+	primary := &fakeSource{name: api.SourceCMB, rate: fixed.MustParse("7.08")}
+	p := NewPoller(primary, repo, logger,
+		WithoutBusinessHours(),
+		WithAlerts(&alerts.Config{CheckPatterns: true, PatternStdDevs: 2, Currency: "USD"}, ""),
+		WithNotifiers(notifier))
+
+	// A usual CMB rate is 2.8 deviations below the mix of both banks
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	for _, alert := range notifier.alerts {
+		if alert.Type == alerts.AlertTypeUnusual {
+			t.Errorf("unusual-rate alert %q, want patterns of the primary source only", alert.Message)
+		}
+	}
+}
+
+func TestPollFailoverAlertsReadBackupSource(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	notifier := &recordingNotifier{}
+	primary := &fakeSource{name: api.SourceCMB, rate: fixed.MustParse("7.08")}
+	backup := &fakeSource{name: api.SourceBOC, rate: fixed.MustParse("7.20")}
+	p := NewPoller(primary, repo, logger,
+		WithoutBusinessHours(),
+		WithFailover(1, backup),
+		WithAlerts(&alerts.Config{ChangePercent: 1, Currency: "USD"}, ""),
+		WithNotifiers(notifier))
+
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	// Failing over to a bank quoting 1.7% higher isn't a 1.7% move
+	primary.err = errors.New("connection refused")
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v, want failover to succeed", err)
+	}
+	if len(notifier.alerts) != 0 {
+		t.Errorf("alerts = %+v after failing over, want none", notifier.alerts)
+	}
+
+	// but a move of the backup's own rate still alerts
+	backup.rate = fixed.MustParse("7.30")
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Type != alerts.AlertTypeChangeIncrease {
+		t.Errorf("alerts = %+v, want one change alert from the backup's own history", notifier.alerts)
+	}
+}
+
+// recordingNotifier collects the alerts it is sent
+type recordingNotifier struct {
+	alerts []alerts.Alert
+}
+
+func (n *recordingNotifier) Notify(alert alerts.Alert) error {
+	n.alerts = append(n.alerts, alert)
+	return nil
+}
+
+func TestReplayAlerts(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	fixture, err := os.ReadFile("../../fixtures/sample-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"20251125T201100.json", "20251125T201200.json"} {
+		if err := os.WriteFile(filepath.Join(dir, name), fixture, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	source, err := api.NewReplaySource(dir, logger)
+	if err != nil {
+		t.Fatalf("NewReplaySource() error = %v", err)
+	}
+
+	notifier := &recordingNotifier{}
+	p := NewPoller(source, repo, logger,
+		WithAlerts(&alerts.Config{HighThreshold: 7.05, Currency: "USD"}, ""),
+		WithNotifiers(notifier))
+
+	if err := p.Replay(ctx, source, 0); err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+
+	// The repeated payload is de-duplicated, so only one USD row and one alert
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Type != alerts.AlertTypeThresholdHigh {
+		t.Fatalf("alerts = %+v, want one high threshold alert", notifier.alerts)
+	}
+
+	latest, err := repo.GetLatestRate(ctx, "USD")
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestRate() = %v, %v", latest, err)
+	}
+	want := time.Date(2025, 11, 25, 20, 11, 0, 0, time.Local)
+	if !latest.CollectedAt.Equal(want) {
+		t.Errorf("CollectedAt = %v, want recorded time %v", latest.CollectedAt, want)
+	}
+}
+
+// fakeRawSource serves a recorded CMB payload as a raw body
+type fakeRawSource struct {
+	body []byte
+}
+
+func (s *fakeRawSource) Name() string { return api.SourceCMB }
+
+func (s *fakeRawSource) FetchRates(ctx context.Context) ([]api.CurrencyRate, error) {
+	return s.ParseRates(s.body)
+}
+
+func (s *fakeRawSource) FetchRaw(ctx context.Context) ([]byte, error) { return s.body, nil }
+
+func (s *fakeRawSource) ParseRates(body []byte) ([]api.CurrencyRate, error) {
+	return api.ParseRaw(api.SourceCMB, body)
+}
+
+func (s *fakeRawSource) ValidatePayload(contract *api.Contract, body []byte) error {
+	return contract.ValidateCMB(body)
+}
+
+func TestPollArchive(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	body, err := os.ReadFile("../../fixtures/sample-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPoller(&fakeRawSource{body: body}, repo, logger, WithoutBusinessHours(), WithArchive())
+
+	for i := 0; i < 2; i++ {
+		if err := p.poll(ctx); err != nil {
+			t.Fatalf("poll() error = %v", err)
+		}
+	}
+
+	dates, err := repo.GetArchiveDates(ctx, "", "")
+	if err != nil || len(dates) != 1 {
+		t.Fatalf("GetArchiveDates() = %v, %v", dates, err)
+	}
+	responses, err := repo.GetRawResponsesForDate(ctx, dates[0])
+	if err != nil {
+		t.Fatalf("GetRawResponsesForDate() error = %v", err)
+	}
+	if len(responses) != 1 {
+		t.Fatalf("archived %d responses, want 1 (identical body not archived twice)", len(responses))
+	}
+	if string(responses[0].Body) != string(body) {
+		t.Error("archived body does not round-trip")
+	}
+
+	latest, err := repo.GetLatestRate(ctx, "USD")
+	if err != nil || latest == nil {
+		t.Fatalf("GetLatestRate() = %v, %v", latest, err)
+	}
+	if latest.ResponseID != responses[0].ID {
+		t.Errorf("ResponseID = %d, want %d", latest.ResponseID, responses[0].ID)
+	}
+}
+
+func TestPollContractViolation(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	fixture, err := os.ReadFile("../../fixtures/sample-data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &fakeRawSource{body: []byte(strings.ReplaceAll(string(fixture), `"ccyExc": "10"`, `"ccyExc": "100"`))}
+
+	notifier := &recordingNotifier{}
+	p := NewPoller(source, repo, logger,
+		WithoutBusinessHours(),
+		WithContract(api.DefaultContract()),
+		WithAlerts(&alerts.Config{Currency: "USD"}, ""),
+		WithNotifiers(notifier))
+
+	err = p.poll(ctx)
+	var cerr *api.ContractError
+	if !errors.As(err, &cerr) {
+		t.Fatalf("poll() error = %v, want *api.ContractError", err)
+	}
+	if count, _ := repo.Count(ctx); count != 0 {
+		t.Errorf("Count() = %d, want drifted payload rejected", count)
+	}
+	if p.ContractViolations() != 1 {
+		t.Errorf("ContractViolations() = %d, want 1", p.ContractViolations())
+	}
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Type != alerts.AlertTypeContract {
+		t.Errorf("alerts = %+v, want one contract alert", notifier.alerts)
+	}
+
+	// Back to the expected format
+	source.body = fixture
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if count, _ := repo.Count(ctx); count == 0 {
+		t.Error("valid payload was not stored")
+	}
+}
+
+func TestPollRateBand(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	source := &fakeSource{name: api.SourceCMB, rate: fixed.MustParse("7.08")}
+	p := NewPoller(source, repo, logger, WithoutBusinessHours(), WithContract(api.DefaultContract()))
+
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+
+	// A tenfold jump is held back until the next poll repeats it
+	source.rate = fixed.MustParse("70.8")
+	for i, wantRate := range []fixed.Rate{fixed.MustParse("7.08"), fixed.MustParse("70.8")} {
+		if err := p.poll(ctx); err != nil {
+			t.Fatalf("poll() error = %v", err)
+		}
+		latest, err := repo.GetLatestRate(ctx, "USD")
+		if err != nil || latest == nil || latest.RtcBid != wantRate {
+			t.Errorf("poll %d: latest = %+v, %v, want %s", i+1, latest, err, wantRate)
+		}
+	}
+	if p.ContractViolations() != 1 {
+		t.Errorf("ContractViolations() = %d, want 1", p.ContractViolations())
+	}
+}
+
+func TestPollRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepo(t)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	source := &fakeSource{
+		name: api.SourceCMB,
+		err:  &api.HTTPError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour},
+	}
+	p := NewPoller(source, repo, logger, WithoutBusinessHours())
+
+	if err := p.poll(ctx); err == nil {
+		t.Fatal("poll() should fail on a 429")
+	}
+
+	// The next ticks are skipped without contacting the source
+	source.err = nil
+	source.rate = fixed.MustParse("7.08")
+	if err := p.poll(ctx); !errors.Is(err, errBackingOff) {
+		t.Errorf("poll() during backoff error = %v, want errBackingOff", err)
+	}
+	if source.calls != 1 {
+		t.Errorf("source called %d times, want 1", source.calls)
+	}
+
+	// Once the wait is over polling resumes
+	p.backoffUntil[api.SourceCMB] = time.Now().Add(-time.Second)
+	if err := p.poll(ctx); err != nil {
+		t.Fatalf("poll() after backoff error = %v", err)
+	}
+	if source.calls != 2 {
+		t.Errorf("source called %d times, want 2", source.calls)
+	}
+}
+
+func TestNoteBackoffRateLimitWithoutRetryAfter(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	p := NewPoller(&fakeSource{name: api.SourceCMB}, nil, logger)
+	p.interval = time.Minute
+
+	rateLimited := &api.HTTPError{StatusCode: http.StatusTooManyRequests}
+	for i, want := range []time.Duration{2 * time.Minute, 4 * time.Minute, 8 * time.Minute} {
+		start := time.Now()
+		p.noteBackoff(api.SourceCMB, rateLimited)
+		got := p.backoffUntil[api.SourceCMB].Sub(start)
+		if got < want || got > want+time.Second {
+			t.Errorf("rate limit %d: backoff = %v, want %v", i+1, got, want)
+		}
+	}
+
+	p.noteBackoff(api.SourceCMB, nil)
+	if p.rateLimited[api.SourceCMB] != 0 {
+		t.Error("successful fetch should reset the rate-limit streak")
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"sort"
 	"time"
 
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
@@ -35,10 +37,11 @@ type Recommendation struct {
 	Action          Action
 	Confidence      Confidence
 	ConfidenceScore float64 // 0-100
-	CurrentRate     float64
+	Currency        string  // Currency being bought (e.g., "USD")
+	CurrentRate     fixed.Rate
 	PercentileRank  float64 // 0-100, where 100 is best
 	Amount          float64 // RMB amount
-	USDAmount       float64 // Converted USD
+	USDAmount       float64 // Converted amount in the target currency
 
 	// Predictions
 	PredictedNextHours []HourPrediction
@@ -75,24 +78,24 @@ type TimeWindow struct {
 
 // HistoricalContext provides context about current rate vs history
 type HistoricalContext struct {
-	AvgRate30Days   float64
-	MinRate30Days   float64
-	MaxRate30Days   float64
+	AvgRate30Days   fixed.Rate
+	MinRate30Days   fixed.Rate
+	MaxRate30Days   fixed.Rate
 	StdDev30Days    float64
 	TodayDayOfWeek  string
 	CurrentHour     int
-	HourlyAvgRate   float64
-	DailyAvgRate    float64
+	HourlyAvgRate   fixed.Rate
+	DailyAvgRate    fixed.Rate
 }
 
 // Recommender provides intelligent exchange recommendations
 type Recommender struct {
-	repo   *storage.Repository
+	repo   storage.RateReader
 	logger *slog.Logger
 }
 
 // NewRecommender creates a new recommendation engine
-func NewRecommender(repo *storage.Repository, logger *slog.Logger) *Recommender {
+func NewRecommender(repo storage.RateReader, logger *slog.Logger) *Recommender {
 	return &Recommender{
 		repo:   repo,
 		logger: logger,
@@ -100,9 +103,9 @@ func NewRecommender(repo *storage.Repository, logger *slog.Logger) *Recommender
 }
 
 // GetRecommendation analyzes current conditions and returns exchange recommendation
-func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*Recommendation, error) {
+func (r *Recommender) GetRecommendation(ctx context.Context, currency string, amount float64) (*Recommendation, error) {
 	// Get current rate
-	latest, err := r.repo.GetLatestRate(ctx)
+	latest, err := r.repo.GetLatestRate(ctx, currency)
 	if err != nil {
 		return nil, fmt.Errorf("getting latest rate: %w", err)
 	}
@@ -116,7 +119,7 @@ func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*R
 
 	// Get historical context (last 30 days)
 	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	historicalRates, err := r.repo.GetRatesByTimeRange(ctx, thirtyDaysAgo, now)
+	historicalRates, err := r.repo.GetRatesByTimeRange(ctx, currency, thirtyDaysAgo, now)
 	if err != nil {
 		return nil, fmt.Errorf("getting historical rates: %w", err)
 	}
@@ -129,13 +132,13 @@ func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*R
 	percentile := r.calculatePercentile(currentRate, historicalRates)
 
 	// Get hourly patterns
-	hourlyPatterns, err := r.repo.GetHourlyPatterns(ctx, 30)
+	hourlyPatterns, err := r.repo.GetHourlyPatterns(ctx, currency, 30)
 	if err != nil {
 		return nil, fmt.Errorf("getting hourly patterns: %w", err)
 	}
 
 	// Get day of week patterns
-	dowPatterns, err := r.repo.GetDayOfWeekPatterns(ctx, 4)
+	dowPatterns, err := r.repo.GetDayOfWeekPatterns(ctx, currency, 4)
 	if err != nil {
 		return nil, fmt.Errorf("getting day of week patterns: %w", err)
 	}
@@ -144,14 +147,16 @@ func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*R
 	histContext := r.buildHistoricalContext(historicalRates, hourlyPatterns, dowPatterns, now)
 
 	// Generate predictions for next few hours
-	predictions := r.predictNextHours(now, hourlyPatterns, currentRate, histContext)
+	// The prediction model works in floating point; stored rates and the
+	// statistics compared against them stay exact
+	predictions := r.predictNextHours(now, hourlyPatterns, currentRate.Float64(), histContext)
 
 	// Find optimal exchange window
 	optimalWindow := r.findOptimalWindow(now, predictions, hourlyPatterns)
 
 	// Determine action and confidence
 	action, confidence, confidenceScore, reasoning := r.determineAction(
-		currentRate,
+		currentRate.Float64(),
 		percentile,
 		predictions,
 		optimalWindow,
@@ -161,7 +166,7 @@ func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*R
 
 	// Calculate potential gain/loss
 	potentialGain, potentialLoss, riskLevel := r.calculateRiskReward(
-		currentRate,
+		currentRate.Float64(),
 		amount,
 		optimalWindow,
 		histContext,
@@ -174,10 +179,11 @@ func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*R
 		Action:             action,
 		Confidence:         confidence,
 		ConfidenceScore:    confidenceScore,
+		Currency:           currency,
 		CurrentRate:        currentRate,
 		PercentileRank:     percentile,
 		Amount:             amount,
-		USDAmount:          amount / currentRate,
+		USDAmount:          amount / currentRate.Float64(),
 		PredictedNextHours: predictions,
 		OptimalWindow:      optimalWindow,
 		Reasoning:          reasoning,
@@ -192,7 +198,7 @@ func (r *Recommender) GetRecommendation(ctx context.Context, amount float64) (*R
 }
 
 // calculatePercentile calculates where current rate ranks (0-100, higher is better)
-func (r *Recommender) calculatePercentile(currentRate float64, historicalRates []storage.ExchangeRate) float64 {
+func (r *Recommender) calculatePercentile(currentRate fixed.Rate, historicalRates []storage.ExchangeRate) float64 {
 	count := 0
 	for _, rate := range historicalRates {
 		if rate.RtcBid <= currentRate {
@@ -210,13 +216,12 @@ func (r *Recommender) buildHistoricalContext(
 	now time.Time,
 ) HistoricalContext {
 	// Calculate 30-day statistics
-	var sum, sumSq float64
-	min := math.MaxFloat64
-	max := -math.MaxFloat64
+	values := make([]fixed.Rate, len(rates))
+	min := fixed.Rate(math.MaxInt64)
+	max := fixed.Rate(math.MinInt64)
 
-	for _, rate := range rates {
-		sum += rate.RtcBid
-		sumSq += rate.RtcBid * rate.RtcBid
+	for i, rate := range rates {
+		values[i] = rate.RtcBid
 		if rate.RtcBid < min {
 			min = rate.RtcBid
 		}
@@ -225,18 +230,21 @@ func (r *Recommender) buildHistoricalContext(
 		}
 	}
 
-	n := float64(len(rates))
-	avg := sum / n
-	variance := (sumSq / n) - (avg * avg)
-	stdDev := math.Sqrt(variance)
+	avg := fixed.Mean(values)
+	var sumSq float64
+	for _, v := range values {
+		d := (v - avg).Float64()
+		sumSq += d * d
+	}
+	stdDev := math.Sqrt(sumSq / float64(len(values)))
 
 	// Get current hour pattern
-	cstLocation := time.FixedZone("CST", 8*60*60)
+	cstLocation := market.Zone
 	nowCST := now.In(cstLocation)
 	currentHour := nowCST.Hour()
 	currentDOW := nowCST.Weekday()
 
-	var hourlyAvg, dailyAvg float64
+	var hourlyAvg, dailyAvg fixed.Rate
 	for _, hp := range hourlyPatterns {
 		if hp.Hour == currentHour {
 			hourlyAvg = hp.AvgRate
@@ -272,7 +280,7 @@ func (r *Recommender) predictNextHours(
 	currentRate float64,
 	histContext HistoricalContext,
 ) []HourPrediction {
-	cstLocation := time.FixedZone("CST", 8*60*60)
+	cstLocation := market.Zone
 	nowCST := now.In(cstLocation)
 	currentHour := nowCST.Hour()
 
@@ -300,15 +308,15 @@ func (r *Recommender) predictNextHours(
 
 		// Predict rate: blend historical average with current trend
 		// 70% historical pattern, 30% current rate adjustment
-		trendAdjustment := currentRate - histContext.HourlyAvgRate
-		predictedRate := (pattern.AvgRate * 0.7) + ((currentRate + trendAdjustment*0.3) * 0.3)
+		trendAdjustment := currentRate - histContext.HourlyAvgRate.Float64()
+		predictedRate := (pattern.AvgRate.Float64() * 0.7) + ((currentRate + trendAdjustment*0.3) * 0.3)
 
 		// Confidence based on sample count and volatility
 		confidence := 50.0
 		if pattern.SampleCount > 100 {
 			confidence += 20.0
 		}
-		rangeRatio := (pattern.MaxRate - pattern.MinRate) / pattern.AvgRate
+		rangeRatio := (pattern.MaxRate - pattern.MinRate).Float64() / pattern.AvgRate.Float64()
 		if rangeRatio < 0.01 {
 			confidence += 15.0 // Low volatility increases confidence
 		}
@@ -356,7 +364,7 @@ func (r *Recommender) findOptimalWindow(
 	}
 
 	// Create time window around optimal hour (±1 hour)
-	cstLocation := time.FixedZone("CST", 8*60*60)
+	cstLocation := market.Zone
 	nowCST := now.In(cstLocation)
 
 	startHour := bestPrediction.Hour
@@ -416,10 +424,10 @@ func (r *Recommender) determineAction(
 
 	// Factor 2: Comparison to hourly average (25% weight)
 	if histContext.HourlyAvgRate > 0 {
-		diff := currentRate - histContext.HourlyAvgRate
-		diffPct := (diff / histContext.HourlyAvgRate) * 100
+		hourlyAvg := histContext.HourlyAvgRate.Float64()
+		diffPct := (currentRate - hourlyAvg) / hourlyAvg * 100
 
-		if diff >= 0 {
+		if diffPct >= 0 {
 			if diffPct > 0.5 {
 				score += 25
 				reasons = append(reasons, fmt.Sprintf("Rate is %.2f%% above hourly average", diffPct))
@@ -435,7 +443,7 @@ func (r *Recommender) determineAction(
 
 	// Factor 3: Future predictions (35% weight)
 	if optimalWindow != nil {
-		cstLocation := time.FixedZone("CST", 8*60*60)
+		cstLocation := market.Zone
 		nowCST := now.In(cstLocation)
 
 		// Is the optimal window in the near future?
@@ -510,12 +518,12 @@ func (r *Recommender) calculateRiskReward(
 		potentialGain = optentialUSD - currentUSD
 
 		// Loss if rate goes down to recent min
-		worstCaseRate := histContext.MinRate30Days
+		worstCaseRate := histContext.MinRate30Days.Float64()
 		worstCaseUSD := amount / worstCaseRate
 		potentialLoss = currentUSD - worstCaseUSD
 
 		// Risk assessment based on volatility
-		volatility := histContext.StdDev30Days / histContext.AvgRate30Days
+		volatility := histContext.StdDev30Days / histContext.AvgRate30Days.Float64()
 		if volatility > 0.02 {
 			riskLevel = "HIGH"
 		} else if volatility > 0.01 {
@@ -545,11 +553,11 @@ func (r *Recommender) determineNextCheckTime(now time.Time, action Action, optim
 }
 
 // GetPercentileRank returns the percentile rank of a given rate (public helper)
-func (r *Recommender) GetPercentileRank(ctx context.Context, rate float64, days int) (float64, error) {
+func (r *Recommender) GetPercentileRank(ctx context.Context, currency string, rate fixed.Rate, days int) (float64, error) {
 	startTime := time.Now().AddDate(0, 0, -days)
 	endTime := time.Now()
 
-	rates, err := r.repo.GetRatesByTimeRange(ctx, startTime, endTime)
+	rates, err := r.repo.GetRatesByTimeRange(ctx, currency, startTime, endTime)
 	if err != nil {
 		return 0, fmt.Errorf("getting historical rates: %w", err)
 	}
@@ -562,8 +570,8 @@ func (r *Recommender) GetPercentileRank(ctx context.Context, rate float64, days
 }
 
 // GetHistoricalRanking provides historical context for a rate
-func (r *Recommender) GetHistoricalRanking(ctx context.Context, rate float64, days int) (string, error) {
-	percentile, err := r.GetPercentileRank(ctx, rate, days)
+func (r *Recommender) GetHistoricalRanking(ctx context.Context, currency string, rate fixed.Rate, days int) (string, error) {
+	percentile, err := r.GetPercentileRank(ctx, currency, rate, days)
 	if err != nil {
 		return "", err
 	}
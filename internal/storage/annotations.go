@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Annotation is a note pinned to a point or range of time, such as a market
+// event, a conversion or an outage
+type Annotation struct {
+	ID           int64
+	CurrencyCode string // Empty: every currency
+	StartsAt     time.Time
+	EndsAt       time.Time // Zero: a point in time
+	Note         string
+}
+
+// End returns when the annotation ends; a point in time ends as it starts
+func (a Annotation) End() time.Time {
+	if a.EndsAt.IsZero() {
+		return a.StartsAt
+	}
+	return a.EndsAt
+}
+
+// Overlaps reports whether the annotation overlaps start to end (inclusive)
+func (a Annotation) Overlaps(start, end time.Time) bool {
+	return !a.StartsAt.After(end) && !a.End().Before(start)
+}
+
+// appliesTo reports whether the annotation concerns a currency (empty: any)
+func (a Annotation) appliesTo(currency string) bool {
+	return currency == "" || a.CurrencyCode == "" || a.CurrencyCode == currency
+}
+
+// check validates an annotation about to be stored
+func (a *Annotation) check() error {
+	if strings.TrimSpace(a.Note) == "" {
+		return fmt.Errorf("annotation note is empty")
+	}
+	if a.StartsAt.IsZero() {
+		return fmt.Errorf("annotation has no time")
+	}
+	if !a.EndsAt.IsZero() && a.EndsAt.Before(a.StartsAt) {
+		return fmt.Errorf("annotation ends before it starts")
+	}
+	return nil
+}
+
+// AddAnnotation stores an annotation and sets its ID
+func (r *Repository) AddAnnotation(ctx context.Context, a *Annotation) error {
+	if err := a.check(); err != nil {
+		return err
+	}
+
+	var endsAt sql.NullTime
+	if !a.EndsAt.IsZero() {
+		endsAt = sql.NullTime{Time: a.EndsAt.UTC(), Valid: true}
+	}
+	result, err := r.db.conn.ExecContext(ctx, `
+		INSERT INTO annotations (currency_code, starts_at, ends_at, note)
+		VALUES (NULLIF(?, ''), ?, ?, ?)`,
+		a.CurrencyCode, a.StartsAt.UTC(), endsAt, a.Note)
+	if err != nil {
+		return fmt.Errorf("inserting annotation: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting insert ID: %w", err)
+	}
+
+	a.ID = id
+	return nil
+}
+
+// GetAnnotations returns the annotations of a currency (empty: every
+// currency), including those of every currency, that overlap start to end,
+// in time order. Annotations belong to no source, so every source-scoped
+// view sees them all
+func (r *Repository) GetAnnotations(ctx context.Context, currency string, start, end time.Time) ([]Annotation, error) {
+	query := `
+		SELECT id, COALESCE(currency_code, ''), starts_at, ends_at, note
+		FROM annotations
+		WHERE starts_at <= ? AND COALESCE(ends_at, starts_at) >= ?
+		  AND (? = '' OR currency_code IS NULL OR currency_code = ?)
+		ORDER BY starts_at, id
+	`
+
+	rows, err := r.db.conn.QueryContext(ctx, query, end.UTC(), start.UTC(), currency, currency)
+	if err != nil {
+		return nil, fmt.Errorf("querying annotations: %w", err)
+	}
+	defer rows.Close()
+
+	var annotations []Annotation
+	for rows.Next() {
+		var a Annotation
+		var endsAt sql.NullTime
+		if err := rows.Scan(&a.ID, &a.CurrencyCode, &a.StartsAt, &endsAt, &a.Note); err != nil {
+			return nil, fmt.Errorf("scanning annotation: %w", err)
+		}
+		a.EndsAt = endsAt.Time
+		annotations = append(annotations, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating annotations: %w", err)
+	}
+
+	return annotations, nil
+}
+
+// DeleteAnnotation deletes an annotation by ID
+func (r *Repository) DeleteAnnotation(ctx context.Context, id int64) error {
+	result, err := r.db.conn.ExecContext(ctx, `DELETE FROM annotations WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting annotation: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no annotation with ID %d", id)
+	}
+	return nil
+}
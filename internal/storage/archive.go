@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// RawResponse is an archived API response body
+type RawResponse struct {
+	ID            int64
+	Source        string
+	CollectedAt   time.Time
+	DatePartition string
+	Checksum      string // SHA-256 of Body
+	Body          []byte // Uncompressed body; stored gzip-compressed
+}
+
+// Checksum returns the archive checksum of a response body
+func Checksum(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// InsertRawResponse compresses and archives a raw response body
+func (r *Repository) InsertRawResponse(ctx context.Context, resp *RawResponse) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(resp.Body); err != nil {
+		return fmt.Errorf("compressing response: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("compressing response: %w", err)
+	}
+
+	if resp.Checksum == "" {
+		resp.Checksum = Checksum(resp.Body)
+	}
+
+	query := `
+		INSERT INTO raw_responses (source, collected_at, date_partition, checksum, body)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.conn.ExecContext(ctx, query,
+		resp.Source, resp.CollectedAt.UTC(), resp.DatePartition, resp.Checksum, buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("inserting raw response: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting insert ID: %w", err)
+	}
+
+	resp.ID = id
+	return nil
+}
+
+// GetArchiveDates lists the dates with archived responses between start and
+// end (YYYY-MM-DD, inclusive; empty for no bound)
+func (r *Repository) GetArchiveDates(ctx context.Context, start, end string) ([]string, error) {
+	query := `
+		SELECT DISTINCT date_partition
+		FROM raw_responses
+		WHERE ` + sourceFilter + `
+		  AND (? = '' OR date_partition >= ?)
+		  AND (? = '' OR date_partition <= ?)
+		ORDER BY date_partition
+	`
+
+	rows, err := r.db.conn.QueryContext(ctx, query, r.source, r.source, start, start, end, end)
+	if err != nil {
+		return nil, fmt.Errorf("querying archive dates: %w", err)
+	}
+	defer rows.Close()
+
+	var dates []string
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("scanning date: %w", err)
+		}
+		dates = append(dates, date)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating dates: %w", err)
+	}
+
+	return dates, nil
+}
+
+// GetRawResponsesForDate retrieves and decompresses the responses archived
+// on a date, in collection order
+func (r *Repository) GetRawResponsesForDate(ctx context.Context, date string) ([]RawResponse, error) {
+	query := `
+		SELECT id, source, collected_at, date_partition, checksum, body
+		FROM raw_responses
+		WHERE date_partition = ? AND ` + sourceFilter + `
+		ORDER BY collected_at, id
+	`
+
+	rows, err := r.db.conn.QueryContext(ctx, query, date, r.source, r.source)
+	if err != nil {
+		return nil, fmt.Errorf("querying raw responses: %w", err)
+	}
+	defer rows.Close()
+
+	var responses []RawResponse
+	for rows.Next() {
+		var resp RawResponse
+		var compressed []byte
+		err := rows.Scan(
+			&resp.ID,
+			&resp.Source,
+			&resp.CollectedAt,
+			&resp.DatePartition,
+			&resp.Checksum,
+			&compressed,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning raw response: %w", err)
+		}
+
+		resp.Body, err = decompress(compressed)
+		if err != nil {
+			return nil, fmt.Errorf("raw response %d: %w", resp.ID, err)
+		}
+		responses = append(responses, resp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating raw responses: %w", err)
+	}
+
+	return responses, nil
+}
+
+// CountArchivedRates counts the exchange rates linked to responses archived on a date
+func (r *Repository) CountArchivedRates(ctx context.Context, date string) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM exchange_rates
+		WHERE response_id IN (
+			SELECT id FROM raw_responses WHERE date_partition = ? AND ` + sourceFilter + `
+		)
+	`
+
+	var count int64
+	err := r.db.conn.QueryRowContext(ctx, query, date, r.source, r.source).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting archived rates: %w", err)
+	}
+	return count, nil
+}
+
+// ReplaceArchivedRates deletes the exchange rates linked to the given raw
+// responses and stores rates in their place, in one transaction
+// Returns the number of rows deleted
+func (r *Repository) ReplaceArchivedRates(ctx context.Context, responseIDs []int64, rates []*ExchangeRate) (int64, error) {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	touched := rollupSet{}
+	var deleted int64
+	if len(responseIDs) > 0 {
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(responseIDs)), ",")
+		args := make([]any, len(responseIDs))
+		for i, id := range responseIDs {
+			args[i] = id
+		}
+
+		// The rollups of deleted rows shrink unless the new rates refill them
+		rows, err := tx.QueryContext(ctx,
+			"DELETE FROM exchange_rates WHERE response_id IN ("+placeholders+") RETURNING "+rollupKeyColumns, args...)
+		if err != nil {
+			return 0, fmt.Errorf("deleting archived rates: %w", err)
+		}
+		if deleted, err = touched.addRows(rows); err != nil {
+			return 0, fmt.Errorf("deleting archived rates: %w", err)
+		}
+	}
+
+	for _, rate := range rates {
+		if err := upsertRate(ctx, tx, rate, touched); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := touched.refresh(ctx, tx); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing rates: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// decompress inflates a gzip-compressed archive body
+func decompress(data []byte) ([]byte, error) {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+	defer zr.Close()
+
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+	return body, nil
+}
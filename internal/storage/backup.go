@@ -0,0 +1,306 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// Snapshot files are named rates-<UTC time>.db so they sort by age. The time
+// goes down to the microsecond so a manual backup taken during a scheduled one
+// gets a name of its own
+const (
+	snapshotPrefix     = "rates-"
+	snapshotSuffix     = ".db"
+	snapshotTimeFormat = "20060102T150405.000000Z"
+)
+
+// BackupInfo describes a verified database snapshot
+type BackupInfo struct {
+	Path          string
+	Size          int64 // Bytes
+	Rates         int64 // Rows in exchange_rates
+	SchemaVersion int   // Highest applied migration (0: unversioned)
+}
+
+// BackupSchedule configures the daemon's periodic snapshots
+type BackupSchedule struct {
+	Dir      string        // Directory snapshots are written to
+	Interval time.Duration // Time between snapshots
+	Keep     int           // Newest snapshots kept; older ones are deleted (0: keep all)
+}
+
+// Backup writes a consistent copy of the database to dest with VACUUM INTO
+// and verifies it before it appears under its final name
+// It is safe while the daemon writes: the copy is read in a single WAL read
+// transaction, so it sees the database as of one commit and blocks no writer
+func (db *DB) Backup(ctx context.Context, dest string) (*BackupInfo, error) {
+	if _, err := os.Stat(dest); err == nil {
+		return nil, fmt.Errorf("%s already exists", dest)
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+	if _, err := db.conn.ExecContext(ctx, "VACUUM INTO ?", tmp); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("copying database: %w", err)
+	}
+
+	info, err := VerifyBackup(ctx, tmp)
+	if err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("renaming backup: %w", err)
+	}
+	info.Path = dest
+
+	db.logger.Info("database backed up", "path", dest, "bytes", info.Size, "rates", info.Rates)
+	return info, nil
+}
+
+// Snapshot backs the database up to a timestamped file in dir, then deletes
+// all but the newest keep snapshots there (keep 0 deletes none)
+func (db *DB) Snapshot(ctx context.Context, dir string, keep int) (*BackupInfo, error) {
+	name := snapshotPrefix + time.Now().UTC().Format(snapshotTimeFormat) + snapshotSuffix
+	info, err := db.Backup(ctx, filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	if keep > 0 {
+		snapshots, err := ListBackups(dir)
+		if err != nil {
+			return info, err
+		}
+		for _, old := range snapshots[min(keep, len(snapshots)):] {
+			if err := os.Remove(old); err != nil {
+				return info, fmt.Errorf("rotating snapshots: %w", err)
+			}
+			db.logger.Info("deleted old snapshot", "path", old)
+		}
+	}
+
+	return info, nil
+}
+
+// RunBackups takes a snapshot every schedule.Interval until ctx is cancelled
+// A failed snapshot is logged and tried again at the next interval
+func (db *DB) RunBackups(ctx context.Context, schedule BackupSchedule) error {
+	ticker := time.NewTicker(schedule.Interval)
+	defer ticker.Stop()
+
+	db.logger.Info("scheduled backups enabled",
+		"dir", schedule.Dir,
+		"interval", schedule.Interval,
+		"keep", schedule.Keep)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if _, err := db.Snapshot(ctx, schedule.Dir, schedule.Keep); err != nil {
+				db.logger.Error("scheduled backup failed", "dir", schedule.Dir, "error", err)
+			}
+		}
+	}
+}
+
+// ListBackups returns the snapshot files in dir, newest first
+func ListBackups(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading backup directory: %w", err)
+	}
+
+	var snapshots []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.Type().IsRegular() && strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotSuffix) {
+			snapshots = append(snapshots, filepath.Join(dir, name))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(snapshots)))
+	return snapshots, nil
+}
+
+// VerifyBackup opens a snapshot read-only, runs SQLite's integrity check on
+// it and summarizes its contents
+func VerifyBackup(ctx context.Context, path string) (*BackupInfo, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("opening backup: %w", err)
+	}
+	defer conn.Close()
+
+	if err := integrityCheck(ctx, conn); err != nil {
+		return nil, fmt.Errorf("backup %s: %w", path, err)
+	}
+
+	info := &BackupInfo{Path: path, Size: stat.Size()}
+	if err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM exchange_rates").Scan(&info.Rates); err != nil {
+		return nil, fmt.Errorf("backup %s: counting rates: %w", path, err)
+	}
+
+	var ledger int
+	err = conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&ledger)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s: reading tables: %w", path, err)
+	}
+	if ledger > 0 {
+		err := conn.QueryRowContext(ctx,
+			"SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&info.SchemaVersion)
+		if err != nil {
+			return nil, fmt.Errorf("backup %s: reading schema version: %w", path, err)
+		}
+	}
+
+	return info, nil
+}
+
+// Restore replaces the database's contents with a verified snapshot using
+// SQLite's online backup API, then applies any migrations the snapshot
+// predates
+// Other connections, the daemon's included, wait on the lock while the pages
+// are copied and never see a partial restore
+func (db *DB) Restore(ctx context.Context, snapshot string) (*BackupInfo, error) {
+	info, err := VerifyBackup(ctx, snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	if latest := migrations[len(migrations)-1].Version; info.SchemaVersion > latest {
+		return nil, fmt.Errorf("backup is at schema version %d, newer than this build supports (%d)", info.SchemaVersion, latest)
+	}
+
+	src, err := sql.Open("sqlite3", "file:"+snapshot+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("opening backup: %w", err)
+	}
+	defer src.Close()
+
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("opening backup: %w", err)
+	}
+	defer srcConn.Close()
+
+	dstConn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	defer dstConn.Close()
+
+	err = dstConn.Raw(func(dst any) error {
+		return srcConn.Raw(func(src any) error {
+			return copyPages(ctx, dst.(*sqlite3.SQLiteConn), src.(*sqlite3.SQLiteConn))
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restoring %s: %w", snapshot, err)
+	}
+
+	if _, err := db.MigrateUp(ctx); err != nil {
+		return nil, fmt.Errorf("migrating restored database: %w", err)
+	}
+	if err := integrityCheck(ctx, db.conn); err != nil {
+		return nil, fmt.Errorf("restored database: %w", err)
+	}
+
+	db.logger.Info("database restored", "from", snapshot, "rates", info.Rates)
+	return info, nil
+}
+
+// copyPages copies every page of src over dst in one backup step, retrying
+// while another connection holds a lock
+func copyPages(ctx context.Context, dst, src *sqlite3.SQLiteConn) error {
+	backup, err := dst.Backup("main", src, "main")
+	if err != nil {
+		return fmt.Errorf("starting backup: %w", err)
+	}
+
+	for {
+		done, err := backup.Step(-1)
+		if err != nil {
+			backup.Finish()
+			return fmt.Errorf("copying pages: %w", err)
+		}
+		if done {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			backup.Finish()
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	if err := backup.Finish(); err != nil {
+		return fmt.Errorf("finishing backup: %w", err)
+	}
+	return nil
+}
+
+// integrityCheck runs PRAGMA integrity_check and returns the problems found
+func integrityCheck(ctx context.Context, conn *sql.DB) error {
+	problems, err := integrityProblems(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// integrityProblems runs PRAGMA integrity_check and lists what it reports
+func integrityProblems(ctx context.Context, conn *sql.DB) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, "PRAGMA integrity_check")
+	if err != nil {
+		return nil, fmt.Errorf("checking integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return nil, fmt.Errorf("checking integrity: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("checking integrity: %w", err)
+	}
+	return problems, nil
+}
@@ -0,0 +1,203 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+func newTestDB(t *testing.T) (*DB, *Repository) {
+	t.Helper()
+	db, err := NewDB(filepath.Join(t.TempDir(), "rates.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db, NewRepository(db, testLogger())
+}
+
+func testRate(at time.Time) *ExchangeRate {
+	return &ExchangeRate{
+		CurrencyCode:  "USD",
+		Source:        "cmb",
+		RtcBid:        fixed.MustParse("7.0749"),
+		CollectedAt:   at,
+		DatePartition: at.Format("2006-01-02"),
+	}
+}
+
+func insertTestRate(t *testing.T, repo *Repository, at time.Time) {
+	t.Helper()
+	if err := repo.InsertRate(context.Background(), testRate(at)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func countRates(t *testing.T, db *DB) int64 {
+	t.Helper()
+	var n int64
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM exchange_rates").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestBackupWhileWriting(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	start := time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC)
+	insertTestRate(t, repo, start)
+
+	// The daemon keeps writing while the backup runs
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 50; i++ {
+			if err := repo.InsertRate(ctx, testRate(start.Add(time.Duration(i)*time.Minute))); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	info, err := db.Backup(ctx, dest)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+	if info.Rates < 1 || info.Rates > 51 {
+		t.Errorf("backup has %d rates, want a snapshot between 1 and 51", info.Rates)
+	}
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest := migrations[len(migrations)-1].Version; info.SchemaVersion != latest {
+		t.Errorf("SchemaVersion = %d, want %d", info.SchemaVersion, latest)
+	}
+	if _, err := os.Stat(dest + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+
+	if _, err := db.Backup(ctx, dest); err == nil {
+		t.Error("Backup() should refuse to overwrite an existing file")
+	}
+}
+
+func TestVerifyBackupRejectsCorruptFile(t *testing.T) {
+	db, repo := newTestDB(t)
+	insertTestRate(t, repo, time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC))
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if _, err := db.Backup(context.Background(), dest); err != nil {
+		t.Fatal(err)
+	}
+
+	// Truncate the snapshot, as a copy taken mid-write would be
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dest, data[:len(data)/2], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyBackup(context.Background(), dest); err == nil {
+		t.Error("VerifyBackup() accepted a truncated snapshot")
+	}
+}
+
+func TestSnapshotRotation(t *testing.T) {
+	db, _ := newTestDB(t)
+	dir := t.TempDir()
+
+	// Older snapshots from earlier runs
+	for _, name := range []string{"rates-20250101T000000Z.db", "rates-20250102T000000Z.db", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	info, err := db.Snapshot(context.Background(), dir, 2)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+
+	snapshots, err := ListBackups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{info.Path, filepath.Join(dir, "rates-20250102T000000Z.db")}
+	if len(snapshots) != 2 || snapshots[0] != want[0] || snapshots[1] != want[1] {
+		t.Errorf("snapshots = %v, want %v", snapshots, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); err != nil {
+		t.Error("rotation deleted a file that is not a snapshot")
+	}
+}
+
+func TestSnapshotsInOneSecond(t *testing.T) {
+	db, _ := newTestDB(t)
+	dir := t.TempDir()
+
+	// A manual backup taken while a scheduled one runs
+	first, err := db.Snapshot(context.Background(), dir, 0)
+	if err != nil {
+		t.Fatalf("Snapshot() error = %v", err)
+	}
+	second, err := db.Snapshot(context.Background(), dir, 0)
+	if err != nil {
+		t.Fatalf("second Snapshot() error = %v", err)
+	}
+
+	snapshots, err := ListBackups(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0] != second.Path || snapshots[1] != first.Path {
+		t.Errorf("snapshots = %v, want %s and %s newest first", snapshots, second.Path, first.Path)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	start := time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC)
+	insertTestRate(t, repo, start)
+	insertTestRate(t, repo, start.Add(time.Minute))
+
+	dest := filepath.Join(t.TempDir(), "backup.db")
+	if _, err := db.Backup(ctx, dest); err != nil {
+		t.Fatal(err)
+	}
+
+	insertTestRate(t, repo, start.Add(2*time.Minute))
+	if _, err := db.conn.Exec("DELETE FROM exchange_rates WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := db.Restore(ctx, dest)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if info.Rates != 2 || countRates(t, db) != 2 {
+		t.Errorf("restored %d rates, database has %d; want 2", info.Rates, countRates(t, db))
+	}
+
+	var mode string
+	if err := db.conn.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q (%v), want wal", mode, err)
+	}
+
+	// The restored database keeps taking writes
+	insertTestRate(t, repo, start.Add(3*time.Minute))
+	if countRates(t, db) != 3 {
+		t.Errorf("database has %d rates after a write, want 3", countRates(t, db))
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -19,8 +17,25 @@ type DB struct {
 	logger *slog.Logger
 }
 
-// NewDB creates a new database connection and runs migrations
-func NewDB(dbPath string, migrationPath string, logger *slog.Logger) (*DB, error) {
+// NewDB opens the database and applies any pending migrations
+func NewDB(dbPath string, logger *slog.Logger) (*DB, error) {
+	db, err := OpenDB(dbPath, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.MigrateUp(context.Background()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("running migrations: %w", err)
+	}
+
+	logger.Info("database initialized", "path", dbPath)
+
+	return db, nil
+}
+
+// OpenDB opens the database without migrating it (see `ratemon migrate`)
+func OpenDB(dbPath string, logger *slog.Logger) (*DB, error) {
 	// Ensure data directory exists
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return nil, fmt.Errorf("creating data directory: %w", err)
@@ -38,52 +53,10 @@ func NewDB(dbPath string, migrationPath string, logger *slog.Logger) (*DB, error
 	conn.SetMaxOpenConns(5)
 	conn.SetMaxIdleConns(2)
 
-	db := &DB{
+	return &DB{
 		conn:   conn,
 		logger: logger,
-	}
-
-	// Run migrations
-	if err := db.migrate(migrationPath); err != nil {
-		conn.Close()
-		return nil, fmt.Errorf("running migrations: %w", err)
-	}
-
-	logger.Info("database initialized", "path", dbPath)
-
-	return db, nil
-}
-
-// migrate runs SQL migration files in order
-func (db *DB) migrate(migrationPath string) error {
-	files, err := os.ReadDir(migrationPath)
-	if err != nil {
-		return fmt.Errorf("reading migration directory: %w", err)
-	}
-
-	// Sort files by name to ensure order
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Name() < files[j].Name()
-	})
-
-	for _, file := range files {
-		if !strings.HasSuffix(file.Name(), ".sql") {
-			continue
-		}
-
-		content, err := os.ReadFile(filepath.Join(migrationPath, file.Name()))
-		if err != nil {
-			return fmt.Errorf("reading migration %s: %w", file.Name(), err)
-		}
-
-		if _, err := db.conn.Exec(string(content)); err != nil {
-			return fmt.Errorf("executing migration %s: %w", file.Name(), err)
-		}
-
-		db.logger.Info("applied migration", "file", file.Name())
-	}
-
-	return nil
+	}, nil
 }
 
 // Close closes the database connection
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// DuplicateGroup is a set of stored rows sharing a natural key: currency,
+// source and observation time
+// Deduplication keeps the first stored row with the prices of the last, as
+// if the later rows had been stored through InsertRate
+type DuplicateGroup struct {
+	CurrencyCode  string
+	Source        string
+	ObservedAt    time.Time
+	DatePartition string // Date of the row kept
+	Rows          int64  // Rows with the key, including the one kept
+	KeepID        int64  // First stored row, which survives
+	LatestID      int64  // Last stored row, whose prices the kept row takes
+	Conflicting   bool   // Rows disagree on at least one price side
+}
+
+// Extra returns the number of rows deduplication removes from the group
+func (g DuplicateGroup) Extra() int64 {
+	return g.Rows - 1
+}
+
+// FindDuplicates lists the groups of rows sharing a natural key, oldest first
+// Rows stored before inserts were idempotent may repeat a quote
+func (r *Repository) FindDuplicates(ctx context.Context) ([]DuplicateGroup, error) {
+	return findDuplicates(ctx, r.db.conn, r.source)
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func findDuplicates(ctx context.Context, q queryer, source string) ([]DuplicateGroup, error) {
+	// Prices are compared with unquoted sides as 0, as ExchangeRate holds them
+	query := `
+		SELECT e.currency_code, e.source, e.quoted_at, e.collected_at, e.date_partition,
+			g.row_count, g.keep_id, g.latest_id, g.variants > 1
+		FROM (
+			SELECT MIN(id) AS keep_id, MAX(id) AS latest_id, COUNT(*) AS row_count,
+				COUNT(DISTINCT printf('%d/%d/%d/%d/%d', rtc_bid, rtb_bid, rth_bid, rth_ofr, rtc_ofr)) AS variants
+			FROM exchange_rates
+			WHERE ` + sourceFilter + `
+			GROUP BY currency_code, source, ` + observedAtExpr + `
+			HAVING COUNT(*) > 1
+		) g
+		JOIN exchange_rates e ON e.id = g.keep_id
+		ORDER BY g.keep_id
+	`
+
+	rows, err := q.QueryContext(ctx, query, source, source)
+	if err != nil {
+		return nil, fmt.Errorf("querying duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []DuplicateGroup
+	for rows.Next() {
+		var g DuplicateGroup
+		var quotedAt sql.NullTime
+		var collectedAt time.Time
+		if err := rows.Scan(&g.CurrencyCode, &g.Source, &quotedAt, &collectedAt, &g.DatePartition,
+			&g.Rows, &g.KeepID, &g.LatestID, &g.Conflicting); err != nil {
+			return nil, fmt.Errorf("scanning duplicate group: %w", err)
+		}
+		g.ObservedAt = (&ExchangeRate{QuotedAt: quotedAt.Time, CollectedAt: collectedAt}).ObservedAt()
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
+
+// RemoveDuplicates collapses every group found by FindDuplicates into its
+// first stored row, in one transaction
+// Returns the groups collapsed and the number of rows deleted
+func (r *Repository) RemoveDuplicates(ctx context.Context) ([]DuplicateGroup, int64, error) {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	groups, err := findDuplicates(ctx, tx, r.source)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// The kept row takes the latest prices and keeps its archive link unless
+	// the latest row has one, as upsertRate would have left it
+	mergeQuery := `
+		UPDATE exchange_rates
+		SET (rtc_bid, rtb_bid, rth_bid, rth_ofr, rtc_ofr, response_id) = (
+			SELECT l.rtc_bid, l.rtb_bid, l.rth_bid, l.rth_ofr, l.rtc_ofr,
+				COALESCE(l.response_id, exchange_rates.response_id)
+			FROM exchange_rates l WHERE l.id = ?
+		)
+		WHERE id = ?
+		RETURNING ` + rollupKeyColumns + `
+	`
+	deleteQuery := `
+		DELETE FROM exchange_rates
+		WHERE id > ? AND currency_code = ? AND source = ?
+			AND ` + observedAtExpr + ` = (SELECT ` + observedAtExpr + ` FROM exchange_rates WHERE id = ?)
+		RETURNING ` + rollupKeyColumns + `
+	`
+
+	// Copies may have been collected on other dates than the row kept
+	touched := rollupSet{}
+	var deleted int64
+	for _, g := range groups {
+		rows, err := tx.QueryContext(ctx, mergeQuery, g.LatestID, g.KeepID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("merging duplicates of rate %d: %w", g.KeepID, err)
+		}
+		if _, err := touched.addRows(rows); err != nil {
+			return nil, 0, fmt.Errorf("merging duplicates of rate %d: %w", g.KeepID, err)
+		}
+
+		rows, err = tx.QueryContext(ctx, deleteQuery, g.KeepID, g.CurrencyCode, g.Source, g.KeepID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("deleting duplicates of rate %d: %w", g.KeepID, err)
+		}
+		n, err := touched.addRows(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("deleting duplicates of rate %d: %w", g.KeepID, err)
+		}
+		deleted += n
+	}
+
+	if err := touched.refresh(ctx, tx); err != nil {
+		return nil, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, 0, fmt.Errorf("committing deduplication: %w", err)
+	}
+
+	r.logger.Info("removed duplicate rates", "groups", len(groups), "deleted", deleted)
+	return groups, deleted, nil
+}
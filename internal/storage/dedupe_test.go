@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+// insertDuplicate stores a rate without the natural key lookup, the way
+// inserts worked before they were idempotent. The UNIQUE natural key is
+// swapped for the plain index of databases from then, until restoreUniqueKey
+func insertDuplicate(t *testing.T, db *DB, rate *ExchangeRate) {
+	t.Helper()
+	if _, err := db.conn.Exec(`
+		DROP INDEX idx_rates_natural_key;
+		CREATE INDEX idx_rates_natural_key
+			ON exchange_rates(currency_code, source, ` + observedAtExpr + `)
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(insertRateQuery, insertRateArgs(rate)...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// restoreUniqueKey makes the natural key UNIQUE again, failing the test if
+// duplicates are left
+func restoreUniqueKey(t *testing.T, db *DB) {
+	t.Helper()
+	if _, err := db.conn.Exec(`
+		DROP INDEX idx_rates_natural_key;
+		CREATE UNIQUE INDEX idx_rates_natural_key
+			ON exchange_rates(currency_code, source, ` + observedAtExpr + `)
+	`); err != nil {
+		t.Fatalf("restoring the UNIQUE natural key: %v", err)
+	}
+}
+
+func TestInsertRateIsIdempotent(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	at := time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC)
+
+	first := testRate(at)
+	first.QuotedAt = at.Add(-time.Minute)
+	if err := repo.InsertRate(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same quote polled again, by a restarted or second daemon
+	again := testRate(at.Add(time.Minute))
+	again.QuotedAt = first.QuotedAt
+	again.RtcBid = fixed.MustParse("7.0750")
+	if err := repo.InsertRates(ctx, []*ExchangeRate{again}); err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != first.ID {
+		t.Errorf("repeated quote got ID %d, want %d", again.ID, first.ID)
+	}
+	if n := countRates(t, db); n != 1 {
+		t.Errorf("database has %d rates, want 1", n)
+	}
+	latest, err := repo.GetLatestRate(ctx, "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest.RtcBid != again.RtcBid || !latest.CollectedAt.Equal(at) {
+		t.Errorf("stored rate = %s at %s, want %s at %s", latest.RtcBid, latest.CollectedAt, again.RtcBid, at)
+	}
+
+	// Another source or currency is another quote
+	other := testRate(at)
+	other.QuotedAt = first.QuotedAt
+	other.Source = "boc"
+	if err := repo.InsertRate(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRates(t, db); n != 2 {
+		t.Errorf("database has %d rates, want 2", n)
+	}
+}
+
+func TestInsertRateConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC)
+
+	// Two handles on one file, as the daemon and an import have
+	path := filepath.Join(t.TempDir(), "rates.db")
+	db, err := NewDB(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	other, err := NewDB(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	// Both writers store the same quotes, one transaction each
+	const quotes = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*quotes)
+	for _, writer := range []*DB{db, other} {
+		repo := NewRepository(writer, testLogger())
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < quotes; i++ {
+				rate := testRate(at.Add(time.Duration(i) * time.Second))
+				rate.QuotedAt = at.Add(time.Duration(i) * time.Minute)
+				if err := repo.InsertRate(ctx, rate); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Errorf("InsertRate() error = %v", err)
+	}
+	if n := countRates(t, db); n != quotes {
+		t.Errorf("database has %d rates, want %d", n, quotes)
+	}
+}
+
+func TestRemoveDuplicates(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	at := time.Date(2025, 11, 22, 10, 0, 0, 0, time.UTC)
+
+	// Three polls of one quote, the last with a corrected price
+	for i, bid := range []string{"7.0749", "7.0749", "7.0760"} {
+		rate := testRate(at.Add(time.Duration(i) * time.Minute))
+		rate.QuotedAt = at
+		rate.RtcBid = fixed.MustParse(bid)
+		insertDuplicate(t, db, rate)
+	}
+	// Two imports of a quote without a quote time
+	insertDuplicate(t, db, testRate(at.Add(time.Hour)))
+	insertDuplicate(t, db, testRate(at.Add(time.Hour)))
+	// A distinct quote
+	insertDuplicate(t, db, testRate(at.Add(2*time.Hour)))
+
+	groups, err := repo.FindDuplicates(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("FindDuplicates() = %+v, want 2 groups", groups)
+	}
+	if g := groups[0]; g.Rows != 3 || g.KeepID != 1 || g.LatestID != 3 || !g.Conflicting || !g.ObservedAt.Equal(at) {
+		t.Errorf("first group = %+v", g)
+	}
+	if g := groups[1]; g.Rows != 2 || g.Conflicting || !g.ObservedAt.Equal(at.Add(time.Hour)) {
+		t.Errorf("second group = %+v", g)
+	}
+	if n := countRates(t, db); n != 6 {
+		t.Errorf("FindDuplicates() changed the database: %d rates, want 6", n)
+	}
+
+	if _, deleted, err := repo.RemoveDuplicates(ctx); err != nil || deleted != 3 {
+		t.Fatalf("RemoveDuplicates() = %d, %v; want 3 rows deleted", deleted, err)
+	}
+	if n := countRates(t, db); n != 3 {
+		t.Errorf("database has %d rates, want 3", n)
+	}
+
+	var bid fixed.Rate
+	var collectedAt time.Time
+	if err := db.conn.QueryRow("SELECT rtc_bid, collected_at FROM exchange_rates WHERE id = 1").Scan(&bid, &collectedAt); err != nil {
+		t.Fatal(err)
+	}
+	if bid != fixed.MustParse("7.0760") || !collectedAt.Equal(at) {
+		t.Errorf("kept row = %s at %s, want the latest price at the first poll", bid, collectedAt)
+	}
+
+	if groups, err := repo.FindDuplicates(ctx); err != nil || len(groups) != 0 {
+		t.Errorf("FindDuplicates() after removal = %+v, %v", groups, err)
+	}
+	restoreUniqueKey(t, db)
+}
@@ -0,0 +1,274 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// ImportCounts counts what an import did, or would do, at one tier
+type ImportCounts struct {
+	Inserted   int // Rows stored
+	Duplicates int // Already stored: a quote with the same natural key, or a rollup of the same hour or day
+	Covered    int // Skipped because their date is kept at another tier (see ImportBatch)
+}
+
+// ImportStats counts what an import did, or would do, per tier
+type ImportStats struct {
+	Raw    ImportCounts
+	Hourly ImportCounts
+	Daily  ImportCounts
+}
+
+// Tier returns the counts of a tier
+func (s *ImportStats) Tier(tier Tier) *ImportCounts {
+	switch tier {
+	case TierHourly:
+		return &s.Hourly
+	case TierDaily:
+		return &s.Daily
+	}
+	return &s.Raw
+}
+
+// Add adds the counts of another import
+func (s *ImportStats) Add(other ImportStats) {
+	for _, tier := range tiers {
+		sum, add := s.Tier(tier), other.Tier(tier)
+		sum.Inserted += add.Inserted
+		sum.Duplicates += add.Duplicates
+		sum.Covered += add.Covered
+	}
+}
+
+// insertNewRateQuery stores a rate unless a quote with its natural key is
+// already stored (see upsertRateQuery)
+const insertNewRateQuery = insertRateQuery + `
+	ON CONFLICT (currency_code, source, ` + observedAtExpr + `) DO NOTHING
+`
+
+// ImportBatch stores historical quotes and rollups in one transaction
+// Unlike InsertRates it never overwrites: quotes and rollups already stored
+// are counted as duplicates and left as they are. Rollups are only stored
+// for dates without raw quotes of their currency and source, since those
+// dates' rollups are computed from the quotes. Likewise quotes are only
+// stored for dates that have raw quotes or no rollups: a date retention has
+// left only rollups of would have them rebuilt from the imported quotes
+// alone, losing the samples it summarizes. A date given hourly rollups
+// gets its daily rollup recomputed from them. Imported rollups have no
+// observation times, so their peak and first and last collection times are
+// the start of their hour or day. With dryRun the transaction is rolled
+// back, so the stats say what the import would do
+func (r *Repository) ImportBatch(ctx context.Context, rates []*ExchangeRate, rollups []SeriesPoint, dryRun bool) (ImportStats, error) {
+	var stats ImportStats
+
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return stats, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	touched := rollupSet{}
+	rollupOnly := make(map[rollupKey]bool)
+	for _, rate := range rates {
+		args := insertRateArgs(rate)
+		key := rollupKey{currency: rate.CurrencyCode, source: rate.Source, date: rate.DatePartition}
+		only, seen := rollupOnly[key]
+		if !seen {
+			if only, err = hasRollupsOnly(ctx, tx, key); err != nil {
+				return stats, err
+			}
+			rollupOnly[key] = only
+		}
+		if only {
+			stats.Raw.Covered++
+			continue
+		}
+
+		result, err := tx.ExecContext(ctx, insertNewRateQuery, args...)
+		if err != nil {
+			return stats, fmt.Errorf("inserting %s rate: %w", rate.CurrencyCode, err)
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return stats, fmt.Errorf("getting rows affected: %w", err)
+		}
+		if n == 0 {
+			stats.Raw.Duplicates++
+			continue
+		}
+		touched[rateRollupKey(rate)] = true
+		stats.Raw.Inserted++
+	}
+	if err := touched.refresh(ctx, tx); err != nil {
+		return stats, err
+	}
+
+	hourlyDays := rollupSet{}
+	for _, p := range rollups {
+		counts := stats.Tier(p.Tier)
+		key := rollupKey{currency: p.CurrencyCode, source: p.Source, date: p.DatePartition}
+
+		covered, err := hasRawRates(ctx, tx, key)
+		if err != nil {
+			return stats, err
+		}
+		if covered {
+			counts.Covered++
+			continue
+		}
+
+		inserted, err := importRollup(ctx, tx, p)
+		if err != nil {
+			return stats, err
+		}
+		if !inserted {
+			counts.Duplicates++
+			continue
+		}
+		counts.Inserted++
+		if p.Tier == TierHourly {
+			hourlyDays[key] = true
+		}
+	}
+	for key := range hourlyDays {
+		if err := refreshDailyFromHourly(ctx, tx, key); err != nil {
+			return stats, err
+		}
+	}
+
+	if dryRun {
+		return stats, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return stats, fmt.Errorf("committing import: %w", err)
+	}
+	return stats, nil
+}
+
+// hasRawRates reports whether raw quotes of a rollup key's currency, source
+// and date are stored
+func hasRawRates(ctx context.Context, tx *sql.Tx, key rollupKey) (bool, error) {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM exchange_rates
+			WHERE currency_code = ? AND source = ? AND date_partition = ?
+		)`, key.currency, key.source, key.date).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("looking up raw rates of %s: %w", key.date, err)
+	}
+	return exists, nil
+}
+
+// hasRollupsOnly reports whether a rollup key's currency, source and date
+// has rollups but no raw quotes, as retention leaves a date
+func hasRollupsOnly(ctx context.Context, tx *sql.Tx, key rollupKey) (bool, error) {
+	var only bool
+	err := tx.QueryRowContext(ctx, `
+		SELECT (
+			EXISTS (
+				SELECT 1 FROM daily_rates
+				WHERE currency_code = ? AND source = ? AND date_partition = ?
+			) OR EXISTS (
+				SELECT 1 FROM hourly_rates
+				WHERE currency_code = ? AND source = ? AND date_partition = ?
+			)
+		) AND NOT EXISTS (
+			SELECT 1 FROM exchange_rates
+			WHERE currency_code = ? AND source = ? AND date_partition = ?
+		)`,
+		key.currency, key.source, key.date,
+		key.currency, key.source, key.date,
+		key.currency, key.source, key.date).Scan(&only)
+	if err != nil {
+		return false, fmt.Errorf("looking up rollups of %s: %w", key.date, err)
+	}
+	return only, nil
+}
+
+// importRollup stores an hourly or daily rollup unless one of the same hour
+// or day is stored, and reports whether it did
+func importRollup(ctx context.Context, tx *sql.Tx, p SeriesPoint) (bool, error) {
+	at := p.Time.UTC()
+	var result sql.Result
+	var err error
+	switch p.Tier {
+	case TierHourly:
+		result, err = tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO hourly_rates (
+				currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+				sample_count, first_collected_at, last_collected_at
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.CurrencyCode, p.Source, p.DatePartition, p.Time.In(market.Zone).Hour(),
+			p.SumRate, p.AvgRate, p.MinRate, p.MaxRate, p.SampleCount, at, at)
+	case TierDaily:
+		result, err = tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO daily_rates (
+				currency_code, source, date_partition, sum_rate, avg_rate, min_rate, max_rate, peak_rate,
+				peak_time, volatility, sample_count, first_collected_at, last_collected_at
+			)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			p.CurrencyCode, p.Source, p.DatePartition, p.SumRate, p.AvgRate, p.MinRate, p.MaxRate, p.MaxRate,
+			at, p.MaxRate-p.MinRate, p.SampleCount, at, at)
+	default:
+		return false, fmt.Errorf("cannot import a %s point as a rollup", p.Tier)
+	}
+	if err != nil {
+		return false, fmt.Errorf("importing %s rollup of %s: %w", p.Tier, p.DatePartition, err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("getting rows affected: %w", err)
+	}
+	return n > 0, nil
+}
+
+// refreshDailyFromHourly recomputes a daily rollup from the hourly rollups
+// of a date without raw quotes; the peak time is the start of the first
+// hour at the day's highest rate
+func refreshDailyFromHourly(ctx context.Context, tx *sql.Tx, key rollupKey) error {
+	const deleteQuery = `
+		DELETE FROM daily_rates
+		WHERE currency_code = ? AND source = ? AND date_partition = ?
+	`
+	insertQuery := `
+		INSERT INTO daily_rates (
+			currency_code, source, date_partition, sum_rate, avg_rate, min_rate, max_rate, peak_rate,
+			peak_time, volatility, sample_count, first_collected_at, last_collected_at
+		)
+		SELECT
+			currency_code,
+			source,
+			date_partition,
+			SUM(sum_rate) as sum_rate,
+			` + rollupAvg("sum_rate", "sample_count") + ` as avg_rate,
+			MIN(min_rate) as min_rate,
+			MAX(max_rate) as max_rate,
+			MAX(max_rate) as peak_rate,
+			(SELECT p.first_collected_at
+			 FROM hourly_rates p
+			 WHERE p.currency_code = h.currency_code AND p.source = h.source AND p.date_partition = h.date_partition
+			 ORDER BY p.max_rate DESC, p.hour
+			 LIMIT 1) as peak_time,
+			(MAX(max_rate) - MIN(min_rate)) as volatility,
+			SUM(sample_count) as sample_count,
+			MIN(first_collected_at) as first_collected_at,
+			MAX(last_collected_at) as last_collected_at
+		FROM hourly_rates h
+		WHERE currency_code = ? AND source = ? AND date_partition = ?
+		GROUP BY currency_code, source, date_partition
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, key.currency, key.source, key.date); err != nil {
+		return fmt.Errorf("clearing daily rollup: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insertQuery, key.currency, key.source, key.date); err != nil {
+		return fmt.Errorf("rolling up hours of %s %s %s: %w", key.source, key.currency, key.date, err)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+func TestImportBatch(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestDB(t)
+
+	stored := testRate(time.Date(2025, 11, 24, 2, 0, 0, 0, time.UTC))
+	stored.DatePartition = market.Date(stored.CollectedAt)
+	if err := repo.InsertRate(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored quote again with another price, and a new one
+	again := *stored
+	again.ID = 0
+	again.RtcBid = fixed.MustParse("7.5")
+	fresh := testRate(stored.CollectedAt.Add(time.Hour))
+	fresh.DatePartition = stored.DatePartition
+
+	hour := func(date string, h int, rate string, samples int) SeriesPoint {
+		at, err := rollupTime(date, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := fixed.MustParse(rate)
+		return SeriesPoint{Time: at, Tier: TierHourly, CurrencyCode: "USD", Source: "cmb", DatePartition: date,
+			SumRate: r * fixed.Rate(samples), AvgRate: r, MinRate: r, MaxRate: r, SampleCount: samples}
+	}
+	day := hour("2024-03-01", 0, "7.1950", 1)
+	day.Tier = TierDaily
+	rollups := []SeriesPoint{
+		hour("2024-02-29", 9, "7.1900", 10),
+		hour("2024-02-29", 10, "7.2000", 30),
+		hour("2024-02-29", 10, "7.3000", 30), // The same hour again
+		day,
+		hour("2025-11-24", 10, "7.0000", 1), // A date with raw quotes
+	}
+
+	want := ImportStats{
+		Raw:    ImportCounts{Inserted: 1, Duplicates: 1},
+		Hourly: ImportCounts{Inserted: 2, Duplicates: 1, Covered: 1},
+		Daily:  ImportCounts{Inserted: 1},
+	}
+
+	// A dry run reports the same counts and stores nothing
+	for _, dryRun := range []bool{true, false} {
+		stats, err := repo.ImportBatch(ctx, []*ExchangeRate{&again, fresh}, rollups, dryRun)
+		if err != nil {
+			t.Fatalf("ImportBatch(dryRun=%v) error = %v", dryRun, err)
+		}
+		if stats != want {
+			t.Errorf("ImportBatch(dryRun=%v) = %+v, want %+v", dryRun, stats, want)
+		}
+	}
+
+	count, err := repo.Count(ctx)
+	if err != nil || count != 2 {
+		t.Errorf("Count() = %d, %v; want 2", count, err)
+	}
+	if peak, _ := repo.GetDailyPeak(ctx, "USD", stored.DatePartition); peak.RtcBid != stored.RtcBid {
+		t.Errorf("stored quote's price changed to %s", peak.RtcBid)
+	}
+
+	// The hours make up the day, peaking at 10:00
+	stats, err := repo.GetDailyStats(ctx, "USD", "2024-02-29")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.SampleCount != 40 || stats.AvgRate != fixed.MustParse("7.1975") || stats.PeakTime.In(market.Zone).Hour() != 10 {
+		t.Errorf("GetDailyStats() = %+v, want 40 samples averaging 7.1975 and peaking at 10:00", stats)
+	}
+
+	// Importing again finds everything stored
+	stats2, err := repo.ImportBatch(ctx, []*ExchangeRate{&again, fresh}, rollups, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats2.Raw.Inserted+stats2.Hourly.Inserted+stats2.Daily.Inserted != 0 {
+		t.Errorf("ImportBatch() again = %+v, want only duplicates", stats2)
+	}
+}
+
+func TestImportKeepsRetainedRollups(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+
+	// A day of quotes, 6 an hour for 12 hours, whose raw rows retention deletes
+	day := time.Date(2025, 11, 24, 9, 0, 0, 0, market.Zone)
+	for i := 0; i < 72; i++ {
+		rate := testRate(day.Add(time.Duration(i) * 10 * time.Minute))
+		rate.DatePartition = market.Date(rate.CollectedAt)
+		rate.RtcBid = fixed.MustParse("7.1000") + fixed.Rate(i%3-1)*100
+		if err := repo.InsertRate(ctx, rate); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := repo.DeleteRawDataBefore(ctx, "2025-11-25"); err != nil {
+		t.Fatal(err)
+	}
+	before := rollupRows(t, db)
+
+	// A quote of that day turns up in an import
+	late := testRate(day.Add(30 * time.Second))
+	late.DatePartition = "2025-11-24"
+	late.RtcBid = fixed.MustParse("7.2000")
+	stats, err := repo.ImportBatch(ctx, []*ExchangeRate{late}, nil, false)
+	if err != nil {
+		t.Fatalf("ImportBatch() error = %v", err)
+	}
+	if want := (ImportStats{Raw: ImportCounts{Covered: 1}}); stats != want {
+		t.Errorf("ImportBatch() = %+v, want %+v", stats, want)
+	}
+
+	if after := rollupRows(t, db); !reflect.DeepEqual(before, after) {
+		t.Errorf("rollups after import = %v, want them kept as %v", after, before)
+	}
+	daily, err := repo.GetDailyStats(ctx, "USD", "2025-11-24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if daily.SampleCount != 72 || daily.AvgRate != fixed.MustParse("7.1000") {
+		t.Errorf("GetDailyStats() = %+v, want 72 samples averaging 7.1000", daily)
+	}
+	if count, _ := repo.Count(ctx); count != 0 {
+		t.Errorf("Count() = %d, want the quote skipped", count)
+	}
+}
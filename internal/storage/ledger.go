@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+// Conversion is a currency conversion actually made, recorded in the ledger
+type Conversion struct {
+	ID            int64
+	CurrencyCode  string
+	ConvertedAt   time.Time
+	RMBAmount     float64 // RMB side of the conversion, before fees
+	ForeignAmount float64 // Foreign currency side
+	Fees          float64 // RMB
+
+	// What recommend said when the conversion was recorded
+	RecommendedAction     string // Empty: no recommendation on record
+	RecommendedConfidence float64
+	RecommendedRate       fixed.Rate
+
+	Note string
+}
+
+// Rate returns the rate the conversion was quoted at, before fees
+func (c Conversion) Rate() fixed.Rate {
+	return fixed.FromFloat(c.RMBAmount / c.ForeignAmount)
+}
+
+// EffectiveRate returns the rate the conversion achieved net of fees
+func (c Conversion) EffectiveRate() fixed.Rate {
+	return fixed.FromFloat((c.RMBAmount - c.Fees) / c.ForeignAmount)
+}
+
+// LeftOnTable returns the RMB the conversion would have brought on top of
+// what it did had it achieved peak, such as the day's peak rate; 0 when it
+// achieved at least that
+func (c Conversion) LeftOnTable(peak fixed.Rate) float64 {
+	rate := c.EffectiveRate()
+	if rate >= peak {
+		return 0
+	}
+	return (peak - rate).Float64() * c.ForeignAmount
+}
+
+// check validates a conversion about to be stored
+func (c *Conversion) check() error {
+	switch {
+	case c.CurrencyCode == "":
+		return fmt.Errorf("conversion has no currency")
+	case c.ConvertedAt.IsZero():
+		return fmt.Errorf("conversion has no time")
+	case c.RMBAmount <= 0 || c.ForeignAmount <= 0:
+		return fmt.Errorf("conversion amounts must be positive")
+	case c.Fees < 0 || c.Fees >= c.RMBAmount:
+		return fmt.Errorf("conversion fees must be at least 0 and less than the RMB amount")
+	}
+	return nil
+}
+
+// AddConversion records a conversion in the ledger and sets its ID
+func (r *Repository) AddConversion(ctx context.Context, c *Conversion) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	var action sql.NullString
+	var confidence sql.NullFloat64
+	var rate sql.NullInt64
+	if c.RecommendedAction != "" {
+		action = sql.NullString{String: c.RecommendedAction, Valid: true}
+		confidence = sql.NullFloat64{Float64: c.RecommendedConfidence, Valid: true}
+		rate = sql.NullInt64{Int64: int64(c.RecommendedRate), Valid: true}
+	}
+	result, err := r.db.conn.ExecContext(ctx, `
+		INSERT INTO conversions (currency_code, converted_at, rmb_amount, foreign_amount, fees,
+			recommended_action, recommended_confidence, recommended_rate, note)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.CurrencyCode, c.ConvertedAt.UTC(), c.RMBAmount, c.ForeignAmount, c.Fees,
+		action, confidence, rate, c.Note)
+	if err != nil {
+		return fmt.Errorf("inserting conversion: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting insert ID: %w", err)
+	}
+
+	c.ID = id
+	return nil
+}
+
+// GetConversions returns the conversions of a currency (empty: every
+// currency) made from start to end (inclusive), in time order. Conversions
+// belong to no source, so every source-scoped view sees them all
+func (r *Repository) GetConversions(ctx context.Context, currency string, start, end time.Time) ([]Conversion, error) {
+	query := `
+		SELECT id, currency_code, converted_at, rmb_amount, foreign_amount, fees,
+			COALESCE(recommended_action, ''), COALESCE(recommended_confidence, 0), COALESCE(recommended_rate, 0), note
+		FROM conversions
+		WHERE converted_at BETWEEN ? AND ? AND (? = '' OR currency_code = ?)
+		ORDER BY converted_at, id
+	`
+
+	rows, err := r.db.conn.QueryContext(ctx, query, start.UTC(), end.UTC(), currency, currency)
+	if err != nil {
+		return nil, fmt.Errorf("querying conversions: %w", err)
+	}
+	defer rows.Close()
+
+	var conversions []Conversion
+	for rows.Next() {
+		var c Conversion
+		err := rows.Scan(&c.ID, &c.CurrencyCode, &c.ConvertedAt, &c.RMBAmount, &c.ForeignAmount, &c.Fees,
+			&c.RecommendedAction, &c.RecommendedConfidence, &c.RecommendedRate, &c.Note)
+		if err != nil {
+			return nil, fmt.Errorf("scanning conversion: %w", err)
+		}
+		conversions = append(conversions, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating conversions: %w", err)
+	}
+
+	return conversions, nil
+}
+
+// DeleteConversion deletes a conversion from the ledger by ID
+func (r *Repository) DeleteConversion(ctx context.Context, id int64) error {
+	result, err := r.db.conn.ExecContext(ctx, `DELETE FROM conversions WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("deleting conversion: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("getting rows affected: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("no conversion with ID %d", id)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+func TestConversions(t *testing.T) {
+	_, repo := newTestDB(t)
+	for name, ledger := range map[string]Ledger{"Repository": repo, "MemoryStore": NewMemoryStore()} {
+		t.Run(name, func(t *testing.T) { testLedger(t, ledger) })
+	}
+}
+
+func testLedger(t *testing.T, repo Ledger) {
+	ctx := context.Background()
+	at := time.Date(2025, 11, 20, 10, 30, 0, 0, market.Zone)
+
+	conversions := []*Conversion{
+		{CurrencyCode: "USD", ConvertedAt: at, RMBAmount: 70749, ForeignAmount: 10000, Fees: 49,
+			RecommendedAction: "EXCHANGE_NOW", RecommendedConfidence: 72, RecommendedRate: fixed.MustParse("7.0751")},
+		{CurrencyCode: "EUR", ConvertedAt: at.Add(time.Hour), RMBAmount: 8200, ForeignAmount: 1000, Note: "travel"},
+		{CurrencyCode: "USD", ConvertedAt: at.AddDate(0, 0, 2), RMBAmount: 14150, ForeignAmount: 2000},
+	}
+	for _, c := range conversions {
+		if err := repo.AddConversion(ctx, c); err != nil {
+			t.Fatalf("AddConversion() error = %v", err)
+		}
+	}
+
+	for _, c := range []*Conversion{
+		{CurrencyCode: "USD", ConvertedAt: at, RMBAmount: 100, ForeignAmount: 0},
+		{CurrencyCode: "USD", ConvertedAt: at, RMBAmount: 100, ForeignAmount: 10, Fees: 100},
+		{CurrencyCode: "USD", RMBAmount: 100, ForeignAmount: 10},
+	} {
+		if err := repo.AddConversion(ctx, c); err == nil {
+			t.Errorf("AddConversion(%+v) succeeded, want an error", c)
+		}
+	}
+
+	got, err := repo.GetConversions(ctx, "USD", at.Add(-time.Hour), at.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("GetConversions() error = %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("GetConversions(USD) = %d conversions, want 1", len(got))
+	}
+	c := got[0]
+	if c.ID != conversions[0].ID || !c.ConvertedAt.Equal(at) || c.Fees != 49 ||
+		c.RecommendedAction != "EXCHANGE_NOW" || c.RecommendedRate != fixed.MustParse("7.0751") {
+		t.Errorf("GetConversions(USD) = %+v, want %+v", c, *conversions[0])
+	}
+	// Fees are deducted from the RMB the conversion brought
+	if rate, effective := c.Rate(), c.EffectiveRate(); rate != fixed.MustParse("7.0749") || effective != fixed.MustParse("7.07") {
+		t.Errorf("Rate(), EffectiveRate() = %s, %s, want 7.0749, 7.0700", rate, effective)
+	}
+
+	// Converting below the day's peak leaves the difference on the table;
+	// converting at or above it leaves nothing
+	for _, tt := range []struct {
+		peak string
+		want float64
+	}{
+		{"7.0800", 100},
+		{"7.0700", 0},
+		{"7.0600", 0},
+	} {
+		if got := c.LeftOnTable(fixed.MustParse(tt.peak)); math.Abs(got-tt.want) > 1e-6 {
+			t.Errorf("LeftOnTable(%s) = %f, want %f", tt.peak, got, tt.want)
+		}
+	}
+
+	all, err := repo.GetConversions(ctx, "", at.Add(-time.Hour), at.AddDate(0, 0, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[1].RecommendedAction != "" || all[1].Note != "travel" {
+		t.Errorf("GetConversions(all) = %+v, want the 3 conversions in time order", all)
+	}
+
+	if err := repo.DeleteConversion(ctx, conversions[1].ID); err != nil {
+		t.Fatalf("DeleteConversion() error = %v", err)
+	}
+	if err := repo.DeleteConversion(ctx, conversions[1].ID); err == nil {
+		t.Error("DeleteConversion() of a deleted conversion succeeded, want an error")
+	}
+	if all, _ := repo.GetConversions(ctx, "", at.Add(-time.Hour), at.AddDate(0, 0, 3)); len(all) != 2 {
+		t.Errorf("GetConversions() after delete = %d conversions, want 2", len(all))
+	}
+}
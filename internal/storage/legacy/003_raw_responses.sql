@@ -0,0 +1,15 @@
+-- Migration: Archive of raw API response bodies
+-- Lets exchange_rates be rebuilt when extraction changes (see `ratemon reparse`)
+
+CREATE TABLE IF NOT EXISTS raw_responses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,               -- Rate source that served the body (e.g., 'cmb')
+    collected_at TIMESTAMP NOT NULL,    -- Poll time of the first identical response
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD
+    checksum TEXT NOT NULL,             -- SHA-256 of the uncompressed body
+    body BLOB NOT NULL,                 -- gzip-compressed response body
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_raw_date_time
+    ON raw_responses(date_partition, collected_at);
@@ -0,0 +1,765 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// MemoryStore is a Store kept in memory, for tests and for services that
+// embed the poller, alerts or recommender without a SQLite file
+// It answers every query the way Repository does, with two caveats: times
+// are compared as instants (SQLite compares their text, which agrees when
+// all times share a zone), and nothing survives the process
+type MemoryStore struct {
+	data   *memoryData // Shared by every source-scoped view
+	source string      // Only read rows from this source (empty: all sources)
+}
+
+// memoryData holds the rows of a MemoryStore
+type memoryData struct {
+	mu             sync.RWMutex
+	rates          []ExchangeRate // In insertion (ID) order
+	responses      []RawResponse  // In insertion (ID) order
+	polls          []Poll         // In insertion (ID) order
+	annotations    []Annotation   // In insertion (ID) order
+	conversions    []Conversion   // In insertion (ID) order
+	lastRateID     int64
+	lastResponseID int64
+	lastPollID     int64
+	lastNoteID     int64
+	lastConvID     int64
+}
+
+// errInvalidRate mirrors the exchange_rates CHECK constraints
+var errInvalidRate = errors.New("rate must be positive with a 3-letter currency code")
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: &memoryData{}}
+}
+
+// WithSource returns a view of the store whose queries only see rows from a
+// source; writes through any view go to the same store
+func (m *MemoryStore) WithSource(source string) Store {
+	return &MemoryStore{data: m.data, source: source}
+}
+
+// Source returns the source the store is restricted to (empty: all)
+func (m *MemoryStore) Source() string {
+	return m.source
+}
+
+// InsertRate stores an exchange rate reading, updating the stored quote with
+// the same natural key instead if there is one (see Repository.InsertRate)
+func (m *MemoryStore) InsertRate(ctx context.Context, rate *ExchangeRate) error {
+	return m.InsertRates(ctx, []*ExchangeRate{rate})
+}
+
+// InsertRates stores all readings from a single poll, all or none, with the
+// same upsert semantics as InsertRate
+func (m *MemoryStore) InsertRates(ctx context.Context, rates []*ExchangeRate) error {
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	if err := m.data.checkRates(rates); err != nil {
+		return err
+	}
+	m.data.upsertRates(rates)
+	return nil
+}
+
+// checkRates applies the exchange_rates constraints to rows about to be stored
+// Callers must hold d.mu
+func (d *memoryData) checkRates(rates []*ExchangeRate) error {
+	for _, rate := range rates {
+		if err := d.checkRate(rate); err != nil {
+			return fmt.Errorf("inserting %s rate: %w", rate.CurrencyCode, err)
+		}
+	}
+	return nil
+}
+
+// checkRate applies the exchange_rates constraints to a row
+// Callers must hold d.mu
+func (d *memoryData) checkRate(rate *ExchangeRate) error {
+	if rate.RtcBid <= 0 || len(rate.CurrencyCode) != 3 {
+		return errInvalidRate
+	}
+	if rate.ResponseID != 0 && d.response(rate.ResponseID) == nil {
+		return fmt.Errorf("no raw response %d", rate.ResponseID)
+	}
+	return nil
+}
+
+// upsertRates stores rows that passed checkRates, assigning their IDs
+// A row whose natural key is already stored updates the first such row, as
+// upsertRate does
+// Callers must hold d.mu
+func (d *memoryData) upsertRates(rates []*ExchangeRate) {
+	createdAt := time.Now().UTC().Truncate(time.Second)
+	for _, rate := range rates {
+		if rate.Source == "" {
+			rate.Source = DefaultSource
+		}
+
+		if stored := d.findQuote(rate); stored != nil {
+			stored.RtcBid = rate.RtcBid
+			stored.RtbBid = rate.RtbBid
+			stored.RthBid = rate.RthBid
+			stored.RthOfr = rate.RthOfr
+			stored.RtcOfr = rate.RtcOfr
+			if rate.ResponseID != 0 {
+				stored.ResponseID = rate.ResponseID
+			}
+			rate.ID = stored.ID
+			continue
+		}
+
+		d.lastRateID++
+		rate.ID = d.lastRateID
+
+		stored := *rate
+		stored.CollectedAt = rate.CollectedAt.UTC()
+		if !rate.QuotedAt.IsZero() {
+			stored.QuotedAt = rate.QuotedAt.UTC()
+		}
+		stored.CreatedAt = createdAt
+		d.rates = append(d.rates, stored)
+	}
+}
+
+// findQuote returns the first stored row with the rate's natural key:
+// currency, source and observation time
+// Callers must hold d.mu
+func (d *memoryData) findQuote(rate *ExchangeRate) *ExchangeRate {
+	for i := range d.rates {
+		stored := &d.rates[i]
+		if stored.CurrencyCode == rate.CurrencyCode && stored.Source == rate.Source &&
+			stored.ObservedAt().Equal(rate.ObservedAt()) {
+			return stored
+		}
+	}
+	return nil
+}
+
+// GetLatestRate retrieves the most recent exchange rate for a currency
+func (m *MemoryStore) GetLatestRate(ctx context.Context, currency string) (*ExchangeRate, error) {
+	rates := m.ratesOf(currency, "")
+
+	var latest *ExchangeRate
+	for i := range rates {
+		if latest == nil || !rates[i].CollectedAt.Before(latest.CollectedAt) {
+			latest = &rates[i]
+		}
+	}
+	return latest, nil
+}
+
+// GetRatesByTimeRange retrieves rates for a currency within a time range
+func (m *MemoryStore) GetRatesByTimeRange(ctx context.Context, currency string, start, end time.Time) ([]ExchangeRate, error) {
+	var rates []ExchangeRate
+	for _, rate := range m.ratesOf(currency, "") {
+		if !rate.CollectedAt.Before(start) && !rate.CollectedAt.After(end) {
+			rates = append(rates, rate)
+		}
+	}
+
+	sort.SliceStable(rates, func(i, j int) bool {
+		return rates[i].CollectedAt.Before(rates[j].CollectedAt)
+	})
+	return rates, nil
+}
+
+// GetDailyPeak finds the highest rate of a currency for a given date
+func (m *MemoryStore) GetDailyPeak(ctx context.Context, currency, date string) (*ExchangeRate, error) {
+	return peakOf(m.ratesOn(currency, date)), nil
+}
+
+// GetDailyStats calculates aggregate statistics of a currency for a date
+func (m *MemoryStore) GetDailyStats(ctx context.Context, currency, date string) (*DailyStats, error) {
+	rates := m.ratesOn(currency, date)
+	stats := &DailyStats{Date: date, SampleCount: len(rates)}
+	if len(rates) == 0 {
+		return stats, nil
+	}
+
+	stats.MinRate, stats.MaxRate, stats.AvgRate = summarize(rates, cashBid)
+
+	// Each source's peak as its daily rollup records it, then the earliest
+	bySource := make(map[string][]ExchangeRate)
+	for _, rate := range rates {
+		bySource[rate.Source] = append(bySource[rate.Source], rate)
+	}
+	for _, group := range bySource {
+		peak := peakOf(group)
+		if peak.RtcBid == stats.MaxRate && (stats.PeakTime.IsZero() || peak.ObservedAt().Before(stats.PeakTime)) {
+			stats.PeakTime = peak.ObservedAt()
+		}
+	}
+	return stats, nil
+}
+
+// GetSeries returns a currency's rates from start to end at a resolution
+// (see Repository.GetSeries). Nothing is deleted from memory, so every date
+// is served at the resolution asked for, rolled up from the raw quotes
+func (m *MemoryStore) GetSeries(ctx context.Context, currency string, start, end time.Time, resolution Tier) ([]SeriesPoint, error) {
+	first, last := market.Date(start), market.Date(end)
+
+	var points []SeriesPoint
+	rollups := make(map[rollupKey]int) // Index in points
+	for _, rate := range m.ratesOf(currency, first) {
+		if rate.DatePartition > last {
+			continue
+		}
+		p := rawPoint(rate)
+		if resolution == TierRaw {
+			points = append(points, p)
+			continue
+		}
+
+		key := rateRollupKey(&rate)
+		if resolution == TierDaily {
+			key.hour = 0
+		}
+		i, ok := rollups[key]
+		if !ok {
+			p.Tier = resolution
+			t, err := rollupTime(key.date, key.hour)
+			if err != nil {
+				return nil, err
+			}
+			p.Time = t
+			rollups[key] = len(points)
+			points = append(points, p)
+			continue
+		}
+
+		r := &points[i]
+		r.SumRate += p.SumRate
+		r.SampleCount++
+		r.AvgRate = fixed.MeanOf(r.SumRate, r.SampleCount)
+		r.MinRate = min(r.MinRate, p.MinRate)
+		r.MaxRate = max(r.MaxRate, p.MaxRate)
+	}
+
+	return stitch(points, start, end, resolution), nil
+}
+
+// Count returns the total number of exchange rate records of every source
+func (m *MemoryStore) Count(ctx context.Context) (int64, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+	return int64(len(m.data.rates)), nil
+}
+
+// GetHourlyPatterns analyzes a currency's rate patterns by hour of day over the last N days
+func (m *MemoryStore) GetHourlyPatterns(ctx context.Context, currency string, days int) ([]HourlyPattern, error) {
+	rates := m.ratesOf(currency, cutoffDate(days))
+
+	byHour := make(map[int][]ExchangeRate)
+	byDate := make(map[string][]ExchangeRate)
+	for _, rate := range rates {
+		hour := observedHour(rate)
+		byHour[hour] = append(byHour[hour], rate)
+		byDate[rate.DatePartition] = append(byDate[rate.DatePartition], rate)
+	}
+
+	// The hours each day peaked in; a tie counts for every hour it occurred in
+	peakHours := make(map[int]int)
+	for _, day := range byDate {
+		_, peak, _ := summarize(day, cashBid)
+		hours := make(map[int]bool)
+		for _, rate := range day {
+			if rate.RtcBid == peak {
+				hours[observedHour(rate)] = true
+			}
+		}
+		for hour := range hours {
+			peakHours[hour]++
+		}
+	}
+
+	var patterns []HourlyPattern
+	for hour := 0; hour < 24; hour++ {
+		group, ok := byHour[hour]
+		if !ok {
+			continue
+		}
+		p := HourlyPattern{Hour: hour, SampleCount: len(group), PeakFreq: peakHours[hour]}
+		p.MinRate, p.MaxRate, p.AvgRate = summarize(group, cashBid)
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// GetDayOfWeekPatterns analyzes a currency's rate patterns by day of week
+// Each market date counts towards its own weekday
+func (m *MemoryStore) GetDayOfWeekPatterns(ctx context.Context, currency string, weeks int) ([]DayOfWeekPattern, error) {
+	byDate := make(map[string][]ExchangeRate)
+	for _, rate := range m.ratesOf(currency, cutoffDate(weeks*7)) {
+		byDate[rate.DatePartition] = append(byDate[rate.DatePartition], rate)
+	}
+
+	type dailyData struct {
+		avg, min, max, spread fixed.Rate
+	}
+	byDay := make(map[int][]dailyData)
+	for _, day := range byDate {
+		var d dailyData
+		d.min, d.max, d.avg = summarize(day, cashBid)
+		d.spread = d.max - d.min
+
+		date, err := time.Parse("2006-01-02", day[0].DatePartition)
+		if err != nil {
+			continue
+		}
+		dow := int(date.Weekday())
+		byDay[dow] = append(byDay[dow], d)
+	}
+
+	var patterns []DayOfWeekPattern
+	for dow := range dayNames {
+		days, ok := byDay[dow]
+		if !ok {
+			continue
+		}
+
+		p := DayOfWeekPattern{DayOfWeek: dow, DayName: dayNames[dow], SampleDays: len(days)}
+		avgs := make([]fixed.Rate, 0, len(days))
+		spreads := make([]fixed.Rate, 0, len(days))
+		for i, d := range days {
+			avgs = append(avgs, d.avg)
+			spreads = append(spreads, d.spread)
+			if i == 0 || d.min < p.MinRate {
+				p.MinRate = d.min
+			}
+			if i == 0 || d.max > p.MaxRate {
+				p.MaxRate = d.max
+			}
+		}
+		p.AvgRate = fixed.Mean(avgs)
+		p.AvgRange = fixed.Mean(spreads)
+		patterns = append(patterns, p)
+	}
+	return patterns, nil
+}
+
+// GetDailySpreads summarizes a currency's spreads per day over the last N days
+func (m *MemoryStore) GetDailySpreads(ctx context.Context, currency string, days int) ([]SpreadStats, error) {
+	return m.spreads(currency, days, func(r ExchangeRate) string {
+		return r.DatePartition
+	}, func(key string) string {
+		return key
+	})
+}
+
+// GetHourlySpreads summarizes a currency's spreads by hour of day over the last N days
+func (m *MemoryStore) GetHourlySpreads(ctx context.Context, currency string, days int) ([]SpreadStats, error) {
+	return m.spreads(currency, days, func(r ExchangeRate) string {
+		return fmt.Sprintf("%02d", observedHour(r))
+	}, func(key string) string {
+		return key + ":00"
+	})
+}
+
+// GetDayOfWeekSpreads summarizes a currency's spreads by day of week over the last N weeks
+func (m *MemoryStore) GetDayOfWeekSpreads(ctx context.Context, currency string, weeks int) ([]SpreadStats, error) {
+	return m.spreads(currency, weeks*7, func(r ExchangeRate) string {
+		return strconv.Itoa(observedWeekday(r))
+	}, func(key string) string {
+		dow, _ := strconv.Atoi(key)
+		return dayNames[dow]
+	})
+}
+
+// spreads groups spreads by the key of each rate, ordering groups by key;
+// rows without all four price sides are skipped
+func (m *MemoryStore) spreads(currency string, days int, key func(ExchangeRate) string, label func(string) string) ([]SpreadStats, error) {
+	groups := make(map[string][]ExchangeRate)
+	for _, rate := range m.ratesOf(currency, cutoffDate(days)) {
+		if rate.RthBid == 0 || rate.RthOfr == 0 || rate.RtcOfr == 0 {
+			continue
+		}
+		k := key(rate)
+		groups[k] = append(groups[k], rate)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var spreads []SpreadStats
+	for _, k := range keys {
+		group := groups[k]
+		s := SpreadStats{Label: label(k), SampleCount: len(group)}
+		s.MinSpotSpread, s.MaxSpotSpread, s.AvgSpotSpread = summarize(group, func(r ExchangeRate) fixed.Rate {
+			return r.RthOfr - r.RthBid
+		})
+		s.MinCashSpread, s.MaxCashSpread, s.AvgCashSpread = summarize(group, func(r ExchangeRate) fixed.Rate {
+			return r.RtcOfr - r.RtcBid
+		})
+		_, _, s.AvgCashPremium = summarize(group, func(r ExchangeRate) fixed.Rate {
+			return (r.RtcOfr - r.RtcBid) - (r.RthOfr - r.RthBid)
+		})
+		spreads = append(spreads, s)
+	}
+	return spreads, nil
+}
+
+// RecordPoll logs a successful poll, whether or not it stored quotes
+func (m *MemoryStore) RecordPoll(ctx context.Context, poll *Poll) error {
+	if poll.Source == "" {
+		poll.Source = DefaultSource
+	}
+
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	m.data.lastPollID++
+	poll.ID = m.data.lastPollID
+	stored := *poll
+	stored.PolledAt = poll.PolledAt.UTC()
+	m.data.polls = append(m.data.polls, stored)
+	return nil
+}
+
+// GetPollTimes returns the distinct times between start and end (inclusive)
+// at which a source was polled or had a rate stored, in order
+func (m *MemoryStore) GetPollTimes(ctx context.Context, start, end time.Time) ([]time.Time, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	var times []time.Time
+	add := func(source string, t time.Time) {
+		if m.sees(source) && !t.Before(start) && !t.After(end) {
+			times = append(times, t)
+		}
+	}
+	for _, poll := range m.data.polls {
+		add(poll.Source, poll.PolledAt)
+	}
+	for _, rate := range m.data.rates {
+		add(rate.Source, rate.CollectedAt)
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	distinct := times[:0]
+	for _, t := range times {
+		if n := len(distinct); n == 0 || !distinct[n-1].Equal(t) {
+			distinct = append(distinct, t)
+		}
+	}
+	return distinct, nil
+}
+
+// AddAnnotation stores an annotation and sets its ID
+func (m *MemoryStore) AddAnnotation(ctx context.Context, a *Annotation) error {
+	if err := a.check(); err != nil {
+		return err
+	}
+
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	m.data.lastNoteID++
+	a.ID = m.data.lastNoteID
+	stored := *a
+	stored.StartsAt = a.StartsAt.UTC()
+	if !a.EndsAt.IsZero() {
+		stored.EndsAt = a.EndsAt.UTC()
+	}
+	m.data.annotations = append(m.data.annotations, stored)
+	return nil
+}
+
+// GetAnnotations returns the annotations of a currency (empty: every
+// currency), including those of every currency, that overlap start to end,
+// in time order
+func (m *MemoryStore) GetAnnotations(ctx context.Context, currency string, start, end time.Time) ([]Annotation, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	var annotations []Annotation
+	for _, a := range m.data.annotations {
+		if a.appliesTo(currency) && a.Overlaps(start, end) {
+			annotations = append(annotations, a)
+		}
+	}
+	sort.SliceStable(annotations, func(i, j int) bool {
+		return annotations[i].StartsAt.Before(annotations[j].StartsAt)
+	})
+	return annotations, nil
+}
+
+// DeleteAnnotation deletes an annotation by ID
+func (m *MemoryStore) DeleteAnnotation(ctx context.Context, id int64) error {
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	for i, a := range m.data.annotations {
+		if a.ID == id {
+			m.data.annotations = append(m.data.annotations[:i], m.data.annotations[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no annotation with ID %d", id)
+}
+
+// AddConversion records a conversion in the ledger and sets its ID
+func (m *MemoryStore) AddConversion(ctx context.Context, c *Conversion) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	m.data.lastConvID++
+	c.ID = m.data.lastConvID
+	stored := *c
+	stored.ConvertedAt = c.ConvertedAt.UTC()
+	if stored.RecommendedAction == "" {
+		stored.RecommendedConfidence, stored.RecommendedRate = 0, 0
+	}
+	m.data.conversions = append(m.data.conversions, stored)
+	return nil
+}
+
+// GetConversions returns the conversions of a currency (empty: every
+// currency) made from start to end (inclusive), in time order
+func (m *MemoryStore) GetConversions(ctx context.Context, currency string, start, end time.Time) ([]Conversion, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	var conversions []Conversion
+	for _, c := range m.data.conversions {
+		if (currency == "" || c.CurrencyCode == currency) && !c.ConvertedAt.Before(start) && !c.ConvertedAt.After(end) {
+			conversions = append(conversions, c)
+		}
+	}
+	sort.SliceStable(conversions, func(i, j int) bool {
+		return conversions[i].ConvertedAt.Before(conversions[j].ConvertedAt)
+	})
+	return conversions, nil
+}
+
+// DeleteConversion deletes a conversion from the ledger by ID
+func (m *MemoryStore) DeleteConversion(ctx context.Context, id int64) error {
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	for i, c := range m.data.conversions {
+		if c.ID == id {
+			m.data.conversions = append(m.data.conversions[:i], m.data.conversions[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("no conversion with ID %d", id)
+}
+
+// InsertRawResponse archives a raw response body
+func (m *MemoryStore) InsertRawResponse(ctx context.Context, resp *RawResponse) error {
+	if resp.Checksum == "" {
+		resp.Checksum = Checksum(resp.Body)
+	}
+
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	m.data.lastResponseID++
+	resp.ID = m.data.lastResponseID
+
+	stored := *resp
+	stored.CollectedAt = resp.CollectedAt.UTC()
+	stored.Body = append([]byte(nil), resp.Body...)
+	m.data.responses = append(m.data.responses, stored)
+	return nil
+}
+
+// GetArchiveDates lists the dates with archived responses between start and
+// end (YYYY-MM-DD, inclusive; empty for no bound)
+func (m *MemoryStore) GetArchiveDates(ctx context.Context, start, end string) ([]string, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	var dates []string
+	for _, resp := range m.data.responses {
+		date := resp.DatePartition
+		if !m.sees(resp.Source) || seen[date] ||
+			(start != "" && date < start) || (end != "" && date > end) {
+			continue
+		}
+		seen[date] = true
+		dates = append(dates, date)
+	}
+
+	sort.Strings(dates)
+	return dates, nil
+}
+
+// GetRawResponsesForDate retrieves the responses archived on a date, in
+// collection order
+func (m *MemoryStore) GetRawResponsesForDate(ctx context.Context, date string) ([]RawResponse, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	var responses []RawResponse
+	for _, resp := range m.data.responses {
+		if resp.DatePartition == date && m.sees(resp.Source) {
+			resp.Body = append([]byte(nil), resp.Body...)
+			responses = append(responses, resp)
+		}
+	}
+
+	sort.SliceStable(responses, func(i, j int) bool {
+		return responses[i].CollectedAt.Before(responses[j].CollectedAt)
+	})
+	return responses, nil
+}
+
+// CountArchivedRates counts the exchange rates linked to responses archived on a date
+func (m *MemoryStore) CountArchivedRates(ctx context.Context, date string) (int64, error) {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	var count int64
+	for _, rate := range m.data.rates {
+		if resp := m.data.response(rate.ResponseID); resp != nil && resp.DatePartition == date && m.sees(resp.Source) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// ReplaceArchivedRates deletes the exchange rates linked to the given raw
+// responses and stores rates in their place, all or none
+// Returns the number of rows deleted
+func (m *MemoryStore) ReplaceArchivedRates(ctx context.Context, responseIDs []int64, rates []*ExchangeRate) (int64, error) {
+	m.data.mu.Lock()
+	defer m.data.mu.Unlock()
+
+	if err := m.data.checkRates(rates); err != nil {
+		return 0, err
+	}
+
+	replaced := make(map[int64]bool, len(responseIDs))
+	for _, id := range responseIDs {
+		replaced[id] = true
+	}
+
+	kept := m.data.rates[:0]
+	var deleted int64
+	for _, rate := range m.data.rates {
+		if rate.ResponseID != 0 && replaced[rate.ResponseID] {
+			deleted++
+			continue
+		}
+		kept = append(kept, rate)
+	}
+	m.data.rates = kept
+
+	m.data.upsertRates(rates)
+	return deleted, nil
+}
+
+// ratesOf returns copies of the currency's rates visible to the store, on or
+// after since (YYYY-MM-DD; empty for all dates), in ID order
+func (m *MemoryStore) ratesOf(currency, since string) []ExchangeRate {
+	m.data.mu.RLock()
+	defer m.data.mu.RUnlock()
+
+	var rates []ExchangeRate
+	for _, rate := range m.data.rates {
+		if rate.CurrencyCode == currency && m.sees(rate.Source) && rate.DatePartition >= since {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
+
+// ratesOn returns copies of the currency's rates visible to the store on a date
+func (m *MemoryStore) ratesOn(currency, date string) []ExchangeRate {
+	var rates []ExchangeRate
+	for _, rate := range m.ratesOf(currency, date) {
+		if rate.DatePartition == date {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
+
+// sees reports whether rows of a source are visible to the store
+func (m *MemoryStore) sees(source string) bool {
+	return m.source == "" || source == m.source
+}
+
+// response returns the archived response with an ID, or nil
+// Callers must hold d.mu
+func (d *memoryData) response(id int64) *RawResponse {
+	for i := range d.responses {
+		if d.responses[i].ID == id {
+			return &d.responses[i]
+		}
+	}
+	return nil
+}
+
+// peakOf returns the highest rate, the earliest collected on a tie (nil if none)
+func peakOf(rates []ExchangeRate) *ExchangeRate {
+	var peak *ExchangeRate
+	for i := range rates {
+		if peak == nil || rates[i].RtcBid > peak.RtcBid ||
+			(rates[i].RtcBid == peak.RtcBid && rates[i].CollectedAt.Before(peak.CollectedAt)) {
+			peak = &rates[i]
+		}
+	}
+	return peak
+}
+
+// summarize returns the minimum, maximum and rounded mean of a price of rates
+func summarize(rates []ExchangeRate, price func(ExchangeRate) fixed.Rate) (lo, hi, avg fixed.Rate) {
+	prices := make([]fixed.Rate, 0, len(rates))
+	for i, rate := range rates {
+		p := price(rate)
+		if i == 0 || p < lo {
+			lo = p
+		}
+		if i == 0 || p > hi {
+			hi = p
+		}
+		prices = append(prices, p)
+	}
+	return lo, hi, fixed.Mean(prices)
+}
+
+// cashBid is the tracked price of a rate
+func cashBid(r ExchangeRate) fixed.Rate {
+	return r.RtcBid
+}
+
+// observedHour is the market hour a quote was observed, as
+// strftime('%H', marketTime(...)) computes it
+func observedHour(rate ExchangeRate) int {
+	return rate.ObservedAt().In(market.Zone).Hour()
+}
+
+// observedWeekday is the market weekday a quote was observed
+func observedWeekday(rate ExchangeRate) int {
+	return int(rate.ObservedAt().In(market.Zone).Weekday())
+}
+
+// cutoffDate is marketDaysAgo
+func cutoffDate(days int) string {
+	return market.DaysAgo(days)
+}
@@ -0,0 +1,315 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// seedStore fills a store with two sources and two currencies over the last
+// ten days, some quotes archived and some carrying every price side
+func seedStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	for day := 1; day <= 10; day++ {
+		date := today.AddDate(0, 0, -day)
+		for _, hour := range []int{1, 3, 9, 14, 23} {
+			collected := date.Add(time.Duration(hour)*time.Hour + 30*time.Second)
+
+			var responseID int64
+			if day <= 2 {
+				resp := &RawResponse{
+					Source:        "cmb",
+					CollectedAt:   collected,
+					DatePartition: market.Date(collected),
+					Body:          []byte(fmt.Sprintf(`{"day":%d,"hour":%d}`, day, hour)),
+				}
+				if err := store.InsertRawResponse(ctx, resp); err != nil {
+					t.Fatal(err)
+				}
+				responseID = resp.ID
+			}
+
+			// A different hour peaks each day
+			bid := fixed.MustParse("7.0500") + fixed.Rate((hour*5+day*3)%24)*100
+			var rates []*ExchangeRate
+			for _, source := range []string{"cmb", "boc"} {
+				for _, currency := range []string{"USD", "EUR"} {
+					rate := &ExchangeRate{
+						CurrencyCode:  currency,
+						Source:        source,
+						RtcBid:        bid,
+						CollectedAt:   collected,
+						DatePartition: market.Date(collected),
+					}
+					if source == "cmb" {
+						rate.ResponseID = responseID
+						rate.QuotedAt = collected.Add(-20 * time.Second)
+					}
+					if day%2 == 0 {
+						rate.RthBid = bid + 300
+						rate.RthOfr = bid + 600 + fixed.Rate(hour)
+						rate.RtcOfr = bid + 900 + fixed.Rate(day)
+					}
+					if source == "boc" {
+						rate.RtcBid += 50
+					}
+					rates = append(rates, rate)
+				}
+			}
+			if err := store.InsertRates(ctx, rates); err != nil {
+				t.Fatal(err)
+			}
+
+			// The daemon logs cmb polls, including later ones that stored nothing
+			if day <= 5 {
+				for _, at := range []time.Time{collected, collected.Add(30 * time.Minute)} {
+					poll := &Poll{Source: "cmb", PolledAt: at, DatePartition: market.Date(collected), Quotes: 2}
+					if at.Equal(collected) {
+						poll.Stored = 2
+					}
+					if err := store.RecordPoll(ctx, poll); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+		}
+	}
+
+	// Notes on one currency and on all of them, at a point and over a range
+	annotations := []*Annotation{
+		{CurrencyCode: "USD", StartsAt: today.AddDate(0, 0, -5).Add(2 * time.Hour), Note: "fixing shock"},
+		{StartsAt: today.AddDate(0, 0, -8), EndsAt: today.AddDate(0, 0, -5), Note: "daemon down"},
+		{CurrencyCode: "EUR", StartsAt: today.AddDate(0, 0, -4).Add(9 * time.Hour), Note: "converted"},
+		{CurrencyCode: "USD", StartsAt: today.AddDate(0, 0, -9), Note: "too early"},
+	}
+	for _, a := range annotations {
+		if err := store.AddAnnotation(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// normalize drops what legitimately differs between stores: creation times
+// and the zone times are returned in
+func normalize(v any) any {
+	switch v := v.(type) {
+	case *ExchangeRate:
+		if v == nil {
+			return v
+		}
+		r := *v
+		r.CreatedAt = time.Time{}
+		r.CollectedAt = r.CollectedAt.UTC()
+		if !r.QuotedAt.IsZero() {
+			r.QuotedAt = r.QuotedAt.UTC()
+		}
+		return r
+	case []ExchangeRate:
+		out := make([]any, len(v))
+		for i := range v {
+			out[i] = normalize(&v[i])
+		}
+		return out
+	case *DailyStats:
+		s := *v
+		s.PeakTime = s.PeakTime.UTC()
+		return s
+	case []SeriesPoint:
+		out := make([]SeriesPoint, len(v))
+		for i, p := range v {
+			p.Time = p.Time.UTC()
+			out[i] = p
+		}
+		return out
+	case []time.Time:
+		out := make([]time.Time, len(v))
+		for i, t := range v {
+			out[i] = t.UTC()
+		}
+		return out
+	case []Annotation:
+		out := make([]Annotation, len(v))
+		for i, a := range v {
+			a.StartsAt = a.StartsAt.UTC()
+			if !a.EndsAt.IsZero() {
+				a.EndsAt = a.EndsAt.UTC()
+			}
+			out[i] = a
+		}
+		return out
+	case []RawResponse:
+		out := make([]RawResponse, len(v))
+		for i, resp := range v {
+			resp.CollectedAt = resp.CollectedAt.UTC()
+			out[i] = resp
+		}
+		return out
+	}
+	return v
+}
+
+func TestMemoryStoreMatchesRepository(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestDB(t)
+	mem := NewMemoryStore()
+	seedStore(t, repo)
+	seedStore(t, mem)
+
+	date := time.Now().UTC().AddDate(0, 0, -4).Format("2006-01-02")
+	archived := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+	start := time.Now().UTC().AddDate(0, 0, -6)
+	end := time.Now().UTC().AddDate(0, 0, -3)
+
+	queries := []struct {
+		name string
+		run  func(Store) (any, error)
+	}{
+		{"GetLatestRate", func(s Store) (any, error) { return s.GetLatestRate(ctx, "USD") }},
+		{"GetLatestRate/missing", func(s Store) (any, error) { return s.GetLatestRate(ctx, "JPY") }},
+		{"GetRatesByTimeRange", func(s Store) (any, error) { return s.GetRatesByTimeRange(ctx, "EUR", start, end) }},
+		{"GetDailyPeak", func(s Store) (any, error) { return s.GetDailyPeak(ctx, "USD", date) }},
+		{"GetDailyStats", func(s Store) (any, error) { return s.GetDailyStats(ctx, "USD", date) }},
+		{"GetDailyStats/empty", func(s Store) (any, error) { return s.GetDailyStats(ctx, "USD", "2001-01-01") }},
+		{"GetSeries/raw", func(s Store) (any, error) { return s.GetSeries(ctx, "USD", start, end, TierRaw) }},
+		{"GetSeries/hourly", func(s Store) (any, error) { return s.GetSeries(ctx, "EUR", start, end, TierHourly) }},
+		{"GetSeries/daily", func(s Store) (any, error) { return s.GetSeries(ctx, "USD", start, end, TierDaily) }},
+		{"GetHourlyPatterns", func(s Store) (any, error) { return s.GetHourlyPatterns(ctx, "USD", 7) }},
+		{"GetDayOfWeekPatterns", func(s Store) (any, error) { return s.GetDayOfWeekPatterns(ctx, "USD", 1) }},
+		{"GetDailySpreads", func(s Store) (any, error) { return s.GetDailySpreads(ctx, "USD", 30) }},
+		{"GetHourlySpreads", func(s Store) (any, error) { return s.GetHourlySpreads(ctx, "EUR", 30) }},
+		{"GetDayOfWeekSpreads", func(s Store) (any, error) { return s.GetDayOfWeekSpreads(ctx, "USD", 4) }},
+		{"Count", func(s Store) (any, error) { return s.Count(ctx) }},
+		{"GetPollTimes", func(s Store) (any, error) { return s.GetPollTimes(ctx, start, end) }},
+		{"GetAnnotations", func(s Store) (any, error) { return s.GetAnnotations(ctx, "USD", start, end) }},
+		{"GetAnnotations/all", func(s Store) (any, error) { return s.GetAnnotations(ctx, "", start, end) }},
+		{"GetArchiveDates", func(s Store) (any, error) { return s.GetArchiveDates(ctx, "", archived) }},
+		{"GetRawResponsesForDate", func(s Store) (any, error) { return s.GetRawResponsesForDate(ctx, archived) }},
+		{"CountArchivedRates", func(s Store) (any, error) { return s.CountArchivedRates(ctx, archived) }},
+	}
+
+	for _, source := range []string{"", "cmb", "boc"} {
+		for _, q := range queries {
+			t.Run(q.name+"/"+source, func(t *testing.T) {
+				want, err := q.run(repo.WithSource(source))
+				if err != nil {
+					t.Fatalf("Repository: %v", err)
+				}
+				got, err := q.run(mem.WithSource(source))
+				if err != nil {
+					t.Fatalf("MemoryStore: %v", err)
+				}
+				if !reflect.DeepEqual(normalize(got), normalize(want)) {
+					t.Errorf("MemoryStore returned\n%+v\nRepository returned\n%+v", normalize(got), normalize(want))
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryStoreWrites(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestDB(t)
+	mem := NewMemoryStore()
+	seedStore(t, repo)
+	seedStore(t, mem)
+
+	for name, store := range map[string]Store{"Repository": repo, "MemoryStore": mem} {
+		t.Run(name, func(t *testing.T) {
+			at := time.Now().UTC()
+			bad := []*ExchangeRate{
+				{CurrencyCode: "USD", RtcBid: 0, CollectedAt: at, DatePartition: at.Format("2006-01-02")},
+				{CurrencyCode: "USDX", RtcBid: 1, CollectedAt: at, DatePartition: at.Format("2006-01-02")},
+				{CurrencyCode: "USD", RtcBid: 1, ResponseID: 9999, CollectedAt: at, DatePartition: at.Format("2006-01-02")},
+			}
+			for _, rate := range bad {
+				if err := store.InsertRate(ctx, rate); err == nil {
+					t.Errorf("InsertRate(%+v) succeeded", rate)
+				}
+			}
+
+			// A failed batch stores nothing
+			before, _ := store.Count(ctx)
+			good := &ExchangeRate{CurrencyCode: "USD", RtcBid: 1, CollectedAt: at, DatePartition: at.Format("2006-01-02")}
+			if err := store.InsertRates(ctx, []*ExchangeRate{good, bad[0]}); err == nil {
+				t.Error("InsertRates() accepted an invalid rate")
+			}
+			if after, _ := store.Count(ctx); after != before {
+				t.Errorf("failed batch stored %d rows", after-before)
+			}
+
+			// Storing a quote again updates the stored row
+			if err := store.InsertRate(ctx, good); err != nil {
+				t.Fatal(err)
+			}
+			again := *good
+			again.ID = 0
+			again.RtcBid = 2
+			if err := store.InsertRates(ctx, []*ExchangeRate{&again}); err != nil {
+				t.Fatal(err)
+			}
+			if again.ID != good.ID {
+				t.Errorf("repeated quote got ID %d, want %d", again.ID, good.ID)
+			}
+			if after, _ := store.Count(ctx); after != before+1 {
+				t.Errorf("storing a quote twice added %d rows, want 1", after-before)
+			}
+			if latest, _ := store.WithSource("").GetLatestRate(ctx, "USD"); latest.ID != good.ID || latest.RtcBid != 2 {
+				t.Errorf("latest rate = %+v, want rate %d with the repeated price", latest, good.ID)
+			}
+
+			date := time.Now().UTC().AddDate(0, 0, -1).Format("2006-01-02")
+			responses, err := store.GetRawResponsesForDate(ctx, date)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []int64{responses[0].ID, responses[1].ID}
+			rebuilt := &ExchangeRate{
+				CurrencyCode:  "USD",
+				RtcBid:        fixed.MustParse("7.1000"),
+				ResponseID:    ids[0],
+				CollectedAt:   responses[0].CollectedAt,
+				DatePartition: date,
+			}
+			deleted, err := store.ReplaceArchivedRates(ctx, ids, []*ExchangeRate{rebuilt})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != 4 {
+				t.Errorf("ReplaceArchivedRates() deleted %d rows, want 4", deleted)
+			}
+			if rebuilt.ID == 0 || rebuilt.Source != DefaultSource {
+				t.Errorf("rebuilt rate = %+v, want an ID and the default source", rebuilt)
+			}
+			if n, _ := store.CountArchivedRates(ctx, date); n != 7 {
+				t.Errorf("CountArchivedRates() = %d, want 7", n)
+			}
+
+			// Annotations need a note and end after they start
+			note := &Annotation{StartsAt: at, EndsAt: at.Add(-time.Hour), Note: "backwards"}
+			if err := store.AddAnnotation(ctx, note); err == nil {
+				t.Error("AddAnnotation() accepted a range ending before it starts")
+			}
+			note.EndsAt = at.Add(time.Hour)
+			if err := store.AddAnnotation(ctx, note); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.DeleteAnnotation(ctx, note.ID); err != nil {
+				t.Fatalf("DeleteAnnotation() error = %v", err)
+			}
+			if err := store.DeleteAnnotation(ctx, note.ID); err == nil {
+				t.Error("DeleteAnnotation() of a deleted annotation succeeded")
+			}
+			if notes, _ := store.GetAnnotations(ctx, "", at, at); len(notes) != 0 {
+				t.Errorf("GetAnnotations() after delete = %+v, want none", notes)
+			}
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// migrationFiles holds the versioned migrations, named NNN_name.up.sql with an
+// optional NNN_name.down.sql that reverts it
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// legacyFiles holds the unversioned schema files databases were created from
+// before schema_migrations existed
+//
+//go:embed legacy/*.sql
+var legacyFiles embed.FS
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// ledgerSchema records which migrations a database has applied
+const ledgerSchema = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,        -- SHA-256 of the up migration as applied
+		applied_at TIMESTAMP NOT NULL
+	)`
+
+// Migration is a versioned schema change embedded in the binary
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // Empty if the migration cannot be reverted
+	Checksum string // SHA-256 of Up
+}
+
+// String returns the migration's file name stem (e.g., "001_initial_schema")
+func (m Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus is a migration and its state in the database
+type MigrationStatus struct {
+	Migration
+	AppliedAt time.Time // Zero if pending
+	Modified  bool      // Applied with a checksum other than the embedded file's
+	Unknown   bool      // Applied by a newer build; not embedded in this one
+}
+
+// Applied reports whether the database has applied the migration
+func (s MigrationStatus) Applied() bool {
+	return !s.AppliedAt.IsZero()
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// Migrations returns the embedded migrations in version order
+func Migrations() ([]Migration, error) {
+	files, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading embedded migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, file := range files {
+		match := migrationName.FindStringSubmatch(file.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be NNN_name.up.sql or NNN_name.down.sql", file.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+
+		content, err := migrationFiles.ReadFile(path.Join("migrations", file.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading migration %s: %w", file.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %s: version %d is already named %q", file.Name(), version, m.Name)
+		}
+		if match[3] == "up" {
+			m.Up = string(content)
+			sum := sha256.Sum256(content)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %s has no up file", m)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// MigrationStatus lists every embedded migration, and any applied by a newer
+// build, with its state in the database; it does not modify the database
+func (db *DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := db.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if a, ok := applied[m.Version]; ok {
+			s.AppliedAt = a.appliedAt
+			s.Modified = a.checksum != m.Checksum
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, s)
+	}
+	for version, a := range applied {
+		statuses = append(statuses, MigrationStatus{
+			Migration: Migration{Version: version, Name: a.name, Checksum: a.checksum},
+			AppliedAt: a.appliedAt,
+			Unknown:   true,
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Version < statuses[j].Version
+	})
+	return statuses, nil
+}
+
+// MigrateUp applies every pending migration in order, each in its own
+// transaction, and returns the ones it applied
+// A database created before versioned migrations is first upgraded the old
+// way and recorded as being at the baseline
+func (db *DB) MigrateUp(ctx context.Context) ([]Migration, error) {
+	if _, err := db.conn.ExecContext(ctx, ledgerSchema); err != nil {
+		return nil, fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatuses(statuses); err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, s := range statuses {
+		if !s.Applied() {
+			pending = append(pending, s.Migration)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	if len(pending) == len(statuses) {
+		legacy, err := db.hasTable(ctx, "exchange_rates")
+		if err != nil {
+			return nil, err
+		}
+		if legacy {
+			if err := db.adoptLegacy(ctx, pending[0]); err != nil {
+				return nil, fmt.Errorf("adopting unversioned database: %w", err)
+			}
+			pending = pending[1:]
+		}
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		ok, err := db.applyMigration(ctx, m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", m, err)
+		}
+		if ok {
+			db.logger.Info("applied migration", "migration", m.String())
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// MigrateDown reverts the most recently applied migration and returns it
+func (db *DB) MigrateDown(ctx context.Context) (*Migration, error) {
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatuses(statuses); err != nil {
+		return nil, err
+	}
+
+	var latest *Migration
+	for i := range statuses {
+		if statuses[i].Applied() {
+			latest = &statuses[i].Migration
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("no migrations applied")
+	}
+	if latest.Down == "" {
+		return nil, fmt.Errorf("migration %s cannot be reverted", latest)
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := execMigration(ctx, tx, latest.Down); err != nil {
+		return nil, fmt.Errorf("reverting migration %s: %w", latest, err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", latest.Version); err != nil {
+		return nil, fmt.Errorf("updating schema_migrations: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing: %w", err)
+	}
+
+	db.logger.Info("reverted migration", "migration", latest.String())
+	return latest, nil
+}
+
+// checkStatuses refuses to migrate a database whose history disagrees with
+// the embedded migrations
+func checkStatuses(statuses []MigrationStatus) error {
+	for _, s := range statuses {
+		if s.Unknown {
+			return fmt.Errorf("database has migration %s applied, which this build does not know; upgrade ratemon", s.Migration)
+		}
+		if s.Modified {
+			return fmt.Errorf("migration %s was modified after it was applied (checksum mismatch)", s.Migration)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs a migration and records it in one transaction
+// It reports false if another process applied the migration first
+func (db *DB) applyMigration(ctx context.Context, m Migration) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Recording the migration first takes the write lock before anything else
+	// is read, so a concurrent process either waits or has already applied it
+	ok, err := recordMigration(ctx, tx, m)
+	if err != nil || !ok {
+		return false, err
+	}
+	if err := execMigration(ctx, tx, m.Up); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("committing: %w", err)
+	}
+	return true, nil
+}
+
+// adoptLegacy brings a database created from the unversioned schema files up
+// to the baseline migration and records the baseline as applied
+func (db *DB) adoptLegacy(ctx context.Context, baseline Migration) error {
+	files, err := fs.ReadDir(legacyFiles, "legacy")
+	if err != nil {
+		return fmt.Errorf("reading legacy schema: %w", err)
+	}
+
+	// The legacy files are re-runnable; they fill in any tables the database
+	// predates so that every upgrade has something to work on
+	for _, file := range files {
+		content, err := legacyFiles.ReadFile(path.Join("legacy", file.Name()))
+		if err != nil {
+			return fmt.Errorf("reading legacy schema %s: %w", file.Name(), err)
+		}
+		if _, err := db.conn.ExecContext(ctx, string(content)); err != nil {
+			return fmt.Errorf("executing legacy schema %s: %w", file.Name(), err)
+		}
+	}
+
+	if err := db.upgradeSchema(ctx); err != nil {
+		return fmt.Errorf("upgrading schema: %w", err)
+	}
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := recordMigration(ctx, tx, baseline); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing: %w", err)
+	}
+
+	db.logger.Info("adopted unversioned database", "baseline", baseline.String())
+	return nil
+}
+
+// recordMigration adds a migration to schema_migrations, reporting false if
+// it is already there
+func recordMigration(ctx context.Context, tx *sql.Tx, m Migration) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO schema_migrations (version, name, checksum, applied_at)
+		VALUES (?, ?, ?, ?)
+	`, m.Version, m.Name, m.Checksum, time.Now().UTC())
+	if err != nil {
+		return false, fmt.Errorf("updating schema_migrations: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("updating schema_migrations: %w", err)
+	}
+	return n > 0, nil
+}
+
+// execMigration runs migration SQL inside a transaction
+func execMigration(ctx context.Context, tx *sql.Tx, stmts string) error {
+	// Table rebuilds drop and rename tables that views (all_rates) refer to;
+	// legacy rename semantics stop SQLite from rejecting the transient state
+	// The pragma outlives the transaction, so it's reset on the pooled
+	// connection whether or not the migration succeeds
+	if _, err := tx.ExecContext(ctx, "PRAGMA legacy_alter_table = ON"); err != nil {
+		return fmt.Errorf("enabling legacy alter table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, stmts); err != nil {
+		if _, resetErr := tx.ExecContext(context.WithoutCancel(ctx), "PRAGMA legacy_alter_table = OFF"); resetErr != nil {
+			return fmt.Errorf("executing: %w (disabling legacy alter table: %v)", err, resetErr)
+		}
+		return fmt.Errorf("executing: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "PRAGMA legacy_alter_table = OFF"); err != nil {
+		return fmt.Errorf("disabling legacy alter table: %w", err)
+	}
+	return nil
+}
+
+// appliedMigrations reads schema_migrations, which may not exist yet
+func (db *DB) appliedMigrations(ctx context.Context) (map[int]appliedMigration, error) {
+	applied := make(map[int]appliedMigration)
+
+	exists, err := db.hasTable(ctx, "schema_migrations")
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx,
+		"SELECT version, name, checksum, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("reading schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.name, &a.checksum, &a.appliedAt); err != nil {
+			return nil, fmt.Errorf("scanning schema_migrations: %w", err)
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// hasTable reports whether the database has the given table
+func (db *DB) hasTable(ctx context.Context, table string) (bool, error) {
+	var count int
+	err := db.conn.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("reading tables: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,321 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rates.db")
+
+	db, err := NewDB(path, testLogger())
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	db.Close()
+
+	// Reopening applies nothing
+	db, err = OpenDB(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	applied, err := db.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("MigrateUp() applied %v again", applied)
+	}
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range statuses {
+		if !s.Applied() || s.Modified || s.Unknown {
+			t.Errorf("migration %s: %+v, want cleanly applied", s.Migration, s)
+		}
+	}
+}
+
+func TestMigrateAdoptsLegacyDatabase(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "rates.db")
+
+	// A database created by the original unversioned schema file
+	db, err := OpenDB(path, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := legacyFiles.ReadFile("legacy/001_initial_schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(string(legacy)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec(`
+		INSERT INTO exchange_rates (rtc_bid, collected_at, date_partition)
+		VALUES (7.0749, '2025-11-22 10:00:00', '2025-11-22')
+	`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	db, err = NewDB(path, testLogger())
+	if err != nil {
+		t.Fatalf("NewDB() error = %v", err)
+	}
+	defer db.Close()
+
+	var bid int64
+	var source string
+	if err := db.conn.QueryRow("SELECT rtc_bid, source FROM exchange_rates").Scan(&bid, &source); err != nil {
+		t.Fatal(err)
+	}
+	if bid != 7074900 || source != "cmb" {
+		t.Errorf("upgraded row = (%d, %q), want (7074900, \"cmb\")", bid, source)
+	}
+
+	statuses, err := db.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Applied() || statuses[0].Modified {
+		t.Errorf("baseline not recorded: %+v", statuses[0])
+	}
+}
+
+func TestMigrateRejectsDivergedHistory(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		stmt string
+		want string
+	}{
+		{"modified", "UPDATE schema_migrations SET checksum = 'x' WHERE version = 1", "modified"},
+		{"newer", "INSERT INTO schema_migrations VALUES (999, 'future', 'x', '2030-01-01')", "does not know"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewDB(filepath.Join(t.TempDir(), "rates.db"), testLogger())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			if _, err := db.conn.Exec(tt.stmt); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.MigrateUp(ctx); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("MigrateUp() error = %v, want %q", err, tt.want)
+			}
+			if _, err := db.MigrateDown(ctx); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("MigrateDown() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestMigrateDownBaseline(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "rates.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Later migrations revert one at a time down to the baseline
+	migrations, err := Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := len(migrations) - 1; i > 0; i-- {
+		reverted, err := db.MigrateDown(context.Background())
+		if err != nil {
+			t.Fatalf("MigrateDown() error = %v", err)
+		}
+		if reverted.Version != migrations[i].Version {
+			t.Errorf("MigrateDown() reverted %s, want %s", reverted, migrations[i])
+		}
+	}
+
+	if _, err := db.MigrateDown(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot be reverted") {
+		t.Errorf("MigrateDown() error = %v, want the baseline to be irreversible", err)
+	}
+}
+
+func TestFailedMigrationResetsLegacyAlterTable(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(filepath.Join(t.TempDir(), "rates.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// One pooled connection, so the check below sees the migration's
+	db.conn.SetMaxOpenConns(1)
+
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := execMigration(ctx, tx, "ALTER TABLE no_such_table RENAME TO other"); err == nil {
+		t.Fatal("execMigration() succeeded, want an error")
+	}
+	tx.Rollback()
+
+	var legacy int
+	if err := db.conn.QueryRowContext(ctx, "PRAGMA legacy_alter_table").Scan(&legacy); err != nil {
+		t.Fatal(err)
+	}
+	if legacy != 0 {
+		t.Error("legacy_alter_table left on after a failed migration")
+	}
+}
+
+func TestMigrateRewritesTimesInUTC(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(filepath.Join(t.TempDir(), "rates.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// Revert down to before utc_timestamps
+	for {
+		reverted, err := db.MigrateDown(ctx)
+		if err != nil {
+			t.Fatalf("MigrateDown() error = %v", err)
+		}
+		if reverted.Name == "utc_timestamps" {
+			break
+		}
+	}
+
+	// Rows written by hosts in Beijing and New York, partitioned by host date
+	if _, err := db.conn.Exec(`
+		INSERT INTO exchange_rates (rtc_bid, collected_at, date_partition) VALUES
+			(7074900, '2025-11-24 07:30:00.25+08:00', '2025-11-24'),
+			(7075000, '2025-11-24 20:00:00-05:00', '2025-11-24'),
+			(7075100, '2025-11-24 12:00:00+00:00', '2025-11-24')
+	`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+
+	want := map[int64][2]string{
+		7074900: {"2025-11-23 23:30:00.25+00:00", "2025-11-24"},
+		7075000: {"2025-11-25 01:00:00+00:00", "2025-11-25"},
+		7075100: {"2025-11-24 12:00:00+00:00", "2025-11-24"},
+	}
+	// Read the stored text, not the driver's parsed time
+	rows, err := db.conn.Query("SELECT rtc_bid, CAST(collected_at AS TEXT), date_partition FROM exchange_rates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bid int64
+		var collected, partition string
+		if err := rows.Scan(&bid, &collected, &partition); err != nil {
+			t.Fatal(err)
+		}
+		if got := [2]string{collected, partition}; got != want[bid] {
+			t.Errorf("row %d = %v, want %v", bid, got, want[bid])
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateCollapsesDuplicateRates(t *testing.T) {
+	ctx := context.Background()
+	db, err := NewDB(filepath.Join(t.TempDir(), "rates.db"), testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	repo := NewRepository(db, testLogger())
+
+	// Revert down to before unique_rate_key
+	for {
+		reverted, err := db.MigrateDown(ctx)
+		if err != nil {
+			t.Fatalf("MigrateDown() error = %v", err)
+		}
+		if reverted.Name == "unique_rate_key" {
+			break
+		}
+	}
+
+	// A quote polled three times, the last poll just past market midnight and
+	// with a corrected price, next to a distinct quote
+	quotedAt := time.Date(2025, 11, 24, 15, 59, 0, 0, time.UTC)
+	for i, bid := range []string{"7.0749", "7.0749", "7.0760"} {
+		rate := testRate(quotedAt.Add(time.Duration(i) * 30 * time.Second))
+		rate.QuotedAt = quotedAt
+		rate.RtcBid = fixed.MustParse(bid)
+		rate.DatePartition = market.Date(rate.CollectedAt)
+		if _, err := db.conn.Exec(insertRateQuery, insertRateArgs(rate)...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	distinct := testRate(quotedAt.Add(-time.Hour))
+	distinct.DatePartition = market.Date(distinct.CollectedAt)
+	if _, err := db.conn.Exec(insertRateQuery, insertRateArgs(distinct)...); err != nil {
+		t.Fatal(err)
+	}
+	if _, days, err := repo.RebuildRollups(ctx, "2025-11-24", "2025-11-25"); err != nil || days != 2 {
+		t.Fatalf("RebuildRollups() = %d days, %v; want the duplicates rolled up on 2 days", days, err)
+	}
+
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp() error = %v", err)
+	}
+
+	if n := countRates(t, db); n != 2 {
+		t.Errorf("database has %d rates, want 2", n)
+	}
+	var bid fixed.Rate
+	var collectedAt time.Time
+	if err := db.conn.QueryRow("SELECT rtc_bid, collected_at FROM exchange_rates WHERE id = 1").Scan(&bid, &collectedAt); err != nil {
+		t.Fatal(err)
+	}
+	if bid != fixed.MustParse("7.0760") || !collectedAt.Equal(quotedAt) {
+		t.Errorf("kept row = %s at %s, want the latest price at the first poll", bid, collectedAt)
+	}
+
+	// The date only the last copy was collected on loses its rollups
+	if stats, err := repo.GetDailyStats(ctx, "USD", "2025-11-25"); err != nil || stats.SampleCount != 0 {
+		t.Errorf("GetDailyStats(2025-11-25) = %+v, %v; want no rollup", stats, err)
+	}
+	assertRollupsFresh(t, db, repo)
+
+	// and the key is UNIQUE
+	if err := repo.InsertRate(ctx, testRate(quotedAt)); err != nil {
+		t.Fatalf("InsertRate() error = %v", err)
+	}
+	if n := countRates(t, db); n != 2 {
+		t.Errorf("database has %d rates after storing a stored quote, want 2", n)
+	}
+}
@@ -0,0 +1,123 @@
+-- Baseline schema for exchange rate monitoring
+-- Prices are fixed-point integers in millionths of a yuan per unit of currency
+-- Databases created before versioned migrations are brought to this schema by
+-- the legacy upgrades in upgrade.go and then recorded as being at version 1
+
+-- Archive of raw API response bodies
+-- Lets exchange_rates be rebuilt when extraction changes (see `ratemon reparse`)
+CREATE TABLE IF NOT EXISTS raw_responses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,               -- Rate source that served the body (e.g., 'cmb')
+    collected_at TIMESTAMP NOT NULL,    -- Poll time of the first identical response
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD
+    checksum TEXT NOT NULL,             -- SHA-256 of the uncompressed body
+    body BLOB NOT NULL,                 -- gzip-compressed response body
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_raw_date_time
+    ON raw_responses(date_partition, collected_at);
+
+-- Minute-level quotes
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    source TEXT NOT NULL DEFAULT 'cmb',
+    rtc_bid INTEGER NOT NULL,           -- Cash bid
+    rtb_bid INTEGER,                    -- Reference rate
+    rth_bid INTEGER,                    -- Spot bid
+    rth_ofr INTEGER,                    -- Spot offer
+    rtc_ofr INTEGER,                    -- Cash offer
+    quoted_at TIMESTAMP,                -- When the bank published the quote
+    collected_at TIMESTAMP NOT NULL,
+    response_id INTEGER REFERENCES raw_responses(id) ON DELETE SET NULL,
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    CHECK (rtc_bid > 0),
+    CHECK (length(currency_code) = 3)
+);
+
+CREATE INDEX IF NOT EXISTS idx_rates_date_time
+    ON exchange_rates(date_partition, collected_at);
+CREATE INDEX IF NOT EXISTS idx_rates_collected
+    ON exchange_rates(collected_at);
+CREATE INDEX IF NOT EXISTS idx_rates_currency_collected
+    ON exchange_rates(currency_code, collected_at);
+CREATE INDEX IF NOT EXISTS idx_rates_response
+    ON exchange_rates(response_id);
+
+-- Hourly aggregated rates (365 days retention)
+CREATE TABLE IF NOT EXISTS hourly_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD
+    hour INTEGER NOT NULL,              -- 0-23
+    avg_rate INTEGER NOT NULL,
+    min_rate INTEGER NOT NULL,
+    max_rate INTEGER NOT NULL,
+    sample_count INTEGER NOT NULL,
+    first_collected_at TEXT NOT NULL,
+    last_collected_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(currency_code, date_partition, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_hourly_date ON hourly_rates(date_partition);
+CREATE INDEX IF NOT EXISTS idx_hourly_date_hour ON hourly_rates(date_partition, hour);
+
+-- Daily aggregated rates (permanent retention)
+CREATE TABLE IF NOT EXISTS daily_rates (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    date_partition TEXT NOT NULL,
+    avg_rate INTEGER NOT NULL,
+    min_rate INTEGER NOT NULL,
+    max_rate INTEGER NOT NULL,
+    peak_rate INTEGER NOT NULL,         -- Highest rate of the day
+    peak_time TEXT NOT NULL,            -- When peak occurred
+    volatility INTEGER NOT NULL,        -- max_rate - min_rate
+    sample_count INTEGER NOT NULL,
+    first_collected_at TEXT NOT NULL,
+    last_collected_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(currency_code, date_partition)
+);
+
+CREATE INDEX IF NOT EXISTS idx_daily_date ON daily_rates(date_partition);
+
+-- View for easy access to all data (raw + aggregated), in CNY
+CREATE VIEW IF NOT EXISTS all_rates AS
+-- Recent raw data (last 90 days)
+SELECT
+    'raw' as source,
+    collected_at as timestamp,
+    rtc_bid / 1000000.0 as rate,
+    date_partition
+FROM exchange_rates
+WHERE date_partition >= date('now', '-90 days')
+
+UNION ALL
+
+-- Hourly aggregates (91-365 days ago)
+SELECT
+    'hourly' as source,
+    datetime(date_partition || ' ' || printf('%02d', hour) || ':00:00') as timestamp,
+    avg_rate / 1000000.0 as rate,
+    date_partition
+FROM hourly_rates
+WHERE date_partition < date('now', '-90 days')
+  AND date_partition >= date('now', '-365 days')
+
+UNION ALL
+
+-- Daily aggregates (older than 365 days)
+SELECT
+    'daily' as source,
+    datetime(date_partition || ' 12:00:00') as timestamp,
+    avg_rate / 1000000.0 as rate,
+    date_partition
+FROM daily_rates
+WHERE date_partition < date('now', '-365 days')
+
+ORDER BY timestamp DESC;
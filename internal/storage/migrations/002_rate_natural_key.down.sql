@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_rates_natural_key;
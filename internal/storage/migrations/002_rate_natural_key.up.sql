@@ -0,0 +1,7 @@
+-- Natural key of a quote: currency, source and observation time (the bank's
+-- quote time, falling back to the poll time as in ExchangeRate.ObservedAt)
+-- Inserts look rows up by this key to update a quote instead of storing it
+-- twice. The index is not UNIQUE because databases written before it may
+-- hold duplicates; `ratemon dedupe` reports and removes them
+CREATE INDEX IF NOT EXISTS idx_rates_natural_key
+    ON exchange_rates(currency_code, source, COALESCE(quoted_at, collected_at));
@@ -0,0 +1,14 @@
+-- One row per successful poll of a source, whether or not it stored quotes
+-- Unchanged quotes are not stored again, so exchange_rates alone cannot tell
+-- a flat market from a daemon that wasn't running (see `ratemon coverage`)
+CREATE TABLE IF NOT EXISTS polls (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source TEXT NOT NULL,               -- Rate source polled (e.g., 'cmb')
+    polled_at TIMESTAMP NOT NULL,       -- Poll time, as collected_at of its quotes
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD
+    quotes INTEGER NOT NULL,            -- Quotes in the response
+    stored INTEGER NOT NULL             -- Quotes stored (the rest repeated stored ones)
+);
+
+CREATE INDEX IF NOT EXISTS idx_polls_time
+    ON polls(polled_at);
@@ -0,0 +1,2 @@
+-- Nothing to undo: UTC times with a +00:00 offset and market-date partitions
+-- are valid for every earlier version, which reads times in any offset
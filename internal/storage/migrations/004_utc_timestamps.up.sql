@@ -0,0 +1,49 @@
+-- Times are stored in UTC and partitioned by the market's date (Beijing time)
+-- Earlier versions wrote times with the host's UTC offset and partitioned by
+-- the host's date, so a host outside UTC+8 stored different text (and dates)
+-- for the same instants. Rewrite offset times to the same instant in UTC,
+-- keeping their fractional seconds, then recompute partitions from them.
+-- Times without an offset are already read as UTC and are left alone.
+-- hourly_rates and daily_rates keep the hours and dates they were built with:
+-- the raw rows they summarize are gone, so they cannot be rebuilt
+
+UPDATE exchange_rates
+SET collected_at = datetime(collected_at)
+    || CASE WHEN instr(collected_at, '.') > 0
+        THEN substr(collected_at, instr(collected_at, '.'), length(collected_at) - 6 - instr(collected_at, '.') + 1)
+        ELSE '' END
+    || '+00:00'
+WHERE collected_at GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND collected_at NOT GLOB '*+00:00';
+
+UPDATE exchange_rates
+SET quoted_at = datetime(quoted_at)
+    || CASE WHEN instr(quoted_at, '.') > 0
+        THEN substr(quoted_at, instr(quoted_at, '.'), length(quoted_at) - 6 - instr(quoted_at, '.') + 1)
+        ELSE '' END
+    || '+00:00'
+WHERE quoted_at GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND quoted_at NOT GLOB '*+00:00';
+
+UPDATE raw_responses
+SET collected_at = datetime(collected_at)
+    || CASE WHEN instr(collected_at, '.') > 0
+        THEN substr(collected_at, instr(collected_at, '.'), length(collected_at) - 6 - instr(collected_at, '.') + 1)
+        ELSE '' END
+    || '+00:00'
+WHERE collected_at GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND collected_at NOT GLOB '*+00:00';
+
+UPDATE polls
+SET polled_at = datetime(polled_at)
+    || CASE WHEN instr(polled_at, '.') > 0
+        THEN substr(polled_at, instr(polled_at, '.'), length(polled_at) - 6 - instr(polled_at, '.') + 1)
+        ELSE '' END
+    || '+00:00'
+WHERE polled_at GLOB '*[+-][0-9][0-9]:[0-9][0-9]' AND polled_at NOT GLOB '*+00:00';
+
+UPDATE exchange_rates SET date_partition = date(collected_at, '+8 hours')
+WHERE date_partition != date(collected_at, '+8 hours');
+
+UPDATE raw_responses SET date_partition = date(collected_at, '+8 hours')
+WHERE date_partition != date(collected_at, '+8 hours');
+
+UPDATE polls SET date_partition = date(polled_at, '+8 hours')
+WHERE date_partition != date(polled_at, '+8 hours');
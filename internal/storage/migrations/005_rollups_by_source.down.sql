@@ -0,0 +1,75 @@
+-- Rollups go back to one row per currency and date (and hour), merging sources
+
+DROP INDEX IF EXISTS idx_rates_rollup;
+
+CREATE TABLE hourly_rates_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD
+    hour INTEGER NOT NULL,              -- 0-23
+    avg_rate INTEGER NOT NULL,
+    min_rate INTEGER NOT NULL,
+    max_rate INTEGER NOT NULL,
+    sample_count INTEGER NOT NULL,
+    first_collected_at TEXT NOT NULL,
+    last_collected_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(currency_code, date_partition, hour)
+);
+
+INSERT INTO hourly_rates_old (
+    currency_code, date_partition, hour, avg_rate, min_rate, max_rate, sample_count,
+    first_collected_at, last_collected_at
+)
+SELECT
+    currency_code, date_partition, hour,
+    CAST(ROUND(1.0 * SUM(sum_rate) / SUM(sample_count)) AS INTEGER),
+    MIN(min_rate), MAX(max_rate), SUM(sample_count),
+    MIN(first_collected_at), MAX(last_collected_at)
+FROM hourly_rates
+GROUP BY currency_code, date_partition, hour;
+
+DROP TABLE hourly_rates;
+ALTER TABLE hourly_rates_old RENAME TO hourly_rates;
+
+CREATE INDEX IF NOT EXISTS idx_hourly_date ON hourly_rates(date_partition);
+CREATE INDEX IF NOT EXISTS idx_hourly_date_hour ON hourly_rates(date_partition, hour);
+
+CREATE TABLE daily_rates_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    date_partition TEXT NOT NULL,
+    avg_rate INTEGER NOT NULL,
+    min_rate INTEGER NOT NULL,
+    max_rate INTEGER NOT NULL,
+    peak_rate INTEGER NOT NULL,         -- Highest rate of the day
+    peak_time TEXT NOT NULL,            -- When peak occurred
+    volatility INTEGER NOT NULL,        -- max_rate - min_rate
+    sample_count INTEGER NOT NULL,
+    first_collected_at TEXT NOT NULL,
+    last_collected_at TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(currency_code, date_partition)
+);
+
+INSERT INTO daily_rates_old (
+    currency_code, date_partition, avg_rate, min_rate, max_rate, peak_rate, peak_time,
+    volatility, sample_count, first_collected_at, last_collected_at
+)
+SELECT
+    currency_code, date_partition,
+    CAST(ROUND(1.0 * SUM(sum_rate) / SUM(sample_count)) AS INTEGER),
+    MIN(min_rate), MAX(max_rate), MAX(peak_rate),
+    (SELECT p.peak_time FROM daily_rates p
+     WHERE p.currency_code = d.currency_code AND p.date_partition = d.date_partition
+     ORDER BY p.peak_rate DESC, p.peak_time
+     LIMIT 1),
+    MAX(max_rate) - MIN(min_rate), SUM(sample_count),
+    MIN(first_collected_at), MAX(last_collected_at)
+FROM daily_rates d
+GROUP BY currency_code, date_partition;
+
+DROP TABLE daily_rates;
+ALTER TABLE daily_rates_old RENAME TO daily_rates;
+
+CREATE INDEX IF NOT EXISTS idx_daily_date ON daily_rates(date_partition);
@@ -0,0 +1,122 @@
+-- Rollups are kept per source and refreshed whenever exchange_rates changes
+-- (see rollup.go), rather than built by `retention run` just before raw data
+-- is deleted. sum_rate keeps the exact sum of the prices rolled up, so
+-- rollups of several sources or hours combine without rounding twice.
+-- Rollups built by earlier versions merged every source; those whose raw data
+-- is gone are kept as the default source's, with sum_rate from their average
+
+CREATE TABLE hourly_rates_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    source TEXT NOT NULL DEFAULT 'cmb',
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD, market date
+    hour INTEGER NOT NULL,              -- 0-23, market hour of observation
+    sum_rate INTEGER NOT NULL,          -- Sum of the prices rolled up
+    avg_rate INTEGER NOT NULL,
+    min_rate INTEGER NOT NULL,
+    max_rate INTEGER NOT NULL,
+    sample_count INTEGER NOT NULL,
+    first_collected_at TIMESTAMP NOT NULL,
+    last_collected_at TIMESTAMP NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(currency_code, source, date_partition, hour)
+);
+
+INSERT INTO hourly_rates_new (
+    currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+    sample_count, first_collected_at, last_collected_at, created_at
+)
+SELECT
+    currency_code, 'cmb', date_partition, hour, avg_rate * sample_count, avg_rate, min_rate, max_rate,
+    sample_count, first_collected_at, last_collected_at, created_at
+FROM hourly_rates
+WHERE date_partition NOT IN (SELECT DISTINCT date_partition FROM exchange_rates);
+
+DROP TABLE hourly_rates;
+ALTER TABLE hourly_rates_new RENAME TO hourly_rates;
+
+CREATE INDEX IF NOT EXISTS idx_hourly_date ON hourly_rates(date_partition);
+CREATE INDEX IF NOT EXISTS idx_hourly_date_hour ON hourly_rates(date_partition, hour);
+
+CREATE TABLE daily_rates_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL DEFAULT 'USD',
+    source TEXT NOT NULL DEFAULT 'cmb',
+    date_partition TEXT NOT NULL,       -- YYYY-MM-DD, market date
+    sum_rate INTEGER NOT NULL,          -- Sum of the prices rolled up
+    avg_rate INTEGER NOT NULL,
+    min_rate INTEGER NOT NULL,
+    max_rate INTEGER NOT NULL,
+    peak_rate INTEGER NOT NULL,         -- Highest rate of the day
+    peak_time TIMESTAMP NOT NULL,       -- Observation time of the first peak collected
+    volatility INTEGER NOT NULL,        -- max_rate - min_rate
+    sample_count INTEGER NOT NULL,
+    first_collected_at TIMESTAMP NOT NULL,
+    last_collected_at TIMESTAMP NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    UNIQUE(currency_code, source, date_partition)
+);
+
+INSERT INTO daily_rates_new (
+    currency_code, source, date_partition, sum_rate, avg_rate, min_rate, max_rate, peak_rate,
+    peak_time, volatility, sample_count, first_collected_at, last_collected_at, created_at
+)
+SELECT
+    currency_code, 'cmb', date_partition, avg_rate * sample_count, avg_rate, min_rate, max_rate, peak_rate,
+    peak_time, volatility, sample_count, first_collected_at, last_collected_at, created_at
+FROM daily_rates
+WHERE date_partition NOT IN (SELECT DISTINCT date_partition FROM exchange_rates);
+
+DROP TABLE daily_rates;
+ALTER TABLE daily_rates_new RENAME TO daily_rates;
+
+CREATE INDEX IF NOT EXISTS idx_daily_date ON daily_rates(date_partition);
+
+-- Rollups are refreshed one currency, source and date at a time
+CREATE INDEX IF NOT EXISTS idx_rates_rollup
+    ON exchange_rates(currency_code, source, date_partition);
+
+-- Backfill every date with raw data, as RebuildRollups does
+INSERT INTO hourly_rates (
+    currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+    sample_count, first_collected_at, last_collected_at
+)
+SELECT
+    currency_code,
+    source,
+    date_partition,
+    CAST(strftime('%H', COALESCE(quoted_at, collected_at), '+8 hours') AS INTEGER) AS hour,
+    SUM(rtc_bid),
+    CAST(ROUND(AVG(rtc_bid)) AS INTEGER),
+    MIN(rtc_bid),
+    MAX(rtc_bid),
+    COUNT(*),
+    MIN(collected_at),
+    MAX(collected_at)
+FROM exchange_rates
+GROUP BY currency_code, source, date_partition, hour;
+
+INSERT INTO daily_rates (
+    currency_code, source, date_partition, sum_rate, avg_rate, min_rate, max_rate, peak_rate,
+    peak_time, volatility, sample_count, first_collected_at, last_collected_at
+)
+SELECT
+    currency_code,
+    source,
+    date_partition,
+    SUM(rtc_bid),
+    CAST(ROUND(AVG(rtc_bid)) AS INTEGER),
+    MIN(rtc_bid),
+    MAX(rtc_bid),
+    MAX(rtc_bid),
+    (SELECT COALESCE(p.quoted_at, p.collected_at)
+     FROM exchange_rates p
+     WHERE p.currency_code = e.currency_code AND p.source = e.source AND p.date_partition = e.date_partition
+     ORDER BY p.rtc_bid DESC, p.collected_at, p.id
+     LIMIT 1),
+    MAX(rtc_bid) - MIN(rtc_bid),
+    COUNT(*),
+    MIN(collected_at),
+    MAX(collected_at)
+FROM exchange_rates e
+GROUP BY currency_code, source, date_partition;
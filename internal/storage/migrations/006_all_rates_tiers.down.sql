@@ -0,0 +1,38 @@
+-- all_rates goes back to fixed raw, hourly and daily windows
+
+DROP VIEW IF EXISTS all_rates;
+
+CREATE VIEW all_rates AS
+-- Recent raw data (last 90 days)
+SELECT
+    'raw' as source,
+    collected_at as timestamp,
+    rtc_bid / 1000000.0 as rate,
+    date_partition
+FROM exchange_rates
+WHERE date_partition >= date('now', '-90 days')
+
+UNION ALL
+
+-- Hourly aggregates (91-365 days ago)
+SELECT
+    'hourly' as source,
+    datetime(date_partition || ' ' || printf('%02d', hour) || ':00:00') as timestamp,
+    avg_rate / 1000000.0 as rate,
+    date_partition
+FROM hourly_rates
+WHERE date_partition < date('now', '-90 days')
+  AND date_partition >= date('now', '-365 days')
+
+UNION ALL
+
+-- Daily aggregates (older than 365 days)
+SELECT
+    'daily' as source,
+    datetime(date_partition || ' 12:00:00') as timestamp,
+    avg_rate / 1000000.0 as rate,
+    date_partition
+FROM daily_rates
+WHERE date_partition < date('now', '-365 days')
+
+ORDER BY timestamp DESC;
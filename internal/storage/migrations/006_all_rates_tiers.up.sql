@@ -0,0 +1,54 @@
+-- all_rates served raw data for the last 90 days, hourly rollups up to a
+-- year and daily ones beyond, counted from today whatever retention had
+-- kept. It now serves each source's market date from the finest tier that
+-- still has it, as Repository.GetSeries does; tier says which. Times are
+-- UTC; rollups are stamped with the start of their market hour or day
+-- (market dates are UTC+8, see market.Zone)
+
+DROP VIEW IF EXISTS all_rates;
+
+CREATE VIEW all_rates AS
+SELECT
+    'raw' as tier,
+    currency_code,
+    source,
+    datetime(COALESCE(quoted_at, collected_at)) as timestamp,
+    rtc_bid / 1000000.0 as rate,
+    date_partition
+FROM exchange_rates
+
+UNION ALL
+
+SELECT
+    'hourly' as tier,
+    h.currency_code,
+    h.source,
+    datetime(h.date_partition, '+' || h.hour || ' hours', '-8 hours') as timestamp,
+    h.avg_rate / 1000000.0 as rate,
+    h.date_partition
+FROM hourly_rates h
+WHERE NOT EXISTS (
+    SELECT 1 FROM exchange_rates e
+    WHERE e.currency_code = h.currency_code AND e.source = h.source AND e.date_partition = h.date_partition
+)
+
+UNION ALL
+
+SELECT
+    'daily' as tier,
+    d.currency_code,
+    d.source,
+    datetime(d.date_partition, '-8 hours') as timestamp,
+    d.avg_rate / 1000000.0 as rate,
+    d.date_partition
+FROM daily_rates d
+WHERE NOT EXISTS (
+    SELECT 1 FROM exchange_rates e
+    WHERE e.currency_code = d.currency_code AND e.source = d.source AND e.date_partition = d.date_partition
+)
+AND NOT EXISTS (
+    SELECT 1 FROM hourly_rates h
+    WHERE h.currency_code = d.currency_code AND h.source = d.source AND h.date_partition = d.date_partition
+)
+
+ORDER BY timestamp DESC;
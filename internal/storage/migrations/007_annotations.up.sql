@@ -0,0 +1,15 @@
+-- Notes pinned to a point or range of time, such as a market event, a
+-- conversion or an outage; history, peak and average show them next to the
+-- rates they explain (see `ratemon annotate`)
+CREATE TABLE IF NOT EXISTS annotations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT,                 -- NULL: every currency
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,                  -- NULL: a point in time
+    note TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    CHECK (ends_at IS NULL OR ends_at >= starts_at)
+);
+
+CREATE INDEX IF NOT EXISTS idx_annotations_time
+    ON annotations(starts_at);
@@ -0,0 +1,19 @@
+-- Conversions actually made, so `ratemon ledger report` can score the rates
+-- achieved against each day's peak and average and the recommendation given
+CREATE TABLE IF NOT EXISTS conversions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    currency_code TEXT NOT NULL,
+    converted_at TIMESTAMP NOT NULL,
+    rmb_amount REAL NOT NULL,           -- RMB converted, before fees
+    foreign_amount REAL NOT NULL,       -- Foreign currency received
+    fees REAL NOT NULL DEFAULT 0,       -- RMB, deducted from the conversion
+    recommended_action TEXT,            -- NULL: no recommendation on record
+    recommended_confidence REAL,
+    recommended_rate INTEGER,           -- The rate the recommendation was for
+    note TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (datetime('now')),
+    CHECK (rmb_amount > 0 AND foreign_amount > 0 AND fees >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_conversions_time
+    ON conversions(converted_at);
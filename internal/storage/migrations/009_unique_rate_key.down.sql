@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_rates_natural_key;
+CREATE INDEX IF NOT EXISTS idx_rates_natural_key
+    ON exchange_rates(currency_code, source, COALESCE(quoted_at, collected_at));
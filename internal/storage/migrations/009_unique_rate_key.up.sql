@@ -0,0 +1,98 @@
+-- The natural key of a quote becomes UNIQUE, so inserts upsert on it with
+-- ON CONFLICT and two writers (the daemon and an import, say) can't both
+-- store a quote. Duplicates stored before are first collapsed as `ratemon
+-- dedupe` does: each quote keeps its first stored row, which takes the
+-- prices of the last one stored and its archive link, if it has one. The
+-- rollups of every date a removed copy counted towards are rebuilt
+
+CREATE TEMP TABLE duplicate_rates AS
+SELECT currency_code, source, COALESCE(quoted_at, collected_at) AS observed_at,
+    MIN(id) AS keep_id, MAX(id) AS latest_id
+FROM exchange_rates
+GROUP BY currency_code, source, COALESCE(quoted_at, collected_at)
+HAVING COUNT(*) > 1;
+
+CREATE TEMP TABLE duplicate_dates AS
+SELECT DISTINCT e.currency_code, e.source, e.date_partition
+FROM exchange_rates e
+JOIN duplicate_rates d
+    ON d.currency_code = e.currency_code AND d.source = e.source
+    AND d.observed_at = COALESCE(e.quoted_at, e.collected_at);
+
+UPDATE exchange_rates
+SET (rtc_bid, rtb_bid, rth_bid, rth_ofr, rtc_ofr, response_id) = (
+    SELECT l.rtc_bid, l.rtb_bid, l.rth_bid, l.rth_ofr, l.rtc_ofr,
+        COALESCE(l.response_id, exchange_rates.response_id)
+    FROM duplicate_rates d
+    JOIN exchange_rates l ON l.id = d.latest_id
+    WHERE d.keep_id = exchange_rates.id
+)
+WHERE id IN (SELECT keep_id FROM duplicate_rates);
+
+DELETE FROM exchange_rates
+WHERE EXISTS (
+    SELECT 1 FROM duplicate_rates d
+    WHERE d.currency_code = exchange_rates.currency_code AND d.source = exchange_rates.source
+        AND d.observed_at = COALESCE(exchange_rates.quoted_at, exchange_rates.collected_at)
+        AND exchange_rates.id > d.keep_id
+);
+
+-- Rebuild the rollups of those dates, as RebuildRollups does
+DELETE FROM hourly_rates
+WHERE (currency_code, source, date_partition) IN (SELECT * FROM duplicate_dates);
+
+INSERT INTO hourly_rates (
+    currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+    sample_count, first_collected_at, last_collected_at
+)
+SELECT
+    currency_code,
+    source,
+    date_partition,
+    CAST(strftime('%H', COALESCE(quoted_at, collected_at), '+8 hours') AS INTEGER) AS hour,
+    SUM(rtc_bid),
+    CAST(ROUND(AVG(rtc_bid)) AS INTEGER),
+    MIN(rtc_bid),
+    MAX(rtc_bid),
+    COUNT(*),
+    MIN(collected_at),
+    MAX(collected_at)
+FROM exchange_rates
+WHERE (currency_code, source, date_partition) IN (SELECT * FROM duplicate_dates)
+GROUP BY currency_code, source, date_partition, hour;
+
+DELETE FROM daily_rates
+WHERE (currency_code, source, date_partition) IN (SELECT * FROM duplicate_dates);
+
+INSERT INTO daily_rates (
+    currency_code, source, date_partition, sum_rate, avg_rate, min_rate, max_rate, peak_rate,
+    peak_time, volatility, sample_count, first_collected_at, last_collected_at
+)
+SELECT
+    currency_code,
+    source,
+    date_partition,
+    SUM(rtc_bid),
+    CAST(ROUND(AVG(rtc_bid)) AS INTEGER),
+    MIN(rtc_bid),
+    MAX(rtc_bid),
+    MAX(rtc_bid),
+    (SELECT COALESCE(p.quoted_at, p.collected_at)
+     FROM exchange_rates p
+     WHERE p.currency_code = e.currency_code AND p.source = e.source AND p.date_partition = e.date_partition
+     ORDER BY p.rtc_bid DESC, p.collected_at, p.id
+     LIMIT 1),
+    MAX(rtc_bid) - MIN(rtc_bid),
+    COUNT(*),
+    MIN(collected_at),
+    MAX(collected_at)
+FROM exchange_rates e
+WHERE (currency_code, source, date_partition) IN (SELECT * FROM duplicate_dates)
+GROUP BY currency_code, source, date_partition;
+
+DROP TABLE duplicate_dates;
+DROP TABLE duplicate_rates;
+
+DROP INDEX IF EXISTS idx_rates_natural_key;
+CREATE UNIQUE INDEX idx_rates_natural_key
+    ON exchange_rates(currency_code, source, COALESCE(quoted_at, collected_at));
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Poll records one successful fetch of a source
+type Poll struct {
+	ID            int64
+	Source        string
+	PolledAt      time.Time // Collection time of the quotes fetched
+	DatePartition string
+	Quotes        int // Quotes in the response
+	Stored        int // Quotes stored (the rest repeated stored ones)
+}
+
+// RecordPoll logs a successful poll, whether or not it stored quotes
+func (r *Repository) RecordPoll(ctx context.Context, poll *Poll) error {
+	if poll.Source == "" {
+		poll.Source = DefaultSource
+	}
+
+	query := `
+		INSERT INTO polls (source, polled_at, date_partition, quotes, stored)
+		VALUES (?, ?, ?, ?, ?)
+	`
+
+	result, err := r.db.conn.ExecContext(ctx, query,
+		poll.Source, poll.PolledAt.UTC(), poll.DatePartition, poll.Quotes, poll.Stored)
+	if err != nil {
+		return fmt.Errorf("recording poll: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("getting insert ID: %w", err)
+	}
+
+	poll.ID = id
+	return nil
+}
+
+// GetPollTimes returns the distinct times between start and end (inclusive)
+// at which a source was successfully polled, in order
+// Stored rates count as polls too, so periods from before polls were logged
+// are covered by the rates stored in them
+func (r *Repository) GetPollTimes(ctx context.Context, start, end time.Time) ([]time.Time, error) {
+	query := `
+		SELECT polled_at FROM polls
+		WHERE ` + sourceFilter + ` AND polled_at >= ? AND polled_at <= ?
+		UNION
+		SELECT collected_at FROM exchange_rates
+		WHERE ` + sourceFilter + ` AND collected_at >= ? AND collected_at <= ?
+		ORDER BY 1
+	`
+
+	rows, err := r.db.conn.QueryContext(ctx, query,
+		r.source, r.source, start.UTC(), end.UTC(),
+		r.source, r.source, start.UTC(), end.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("querying poll times: %w", err)
+	}
+	defer rows.Close()
+
+	var times []time.Time
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, fmt.Errorf("scanning poll time: %w", err)
+		}
+		if n := len(times); n > 0 && times[n-1].Equal(t) {
+			continue // The same instant written in another zone
+		}
+		times = append(times, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating poll times: %w", err)
+	}
+
+	return times, nil
+}
+
+// DeletePollsBefore deletes logged polls older than the specified date
+func (r *Repository) DeletePollsBefore(ctx context.Context, beforeDate string) (int64, error) {
+	result, err := r.db.conn.ExecContext(ctx, `DELETE FROM polls WHERE date_partition < ?`, beforeDate)
+	if err != nil {
+		return 0, fmt.Errorf("deleting polls: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("getting rows affected: %w", err)
+	}
+
+	return rows, nil
+}
@@ -6,23 +6,124 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
 )
 
 // ExchangeRate represents a single exchange rate record
 type ExchangeRate struct {
 	ID            int64
 	CurrencyCode  string
-	RtcBid        float64
-	CollectedAt   time.Time
+	Source        string     // Rate source that published the quote (e.g., "cmb")
+	RtcBid        fixed.Rate // Cash bid (the tracked rate)
+	RtbBid        fixed.Rate // Reference rate (0 if not recorded)
+	RthBid        fixed.Rate // Spot bid (0 if not recorded)
+	RthOfr        fixed.Rate // Spot offer (0 if not recorded)
+	RtcOfr        fixed.Rate // Cash offer (0 if not recorded)
+	QuotedAt      time.Time  // When the bank published the quote (zero if unknown)
+	CollectedAt   time.Time  // When the poller fetched the quote
+	ResponseID    int64      // Archived raw response the quote came from (0 if not archived)
 	DatePartition string
 	CreatedAt     time.Time
 }
 
+// ObservedAt returns the bank's quote time, falling back to the poll time
+func (r *ExchangeRate) ObservedAt() time.Time {
+	if !r.QuotedAt.IsZero() {
+		return r.QuotedAt
+	}
+	return r.CollectedAt
+}
+
+// SameQuote reports whether other repeats this observation: same bank quote
+// time (when known) and same prices on every side
+func (r *ExchangeRate) SameQuote(other *ExchangeRate) bool {
+	return r.QuotedAt.Equal(other.QuotedAt) &&
+		r.RtcBid == other.RtcBid &&
+		r.RtbBid == other.RtbBid &&
+		r.RthBid == other.RthBid &&
+		r.RthOfr == other.RthOfr &&
+		r.RtcOfr == other.RtcOfr
+}
+
+// DefaultSource is the source of rows stored before sources were tracked
+const DefaultSource = "cmb"
+
+// sourceFilter restricts exchange_rates to the repository's source and
+// binds two arguments, both the source (an empty source matches every row)
+const sourceFilter = "(? = '' OR source = ?)"
+
+// observedAtExpr is the SQL counterpart of ExchangeRate.ObservedAt
+const observedAtExpr = "COALESCE(quoted_at, collected_at)"
+
+// marketTime shifts a stored time expression to market time for SQLite's
+// date functions: strftime('%H', marketTime(expr)) is the market hour
+// Times are stored in UTC, so the shift is a fixed offset (see market.Zone)
+func marketTime(expr string) string {
+	return expr + ", '" + market.SQLModifier + "'"
+}
+
+// marketDaysAgo is the market date N days before today, binding N
+const marketDaysAgo = "date('now', '" + market.SQLModifier + "', '-' || ? || ' days')"
+
+// avgRate averages a fixed-point price expression in SQL, rounded to the
+// nearest fixed.Rate. Prices are stored as integers (see fixed.Rate), so the
+// sum is exact and the result only rounds once
+func avgRate(expr string) string {
+	return "CAST(ROUND(AVG(" + expr + ")) AS INTEGER)"
+}
+
+// rollupAvg averages rollups in SQL from their summed prices and sample
+// counts, rounded like avgRate over the raw prices they summarize
+func rollupAvg(sum, count string) string {
+	return "CAST(ROUND(1.0 * SUM(" + sum + ") / SUM(" + count + ")) AS INTEGER)"
+}
+
+// rateColumns lists the exchange_rates columns read by scanRate
+// Price sides are NULL on rows stored before they were recorded
+const rateColumns = `id, currency_code, source, rtc_bid,
+	COALESCE(rtb_bid, 0), COALESCE(rth_bid, 0), COALESCE(rth_ofr, 0), COALESCE(rtc_ofr, 0),
+	quoted_at, collected_at, COALESCE(response_id, 0), date_partition, created_at`
+
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanRate scans a row selected with rateColumns
+func scanRate(row rowScanner, rate *ExchangeRate) error {
+	var quotedAt sql.NullTime
+	err := row.Scan(
+		&rate.ID,
+		&rate.CurrencyCode,
+		&rate.Source,
+		&rate.RtcBid,
+		&rate.RtbBid,
+		&rate.RthBid,
+		&rate.RthOfr,
+		&rate.RtcOfr,
+		&quotedAt,
+		&rate.CollectedAt,
+		&rate.ResponseID,
+		&rate.DatePartition,
+		&rate.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	rate.QuotedAt = quotedAt.Time
+	return nil
+}
+
 // Repository provides data access methods for exchange rates
 type Repository struct {
 	db     *DB
 	logger *slog.Logger
+	source string // Only read rows from this source (empty: all sources)
 }
 
 // NewRepository creates a new repository instance
@@ -33,50 +134,129 @@ func NewRepository(db *DB, logger *slog.Logger) *Repository {
 	}
 }
 
-// InsertRate stores a new exchange rate reading
-func (r *Repository) InsertRate(ctx context.Context, rate *ExchangeRate) error {
-	query := `
-		INSERT INTO exchange_rates (currency_code, rtc_bid, collected_at, date_partition)
-		VALUES (?, ?, ?, ?)
-	`
+// WithSource returns a repository whose queries only see rows from a source
+// An empty source returns a repository that sees every source
+func (r *Repository) WithSource(source string) Store {
+	scoped := *r
+	scoped.source = source
+	return &scoped
+}
+
+// Source returns the source the repository is restricted to (empty: all)
+func (r *Repository) Source() string {
+	return r.source
+}
 
-	result, err := r.db.conn.ExecContext(ctx, query,
+const insertRateQuery = `
+	INSERT INTO exchange_rates (
+		currency_code, source, rtc_bid, rtb_bid, rth_bid, rth_ofr, rtc_ofr, quoted_at, collected_at,
+		response_id, date_partition
+	)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+// upsertRateQuery stores a rate, or refreshes the prices (and archive link,
+// if the rate has one) of the stored row with the same natural key: currency,
+// source and observation time. The key's index is UNIQUE, so a concurrent
+// writer cannot slip the same quote in as a second row
+const upsertRateQuery = insertRateQuery + `
+	ON CONFLICT (currency_code, source, ` + observedAtExpr + `) DO UPDATE
+	SET rtc_bid = excluded.rtc_bid, rtb_bid = excluded.rtb_bid, rth_bid = excluded.rth_bid,
+		rth_ofr = excluded.rth_ofr, rtc_ofr = excluded.rtc_ofr,
+		response_id = COALESCE(excluded.response_id, response_id)
+	RETURNING id, date_partition
+`
+
+// insertRateArgs returns the insertRateQuery arguments for a rate
+// Unquoted price sides (0), unknown quote times and missing archive links
+// are stored as NULL, and times in UTC so they compare as text
+func insertRateArgs(rate *ExchangeRate) []any {
+	if rate.Source == "" {
+		rate.Source = DefaultSource
+	}
+
+	return []any{
 		rate.CurrencyCode,
+		rate.Source,
 		rate.RtcBid,
-		rate.CollectedAt,
+		nullIfZero(rate.RtbBid),
+		nullIfZero(rate.RthBid),
+		nullIfZero(rate.RthOfr),
+		nullIfZero(rate.RtcOfr),
+		sql.NullTime{Time: rate.QuotedAt.UTC(), Valid: !rate.QuotedAt.IsZero()},
+		rate.CollectedAt.UTC(),
+		sql.NullInt64{Int64: rate.ResponseID, Valid: rate.ResponseID != 0},
 		rate.DatePartition,
-	)
+	}
+}
+
+// nullIfZero maps an unset price to NULL
+func nullIfZero(v fixed.Rate) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(v), Valid: v != 0}
+}
+
+// upsertRate stores a rate unless a row with the same natural key exists,
+// in which case that row takes the rate's prices (and archive link, if any)
+// and keeps its ID and collection time. Either way rate.ID is set, and the
+// rollup of the row written is added to touched
+func upsertRate(ctx context.Context, tx *sql.Tx, rate *ExchangeRate, touched rollupSet) error {
+	args := insertRateArgs(rate)
+	key := rateRollupKey(rate)
+	err := tx.QueryRowContext(ctx, upsertRateQuery, args...).Scan(&rate.ID, &key.date)
 	if err != nil {
-		return fmt.Errorf("inserting rate: %w", err)
+		return fmt.Errorf("storing %s rate: %w", rate.CurrencyCode, err)
 	}
+	touched[key] = true
+	return nil
+}
 
-	id, err := result.LastInsertId()
+// InsertRate stores an exchange rate reading
+// Storing a quote that is already stored updates it instead (see upsertRate),
+// so replays, restarts and concurrent daemons don't create duplicates
+func (r *Repository) InsertRate(ctx context.Context, rate *ExchangeRate) error {
+	return r.InsertRates(ctx, []*ExchangeRate{rate})
+}
+
+// InsertRates stores all readings from a single poll in one transaction,
+// with the same upsert semantics as InsertRate, and refreshes the hourly and
+// daily rollups they count towards
+func (r *Repository) InsertRates(ctx context.Context, rates []*ExchangeRate) error {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("getting insert ID: %w", err)
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	touched := rollupSet{}
+	for _, rate := range rates {
+		if err := upsertRate(ctx, tx, rate, touched); err != nil {
+			return err
+		}
+	}
+
+	if err := touched.refresh(ctx, tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing rates: %w", err)
 	}
 
-	rate.ID = id
 	return nil
 }
 
-// GetLatestRate retrieves the most recent exchange rate
-func (r *Repository) GetLatestRate(ctx context.Context) (*ExchangeRate, error) {
+// GetLatestRate retrieves the most recent exchange rate for a currency
+func (r *Repository) GetLatestRate(ctx context.Context, currency string) (*ExchangeRate, error) {
 	query := `
-		SELECT id, currency_code, rtc_bid, collected_at, date_partition, created_at
+		SELECT ` + rateColumns + `
 		FROM exchange_rates
+		WHERE currency_code = ? AND ` + sourceFilter + `
 		ORDER BY collected_at DESC
 		LIMIT 1
 	`
 
 	var rate ExchangeRate
-	err := r.db.conn.QueryRowContext(ctx, query).Scan(
-		&rate.ID,
-		&rate.CurrencyCode,
-		&rate.RtcBid,
-		&rate.CollectedAt,
-		&rate.DatePartition,
-		&rate.CreatedAt,
-	)
+	err := scanRate(r.db.conn.QueryRowContext(ctx, query, currency, r.source, r.source), &rate)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -87,16 +267,16 @@ func (r *Repository) GetLatestRate(ctx context.Context) (*ExchangeRate, error) {
 	return &rate, nil
 }
 
-// GetRatesByTimeRange retrieves rates within a time range
-func (r *Repository) GetRatesByTimeRange(ctx context.Context, start, end time.Time) ([]ExchangeRate, error) {
+// GetRatesByTimeRange retrieves rates for a currency within a time range
+func (r *Repository) GetRatesByTimeRange(ctx context.Context, currency string, start, end time.Time) ([]ExchangeRate, error) {
 	query := `
-		SELECT id, currency_code, rtc_bid, collected_at, date_partition, created_at
+		SELECT ` + rateColumns + `
 		FROM exchange_rates
-		WHERE collected_at >= ? AND collected_at <= ?
+		WHERE currency_code = ? AND ` + sourceFilter + ` AND collected_at >= ? AND collected_at <= ?
 		ORDER BY collected_at ASC
 	`
 
-	rows, err := r.db.conn.QueryContext(ctx, query, start, end)
+	rows, err := r.db.conn.QueryContext(ctx, query, currency, r.source, r.source, start.UTC(), end.UTC())
 	if err != nil {
 		return nil, fmt.Errorf("querying rates: %w", err)
 	}
@@ -105,15 +285,7 @@ func (r *Repository) GetRatesByTimeRange(ctx context.Context, start, end time.Ti
 	var rates []ExchangeRate
 	for rows.Next() {
 		var rate ExchangeRate
-		err := rows.Scan(
-			&rate.ID,
-			&rate.CurrencyCode,
-			&rate.RtcBid,
-			&rate.CollectedAt,
-			&rate.DatePartition,
-			&rate.CreatedAt,
-		)
-		if err != nil {
+		if err := scanRate(rows, &rate); err != nil {
 			return nil, fmt.Errorf("scanning rate: %w", err)
 		}
 		rates = append(rates, rate)
@@ -126,25 +298,18 @@ func (r *Repository) GetRatesByTimeRange(ctx context.Context, start, end time.Ti
 	return rates, nil
 }
 
-// GetDailyPeak finds the highest rate for a given date
-func (r *Repository) GetDailyPeak(ctx context.Context, date string) (*ExchangeRate, error) {
+// GetDailyPeak finds the highest rate of a currency for a given date
+func (r *Repository) GetDailyPeak(ctx context.Context, currency, date string) (*ExchangeRate, error) {
 	query := `
-		SELECT id, currency_code, rtc_bid, collected_at, date_partition, created_at
+		SELECT ` + rateColumns + `
 		FROM exchange_rates
-		WHERE date_partition = ?
+		WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition = ?
 		ORDER BY rtc_bid DESC
 		LIMIT 1
 	`
 
 	var rate ExchangeRate
-	err := r.db.conn.QueryRowContext(ctx, query, date).Scan(
-		&rate.ID,
-		&rate.CurrencyCode,
-		&rate.RtcBid,
-		&rate.CollectedAt,
-		&rate.DatePartition,
-		&rate.CreatedAt,
-	)
+	err := scanRate(r.db.conn.QueryRowContext(ctx, query, currency, r.source, r.source, date), &rate)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -158,29 +323,32 @@ func (r *Repository) GetDailyPeak(ctx context.Context, date string) (*ExchangeRa
 // DailyStats contains aggregate statistics for a date
 type DailyStats struct {
 	Date        string
-	MinRate     float64
-	MaxRate     float64
-	AvgRate     float64
+	MinRate     fixed.Rate
+	MaxRate     fixed.Rate
+	AvgRate     fixed.Rate
 	PeakTime    time.Time
 	SampleCount int
 }
 
-// GetDailyStats calculates aggregate statistics for a date
-func (r *Repository) GetDailyStats(ctx context.Context, date string) (*DailyStats, error) {
+// GetDailyStats calculates aggregate statistics of a currency for a date
+// It reads the date's daily rollups, one per source, so it also covers dates
+// whose raw data retention has deleted. Unscoped, it merges every source's
+// rollups; scope the repository WithSource for one bank's day
+func (r *Repository) GetDailyStats(ctx context.Context, currency, date string) (*DailyStats, error) {
 	query := `
 		SELECT
-			MIN(rtc_bid) as min_rate,
-			MAX(rtc_bid) as max_rate,
-			AVG(rtc_bid) as avg_rate,
-			COUNT(*) as sample_count
-		FROM exchange_rates
-		WHERE date_partition = ?
+			COALESCE(MIN(min_rate), 0) as min_rate,
+			COALESCE(MAX(max_rate), 0) as max_rate,
+			COALESCE(` + rollupAvg("sum_rate", "sample_count") + `, 0) as avg_rate,
+			COALESCE(SUM(sample_count), 0) as sample_count
+		FROM daily_rates
+		WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition = ?
 	`
 
 	var stats DailyStats
 	stats.Date = date
 
-	err := r.db.conn.QueryRowContext(ctx, query, date).Scan(
+	err := r.db.conn.QueryRowContext(ctx, query, currency, r.source, r.source, date).Scan(
 		&stats.MinRate,
 		&stats.MaxRate,
 		&stats.AvgRate,
@@ -190,15 +358,16 @@ func (r *Repository) GetDailyStats(ctx context.Context, date string) (*DailyStat
 		return nil, fmt.Errorf("querying daily stats: %w", err)
 	}
 
-	// Get peak time separately
+	// Get peak time separately: the earliest of the sources' peaks
 	peakQuery := `
-		SELECT collected_at
-		FROM exchange_rates
-		WHERE date_partition = ? AND rtc_bid = ?
+		SELECT peak_time
+		FROM daily_rates
+		WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition = ?
+		ORDER BY peak_rate DESC, peak_time
 		LIMIT 1
 	`
 
-	err = r.db.conn.QueryRowContext(ctx, peakQuery, date, stats.MaxRate).Scan(&stats.PeakTime)
+	err = r.db.conn.QueryRowContext(ctx, peakQuery, currency, r.source, r.source, date).Scan(&stats.PeakTime)
 	if err != nil && !errors.Is(err, sql.ErrNoRows) {
 		return nil, fmt.Errorf("querying peak time: %w", err)
 	}
@@ -219,29 +388,47 @@ func (r *Repository) Count(ctx context.Context) (int64, error) {
 // HourlyPattern represents statistics for a specific hour of the day
 type HourlyPattern struct {
 	Hour        int
-	AvgRate     float64
-	MinRate     float64
-	MaxRate     float64
+	AvgRate     fixed.Rate
+	MinRate     fixed.Rate
+	MaxRate     fixed.Rate
 	SampleCount int
 	PeakFreq    int // How many times this hour had the daily peak
 }
 
-// GetHourlyPatterns analyzes rate patterns by hour of day over the last N days
-func (r *Repository) GetHourlyPatterns(ctx context.Context, days int) ([]HourlyPattern, error) {
+// GetHourlyPatterns analyzes a currency's rate patterns by hour of day over the last N days
+// It reads hourly rollups: a window over each date finds the daily peak
+// among them, so counting peak hours needs no second pass
+func (r *Repository) GetHourlyPatterns(ctx context.Context, currency string, days int) ([]HourlyPattern, error) {
 	query := `
+		WITH hours AS (
+			SELECT
+				date_partition,
+				hour,
+				SUM(sum_rate) as sum_rate,
+				MIN(min_rate) as min_rate,
+				MAX(max_rate) as max_rate,
+				SUM(sample_count) as sample_count
+			FROM hourly_rates
+			WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition >= ` + marketDaysAgo + `
+			GROUP BY date_partition, hour
+		),
+		peaks AS (
+			SELECT *, MAX(max_rate) OVER (PARTITION BY date_partition) as daily_peak
+			FROM hours
+		)
 		SELECT
-			CAST(strftime('%H', collected_at) AS INTEGER) as hour,
-			AVG(rtc_bid) as avg_rate,
-			MIN(rtc_bid) as min_rate,
-			MAX(rtc_bid) as max_rate,
-			COUNT(*) as sample_count
-		FROM exchange_rates
-		WHERE date_partition >= date('now', '-' || ? || ' days')
+			hour,
+			` + rollupAvg("sum_rate", "sample_count") + ` as avg_rate,
+			MIN(min_rate) as min_rate,
+			MAX(max_rate) as max_rate,
+			SUM(sample_count) as sample_count,
+			COUNT(CASE WHEN max_rate = daily_peak THEN 1 END) as peak_freq
+		FROM peaks
 		GROUP BY hour
 		ORDER BY hour
 	`
 
-	rows, err := r.db.conn.QueryContext(ctx, query, days)
+	rows, err := r.db.conn.QueryContext(ctx, query, currency, r.source, r.source, days)
 	if err != nil {
 		return nil, fmt.Errorf("querying hourly patterns: %w", err)
 	}
@@ -256,6 +443,7 @@ func (r *Repository) GetHourlyPatterns(ctx context.Context, days int) ([]HourlyP
 			&p.MinRate,
 			&p.MaxRate,
 			&p.SampleCount,
+			&p.PeakFreq,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scanning hourly pattern: %w", err)
@@ -267,90 +455,56 @@ func (r *Repository) GetHourlyPatterns(ctx context.Context, days int) ([]HourlyP
 		return nil, fmt.Errorf("iterating hourly patterns: %w", err)
 	}
 
-	// Calculate peak frequency for each hour
-	for i := range patterns {
-		freq, err := r.getHourPeakFrequency(ctx, patterns[i].Hour, days)
-		if err != nil {
-			r.logger.Warn("failed to get peak frequency", "hour", patterns[i].Hour, "error", err)
-		} else {
-			patterns[i].PeakFreq = freq
-		}
-	}
-
 	return patterns, nil
 }
 
-// getHourPeakFrequency counts how many times a given hour had the daily peak
-func (r *Repository) getHourPeakFrequency(ctx context.Context, hour, days int) (int, error) {
-	query := `
-		WITH daily_peaks AS (
-			SELECT
-				date_partition,
-				MAX(rtc_bid) as peak_rate
-			FROM exchange_rates
-			WHERE date_partition >= date('now', '-' || ? || ' days')
-			GROUP BY date_partition
-		)
-		SELECT COUNT(DISTINCT e.date_partition)
-		FROM exchange_rates e
-		INNER JOIN daily_peaks dp ON e.date_partition = dp.date_partition AND e.rtc_bid = dp.peak_rate
-		WHERE CAST(strftime('%H', e.collected_at) AS INTEGER) = ?
-	`
-
-	var count int
-	err := r.db.conn.QueryRowContext(ctx, query, days, hour).Scan(&count)
-	if err != nil {
-		return 0, fmt.Errorf("querying peak frequency: %w", err)
-	}
-
-	return count, nil
-}
+var dayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
 
 // DayOfWeekPattern represents statistics for a specific day of the week
 type DayOfWeekPattern struct {
 	DayOfWeek   int    // 0=Sunday, 1=Monday, etc.
 	DayName     string
-	AvgRate     float64
-	MinRate     float64
-	MaxRate     float64
-	AvgRange    float64
+	AvgRate     fixed.Rate
+	MinRate     fixed.Rate
+	MaxRate     fixed.Rate
+	AvgRange    fixed.Rate
 	SampleDays  int
 }
 
-// GetDayOfWeekPatterns analyzes rate patterns by day of week
-func (r *Repository) GetDayOfWeekPatterns(ctx context.Context, weeks int) ([]DayOfWeekPattern, error) {
+// GetDayOfWeekPatterns analyzes a currency's rate patterns by day of week
+// It reads daily rollups; each market date counts towards its own weekday
+func (r *Repository) GetDayOfWeekPatterns(ctx context.Context, currency string, weeks int) ([]DayOfWeekPattern, error) {
 	query := `
 		WITH daily_data AS (
 			SELECT
 				date_partition,
-				strftime('%w', collected_at) as dow,
-				AVG(rtc_bid) as avg_rate,
-				MIN(rtc_bid) as min_rate,
-				MAX(rtc_bid) as max_rate,
-				(MAX(rtc_bid) - MIN(rtc_bid)) as range
-			FROM exchange_rates
-			WHERE date_partition >= date('now', '-' || ? || ' days')
+				strftime('%w', date_partition) as dow,
+				` + rollupAvg("sum_rate", "sample_count") + ` as avg_rate,
+				MIN(min_rate) as min_rate,
+				MAX(max_rate) as max_rate,
+				(MAX(max_rate) - MIN(min_rate)) as range
+			FROM daily_rates
+			WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition >= ` + marketDaysAgo + `
 			GROUP BY date_partition
 		)
 		SELECT
 			CAST(dow AS INTEGER) as day_of_week,
-			AVG(avg_rate) as avg_rate,
+			` + avgRate("avg_rate") + ` as avg_rate,
 			MIN(min_rate) as min_rate,
 			MAX(max_rate) as max_rate,
-			AVG(range) as avg_range,
+			` + avgRate("range") + ` as avg_range,
 			COUNT(*) as sample_days
 		FROM daily_data
 		GROUP BY dow
 		ORDER BY day_of_week
 	`
 
-	rows, err := r.db.conn.QueryContext(ctx, query, weeks*7)
+	rows, err := r.db.conn.QueryContext(ctx, query, currency, r.source, r.source, weeks*7)
 	if err != nil {
 		return nil, fmt.Errorf("querying day of week patterns: %w", err)
 	}
 	defer rows.Close()
 
-	dayNames := []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
 	var patterns []DayOfWeekPattern
 	for rows.Next() {
 		var p DayOfWeekPattern
@@ -376,117 +530,135 @@ func (r *Repository) GetDayOfWeekPatterns(ctx context.Context, weeks int) ([]Day
 	return patterns, nil
 }
 
-// HourlyRate represents aggregated hourly statistics
-type HourlyRate struct {
-	ID                int64
-	DatePartition     string
-	Hour              int
-	AvgRate           float64
-	MinRate           float64
-	MaxRate           float64
-	SampleCount       int
-	FirstCollectedAt  time.Time
-	LastCollectedAt   time.Time
-	CreatedAt         time.Time
+// SpreadStats summarizes the bank's quoted spreads over a group of samples
+type SpreadStats struct {
+	Label          string     // Date (YYYY-MM-DD), hour ("09:00") or weekday name
+	AvgSpotSpread  fixed.Rate // Spot offer - spot bid
+	MinSpotSpread  fixed.Rate
+	MaxSpotSpread  fixed.Rate
+	AvgCashSpread  fixed.Rate // Cash offer - cash bid
+	MinCashSpread  fixed.Rate
+	MaxCashSpread  fixed.Rate
+	AvgCashPremium fixed.Rate // How much wider the cash spread is than the spot spread
+	SampleCount    int
 }
 
-// DailyRate represents aggregated daily statistics
-type DailyRate struct {
-	ID                int64
-	DatePartition     string
-	AvgRate           float64
-	MinRate           float64
-	MaxRate           float64
-	PeakRate          float64
-	PeakTime          time.Time
-	Volatility        float64
-	SampleCount       int
-	FirstCollectedAt  time.Time
-	LastCollectedAt   time.Time
-	CreatedAt         time.Time
+// GetDailySpreads summarizes a currency's spreads per day over the last N days
+func (r *Repository) GetDailySpreads(ctx context.Context, currency string, days int) ([]SpreadStats, error) {
+	return r.querySpreads(ctx, "date_partition", currency, days, func(key string) string {
+		return key
+	})
 }
 
-// AggregateToHourly creates hourly aggregates from raw data for a specific date
-func (r *Repository) AggregateToHourly(ctx context.Context, datePartition string) (int, error) {
+// GetHourlySpreads summarizes a currency's spreads by hour of day over the last N days
+func (r *Repository) GetHourlySpreads(ctx context.Context, currency string, days int) ([]SpreadStats, error) {
+	return r.querySpreads(ctx, "strftime('%H', "+marketTime(observedAtExpr)+")", currency, days, func(key string) string {
+		return key + ":00"
+	})
+}
+
+// GetDayOfWeekSpreads summarizes a currency's spreads by day of week over the last N weeks
+func (r *Repository) GetDayOfWeekSpreads(ctx context.Context, currency string, weeks int) ([]SpreadStats, error) {
+	return r.querySpreads(ctx, "strftime('%w', "+marketTime(observedAtExpr)+")", currency, weeks*7, func(key string) string {
+		dow, err := strconv.Atoi(key)
+		if err != nil || dow < 0 || dow >= len(dayNames) {
+			return key
+		}
+		return dayNames[dow]
+	})
+}
+
+// querySpreads aggregates spreads grouped by groupExpr, which must be a
+// trusted SQL expression; rows without all four price sides are skipped
+func (r *Repository) querySpreads(ctx context.Context, groupExpr, currency string, days int, label func(string) string) ([]SpreadStats, error) {
 	query := `
-		INSERT OR REPLACE INTO hourly_rates (
-			date_partition, hour, avg_rate, min_rate, max_rate, sample_count,
-			first_collected_at, last_collected_at
-		)
 		SELECT
-			date_partition,
-			CAST(strftime('%H', collected_at) AS INTEGER) as hour,
-			AVG(rtc_bid) as avg_rate,
-			MIN(rtc_bid) as min_rate,
-			MAX(rtc_bid) as max_rate,
-			COUNT(*) as sample_count,
-			MIN(collected_at) as first_collected_at,
-			MAX(collected_at) as last_collected_at
+			` + groupExpr + ` as grp,
+			` + avgRate("rth_ofr - rth_bid") + ` as avg_spot,
+			MIN(rth_ofr - rth_bid) as min_spot,
+			MAX(rth_ofr - rth_bid) as max_spot,
+			` + avgRate("rtc_ofr - rtc_bid") + ` as avg_cash,
+			MIN(rtc_ofr - rtc_bid) as min_cash,
+			MAX(rtc_ofr - rtc_bid) as max_cash,
+			` + avgRate("(rtc_ofr - rtc_bid) - (rth_ofr - rth_bid)") + ` as avg_premium,
+			COUNT(*) as sample_count
 		FROM exchange_rates
-		WHERE date_partition = ?
-		GROUP BY date_partition, hour
+		WHERE currency_code = ? AND ` + sourceFilter + `
+		  AND date_partition >= ` + marketDaysAgo + `
+		  AND rth_bid IS NOT NULL AND rth_ofr IS NOT NULL AND rtc_ofr IS NOT NULL
+		GROUP BY grp
+		ORDER BY grp
 	`
 
-	result, err := r.db.conn.ExecContext(ctx, query, datePartition)
+	rows, err := r.db.conn.QueryContext(ctx, query, currency, r.source, r.source, days)
 	if err != nil {
-		return 0, fmt.Errorf("aggregating to hourly: %w", err)
+		return nil, fmt.Errorf("querying spreads: %w", err)
 	}
+	defer rows.Close()
 
-	rows, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("getting rows affected: %w", err)
+	var spreads []SpreadStats
+	for rows.Next() {
+		var s SpreadStats
+		var key string
+		err := rows.Scan(
+			&key,
+			&s.AvgSpotSpread,
+			&s.MinSpotSpread,
+			&s.MaxSpotSpread,
+			&s.AvgCashSpread,
+			&s.MinCashSpread,
+			&s.MaxCashSpread,
+			&s.AvgCashPremium,
+			&s.SampleCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scanning spread: %w", err)
+		}
+		s.Label = label(key)
+		spreads = append(spreads, s)
 	}
 
-	return int(rows), nil
-}
-
-// AggregateToDaily creates daily aggregates from raw data for a specific date
-func (r *Repository) AggregateToDaily(ctx context.Context, datePartition string) error {
-	// First, find the peak rate and its time
-	var peakRate float64
-	var peakTime string
-	peakQuery := `
-		SELECT rtc_bid, collected_at
-		FROM exchange_rates
-		WHERE date_partition = ?
-		ORDER BY rtc_bid DESC
-		LIMIT 1
-	`
-	err := r.db.conn.QueryRowContext(ctx, peakQuery, datePartition).Scan(&peakRate, &peakTime)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil // No data for this date
-		}
-		return fmt.Errorf("finding peak rate: %w", err)
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating spreads: %w", err)
 	}
 
-	// Then, insert the daily aggregate
-	query := `
-		INSERT OR REPLACE INTO daily_rates (
-			date_partition, avg_rate, min_rate, max_rate, peak_rate, peak_time,
-			volatility, sample_count, first_collected_at, last_collected_at
-		)
-		SELECT
-			date_partition,
-			AVG(rtc_bid) as avg_rate,
-			MIN(rtc_bid) as min_rate,
-			MAX(rtc_bid) as max_rate,
-			? as peak_rate,
-			? as peak_time,
-			(MAX(rtc_bid) - MIN(rtc_bid)) as volatility,
-			COUNT(*) as sample_count,
-			MIN(collected_at) as first_collected_at,
-			MAX(collected_at) as last_collected_at
-		FROM exchange_rates
-		WHERE date_partition = ?
-	`
+	return spreads, nil
+}
 
-	_, err = r.db.conn.ExecContext(ctx, query, peakRate, peakTime, datePartition)
-	if err != nil {
-		return fmt.Errorf("aggregating to daily: %w", err)
-	}
+// HourlyRate represents aggregated hourly statistics (see rollup.go)
+type HourlyRate struct {
+	ID                int64
+	CurrencyCode      string
+	Source            string
+	DatePartition     string
+	Hour              int
+	SumRate           fixed.Rate
+	AvgRate           fixed.Rate
+	MinRate           fixed.Rate
+	MaxRate           fixed.Rate
+	SampleCount       int
+	FirstCollectedAt  time.Time
+	LastCollectedAt   time.Time
+	CreatedAt         time.Time
+}
 
-	return nil
+// DailyRate represents aggregated daily statistics (see rollup.go)
+type DailyRate struct {
+	ID                int64
+	CurrencyCode      string
+	Source            string
+	DatePartition     string
+	SumRate           fixed.Rate
+	AvgRate           fixed.Rate
+	MinRate           fixed.Rate
+	MaxRate           fixed.Rate
+	PeakRate          fixed.Rate
+	PeakTime          time.Time
+	Volatility        fixed.Rate
+	SampleCount       int
+	FirstCollectedAt  time.Time
+	LastCollectedAt   time.Time
+	CreatedAt         time.Time
 }
 
 // DeleteRawDataBefore deletes raw exchange rate data before a specific date
@@ -528,7 +700,7 @@ func (r *Repository) GetOldRawDataDates(ctx context.Context, retentionDays int)
 	query := `
 		SELECT DISTINCT date_partition
 		FROM exchange_rates
-		WHERE date_partition < date('now', '-' || ? || ' days')
+		WHERE date_partition < ` + marketDaysAgo + `
 		ORDER BY date_partition
 	`
 
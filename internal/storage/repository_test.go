@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+func TestHourlyPatternsPeakFrequency(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestDB(t)
+
+	// Three days of two sources, with the daily peak tied across sources,
+	// hours and samples of one hour
+	quotes := []struct {
+		daysAgo int
+		source  string
+		at      string
+		rate    string
+	}{
+		{3, "cmb", "09:10", "7.0800"},
+		{3, "cmb", "09:40", "7.0900"}, // Peak, tied with boc at 14:20
+		{3, "cmb", "14:10", "7.0850"},
+		{3, "boc", "14:20", "7.0900"},
+		{3, "boc", "11:00", "7.0700"},
+		{2, "cmb", "09:05", "7.1000"}, // Peak, twice in one hour
+		{2, "cmb", "09:35", "7.1000"},
+		{2, "cmb", "11:30", "7.0950"},
+		{2, "boc", "14:00", "7.0990"},
+		{1, "cmb", "09:00", "7.0600"},
+		{1, "cmb", "14:45", "7.0700"}, // Peak, tied with boc at 11:15
+		{1, "boc", "14:50", "7.0650"},
+		{1, "boc", "11:15", "7.0700"},
+	}
+	memory := NewMemoryStore()
+	for _, q := range quotes {
+		at, err := time.ParseInLocation("2006-01-02 15:04", market.DaysAgo(q.daysAgo)+" "+q.at, market.Zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, store := range []Store{repo, memory} {
+			err := store.InsertRate(ctx, &ExchangeRate{
+				CurrencyCode: "USD", Source: q.source, RtcBid: fixed.MustParse(q.rate),
+				CollectedAt: at, DatePartition: market.Date(at),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Worked out by hand the way the per-hour queries used to: each date's
+	// peak over the sources seen, and per hour the dates it reached it on
+	pattern := func(hour int, avg, min, max string, samples, peaks int) HourlyPattern {
+		return HourlyPattern{
+			Hour:        hour,
+			AvgRate:     fixed.MustParse(avg),
+			MinRate:     fixed.MustParse(min),
+			MaxRate:     fixed.MustParse(max),
+			SampleCount: samples,
+			PeakFreq:    peaks,
+		}
+	}
+	tests := []struct {
+		source string
+		want   []HourlyPattern
+	}{
+		{"", []HourlyPattern{
+			pattern(9, "7.086", "7.06", "7.10", 5, 2),
+			pattern(11, "7.078333", "7.07", "7.095", 3, 1),
+			pattern(14, "7.0818", "7.065", "7.099", 5, 2),
+		}},
+		{"cmb", []HourlyPattern{
+			pattern(9, "7.086", "7.06", "7.10", 5, 2),
+			pattern(11, "7.095", "7.095", "7.095", 1, 0),
+			pattern(14, "7.0775", "7.07", "7.085", 2, 1),
+		}},
+	}
+
+	for _, tt := range tests {
+		for name, store := range map[string]Store{"Repository": repo, "MemoryStore": memory} {
+			got, err := store.WithSource(tt.source).GetHourlyPatterns(ctx, "USD", 7)
+			if err != nil {
+				t.Fatalf("%s GetHourlyPatterns() error = %v", name, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s source %q GetHourlyPatterns() = %+v, want %+v", name, tt.source, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Rollups summarize exchange_rates per currency, source and market date:
+// hourly_rates per hour of observation, daily_rates per day. Every write to
+// exchange_rates refreshes the rollups it touches in the same transaction, so
+// daily stats and patterns read a row per hour or day instead of every sample,
+// and the summaries outlive the raw rows retention deletes
+
+// rollupKey identifies an hourly rollup; its daily rollup has the same key
+// without the hour
+type rollupKey struct {
+	currency string
+	source   string
+	date     string
+	hour     int
+}
+
+// rollupKeyColumns selects the rollup key of exchange_rates rows
+var rollupKeyColumns = "currency_code, source, date_partition, CAST(strftime('%H', " + marketTime(observedAtExpr) + ") AS INTEGER)"
+
+// rateRollupKey returns the rollup a stored rate counts towards
+func rateRollupKey(rate *ExchangeRate) rollupKey {
+	return rollupKey{
+		currency: rate.CurrencyCode,
+		source:   rate.Source,
+		date:     rate.DatePartition,
+		hour:     observedHour(*rate),
+	}
+}
+
+// rollupSet collects the rollups a write touches
+type rollupSet map[rollupKey]bool
+
+// addRows marks the rollups of rows selected (or returned) with
+// rollupKeyColumns and returns the number of rows read
+func (s rollupSet) addRows(rows *sql.Rows) (int64, error) {
+	defer rows.Close()
+	var n int64
+	for rows.Next() {
+		var key rollupKey
+		if err := rows.Scan(&key.currency, &key.source, &key.date, &key.hour); err != nil {
+			return n, fmt.Errorf("scanning rollup key: %w", err)
+		}
+		s[key] = true
+		n++
+	}
+	return n, rows.Err()
+}
+
+// days returns the number of daily rollups in the set
+func (s rollupSet) days() int {
+	days := make(map[rollupKey]bool)
+	for key := range s {
+		key.hour = 0
+		days[key] = true
+	}
+	return len(days)
+}
+
+// refresh recomputes every hourly rollup in the set, and the daily rollups
+// of their dates, from the raw rows. A rollup whose rows are all gone is
+// removed
+func (s rollupSet) refresh(ctx context.Context, tx *sql.Tx) error {
+	days := make(map[rollupKey]bool)
+	for key := range s {
+		if err := refreshHourly(ctx, tx, key); err != nil {
+			return err
+		}
+		key.hour = 0
+		days[key] = true
+	}
+	for key := range days {
+		if err := refreshDaily(ctx, tx, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshHourly recomputes one hourly rollup
+func refreshHourly(ctx context.Context, tx *sql.Tx, key rollupKey) error {
+	const deleteQuery = `
+		DELETE FROM hourly_rates
+		WHERE currency_code = ? AND source = ? AND date_partition = ? AND hour = ?
+	`
+	insertQuery := `
+		INSERT INTO hourly_rates (
+			currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+			sample_count, first_collected_at, last_collected_at
+		)
+		SELECT
+			currency_code,
+			source,
+			date_partition,
+			? as hour,
+			SUM(rtc_bid) as sum_rate,
+			` + avgRate("rtc_bid") + ` as avg_rate,
+			MIN(rtc_bid) as min_rate,
+			MAX(rtc_bid) as max_rate,
+			COUNT(*) as sample_count,
+			MIN(collected_at) as first_collected_at,
+			MAX(collected_at) as last_collected_at
+		FROM exchange_rates
+		WHERE currency_code = ? AND source = ? AND date_partition = ?
+		  AND CAST(strftime('%H', ` + marketTime(observedAtExpr) + `) AS INTEGER) = ?
+		GROUP BY currency_code, source, date_partition
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, key.currency, key.source, key.date, key.hour); err != nil {
+		return fmt.Errorf("clearing hourly rollup: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insertQuery, key.hour, key.currency, key.source, key.date, key.hour); err != nil {
+		return fmt.Errorf("rolling up %s %s %s %02d:00: %w", key.source, key.currency, key.date, key.hour, err)
+	}
+	return nil
+}
+
+// refreshDaily recomputes one daily rollup
+// The peak time is when the first row collected at the day's highest rate
+// was observed, as GetDailyStats reports it
+func refreshDaily(ctx context.Context, tx *sql.Tx, key rollupKey) error {
+	const deleteQuery = `
+		DELETE FROM daily_rates
+		WHERE currency_code = ? AND source = ? AND date_partition = ?
+	`
+	insertQuery := `
+		INSERT INTO daily_rates (
+			currency_code, source, date_partition, sum_rate, avg_rate, min_rate, max_rate, peak_rate,
+			peak_time, volatility, sample_count, first_collected_at, last_collected_at
+		)
+		SELECT
+			currency_code,
+			source,
+			date_partition,
+			SUM(rtc_bid) as sum_rate,
+			` + avgRate("rtc_bid") + ` as avg_rate,
+			MIN(rtc_bid) as min_rate,
+			MAX(rtc_bid) as max_rate,
+			MAX(rtc_bid) as peak_rate,
+			(SELECT ` + observedAtExpr + `
+			 FROM exchange_rates p
+			 WHERE p.currency_code = e.currency_code AND p.source = e.source AND p.date_partition = e.date_partition
+			 ORDER BY p.rtc_bid DESC, p.collected_at, p.id
+			 LIMIT 1) as peak_time,
+			(MAX(rtc_bid) - MIN(rtc_bid)) as volatility,
+			COUNT(*) as sample_count,
+			MIN(collected_at) as first_collected_at,
+			MAX(collected_at) as last_collected_at
+		FROM exchange_rates e
+		WHERE currency_code = ? AND source = ? AND date_partition = ?
+		GROUP BY currency_code, source, date_partition
+	`
+
+	if _, err := tx.ExecContext(ctx, deleteQuery, key.currency, key.source, key.date); err != nil {
+		return fmt.Errorf("clearing daily rollup: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, insertQuery, key.currency, key.source, key.date); err != nil {
+		return fmt.Errorf("rolling up %s %s %s: %w", key.source, key.currency, key.date, err)
+	}
+	return nil
+}
+
+// RebuildRollups recomputes the rollups of every date from start to end
+// (YYYY-MM-DD, inclusive) that has raw data, in one transaction
+// It is idempotent; rollups of dates whose raw data is gone are kept as they
+// are. Returns the number of hourly and daily rollups refreshed
+func (r *Repository) RebuildRollups(ctx context.Context, start, end string) (hours, days int, err error) {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT DISTINCT `+rollupKeyColumns+`
+		FROM exchange_rates
+		WHERE date_partition BETWEEN ? AND ? AND `+sourceFilter,
+		start, end, r.source, r.source)
+	if err != nil {
+		return 0, 0, fmt.Errorf("querying rollup keys: %w", err)
+	}
+	touched := rollupSet{}
+	if _, err := touched.addRows(rows); err != nil {
+		return 0, 0, err
+	}
+
+	// Hours left without raw rows (e.g. after a reparse) lose their rollups
+	stale, err := tx.QueryContext(ctx, `
+		SELECT h.currency_code, h.source, h.date_partition, h.hour
+		FROM hourly_rates h
+		WHERE h.date_partition BETWEEN ? AND ? AND (? = '' OR h.source = ?)
+		  AND EXISTS (
+			SELECT 1 FROM exchange_rates e
+			WHERE e.currency_code = h.currency_code AND e.source = h.source AND e.date_partition = h.date_partition
+		  )`,
+		start, end, r.source, r.source)
+	if err != nil {
+		return 0, 0, fmt.Errorf("querying stale rollups: %w", err)
+	}
+	if _, err := touched.addRows(stale); err != nil {
+		return 0, 0, err
+	}
+
+	if err := touched.refresh(ctx, tx); err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("committing rollups: %w", err)
+	}
+
+	return len(touched), touched.days(), nil
+}
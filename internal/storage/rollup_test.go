@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// rollupRows returns every rollup row, less IDs and creation times
+func rollupRows(t *testing.T, db *DB) []string {
+	t.Helper()
+	rows, err := db.conn.Query(`
+		SELECT 'hourly', currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+			0, '', sample_count, CAST(first_collected_at AS TEXT), CAST(last_collected_at AS TEXT)
+		FROM hourly_rates
+		UNION ALL
+		SELECT 'daily', currency_code, source, date_partition, -1, sum_rate, avg_rate, min_rate, max_rate,
+			peak_rate, CAST(peak_time AS TEXT), sample_count, CAST(first_collected_at AS TEXT), CAST(last_collected_at AS TEXT)
+		FROM daily_rates
+		ORDER BY 1, 2, 3, 4, 5
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var out []string
+	for rows.Next() {
+		cols := make([]any, 14)
+		for i := range cols {
+			cols[i] = new(any)
+		}
+		if err := rows.Scan(cols...); err != nil {
+			t.Fatal(err)
+		}
+		line := ""
+		for _, c := range cols {
+			line += fmt.Sprintf("%v|", *(c.(*any)))
+		}
+		out = append(out, line)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// assertRollupsFresh checks the maintained rollups against a full rebuild
+func assertRollupsFresh(t *testing.T, db *DB, repo *Repository) {
+	t.Helper()
+	maintained := rollupRows(t, db)
+	if len(maintained) == 0 {
+		t.Fatal("no rollups maintained")
+	}
+	if _, _, err := repo.RebuildRollups(context.Background(), "0000-01-01", "9999-12-31"); err != nil {
+		t.Fatalf("RebuildRollups() error = %v", err)
+	}
+	if rebuilt := rollupRows(t, db); !reflect.DeepEqual(maintained, rebuilt) {
+		t.Errorf("maintained rollups differ from a rebuild:\n%v\nwant\n%v", maintained, rebuilt)
+	}
+}
+
+func TestRollupsFollowWrites(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	at := time.Date(2025, 11, 24, 1, 0, 0, 0, time.UTC) // 09:00 in Beijing
+
+	// A day of quotes from two sources
+	var rates []*ExchangeRate
+	for i := 0; i < 90; i++ {
+		for _, source := range []string{"cmb", "boc"} {
+			rate := testRate(at.Add(time.Duration(i) * 7 * time.Minute))
+			rate.Source = source
+			rate.QuotedAt = rate.CollectedAt.Add(-20 * time.Second)
+			rate.DatePartition = market.Date(rate.CollectedAt)
+			rate.RtcBid += fixed.Rate(i%13) * 100
+			rates = append(rates, rate)
+		}
+	}
+	if err := repo.InsertRates(ctx, rates); err != nil {
+		t.Fatal(err)
+	}
+	assertRollupsFresh(t, db, repo)
+
+	// A repeated quote with a corrected price updates its rollup
+	again := *rates[0]
+	again.ID = 0
+	again.RtcBid = fixed.MustParse("7.2000")
+	if err := repo.InsertRate(ctx, &again); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := repo.GetDailyStats(ctx, "USD", "2025-11-24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.MaxRate != again.RtcBid || !stats.PeakTime.Equal(again.QuotedAt) || stats.SampleCount != len(rates) {
+		t.Errorf("GetDailyStats() = %+v, want the corrected peak at %s over %d samples", stats, again.QuotedAt, len(rates))
+	}
+	assertRollupsFresh(t, db, repo)
+
+	// Reparsing replaces an archived response's rates
+	resp := &RawResponse{Source: "cmb", CollectedAt: at, DatePartition: "2025-11-24", Checksum: "x", Body: []byte("{}")}
+	if err := repo.InsertRawResponse(ctx, resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.conn.Exec("UPDATE exchange_rates SET response_id = ? WHERE source = 'cmb' AND id <= 20", resp.ID); err != nil {
+		t.Fatal(err)
+	}
+	reparsed := testRate(at.Add(5 * time.Minute))
+	reparsed.ResponseID = resp.ID
+	reparsed.DatePartition = market.Date(reparsed.CollectedAt)
+	if _, err := repo.ReplaceArchivedRates(ctx, []int64{resp.ID}, []*ExchangeRate{reparsed}); err != nil {
+		t.Fatal(err)
+	}
+	assertRollupsFresh(t, db, repo)
+
+	// Deduplication collapses copies stored without the natural key lookup
+	dup := testRate(at.Add(3 * time.Hour))
+	dup.DatePartition = market.Date(dup.CollectedAt)
+	insertDuplicate(t, db, dup)
+	insertDuplicate(t, db, dup)
+	if _, _, err := repo.RemoveDuplicates(ctx); err != nil {
+		t.Fatal(err)
+	}
+	restoreUniqueKey(t, db)
+	assertRollupsFresh(t, db, repo)
+}
+
+func TestRollupsOutliveRawData(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestDB(t)
+	day := time.Date(2025, 11, 24, 0, 0, 0, 0, market.Zone)
+
+	for hour := 9; hour < 22; hour++ {
+		for minute := 0; minute < 60; minute += 15 {
+			rate := testRate(day.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute))
+			rate.DatePartition = market.Date(rate.CollectedAt)
+			rate.RtcBid += fixed.Rate(hour*minute) * 10
+			if err := repo.InsertRate(ctx, rate); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	before, err := repo.GetDailyStats(ctx, "USD", "2025-11-24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.SampleCount != 13*4 || before.PeakTime.In(market.Zone).Hour() != 21 {
+		t.Fatalf("GetDailyStats() = %+v, want 52 samples peaking at 21:45", before)
+	}
+
+	if _, err := repo.DeleteRawDataBefore(ctx, "2025-11-25"); err != nil {
+		t.Fatal(err)
+	}
+	after, err := repo.GetDailyStats(ctx, "USD", "2025-11-24")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, after) {
+		t.Errorf("GetDailyStats() after deleting raw data = %+v, want %+v", after, before)
+	}
+
+	// Rebuilding a date without raw data keeps its rollups
+	if hours, days, err := repo.RebuildRollups(ctx, "2025-11-24", "2025-11-24"); err != nil || hours != 0 || days != 0 {
+		t.Errorf("RebuildRollups() = %d, %d, %v; want nothing to rebuild", hours, days, err)
+	}
+	if again, _ := repo.GetDailyStats(ctx, "USD", "2025-11-24"); !reflect.DeepEqual(before, again) {
+		t.Errorf("GetDailyStats() after rebuilding = %+v, want %+v", again, before)
+	}
+}
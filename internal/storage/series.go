@@ -0,0 +1,366 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+// Tier is a level of detail rates are kept at: raw quotes, then hourly and
+// daily rollups. Retention deletes raw quotes first and hourly rollups next,
+// so older dates are only kept at coarser tiers
+type Tier string
+
+const (
+	TierRaw    Tier = "raw"
+	TierHourly Tier = "hourly"
+	TierDaily  Tier = "daily"
+)
+
+// tiers lists the tiers from finest to coarsest
+var tiers = []Tier{TierRaw, TierHourly, TierDaily}
+
+// ParseTier parses a tier name (raw, hourly or daily)
+func ParseTier(s string) (Tier, error) {
+	for _, tier := range tiers {
+		if string(tier) == s {
+			return tier, nil
+		}
+	}
+	return "", fmt.Errorf("unknown resolution %q (want raw, hourly or daily)", s)
+}
+
+// finerThan reports whether t keeps more detail than other
+func (t Tier) finerThan(other Tier) bool {
+	return t.rank() < other.rank()
+}
+
+func (t Tier) rank() int {
+	for i, tier := range tiers {
+		if tier == t {
+			return i
+		}
+	}
+	return len(tiers)
+}
+
+// SeriesPoint is a rate of a source at some tier: a raw quote, or the
+// summary of an hour or a market day of quotes
+type SeriesPoint struct {
+	Time          time.Time // Observation time (raw), or start of the market hour or day
+	Tier          Tier
+	CurrencyCode  string
+	Source        string
+	DatePartition string
+	SumRate       fixed.Rate // Sum of the prices summarized
+	AvgRate       fixed.Rate // The quote's cash bid (raw), or the average
+	MinRate       fixed.Rate
+	MaxRate       fixed.Rate
+	SampleCount   int
+}
+
+// within reports whether a point overlaps start to end; a raw point is an
+// instant, a rollup spans its hour or day
+func (p SeriesPoint) within(start, end time.Time) bool {
+	switch {
+	case p.Time.After(end):
+		return false
+	case p.Tier == TierHourly:
+		return p.Time.Add(time.Hour).After(start)
+	case p.Tier == TierDaily:
+		return p.Time.AddDate(0, 0, 1).After(start)
+	}
+	return !p.Time.Before(start)
+}
+
+// rawPoint returns a quote as a raw point
+func rawPoint(rate ExchangeRate) SeriesPoint {
+	return SeriesPoint{
+		Time:          rate.ObservedAt(),
+		Tier:          TierRaw,
+		CurrencyCode:  rate.CurrencyCode,
+		Source:        rate.Source,
+		DatePartition: rate.DatePartition,
+		SumRate:       rate.RtcBid,
+		AvgRate:       rate.RtcBid,
+		MinRate:       rate.RtcBid,
+		MaxRate:       rate.RtcBid,
+		SampleCount:   1,
+	}
+}
+
+// rollupTime returns when the rollup of a market date and hour starts
+func rollupTime(date string, hour int) (time.Time, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, market.Zone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parsing rollup date: %w", err)
+	}
+	return day.Add(time.Duration(hour) * time.Hour), nil
+}
+
+// stitch picks, for each source and market date, the finest tier no finer
+// than resolution that has points for it, and returns those points that
+// overlap start to end, in time order. points holds every point of the
+// dates, whatever their time, so a date counts as kept at a tier even when
+// none of its points fall in the range
+func stitch(points []SeriesPoint, start, end time.Time, resolution Tier) []SeriesPoint {
+	type day struct{ source, date string }
+	served := make(map[day]Tier)
+	for _, p := range points {
+		if p.Tier.finerThan(resolution) {
+			continue
+		}
+		key := day{p.Source, p.DatePartition}
+		if tier, ok := served[key]; !ok || p.Tier.finerThan(tier) {
+			served[key] = p.Tier
+		}
+	}
+
+	var series []SeriesPoint
+	for _, p := range points {
+		if served[day{p.Source, p.DatePartition}] == p.Tier && p.within(start, end) {
+			series = append(series, p)
+		}
+	}
+
+	sort.SliceStable(series, func(i, j int) bool {
+		if !series[i].Time.Equal(series[j].Time) {
+			return series[i].Time.Before(series[j].Time)
+		}
+		return series[i].Source < series[j].Source
+	})
+	return series
+}
+
+// GetSeries returns a currency's rates from start to end at a resolution,
+// stitched across tiers: each source's market date comes from the finest
+// tier no finer than resolution that still has it. Raw points are quotes
+// observed in the range; rollup points are the hours or days overlapping it
+func (r *Repository) GetSeries(ctx context.Context, currency string, start, end time.Time, resolution Tier) ([]SeriesPoint, error) {
+	first, last := market.Date(start), market.Date(end)
+	var points []SeriesPoint
+
+	if resolution == TierRaw {
+		query := `
+			SELECT ` + rateColumns + `
+			FROM exchange_rates
+			WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition BETWEEN ? AND ?
+		`
+		rows, err := r.db.conn.QueryContext(ctx, query, currency, r.source, r.source, first, last)
+		if err != nil {
+			return nil, fmt.Errorf("querying raw rates: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var rate ExchangeRate
+			if err := scanRate(rows, &rate); err != nil {
+				return nil, fmt.Errorf("scanning rate: %w", err)
+			}
+			points = append(points, rawPoint(rate))
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("iterating rates: %w", err)
+		}
+	}
+
+	// Daily rollups start at midnight, hour 0
+	query := `
+		SELECT 'daily', source, date_partition, 0, sum_rate, avg_rate, min_rate, max_rate, sample_count
+		FROM daily_rates
+		WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition BETWEEN ? AND ?
+	`
+	args := []any{currency, r.source, r.source, first, last}
+	if resolution != TierDaily {
+		query += `
+		UNION ALL
+		SELECT 'hourly', source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate, sample_count
+		FROM hourly_rates
+		WHERE currency_code = ? AND ` + sourceFilter + ` AND date_partition BETWEEN ? AND ?
+		`
+		args = append(args, args...)
+	}
+
+	rows, err := r.db.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying rollups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := SeriesPoint{CurrencyCode: currency}
+		var hour int
+		err := rows.Scan(&p.Tier, &p.Source, &p.DatePartition, &hour,
+			&p.SumRate, &p.AvgRate, &p.MinRate, &p.MaxRate, &p.SampleCount)
+		if err != nil {
+			return nil, fmt.Errorf("scanning rollup: %w", err)
+		}
+		if p.Time, err = rollupTime(p.DatePartition, hour); err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating rollups: %w", err)
+	}
+
+	return stitch(points, start, end, resolution), nil
+}
+
+// CombineSources merges the points of different sources that cover the same
+// time at the same tier, as the rollup queries merge sources. A merged
+// point's source is empty
+func CombineSources(points []SeriesPoint) []SeriesPoint {
+	type slot struct {
+		tier Tier
+		at   int64
+	}
+	index := make(map[slot]int)
+
+	var combined []SeriesPoint
+	for _, p := range points {
+		key := slot{p.Tier, p.Time.UnixNano()}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(combined)
+			combined = append(combined, p)
+			continue
+		}
+
+		c := &combined[i]
+		if c.Source != p.Source {
+			c.Source = ""
+		}
+		c.SumRate += p.SumRate
+		c.SampleCount += p.SampleCount
+		c.AvgRate = fixed.MeanOf(c.SumRate, c.SampleCount)
+		c.MinRate = min(c.MinRate, p.MinRate)
+		c.MaxRate = max(c.MaxRate, p.MaxRate)
+	}
+	return combined
+}
+
+// HourlyPatternsOf computes hourly patterns, as GetHourlyPatterns does, from
+// the raw and hourly points of a series; daily points have no hour and are
+// skipped
+func HourlyPatternsOf(points []SeriesPoint) []HourlyPattern {
+	type hourKey struct {
+		date string
+		hour int
+	}
+	hours := make(map[hourKey]*SeriesPoint)
+	var keys []hourKey
+	for _, p := range points {
+		if p.Tier == TierDaily {
+			continue
+		}
+		key := hourKey{p.DatePartition, p.Time.In(market.Zone).Hour()}
+		h, ok := hours[key]
+		if !ok {
+			h = &SeriesPoint{MinRate: p.MinRate, MaxRate: p.MaxRate}
+			hours[key] = h
+			keys = append(keys, key)
+		}
+		h.SumRate += p.SumRate
+		h.SampleCount += p.SampleCount
+		h.MinRate = min(h.MinRate, p.MinRate)
+		h.MaxRate = max(h.MaxRate, p.MaxRate)
+	}
+
+	dailyPeaks := make(map[string]fixed.Rate)
+	for _, key := range keys {
+		dailyPeaks[key.date] = max(dailyPeaks[key.date], hours[key].MaxRate)
+	}
+
+	byHour := make(map[int]*HourlyPattern)
+	sums := make(map[int]fixed.Rate)
+	for _, key := range keys {
+		h := hours[key]
+		p, ok := byHour[key.hour]
+		if !ok {
+			p = &HourlyPattern{Hour: key.hour, MinRate: h.MinRate, MaxRate: h.MaxRate}
+			byHour[key.hour] = p
+		}
+		sums[key.hour] += h.SumRate
+		p.SampleCount += h.SampleCount
+		p.MinRate = min(p.MinRate, h.MinRate)
+		p.MaxRate = max(p.MaxRate, h.MaxRate)
+		if h.MaxRate == dailyPeaks[key.date] {
+			p.PeakFreq++
+		}
+	}
+
+	var patterns []HourlyPattern
+	for hour := 0; hour < 24; hour++ {
+		if p, ok := byHour[hour]; ok {
+			p.AvgRate = fixed.MeanOf(sums[hour], p.SampleCount)
+			patterns = append(patterns, *p)
+		}
+	}
+	return patterns
+}
+
+// DayOfWeekPatternsOf computes day of week patterns, as GetDayOfWeekPatterns
+// does, from the points of a series at any tier
+func DayOfWeekPatternsOf(points []SeriesPoint) []DayOfWeekPattern {
+	dates := make(map[string]*SeriesPoint)
+	for _, p := range points {
+		d, ok := dates[p.DatePartition]
+		if !ok {
+			d = &SeriesPoint{DatePartition: p.DatePartition, MinRate: p.MinRate, MaxRate: p.MaxRate}
+			dates[p.DatePartition] = d
+		}
+		d.SumRate += p.SumRate
+		d.SampleCount += p.SampleCount
+		d.MinRate = min(d.MinRate, p.MinRate)
+		d.MaxRate = max(d.MaxRate, p.MaxRate)
+	}
+
+	type dailyData struct {
+		avg, min, max, spread fixed.Rate
+	}
+	byDay := make(map[int][]dailyData)
+	for date, d := range dates {
+		day, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			continue
+		}
+		dow := int(day.Weekday())
+		byDay[dow] = append(byDay[dow], dailyData{
+			avg:    fixed.MeanOf(d.SumRate, d.SampleCount),
+			min:    d.MinRate,
+			max:    d.MaxRate,
+			spread: d.MaxRate - d.MinRate,
+		})
+	}
+
+	var patterns []DayOfWeekPattern
+	for dow := range dayNames {
+		days, ok := byDay[dow]
+		if !ok {
+			continue
+		}
+
+		p := DayOfWeekPattern{DayOfWeek: dow, DayName: dayNames[dow], SampleDays: len(days)}
+		avgs := make([]fixed.Rate, 0, len(days))
+		spreads := make([]fixed.Rate, 0, len(days))
+		for i, d := range days {
+			avgs = append(avgs, d.avg)
+			spreads = append(spreads, d.spread)
+			if i == 0 || d.min < p.MinRate {
+				p.MinRate = d.min
+			}
+			if i == 0 || d.max > p.MaxRate {
+				p.MaxRate = d.max
+			}
+		}
+		p.AvgRate = fixed.Mean(avgs)
+		p.AvgRange = fixed.Mean(spreads)
+		patterns = append(patterns, p)
+	}
+	return patterns
+}
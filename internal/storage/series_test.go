@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+func TestSeriesStitchesTiers(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	first := time.Date(2025, 11, 20, 0, 0, 0, 0, market.Zone)
+
+	// Three days of quotes every 20 minutes from 09:00 to 16:40
+	for day := 0; day < 3; day++ {
+		for hour := 9; hour < 17; hour++ {
+			for minute := 0; minute < 60; minute += 20 {
+				rate := testRate(first.AddDate(0, 0, day).Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute))
+				rate.DatePartition = market.Date(rate.CollectedAt)
+				rate.RtcBid += fixed.Rate(day*100 + minute)
+				if err := repo.InsertRate(ctx, rate); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	// Retention keeps the first day daily and the second hourly
+	if _, err := repo.DeleteHourlyDataBefore(ctx, "2025-11-21"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.DeleteRawDataBefore(ctx, "2025-11-22"); err != nil {
+		t.Fatal(err)
+	}
+
+	last := first.AddDate(0, 0, 3).Add(-time.Second)
+	tiersOf := func(series []SeriesPoint) map[Tier]int {
+		samples := make(map[Tier]int)
+		for i, p := range series {
+			samples[p.Tier] += p.SampleCount
+			if i > 0 && p.Time.Before(series[i-1].Time) {
+				t.Errorf("point %d at %s comes after %s", i, p.Time, series[i-1].Time)
+			}
+		}
+		return samples
+	}
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		resolution Tier
+		want       map[Tier]int
+	}{
+		{"raw", first, last, TierRaw, map[Tier]int{TierDaily: 24, TierHourly: 24, TierRaw: 24}},
+		{"hourly", first, last, TierHourly, map[Tier]int{TierDaily: 24, TierHourly: 48}},
+		{"daily", first, last, TierDaily, map[Tier]int{TierDaily: 72}},
+		// Raw points fall in the range; hours and days overlap it
+		{"raw/partial", first.AddDate(0, 0, 1).Add(10*time.Hour + 30*time.Minute), first.AddDate(0, 0, 2).Add(11 * time.Hour),
+			TierRaw, map[Tier]int{TierHourly: 21, TierRaw: 7}},
+		{"empty", first.AddDate(0, 0, 5), first.AddDate(0, 0, 6), TierRaw, map[Tier]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			series, err := repo.GetSeries(ctx, "USD", tt.start, tt.end, tt.resolution)
+			if err != nil {
+				t.Fatalf("GetSeries() error = %v", err)
+			}
+			if got := tiersOf(series); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetSeries() samples per tier = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The all_rates view stitches the tiers the same way, a row per point
+	rows, err := db.conn.Query("SELECT tier, COUNT(*) FROM all_rates GROUP BY tier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	view := make(map[string]int)
+	for rows.Next() {
+		var tier string
+		var n int
+		if err := rows.Scan(&tier, &n); err != nil {
+			t.Fatal(err)
+		}
+		view[tier] = n
+	}
+	if want := map[string]int{"daily": 1, "hourly": 8, "raw": 24}; !reflect.DeepEqual(view, want) {
+		t.Errorf("all_rates rows per tier = %v, want %v", view, want)
+	}
+}
+
+func TestSeriesPatternsMatchQueries(t *testing.T) {
+	ctx := context.Background()
+	_, repo := newTestDB(t)
+	seedStore(t, repo)
+
+	since, err := time.ParseInLocation("2006-01-02", market.DaysAgo(7), market.Zone)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{"", "boc"} {
+		store := repo.WithSource(source)
+
+		hourly, err := store.GetSeries(ctx, "USD", since, time.Now(), TierHourly)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := store.GetHourlyPatterns(ctx, "USD", 7)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := HourlyPatternsOf(CombineSources(hourly)); !reflect.DeepEqual(got, want) {
+			t.Errorf("HourlyPatternsOf(%q) = %+v, want %+v", source, got, want)
+		}
+
+		daily, err := store.GetSeries(ctx, "USD", since, time.Now(), TierDaily)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantDays, err := store.GetDayOfWeekPatterns(ctx, "USD", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := DayOfWeekPatternsOf(daily); !reflect.DeepEqual(got, wantDays) {
+			t.Errorf("DayOfWeekPatternsOf(%q) = %+v, want %+v", source, got, wantDays)
+		}
+	}
+}
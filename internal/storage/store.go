@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// RateReader queries stored quotes and the statistics derived from them
+type RateReader interface {
+	GetLatestRate(ctx context.Context, currency string) (*ExchangeRate, error)
+	GetRatesByTimeRange(ctx context.Context, currency string, start, end time.Time) ([]ExchangeRate, error)
+	GetDailyPeak(ctx context.Context, currency, date string) (*ExchangeRate, error)
+	GetDailyStats(ctx context.Context, currency, date string) (*DailyStats, error)
+	GetSeries(ctx context.Context, currency string, start, end time.Time, resolution Tier) ([]SeriesPoint, error)
+	GetHourlyPatterns(ctx context.Context, currency string, days int) ([]HourlyPattern, error)
+	GetDayOfWeekPatterns(ctx context.Context, currency string, weeks int) ([]DayOfWeekPattern, error)
+	GetDailySpreads(ctx context.Context, currency string, days int) ([]SpreadStats, error)
+	GetHourlySpreads(ctx context.Context, currency string, days int) ([]SpreadStats, error)
+	GetDayOfWeekSpreads(ctx context.Context, currency string, weeks int) ([]SpreadStats, error)
+	Count(ctx context.Context) (int64, error)
+	GetPollTimes(ctx context.Context, start, end time.Time) ([]time.Time, error)
+	GetAnnotations(ctx context.Context, currency string, start, end time.Time) ([]Annotation, error)
+}
+
+// RateWriter stores quotes and logs the polls they came from
+type RateWriter interface {
+	InsertRate(ctx context.Context, rate *ExchangeRate) error
+	InsertRates(ctx context.Context, rates []*ExchangeRate) error
+	RecordPoll(ctx context.Context, poll *Poll) error
+}
+
+// Archive stores raw API responses and rebuilds the rates extracted from them
+type Archive interface {
+	InsertRawResponse(ctx context.Context, resp *RawResponse) error
+	GetArchiveDates(ctx context.Context, start, end string) ([]string, error)
+	GetRawResponsesForDate(ctx context.Context, date string) ([]RawResponse, error)
+	CountArchivedRates(ctx context.Context, date string) (int64, error)
+	ReplaceArchivedRates(ctx context.Context, responseIDs []int64, rates []*ExchangeRate) (int64, error)
+}
+
+// Annotator pins notes to points and ranges of time and reads them back
+type Annotator interface {
+	AddAnnotation(ctx context.Context, a *Annotation) error
+	GetAnnotations(ctx context.Context, currency string, start, end time.Time) ([]Annotation, error)
+	DeleteAnnotation(ctx context.Context, id int64) error
+}
+
+// Ledger records the conversions actually made, for scoring the rates they
+// achieved
+type Ledger interface {
+	AddConversion(ctx context.Context, c *Conversion) error
+	GetConversions(ctx context.Context, currency string, start, end time.Time) ([]Conversion, error)
+	DeleteConversion(ctx context.Context, id int64) error
+}
+
+// Importer loads historical quotes and rollups in batches
+// Only Repository implements it: MemoryStore derives its rollups from the
+// quotes it holds, so it has nowhere to keep rollups of dates without them
+type Importer interface {
+	ImportBatch(ctx context.Context, rates []*ExchangeRate, rollups []SeriesPoint, dryRun bool) (ImportStats, error)
+}
+
+// Store is everything the poller needs from storage
+// Repository implements it on SQLite and MemoryStore in memory
+type Store interface {
+	RateReader
+	RateWriter
+	Archive
+	Annotator
+
+	// WithSource returns a store whose queries only see rows from a source
+	// An empty source returns a store that sees every source
+	WithSource(source string) Store
+
+	// Source returns the source the store is restricted to (empty: all)
+	Source() string
+}
+
+var (
+	_ Store    = (*Repository)(nil)
+	_ Store    = (*MemoryStore)(nil)
+	_ Importer = (*Repository)(nil)
+	_ Ledger   = (*Repository)(nil)
+	_ Ledger   = (*MemoryStore)(nil)
+)
@@ -0,0 +1,446 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// schemaUpgrade is a schema change made before versioned migrations existed,
+// guarded by a check on the live schema (SQLite has no way to drop a CHECK
+// constraint or to add a column only if it is missing)
+// The upgrades only run when adopting an unversioned database (see adoptLegacy);
+// new schema changes belong in migrations/
+type schemaUpgrade struct {
+	name   string
+	needed func(ctx context.Context, tx *sql.Tx) (bool, error)
+	stmts  string
+}
+
+var schemaUpgrades = []schemaUpgrade{
+	{
+		name: "exchange_rates: allow all currencies",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			ddl, err := tableSQL(ctx, tx, "exchange_rates")
+			return strings.Contains(ddl, "IN ('USD')"), err
+		},
+		stmts: `
+			CREATE TABLE exchange_rates_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				currency_code TEXT NOT NULL DEFAULT 'USD',
+				rtc_bid REAL NOT NULL,
+				collected_at TIMESTAMP NOT NULL,
+				date_partition TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+				CHECK (rtc_bid > 0),
+				CHECK (length(currency_code) = 3)
+			);
+
+			INSERT INTO exchange_rates_new (id, currency_code, rtc_bid, collected_at, date_partition, created_at)
+			SELECT id, currency_code, rtc_bid, collected_at, date_partition, created_at
+			FROM exchange_rates;
+
+			DROP TABLE exchange_rates;
+			ALTER TABLE exchange_rates_new RENAME TO exchange_rates;
+
+			CREATE INDEX IF NOT EXISTS idx_rates_date_time
+				ON exchange_rates(date_partition, collected_at);
+			CREATE INDEX IF NOT EXISTS idx_rates_collected
+				ON exchange_rates(collected_at);
+			CREATE INDEX IF NOT EXISTS idx_rates_currency_collected
+				ON exchange_rates(currency_code, collected_at);
+		`,
+	},
+	{
+		name: "hourly_rates: key by currency",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			ok, err := hasColumn(ctx, tx, "hourly_rates", "currency_code")
+			return !ok, err
+		},
+		stmts: `
+			CREATE TABLE hourly_rates_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				currency_code TEXT NOT NULL DEFAULT 'USD',
+				date_partition TEXT NOT NULL,
+				hour INTEGER NOT NULL,
+				avg_rate REAL NOT NULL,
+				min_rate REAL NOT NULL,
+				max_rate REAL NOT NULL,
+				sample_count INTEGER NOT NULL,
+				first_collected_at TEXT NOT NULL,
+				last_collected_at TEXT NOT NULL,
+				created_at TEXT NOT NULL DEFAULT (datetime('now')),
+				UNIQUE(currency_code, date_partition, hour)
+			);
+
+			INSERT INTO hourly_rates_new (
+				id, date_partition, hour, avg_rate, min_rate, max_rate, sample_count,
+				first_collected_at, last_collected_at, created_at
+			)
+			SELECT
+				id, date_partition, hour, avg_rate, min_rate, max_rate, sample_count,
+				first_collected_at, last_collected_at, created_at
+			FROM hourly_rates;
+
+			DROP TABLE hourly_rates;
+			ALTER TABLE hourly_rates_new RENAME TO hourly_rates;
+
+			CREATE INDEX IF NOT EXISTS idx_hourly_date ON hourly_rates(date_partition);
+			CREATE INDEX IF NOT EXISTS idx_hourly_date_hour ON hourly_rates(date_partition, hour);
+		`,
+	},
+	{
+		name: "daily_rates: key by currency",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			ok, err := hasColumn(ctx, tx, "daily_rates", "currency_code")
+			return !ok, err
+		},
+		stmts: `
+			CREATE TABLE daily_rates_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				currency_code TEXT NOT NULL DEFAULT 'USD',
+				date_partition TEXT NOT NULL,
+				avg_rate REAL NOT NULL,
+				min_rate REAL NOT NULL,
+				max_rate REAL NOT NULL,
+				peak_rate REAL NOT NULL,
+				peak_time TEXT NOT NULL,
+				volatility REAL NOT NULL,
+				sample_count INTEGER NOT NULL,
+				first_collected_at TEXT NOT NULL,
+				last_collected_at TEXT NOT NULL,
+				created_at TEXT NOT NULL DEFAULT (datetime('now')),
+				UNIQUE(currency_code, date_partition)
+			);
+
+			INSERT INTO daily_rates_new (
+				id, date_partition, avg_rate, min_rate, max_rate, peak_rate, peak_time,
+				volatility, sample_count, first_collected_at, last_collected_at, created_at
+			)
+			SELECT
+				id, date_partition, avg_rate, min_rate, max_rate, peak_rate, peak_time,
+				volatility, sample_count, first_collected_at, last_collected_at, created_at
+			FROM daily_rates;
+
+			DROP TABLE daily_rates;
+			ALTER TABLE daily_rates_new RENAME TO daily_rates;
+
+			CREATE INDEX IF NOT EXISTS idx_daily_date ON daily_rates(date_partition);
+		`,
+	},
+	addColumn("exchange_rates", "rtb_bid", "REAL"),
+	addColumn("exchange_rates", "rth_bid", "REAL"),
+	addColumn("exchange_rates", "rth_ofr", "REAL"),
+	addColumn("exchange_rates", "rtc_ofr", "REAL"),
+	addColumn("exchange_rates", "quoted_at", "TIMESTAMP"),
+	addColumn("exchange_rates", "source", "TEXT NOT NULL DEFAULT 'cmb'"),
+	addColumn("exchange_rates", "response_id", "INTEGER REFERENCES raw_responses(id) ON DELETE SET NULL"),
+	addIndex("idx_rates_response", "exchange_rates(response_id)"),
+	{
+		name: "exchange_rates: store prices as fixed-point integers",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			typ, err := columnType(ctx, tx, "exchange_rates", "rtc_bid")
+			return typ == "REAL", err
+		},
+		stmts: `
+			DROP VIEW IF EXISTS all_rates;
+
+			CREATE TABLE exchange_rates_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				currency_code TEXT NOT NULL DEFAULT 'USD',
+				source TEXT NOT NULL DEFAULT 'cmb',
+				rtc_bid INTEGER NOT NULL,
+				rtb_bid INTEGER,
+				rth_bid INTEGER,
+				rth_ofr INTEGER,
+				rtc_ofr INTEGER,
+				quoted_at TIMESTAMP,
+				collected_at TIMESTAMP NOT NULL,
+				response_id INTEGER REFERENCES raw_responses(id) ON DELETE SET NULL,
+				date_partition TEXT NOT NULL,
+				created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+				CHECK (rtc_bid > 0),
+				CHECK (length(currency_code) = 3)
+			);
+
+			INSERT INTO exchange_rates_new (
+				id, currency_code, source, rtc_bid, rtb_bid, rth_bid, rth_ofr, rtc_ofr,
+				quoted_at, collected_at, response_id, date_partition, created_at
+			)
+			SELECT
+				id, currency_code, source,
+				CAST(ROUND(rtc_bid * 1000000) AS INTEGER),
+				CAST(ROUND(rtb_bid * 1000000) AS INTEGER),
+				CAST(ROUND(rth_bid * 1000000) AS INTEGER),
+				CAST(ROUND(rth_ofr * 1000000) AS INTEGER),
+				CAST(ROUND(rtc_ofr * 1000000) AS INTEGER),
+				quoted_at, collected_at, response_id, date_partition, created_at
+			FROM exchange_rates;
+
+			DROP TABLE exchange_rates;
+			ALTER TABLE exchange_rates_new RENAME TO exchange_rates;
+
+			CREATE INDEX IF NOT EXISTS idx_rates_date_time
+				ON exchange_rates(date_partition, collected_at);
+			CREATE INDEX IF NOT EXISTS idx_rates_collected
+				ON exchange_rates(collected_at);
+			CREATE INDEX IF NOT EXISTS idx_rates_currency_collected
+				ON exchange_rates(currency_code, collected_at);
+			CREATE INDEX IF NOT EXISTS idx_rates_response
+				ON exchange_rates(response_id);
+		`,
+	},
+	{
+		name: "hourly_rates: store prices as fixed-point integers",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			typ, err := columnType(ctx, tx, "hourly_rates", "avg_rate")
+			return typ == "REAL", err
+		},
+		stmts: `
+			DROP VIEW IF EXISTS all_rates;
+
+			CREATE TABLE hourly_rates_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				currency_code TEXT NOT NULL DEFAULT 'USD',
+				date_partition TEXT NOT NULL,
+				hour INTEGER NOT NULL,
+				avg_rate INTEGER NOT NULL,
+				min_rate INTEGER NOT NULL,
+				max_rate INTEGER NOT NULL,
+				sample_count INTEGER NOT NULL,
+				first_collected_at TEXT NOT NULL,
+				last_collected_at TEXT NOT NULL,
+				created_at TEXT NOT NULL DEFAULT (datetime('now')),
+				UNIQUE(currency_code, date_partition, hour)
+			);
+
+			INSERT INTO hourly_rates_new (
+				id, currency_code, date_partition, hour, avg_rate, min_rate, max_rate,
+				sample_count, first_collected_at, last_collected_at, created_at
+			)
+			SELECT
+				id, currency_code, date_partition, hour,
+				CAST(ROUND(avg_rate * 1000000) AS INTEGER),
+				CAST(ROUND(min_rate * 1000000) AS INTEGER),
+				CAST(ROUND(max_rate * 1000000) AS INTEGER),
+				sample_count, first_collected_at, last_collected_at, created_at
+			FROM hourly_rates;
+
+			DROP TABLE hourly_rates;
+			ALTER TABLE hourly_rates_new RENAME TO hourly_rates;
+
+			CREATE INDEX IF NOT EXISTS idx_hourly_date ON hourly_rates(date_partition);
+			CREATE INDEX IF NOT EXISTS idx_hourly_date_hour ON hourly_rates(date_partition, hour);
+		`,
+	},
+	{
+		name: "daily_rates: store prices as fixed-point integers",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			typ, err := columnType(ctx, tx, "daily_rates", "avg_rate")
+			return typ == "REAL", err
+		},
+		stmts: `
+			DROP VIEW IF EXISTS all_rates;
+
+			CREATE TABLE daily_rates_new (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				currency_code TEXT NOT NULL DEFAULT 'USD',
+				date_partition TEXT NOT NULL,
+				avg_rate INTEGER NOT NULL,
+				min_rate INTEGER NOT NULL,
+				max_rate INTEGER NOT NULL,
+				peak_rate INTEGER NOT NULL,
+				peak_time TEXT NOT NULL,
+				volatility INTEGER NOT NULL,
+				sample_count INTEGER NOT NULL,
+				first_collected_at TEXT NOT NULL,
+				last_collected_at TEXT NOT NULL,
+				created_at TEXT NOT NULL DEFAULT (datetime('now')),
+				UNIQUE(currency_code, date_partition)
+			);
+
+			INSERT INTO daily_rates_new (
+				id, currency_code, date_partition, avg_rate, min_rate, max_rate, peak_rate,
+				peak_time, volatility, sample_count, first_collected_at, last_collected_at, created_at
+			)
+			SELECT
+				id, currency_code, date_partition,
+				CAST(ROUND(avg_rate * 1000000) AS INTEGER),
+				CAST(ROUND(min_rate * 1000000) AS INTEGER),
+				CAST(ROUND(max_rate * 1000000) AS INTEGER),
+				CAST(ROUND(peak_rate * 1000000) AS INTEGER),
+				peak_time,
+				CAST(ROUND(volatility * 1000000) AS INTEGER),
+				sample_count, first_collected_at, last_collected_at, created_at
+			FROM daily_rates;
+
+			DROP TABLE daily_rates;
+			ALTER TABLE daily_rates_new RENAME TO daily_rates;
+
+			CREATE INDEX IF NOT EXISTS idx_daily_date ON daily_rates(date_partition);
+		`,
+	},
+	{
+		name: "all_rates: report fixed-point prices in CNY",
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			var count int
+			err := tx.QueryRowContext(ctx,
+				"SELECT COUNT(*) FROM sqlite_master WHERE type = 'view' AND name = 'all_rates'").Scan(&count)
+			if err != nil {
+				return false, fmt.Errorf("reading views: %w", err)
+			}
+			return count == 0, nil
+		},
+		stmts: `
+			CREATE VIEW all_rates AS
+			SELECT
+				'raw' as source,
+				collected_at as timestamp,
+				rtc_bid / 1000000.0 as rate,
+				date_partition
+			FROM exchange_rates
+			WHERE date_partition >= date('now', '-90 days')
+
+			UNION ALL
+
+			SELECT
+				'hourly' as source,
+				datetime(date_partition || ' ' || printf('%02d', hour) || ':00:00') as timestamp,
+				avg_rate / 1000000.0 as rate,
+				date_partition
+			FROM hourly_rates
+			WHERE date_partition < date('now', '-90 days')
+			  AND date_partition >= date('now', '-365 days')
+
+			UNION ALL
+
+			SELECT
+				'daily' as source,
+				datetime(date_partition || ' 12:00:00') as timestamp,
+				avg_rate / 1000000.0 as rate,
+				date_partition
+			FROM daily_rates
+			WHERE date_partition < date('now', '-365 days')
+
+			ORDER BY timestamp DESC;
+		`,
+	},
+}
+
+// addColumn builds an upgrade that adds a column to a table that lacks it
+func addColumn(table, column, definition string) schemaUpgrade {
+	return schemaUpgrade{
+		name: fmt.Sprintf("%s: add %s", table, column),
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			ok, err := hasColumn(ctx, tx, table, column)
+			return !ok, err
+		},
+		stmts: fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition),
+	}
+}
+
+// addIndex builds an upgrade that creates an index on a column added by an upgrade
+func addIndex(name, on string) schemaUpgrade {
+	return schemaUpgrade{
+		name: fmt.Sprintf("create index %s", name),
+		needed: func(ctx context.Context, tx *sql.Tx) (bool, error) {
+			var count int
+			err := tx.QueryRowContext(ctx,
+				"SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&count)
+			if err != nil {
+				return false, fmt.Errorf("reading indexes: %w", err)
+			}
+			return count == 0, nil
+		},
+		stmts: fmt.Sprintf("CREATE INDEX %s ON %s", name, on),
+	}
+}
+
+// upgradeSchema applies any legacy schema upgrades the database still needs
+func (db *DB) upgradeSchema(ctx context.Context) error {
+	for _, u := range schemaUpgrades {
+		applied, err := db.applyUpgrade(ctx, u)
+		if err != nil {
+			return fmt.Errorf("upgrade %q: %w", u.name, err)
+		}
+		if applied {
+			db.logger.Info("applied schema upgrade", "upgrade", u.name)
+		}
+	}
+	return nil
+}
+
+// applyUpgrade runs a single upgrade in a transaction if it is still needed
+func (db *DB) applyUpgrade(ctx context.Context, u schemaUpgrade) (bool, error) {
+	tx, err := db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	needed, err := u.needed(ctx, tx)
+	if err != nil {
+		return false, fmt.Errorf("checking schema: %w", err)
+	}
+	if !needed {
+		return false, nil
+	}
+
+	// Table rebuilds drop and rename tables that views (all_rates) refer to;
+	// legacy rename semantics stop SQLite from rejecting the transient state
+	if _, err := tx.ExecContext(ctx, "PRAGMA legacy_alter_table = ON"); err != nil {
+		return false, fmt.Errorf("enabling legacy alter table: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, u.stmts); err != nil {
+		return false, fmt.Errorf("executing: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "PRAGMA legacy_alter_table = OFF"); err != nil {
+		return false, fmt.Errorf("disabling legacy alter table: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("committing: %w", err)
+	}
+
+	return true, nil
+}
+
+// tableSQL returns the CREATE statement of a table as stored by SQLite
+func tableSQL(ctx context.Context, tx *sql.Tx, table string) (string, error) {
+	var ddl string
+	err := tx.QueryRowContext(ctx,
+		"SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&ddl)
+	if err != nil {
+		return "", fmt.Errorf("reading %s schema: %w", table, err)
+	}
+	return ddl, nil
+}
+
+// columnType returns the declared type of a table column ("" if missing)
+func columnType(ctx context.Context, tx *sql.Tx, table, column string) (string, error) {
+	var typ string
+	err := tx.QueryRowContext(ctx,
+		"SELECT type FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&typ)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading %s columns: %w", table, err)
+	}
+	return strings.ToUpper(typ), nil
+}
+
+// hasColumn reports whether a table has the given column
+func hasColumn(ctx context.Context, tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("reading %s columns: %w", table, err)
+	}
+	return count > 0, nil
+}
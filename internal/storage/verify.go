@@ -0,0 +1,302 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+)
+
+// RollupValues are the stored or expected values of a rollup
+type RollupValues struct {
+	SumRate     fixed.Rate
+	AvgRate     fixed.Rate
+	MinRate     fixed.Rate
+	MaxRate     fixed.Rate
+	PeakRate    fixed.Rate // Daily only
+	PeakTime    string     // Daily only, as stored; empty when computed from hourly rollups
+	SampleCount int
+}
+
+// RollupMismatch is a rollup that differs from what its children give
+type RollupMismatch struct {
+	Tier          Tier // TierHourly or TierDaily
+	CurrencyCode  string
+	Source        string
+	DatePartition string
+	Hour          int           // Hourly only
+	Basis         Tier          // What Expected was computed from: raw quotes, or hourly rollups
+	Stored        *RollupValues // nil: the rollup is missing
+	Expected      *RollupValues // nil: the rollup has no raw quotes left and should be gone
+	Fields        []string      // Fields that differ, when both are set
+}
+
+// key returns the rollup key of a mismatch (hour 0 for a daily rollup)
+func (m RollupMismatch) key() rollupKey {
+	return rollupKey{currency: m.CurrencyCode, source: m.Source, date: m.DatePartition, hour: m.Hour}
+}
+
+// queryRollupRows reads rollups selected as currency, source, date, hour,
+// sum, avg, min, max, peak rate, peak time and sample count
+func queryRollupRows(ctx context.Context, conn *sql.DB, query string, args ...any) (map[rollupKey]RollupValues, error) {
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[rollupKey]RollupValues)
+	for rows.Next() {
+		var key rollupKey
+		var v RollupValues
+		var peakTime sql.NullString
+		err := rows.Scan(&key.currency, &key.source, &key.date, &key.hour,
+			&v.SumRate, &v.AvgRate, &v.MinRate, &v.MaxRate, &v.PeakRate, &peakTime, &v.SampleCount)
+		if err != nil {
+			return nil, err
+		}
+		v.PeakTime = peakTime.String
+		out[key] = v
+	}
+	return out, rows.Err()
+}
+
+// VerifyRollups recomputes the rollups of every date from start to end
+// (YYYY-MM-DD, inclusive) and returns those that differ from what is stored.
+// Dates with raw quotes are checked against them: each hourly and daily
+// rollup's sum, average, minimum, maximum, sample count and the daily peak
+// rate and time. Dates whose raw quotes retention has deleted have their
+// daily rollup checked against its hourly rollups; their hourly averages are
+// rounded, so the daily average may be one unit off them and its sum and
+// peak time can't be checked. Dates kept only daily have nothing to check
+func (r *Repository) VerifyRollups(ctx context.Context, start, end string) ([]RollupMismatch, error) {
+	args := []any{start, end, r.source, r.source}
+
+	expectedHours, err := queryRollupRows(ctx, r.db.conn, `
+		SELECT `+rollupKeyColumns+`,
+			SUM(rtc_bid), `+avgRate("rtc_bid")+`, MIN(rtc_bid), MAX(rtc_bid), 0, NULL, COUNT(*)
+		FROM exchange_rates
+		WHERE date_partition BETWEEN ? AND ? AND `+sourceFilter+`
+		GROUP BY `+rollupKeyColumns, args...)
+	if err != nil {
+		return nil, fmt.Errorf("recomputing hourly rollups: %w", err)
+	}
+	storedHours, err := queryRollupRows(ctx, r.db.conn, `
+		SELECT currency_code, source, date_partition, hour,
+			sum_rate, avg_rate, min_rate, max_rate, 0, NULL, sample_count
+		FROM hourly_rates
+		WHERE date_partition BETWEEN ? AND ? AND `+sourceFilter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading hourly rollups: %w", err)
+	}
+
+	expectedDays, err := queryRollupRows(ctx, r.db.conn, `
+		SELECT currency_code, source, date_partition, 0,
+			SUM(rtc_bid), `+avgRate("rtc_bid")+`, MIN(rtc_bid), MAX(rtc_bid), MAX(rtc_bid),
+			(SELECT CAST(`+observedAtExpr+` AS TEXT)
+			 FROM exchange_rates p
+			 WHERE p.currency_code = e.currency_code AND p.source = e.source AND p.date_partition = e.date_partition
+			 ORDER BY p.rtc_bid DESC, p.collected_at, p.id
+			 LIMIT 1),
+			COUNT(*)
+		FROM exchange_rates e
+		WHERE date_partition BETWEEN ? AND ? AND `+sourceFilter+`
+		GROUP BY currency_code, source, date_partition`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("recomputing daily rollups: %w", err)
+	}
+	daysFromHours, err := queryRollupRows(ctx, r.db.conn, `
+		SELECT currency_code, source, date_partition, 0,
+			SUM(sum_rate), `+rollupAvg("sum_rate", "sample_count")+`, MIN(min_rate), MAX(max_rate), MAX(max_rate),
+			NULL, SUM(sample_count)
+		FROM hourly_rates h
+		WHERE date_partition BETWEEN ? AND ? AND `+sourceFilter+`
+		  AND NOT EXISTS (
+			SELECT 1 FROM exchange_rates e
+			WHERE e.currency_code = h.currency_code AND e.source = h.source AND e.date_partition = h.date_partition
+		  )
+		GROUP BY currency_code, source, date_partition`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("recomputing daily rollups from hourly: %w", err)
+	}
+	storedDays, err := queryRollupRows(ctx, r.db.conn, `
+		SELECT currency_code, source, date_partition, 0,
+			sum_rate, avg_rate, min_rate, max_rate, peak_rate, CAST(peak_time AS TEXT), sample_count
+		FROM daily_rates
+		WHERE date_partition BETWEEN ? AND ? AND `+sourceFilter, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading daily rollups: %w", err)
+	}
+
+	var mismatches []RollupMismatch
+	add := func(tier, basis Tier, key rollupKey, stored, expected *RollupValues, fields []string) {
+		mismatches = append(mismatches, RollupMismatch{
+			Tier:          tier,
+			CurrencyCode:  key.currency,
+			Source:        key.source,
+			DatePartition: key.date,
+			Hour:          key.hour,
+			Basis:         basis,
+			Stored:        stored,
+			Expected:      expected,
+			Fields:        fields,
+		})
+	}
+
+	for key, want := range expectedHours {
+		got, ok := storedHours[key]
+		if !ok {
+			add(TierHourly, TierRaw, key, nil, &want, nil)
+		} else if fields := diffRollup(got, want, TierHourly, TierRaw); len(fields) > 0 {
+			add(TierHourly, TierRaw, key, &got, &want, fields)
+		}
+	}
+	// Hours of dates with raw quotes must all have some
+	for key, got := range storedHours {
+		day := key
+		day.hour = 0
+		if _, ok := expectedDays[day]; ok {
+			if _, ok := expectedHours[key]; !ok {
+				add(TierHourly, TierRaw, key, &got, nil, nil)
+			}
+		}
+	}
+
+	for _, expected := range []struct {
+		basis Tier
+		days  map[rollupKey]RollupValues
+	}{{TierRaw, expectedDays}, {TierHourly, daysFromHours}} {
+		for key, want := range expected.days {
+			got, ok := storedDays[key]
+			if !ok {
+				add(TierDaily, expected.basis, key, nil, &want, nil)
+			} else if fields := diffRollup(got, want, TierDaily, expected.basis); len(fields) > 0 {
+				add(TierDaily, expected.basis, key, &got, &want, fields)
+			}
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		a, b := mismatches[i], mismatches[j]
+		if a.DatePartition != b.DatePartition {
+			return a.DatePartition < b.DatePartition
+		}
+		if a.CurrencyCode != b.CurrencyCode {
+			return a.CurrencyCode < b.CurrencyCode
+		}
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Tier != b.Tier {
+			return a.Tier.finerThan(b.Tier)
+		}
+		return a.Hour < b.Hour
+	})
+	return mismatches, nil
+}
+
+// diffRollup returns the fields of a stored rollup that differ from the
+// expected one
+func diffRollup(got, want RollupValues, tier, basis Tier) []string {
+	var fields []string
+	if basis == TierRaw && got.SumRate != want.SumRate {
+		fields = append(fields, "sum")
+	}
+	avgDiff := got.AvgRate - want.AvgRate
+	if basis == TierRaw && avgDiff != 0 || avgDiff > 1 || avgDiff < -1 {
+		fields = append(fields, "avg")
+	}
+	if got.MinRate != want.MinRate {
+		fields = append(fields, "min")
+	}
+	if got.MaxRate != want.MaxRate {
+		fields = append(fields, "max")
+	}
+	if tier == TierDaily {
+		if got.PeakRate != want.PeakRate || basis == TierRaw && got.PeakTime != want.PeakTime {
+			fields = append(fields, "peak")
+		}
+	}
+	if got.SampleCount != want.SampleCount {
+		fields = append(fields, "sample_count")
+	}
+	return fields
+}
+
+// RepairRollups rebuilds the rollups of mismatches, from the basis they were
+// verified against, in one transaction: hourly rollups and the daily rollups
+// of dates with raw quotes from the quotes, the others from their hourly
+// rollups. Returns the number of hourly and daily rollups rebuilt
+func (r *Repository) RepairRollups(ctx context.Context, mismatches []RollupMismatch) (hours, days int, err error) {
+	tx, err := r.db.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	touched := rollupSet{}
+	fromHours := rollupSet{}
+	for _, m := range mismatches {
+		switch {
+		case m.Tier == TierHourly:
+			touched[m.key()] = true
+		case m.Basis == TierRaw:
+			if err := refreshDaily(ctx, tx, m.key()); err != nil {
+				return 0, 0, err
+			}
+		default:
+			fromHours[m.key()] = true
+		}
+	}
+	if err := touched.refresh(ctx, tx); err != nil {
+		return 0, 0, err
+	}
+	for key := range fromHours {
+		if err := refreshDailyFromHourly(ctx, tx, key); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("committing rollups: %w", err)
+	}
+
+	for _, m := range mismatches {
+		if m.Tier == TierHourly {
+			hours++
+		} else {
+			days++
+		}
+	}
+	return hours, days, nil
+}
+
+// CheckIntegrity runs PRAGMA integrity_check and PRAGMA foreign_key_check
+// and returns the problems each found
+func (db *DB) CheckIntegrity(ctx context.Context) (integrity, foreignKeys []string, err error) {
+	if integrity, err = integrityProblems(ctx, db.conn); err != nil {
+		return nil, nil, err
+	}
+
+	rows, err := db.conn.QueryContext(ctx, "PRAGMA foreign_key_check")
+	if err != nil {
+		return nil, nil, fmt.Errorf("checking foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table, parent string
+		var rowid sql.NullInt64
+		var fkid int
+		if err := rows.Scan(&table, &rowid, &parent, &fkid); err != nil {
+			return nil, nil, fmt.Errorf("checking foreign keys: %w", err)
+		}
+		foreignKeys = append(foreignKeys, fmt.Sprintf("%s row %d references a missing %s row", table, rowid.Int64, parent))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("checking foreign keys: %w", err)
+	}
+	return integrity, foreignKeys, nil
+}
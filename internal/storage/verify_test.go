@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/market"
+)
+
+func TestVerifyRollups(t *testing.T) {
+	ctx := context.Background()
+	db, repo := newTestDB(t)
+	first := time.Date(2025, 11, 20, 0, 0, 0, 0, market.Zone)
+
+	// Two days of quotes every 20 minutes from 09:00 to 11:40
+	for day := 0; day < 2; day++ {
+		for minute := 9 * 60; minute < 12*60; minute += 20 {
+			rate := testRate(first.AddDate(0, 0, day).Add(time.Duration(minute) * time.Minute))
+			rate.DatePartition = market.Date(rate.CollectedAt)
+			rate.RtcBid += fixed.Rate(minute)
+			if err := repo.InsertRate(ctx, rate); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// Retention deletes the first day's quotes
+	if _, err := repo.DeleteRawDataBefore(ctx, "2025-11-21"); err != nil {
+		t.Fatal(err)
+	}
+
+	verify := func() []string {
+		t.Helper()
+		mismatches, err := repo.VerifyRollups(ctx, "2025-11-01", "2025-11-30")
+		if err != nil {
+			t.Fatalf("VerifyRollups() error = %v", err)
+		}
+		var out []string
+		for _, m := range mismatches {
+			problem := strings.Join(m.Fields, ",")
+			switch {
+			case m.Stored == nil:
+				problem = "missing"
+			case m.Expected == nil:
+				problem = "stale"
+			}
+			out = append(out, fmt.Sprintf("%s %s %02d from %s: %s", m.Tier, m.DatePartition, m.Hour, m.Basis, problem))
+		}
+		return out
+	}
+
+	if got := verify(); len(got) != 0 {
+		t.Fatalf("VerifyRollups() of maintained rollups = %v, want none", got)
+	}
+
+	for _, query := range []string{
+		"UPDATE daily_rates SET sample_count = sample_count + 1 WHERE date_partition = '2025-11-21'",
+		"UPDATE hourly_rates SET avg_rate = avg_rate + 5 WHERE date_partition = '2025-11-21' AND hour = 10",
+		"DELETE FROM hourly_rates WHERE date_partition = '2025-11-21' AND hour = 11",
+		`INSERT INTO hourly_rates (currency_code, source, date_partition, hour, sum_rate, avg_rate, min_rate, max_rate,
+			sample_count, first_collected_at, last_collected_at)
+		SELECT currency_code, source, date_partition, 15, sum_rate, avg_rate, min_rate, max_rate,
+			sample_count, first_collected_at, last_collected_at
+		FROM hourly_rates WHERE date_partition = '2025-11-21' AND hour = 9`,
+		"UPDATE daily_rates SET sample_count = 99, peak_rate = peak_rate + 1 WHERE date_partition = '2025-11-20'",
+	} {
+		if _, err := db.conn.Exec(query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		"daily 2025-11-20 00 from hourly: peak,sample_count",
+		"hourly 2025-11-21 10 from raw: avg",
+		"hourly 2025-11-21 11 from raw: missing",
+		"hourly 2025-11-21 15 from raw: stale",
+		"daily 2025-11-21 00 from raw: sample_count",
+	}
+	if got := verify(); !reflect.DeepEqual(got, want) {
+		t.Errorf("VerifyRollups() = %v, want %v", got, want)
+	}
+
+	mismatches, err := repo.VerifyRollups(ctx, "2025-11-01", "2025-11-30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hours, days, err := repo.RepairRollups(ctx, mismatches)
+	if err != nil {
+		t.Fatalf("RepairRollups() error = %v", err)
+	}
+	if hours != 3 || days != 2 {
+		t.Errorf("RepairRollups() = %d hours, %d days, want 3, 2", hours, days)
+	}
+	if got := verify(); len(got) != 0 {
+		t.Errorf("VerifyRollups() after repair = %v, want none", got)
+	}
+
+	integrity, foreignKeys, err := db.CheckIntegrity(ctx)
+	if err != nil {
+		t.Fatalf("CheckIntegrity() error = %v", err)
+	}
+	if len(integrity) != 0 || len(foreignKeys) != 0 {
+		t.Errorf("CheckIntegrity() = %v, %v, want no problems", integrity, foreignKeys)
+	}
+}
@@ -2,8 +2,11 @@ package chart
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/guptarohit/asciigraph"
+	"github.com/qiushi1511/usd-buy-rate-monitor/internal/fixed"
 	"github.com/qiushi1511/usd-buy-rate-monitor/internal/storage"
 )
 
@@ -16,14 +19,15 @@ func RenderLineChart(rates []storage.ExchangeRate, width, height int) string {
 	// Extract rate values for the chart
 	data := make([]float64, len(rates))
 	for i, rate := range rates {
-		data[i] = rate.RtcBid
+		data[i] = rate.RtcBid.Float64()
 	}
 
 	// Configure chart
 	graph := asciigraph.Plot(data,
 		asciigraph.Width(width),
 		asciigraph.Height(height),
-		asciigraph.Caption(fmt.Sprintf("USD/CNY Rate (%s to %s)",
+		asciigraph.Caption(fmt.Sprintf("%s/CNY Rate (%s to %s)",
+			rates[0].CurrencyCode,
 			rates[0].CollectedAt.Format("15:04"),
 			rates[len(rates)-1].CollectedAt.Format("15:04"))),
 	)
@@ -40,7 +44,7 @@ func RenderLineChartWithLabels(rates []storage.ExchangeRate, width, height int,
 	// Extract rate values
 	data := make([]float64, len(rates))
 	for i, rate := range rates {
-		data[i] = rate.RtcBid
+		data[i] = rate.RtcBid.Float64()
 	}
 
 	// Calculate min and max for better visualization
@@ -60,8 +64,8 @@ func RenderLineChartWithLabels(rates []storage.ExchangeRate, width, height int,
 	graph := asciigraph.Plot(data,
 		asciigraph.Width(width),
 		asciigraph.Height(height),
-		asciigraph.Caption(fmt.Sprintf("USD/CNY Exchange Rate Trend (%d samples)",
-			len(rates))),
+		asciigraph.Caption(fmt.Sprintf("%s/CNY Exchange Rate Trend (%d samples)",
+			rates[0].CurrencyCode, len(rates))),
 		asciigraph.Precision(4),
 	)
 
@@ -77,7 +81,7 @@ func RenderDailyAverageChart(stats []*storage.DailyStats, width, height int) str
 	// Extract average rates
 	data := make([]float64, len(stats))
 	for i, stat := range stats {
-		data[i] = stat.AvgRate
+		data[i] = stat.AvgRate.Float64()
 	}
 
 	// Create caption with date range
@@ -104,7 +108,7 @@ func RenderVolatilityChart(stats []*storage.DailyStats, width, height int) strin
 	// Extract volatility (range) values
 	data := make([]float64, len(stats))
 	for i, stat := range stats {
-		data[i] = stat.MaxRate - stat.MinRate
+		data[i] = (stat.MaxRate - stat.MinRate).Float64()
 	}
 
 	caption := fmt.Sprintf("Daily Volatility (%s to %s)",
@@ -136,7 +140,7 @@ func RenderMiniChart(rates []storage.ExchangeRate) string {
 
 	data := make([]float64, len(rates))
 	for i, rate := range rates {
-		data[i] = rate.RtcBid
+		data[i] = rate.RtcBid.Float64()
 	}
 
 	// Mini chart with reduced dimensions
@@ -171,20 +175,82 @@ func FormatTimeLabels(rates []storage.ExchangeRate, count int) []string {
 	return labels
 }
 
-// PrintChartWithStats prints a chart along with statistical summary
-func PrintChartWithStats(rates []storage.ExchangeRate, width, height int) {
+// Marker labels a data point of a chart, such as one an annotation is pinned to
+type Marker struct {
+	Index int    // Data point marked
+	Label string // Shown under the point; its first character is used
+}
+
+// AddMarkers adds a line under the plot of a chart, above its caption, with
+// each marker's label under the column its data point was plotted at.
+// points is the number of data points the chart was given and width the
+// width it was rendered at (0: a column per point). Markers are clamped to
+// the data, and where several share a column the first is shown
+func AddMarkers(graph string, points, width int, markers []Marker) string {
+	if points == 0 || len(markers) == 0 {
+		return graph
+	}
+
+	// Plot rows have the Y axis at the same column; the first point is
+	// plotted on it and the rest a column apart
+	lines := strings.Split(graph, "\n")
+	axis, last := -1, -1
+	for i, line := range lines {
+		runes := []rune(line)
+		if axis < 0 {
+			for j, r := range runes {
+				if r == '┤' || r == '┼' {
+					axis = j
+					break
+				}
+			}
+		}
+		if axis >= 0 && axis < len(runes) && (runes[axis] == '┤' || runes[axis] == '┼') {
+			last = i
+		}
+	}
+	if last < 0 {
+		return graph
+	}
+
+	columns := points
+	if width > 0 {
+		columns = width
+	}
+	row := []rune(strings.Repeat(" ", axis+columns))
+	for _, m := range markers {
+		label := []rune(m.Label)
+		if len(label) == 0 {
+			continue
+		}
+		x := min(max(m.Index, 0), points-1)
+		if width > 0 && points > 1 {
+			x = int(math.Round(float64(x) * float64(width-1) / float64(points-1)))
+		}
+		if row[axis+x] == ' ' {
+			row[axis+x] = label[0]
+		}
+	}
+
+	marked := append(lines[:last+1:last+1], strings.TrimRight(string(row), " "))
+	return strings.Join(append(marked, lines[last+1:]...), "\n")
+}
+
+// PrintChartWithStats prints a chart along with statistical summary, and
+// the labels of any markers under their points
+func PrintChartWithStats(rates []storage.ExchangeRate, width, height int, markers ...Marker) {
 	if len(rates) == 0 {
 		fmt.Println("No data available")
 		return
 	}
 
 	// Calculate statistics
-	var sum, min, max float64
-	min = rates[0].RtcBid
-	max = rates[0].RtcBid
+	values := make([]fixed.Rate, len(rates))
+	min := rates[0].RtcBid
+	max := rates[0].RtcBid
 
-	for _, rate := range rates {
-		sum += rate.RtcBid
+	for i, rate := range rates {
+		values[i] = rate.RtcBid
 		if rate.RtcBid < min {
 			min = rate.RtcBid
 		}
@@ -192,15 +258,15 @@ func PrintChartWithStats(rates []storage.ExchangeRate, width, height int) {
 			max = rate.RtcBid
 		}
 	}
-	avg := sum / float64(len(rates))
+	avg := fixed.Mean(values)
 
 	// Print chart
 	fmt.Println()
-	fmt.Println(RenderLineChart(rates, width, height))
+	fmt.Println(AddMarkers(RenderLineChart(rates, width, height), len(rates), width, markers))
 	fmt.Println()
 
 	// Print statistics below chart
-	fmt.Printf("Statistics: Min=%.4f  Max=%.4f  Avg=%.4f  Range=%.4f  Samples=%d\n",
+	fmt.Printf("Statistics: Min=%s  Max=%s  Avg=%s  Range=%s  Samples=%d\n",
 		min, max, avg, max-min, len(rates))
 	fmt.Println()
 }
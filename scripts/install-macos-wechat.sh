@@ -67,8 +67,6 @@ cat > "/tmp/$PLIST_FILE" <<EOF
         <string>daemon</string>
         <string>--db</string>
         <string>$PROJECT_DIR/data/rates.db</string>
-        <string>--migrations</string>
-        <string>$PROJECT_DIR/migrations</string>
         <string>--wechat-webhook</string>
         <string>$WECHAT_WEBHOOK</string>
 EOF